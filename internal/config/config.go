@@ -7,6 +7,8 @@ package config
 
 import (
 	"log"
+	"slices"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
@@ -18,21 +20,26 @@ var (
 )
 
 type GlobalConfigs struct {
-	Server  *ServerConfig           `json:"server" toml:"server"`
-	Auth    *AuthConfig             `json:"auth" toml:"auth"`
-	Cors    *CorsConfig             `json:"cors" toml:"cors"`
-	Image   *ImageConfig            `json:"image" toml:"image"`
-	Statsd  *StatsdConfig           `json:"statsd" toml:"statsd"`
-	Crawler *CrawlerConfig          `json:"crawler" toml:"crawler"`
-	Robot   *RobotConfig            `json:"robot" toml:"robot"`
-	Logger  *LoggerConfig           `json:"logger" toml:"logger"`
-	Mail    *MailConfig             `json:"mail" toml:"mail"`
-	SSH     *SSHConfig              `json:"ssh" toml:"ssh"`
-	DB      map[string]*DBConfig    `json:"db" toml:"db"`
-	Redis   map[string]*RedisConfig `json:"redis" toml:"redis"`
-	Mongo   map[string]*MongoConfig `json:"mongodb" toml:"mongodb"`
-	S3      map[string]*S3Config    `json:"S3" toml:"S3"`
-	HttpApi map[string]*HttpApi     `json:"api" toml:"httpapi"`
+	Server     *ServerConfig           `json:"server" toml:"server"`
+	Auth       *AuthConfig             `json:"auth" toml:"auth"`
+	Cors       *CorsConfig             `json:"cors" toml:"cors"`
+	Image      *ImageConfig            `json:"image" toml:"image"`
+	Statsd     *StatsdConfig           `json:"statsd" toml:"statsd"`
+	Crawler    *CrawlerConfig          `json:"crawler" toml:"crawler"`
+	Robot      *RobotConfig            `json:"robot" toml:"robot"`
+	Logger     *LoggerConfig           `json:"logger" toml:"logger"`
+	Mail       *MailConfig             `json:"mail" toml:"mail"`
+	SSH        *SSHConfig              `json:"ssh" toml:"ssh"`
+	DB         map[string]*DBConfig    `json:"db" toml:"db"`
+	MysqlAdmin *MysqlAdminConfig       `json:"mysql_admin" toml:"mysql_admin"`
+	PgSQLAdmin *PgSQLAdminConfig       `json:"pgsql_admin" toml:"pgsql_admin"`
+	Redis      map[string]*RedisConfig `json:"redis" toml:"redis"`
+	RedisAdmin *RedisAdminConfig       `json:"redis_admin" toml:"redis_admin"`
+	Mongo      map[string]*MongoConfig `json:"mongodb" toml:"mongodb"`
+	MongoAdmin *MongoAdminConfig       `json:"mongodb_admin" toml:"mongodb_admin"`
+	S3         map[string]*S3Config    `json:"S3" toml:"S3"`
+	S3Admin    *S3AdminConfig          `json:"s3_admin" toml:"s3_admin"`
+	HttpApi    map[string]*HttpApi     `json:"api" toml:"httpapi"`
 }
 
 // server配置参数
@@ -72,9 +79,14 @@ type CorsConfig struct {
 
 // 图片服务器
 type ImageConfig struct {
-	Host      string `json:"host" toml:"host"`               // 图片服务器域名     #如果要修改上传路径的话，请使用绝对路径，不要使用相对路径 并在Nginx配置中，将修改后的目录配置为静态目录
-	Path      string `json:"path" toml:"path"`               // 图片上传的目录, 根据业务再细分, 比如头像相关放到: /images/avatar/
-	MaxSizeMB uint16 `json:"max_size_mb" toml:"max_size_mb"` // 上传的图片最大允许的大小，单位MB
+	Host          string `json:"host" toml:"host"`                     // 访问图片的域名, 为空时使用当前服务的地址, 不为空时需要将 /files/ 路径代理到本服务
+	Path          string `json:"path" toml:"path"`                     // 本地存储时图片上传的目录, 请使用绝对路径, 根据业务再细分, 比如头像相关放到: /images/avatar/
+	MaxSizeMB     uint16 `json:"max_size_mb" toml:"max_size_mb"`       // 上传的图片最大允许的大小，单位MB
+	Storage       string `json:"storage" toml:"storage"`               // 图片存储方式: local 或 s3, 默认 local
+	S3Instance    string `json:"s3_instance" toml:"s3_instance"`       // s3 存储时使用的 S3 实例
+	S3Bucket      string `json:"s3_bucket" toml:"s3_bucket"`           // s3 存储时使用的 bucket
+	S3Presign     bool   `json:"s3_presign" toml:"s3_presign"`         // s3 存储时访问图片重定向到预签名 URL, 否则由本服务代理读取
+	DefaultAvatar string `json:"default_avatar" toml:"default_avatar"` // 注册用户的默认头像, 为空时由前端显示默认头像
 }
 
 // 网络守护进程，用于收集和聚合应用程序的统计数据。它通常与应用程序一起使用，用于收集各种指标，例如请求响应时间、错误率、吞吐量等
@@ -138,6 +150,17 @@ type DBConfig struct {
 	TargetSessionAttrs string `json:"target_session_attrs" toml:"target_session_attrs"`
 }
 
+// mysql 管理功能配置
+type MysqlAdminConfig struct {
+	KillProtectedUsers []string `json:"kill_protected_users" toml:"kill_protected_users"` // 不允许通过接口 kill 的用户, 如复制和监控用户
+	ReplProbeHosts     []string `json:"repl_probe_hosts" toml:"repl_probe_hosts"`         // 发现复制拓扑时允许连接的地址, 支持 ip, 主机名和网段; 配置中的 mysql 实例始终允许
+}
+
+// postgres 管理功能配置
+type PgSQLAdminConfig struct {
+	TerminateProtectedUsers []string `json:"terminate_protected_users" toml:"terminate_protected_users"` // 不允许通过接口 cancel/terminate 的用户, 如复制和监控用户
+}
+
 // redis 配置参数
 type RedisConfig struct {
 	URI          string `json:"URI" toml:"URI"`
@@ -151,8 +174,34 @@ type RedisConfig struct {
 	MaxOpenConns int    `json:"max_open_conns" toml:"max_open_conns"`
 	MaxIdleTime  int    `json:"max_idle_time" toml:"max_idle_time"`
 	Timeout      int    `json:"timeout" toml:"timeout"`
+	ReadOnly     bool   `json:"read_only" toml:"read_only"` // 只读实例, 禁止通过管理接口修改或删除 key
+	Env          string `json:"env" toml:"env"`             // 实例所属环境, 用于选择参数基线, 为空时使用 server.env
+
+	Mode             string   `json:"mode" toml:"mode"`                           // 部署模式: standalone(默认), sentinel, cluster
+	MasterName       string   `json:"master_name" toml:"master_name"`             // sentinel 模式下的主库名称
+	SentinelAddrs    []string `json:"sentinel_addrs" toml:"sentinel_addrs"`       // sentinel 模式下的哨兵地址列表, host:port
+	SentinelUsername string   `json:"sentinel_username" toml:"sentinel_username"` // 哨兵的认证用户, 为空时不认证
+	SentinelPassword string   `json:"sentinel_password" toml:"sentinel_password"` // 哨兵的认证密码
+	Addrs            []string `json:"addrs" toml:"addrs"`                         // cluster 模式下的种子节点列表, host:port
+}
+
+// redis 管理功能配置
+type RedisAdminConfig struct {
+	ConfigSetAllow []string                      `json:"config_set_allow" toml:"config_set_allow"` // 允许通过接口 config set 的参数
+	Baseline       map[string]map[string]string  `json:"baseline" toml:"baseline"`                 // 各环境的参数基线: 环境 -> 参数名 -> 期望值
+	Diagnose       map[string]*RedisDiagnoseRule `json:"diagnose" toml:"diagnose"`                 // 诊断规则: 规则名 -> 规则参数, 覆盖默认规则
 }
 
+// redis 诊断规则参数, 未配置的字段使用默认值
+type RedisDiagnoseRule struct {
+	Disabled  bool     `json:"disabled" toml:"disabled"`   // 禁用该规则
+	Severity  string   `json:"severity" toml:"severity"`   // 严重程度: info, warning, critical
+	Threshold *float64 `json:"threshold" toml:"threshold"` // 阈值, 含义由规则决定, 可以配置为 0
+}
+
+// RedisDiagnoseSeverities 诊断规则可以配置的严重程度
+var RedisDiagnoseSeverities = []string{"info", "warning", "critical"}
+
 // MongoConfig mongodb 配置参数
 type MongoConfig struct {
 	URI                      string `json:"URI" toml:"URI"`
@@ -167,8 +216,23 @@ type MongoConfig struct {
 	ServerSelectionTimeoutMS int64  `json:"serverSelectionTimeoutMS" toml:"serverSelectionTimeoutMS"`
 	AuthDB                   string `json:"auth_db" toml:"auth_db"`
 	ReplSet                  string `json:"replset" toml:"replset"`
-	Connect                  string `json:"connect" toml:"connect"`
+	Connect                  string `json:"connect" toml:"connect"` // 兼容旧配置, direct 等同于 direct_connection = true
 	ReadPreference           string `json:"ReadPreference" toml:"ReadPreference"`
+	Env                      string `json:"env" toml:"env"`                             // 实例所属环境, 用于选择参数基线, 为空时使用 server.env
+	AuthMechanism            string `json:"auth_mechanism" toml:"auth_mechanism"`       // 认证方式: SCRAM-SHA-1, SCRAM-SHA-256, MONGODB-X509, 为空时与服务端协商
+	DirectConnection         bool   `json:"direct_connection" toml:"direct_connection"` // 直连单个节点, 不发现副本集的其他成员
+	TLS                      bool   `json:"tls" toml:"tls"`
+	TLSCAFile                string `json:"tls_ca_file" toml:"tls_ca_file"`     // CA 证书, 为空时使用系统 CA
+	TLSCertFile              string `json:"tls_cert_file" toml:"tls_cert_file"` // 客户端证书, MONGODB-X509 认证时必须配置
+	TLSKeyFile               string `json:"tls_key_file" toml:"tls_key_file"`   // 客户端私钥, 为空时从 tls_cert_file 中读取
+	TLSInsecure              bool   `json:"tls_insecure" toml:"tls_insecure"`   // 不校验服务端证书, 只用于测试环境
+}
+
+// mongodb 管理功能配置
+type MongoAdminConfig struct {
+	ProfileHarvest      string                       `json:"profile_harvest" toml:"profile_harvest"`             // 采集慢查询(system.profile)的 cron 表达式, 如: @every 1m, 为空时不采集
+	ProfileHarvestLimit int64                        `json:"profile_harvest_limit" toml:"profile_harvest_limit"` // 每个库每次最多采集的条数
+	Baseline            map[string]map[string]string `json:"baseline" toml:"baseline"`                           // 各环境的参数基线: 环境 -> 参数名 -> 期望值, 参数名见 /mongodb/param/catalogue 接口
 }
 
 // S3Config s3 配置参数
@@ -177,6 +241,14 @@ type S3Config struct {
 	AccessKey  string `json:"AccessKey" toml:"AccessKey"`
 	SecretKey  string `json:"SecretKey" toml:"SecretKey"`
 	DisableSSL bool   `json:"DisableSSL" toml:"DisableSSL"`
+	StsRoleArn string `json:"StsRoleArn" toml:"StsRoleArn"` // 签发临时凭证时 AssumeRole 的角色, 为空时不允许签发
+}
+
+// s3 管理功能配置, 为 0 时使用默认值
+type S3AdminConfig struct {
+	PresignMaxExpireSec int64 `json:"presign_max_expire_sec" toml:"presign_max_expire_sec"` // 预签名 URL 的最长有效期, 默认 3600
+	PresignMaxSizeMB    int64 `json:"presign_max_size_mb" toml:"presign_max_size_mb"`       // 预签名上传的最大文件, 默认 5120
+	StsMaxDurationSec   int64 `json:"sts_max_duration_sec" toml:"sts_max_duration_sec"`     // 临时凭证的最长有效期, 默认 3600, 最短为 900
 }
 
 // api 配置
@@ -199,4 +271,33 @@ func LoadConfig() {
 	if GlobalConfig.Auth.TokenName == "" {
 		GlobalConfig.Auth.TokenName = TokenName
 	}
+
+	if GlobalConfig.RedisAdmin == nil {
+		GlobalConfig.RedisAdmin = &RedisAdminConfig{}
+	}
+	for name, rule := range GlobalConfig.RedisAdmin.Diagnose {
+		if rule != nil && rule.Severity != "" && !slices.Contains(RedisDiagnoseSeverities, rule.Severity) {
+			log.Fatalf("redis_admin.diagnose.%s 的 severity: %s 无效, 可选值: %s\n", name, rule.Severity, strings.Join(RedisDiagnoseSeverities, ", "))
+		}
+	}
+
+	if GlobalConfig.MysqlAdmin == nil {
+		GlobalConfig.MysqlAdmin = &MysqlAdminConfig{}
+	}
+
+	if GlobalConfig.PgSQLAdmin == nil {
+		GlobalConfig.PgSQLAdmin = &PgSQLAdminConfig{}
+	}
+
+	if GlobalConfig.MongoAdmin == nil {
+		GlobalConfig.MongoAdmin = &MongoAdminConfig{}
+	}
+
+	if GlobalConfig.S3Admin == nil {
+		GlobalConfig.S3Admin = &S3AdminConfig{}
+	}
+
+	if GlobalConfig.Image.Storage == "" {
+		GlobalConfig.Image.Storage = ImageStorageLocal
+	}
 }
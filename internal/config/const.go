@@ -33,3 +33,9 @@ const (
 
 	GinCtxUserKey = "MYADMIN-USER"
 )
+
+// 图片存储方式
+const (
+	ImageStorageLocal = "local"
+	ImageStorageS3    = "s3"
+)
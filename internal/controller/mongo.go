@@ -0,0 +1,502 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-30 15:02:37
+ */
+
+package controller
+
+import (
+	"errors"
+	"io"
+	"myadmin/internal/dto"
+	"myadmin/internal/service/mongoservice"
+	"myadmin/internal/utils/ginutils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Mongo struct {
+}
+
+func (m Mongo) ReplSetConfig(c *gin.Context) {
+	var req dto.MongoInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ReplSetConfig(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ReplSetAdd(c *gin.Context) {
+	var req dto.MongoRSAddReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ReplSetAdd(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ReplSetRemove(c *gin.Context) {
+	var req dto.MongoRSRemoveReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ReplSetRemove(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ReplSetUpdateMember(c *gin.Context) {
+	var req dto.MongoRSMemberUpdateReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ReplSetUpdateMember(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ReplSetStepDown(c *gin.Context) {
+	var req dto.MongoRSStepDownReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := mongoservice.NewMongoService().ReplSetStepDown(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "主节点降级成功")
+}
+
+func (m Mongo) ReplSetStatus(c *gin.Context) {
+	var req dto.MongoInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ReplSetStatus(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) Hello(c *gin.Context) {
+	var req dto.MongoInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().Hello(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ReplSetHealth(c *gin.Context) {
+	var req dto.MongoReplSetHealthReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ReplSetHealth(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ReplSetHealthAll(c *gin.Context) {
+	var req dto.MongoReplSetHealthAllReq
+	// 参数都是可选的, 允许请求体为空
+	if err := c.ShouldBind(&req); err != nil && !errors.Is(err, io.EOF) {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, mongoservice.NewMongoService().ReplSetHealthAll(req.MaxLagSeconds))
+}
+
+func (m Mongo) InconsistentIndex(c *gin.Context) {
+	var req dto.MongoInconsistentIndexReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().InconsistentIndex(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) CurrentOp(c *gin.Context) {
+	var req dto.MongoCurrentOpReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().CurrentOp(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) KillOp(c *gin.Context) {
+	var req dto.MongoKillOpReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().KillOp(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) KillMatching(c *gin.Context) {
+	var req dto.MongoKillMatchingReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().KillMatching(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ProfileEnable(c *gin.Context) {
+	var req dto.MongoProfileReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ProfileEnable(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ProfileDisable(c *gin.Context) {
+	var req dto.MongoProfileReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ProfileDisable(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ProfileStatus(c *gin.Context) {
+	var req dto.MongoProfileStatusReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ProfileStatus(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) SlowQueries(c *gin.Context) {
+	var req dto.MongoSlowQueryReq
+	if err := c.ShouldBind(&req); err != nil && !errors.Is(err, io.EOF) {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, mongoservice.NewMongoService().SlowQueries(req))
+}
+
+func (m Mongo) ProfileHarvest(c *gin.Context) {
+	ginutils.RespData(c, mongoservice.NewMongoService().ProfileHarvest())
+}
+
+func (m Mongo) Provision(c *gin.Context) {
+	var req dto.MongoProvisionReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().Provision(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ParamCatalogue(c *gin.Context) {
+	ginutils.RespData(c, mongoservice.NewMongoService().ParamCatalogue())
+}
+
+func (m Mongo) ParamGet(c *gin.Context) {
+	var req dto.MongoParamGetReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ParamGet(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ParamSet(c *gin.Context) {
+	var req dto.MongoParamSetReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ParamSet(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ParamDiff(c *gin.Context) {
+	var req dto.MongoInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ParamDiff(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) ParamDiffAll(c *gin.Context) {
+	ginutils.RespData(c, mongoservice.NewMongoService().ParamDiffAll())
+}
+
+func (m Mongo) ShardOverview(c *gin.Context) {
+	var req dto.MongoInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().ShardOverview(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) Chunks(c *gin.Context) {
+	var req dto.MongoChunkReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().Chunks(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) BalancerStart(c *gin.Context) {
+	var req dto.MongoInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().BalancerStart(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) BalancerStop(c *gin.Context) {
+	var req dto.MongoInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().BalancerStop(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) BalancerWindow(c *gin.Context) {
+	var req dto.MongoBalancerWindowReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().BalancerWindow(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mongo) CollectionStats(c *gin.Context) {
+	var req dto.MongoCollectionStatsReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mongoservice.NewMongoService().CollectionStats(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
@@ -0,0 +1,164 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-29 18:32:51
+ */
+
+package controller
+
+import (
+	"myadmin/internal/dto"
+	"myadmin/internal/service/mysqlservice"
+	"myadmin/internal/utils/ginutils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Mysql struct {
+}
+
+func (m Mysql) Processlist(c *gin.Context) {
+	var req dto.MysqlProcesslistReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mysqlservice.NewMysqlService().Processlist(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mysql) Variables(c *gin.Context) {
+	var req dto.MysqlVariablesReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mysqlservice.NewMysqlService().Variables(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mysql) Status(c *gin.Context) {
+	var req dto.MysqlVariablesReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mysqlservice.NewMysqlService().Status(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mysql) StatusRates(c *gin.Context) {
+	var req dto.MysqlStatusRatesReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mysqlservice.NewMysqlService().StatusRates(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mysql) InnodbStatus(c *gin.Context) {
+	var req dto.MysqlInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mysqlservice.NewMysqlService().InnodbStatus(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mysql) ReplNode(c *gin.Context) {
+	var req dto.MysqlInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mysqlservice.NewMysqlService().ReplNode(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mysql) ReplTopology(c *gin.Context) {
+	var req dto.MysqlReplTopologyReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mysqlservice.NewMysqlService().ReplTopology(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mysql) Kill(c *gin.Context) {
+	var req dto.MysqlKillReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mysqlservice.NewMysqlService().Kill(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (m Mysql) KillMatching(c *gin.Context) {
+	var req dto.MysqlKillMatchingReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := mysqlservice.NewMysqlService().KillMatching(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
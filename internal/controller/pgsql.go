@@ -0,0 +1,113 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-06 15:02:14
+ */
+
+package controller
+
+import (
+	"myadmin/internal/dto"
+	"myadmin/internal/service/pgsqlservice"
+	"myadmin/internal/utils/ginutils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PgSQL struct {
+}
+
+func (p PgSQL) Activity(c *gin.Context) {
+	var req dto.PgSQLActivityReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := pgsqlservice.NewPgSQLService().Activity(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (p PgSQL) Locks(c *gin.Context) {
+	var req dto.PgSQLLocksReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := pgsqlservice.NewPgSQLService().Locks(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (p PgSQL) BlockingChains(c *gin.Context) {
+	var req dto.PgSQLInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := pgsqlservice.NewPgSQLService().BlockingChains(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (p PgSQL) LongRunning(c *gin.Context) {
+	var req dto.PgSQLLongRunningReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := pgsqlservice.NewPgSQLService().LongRunning(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (p PgSQL) Cancel(c *gin.Context) {
+	var req dto.PgSQLCancelReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := pgsqlservice.NewPgSQLService().Cancel(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (p PgSQL) Health(c *gin.Context) {
+	var req dto.PgSQLHealthReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := pgsqlservice.NewPgSQLService().Health(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
@@ -0,0 +1,362 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 16:10:47
+ */
+
+package controller
+
+import (
+	"myadmin/internal/dto"
+	"myadmin/internal/service/redisservice"
+	"myadmin/internal/utils/ginutils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Redis struct {
+}
+
+func (r Redis) ScanKeys(c *gin.Context) {
+	var req dto.RedisKeyScanReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := req.Validator(); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ScanKeys(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) KeyMeta(c *gin.Context) {
+	var req dto.RedisKeyReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().KeyMeta(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) KeyValue(c *gin.Context) {
+	var req dto.RedisKeyValueReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().KeyValue(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) KeyExpire(c *gin.Context) {
+	var req dto.RedisKeyExpireReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := redisservice.NewRedisService().KeyExpire(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "设置过期时间成功")
+}
+
+func (r Redis) KeyDelete(c *gin.Context) {
+	var req dto.RedisKeyDeleteReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().KeyDelete(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) KeyRename(c *gin.Context) {
+	var req dto.RedisKeyRenameReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := redisservice.NewRedisService().KeyRename(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "重命名成功")
+}
+
+func (r Redis) ClusterTopology(c *gin.Context) {
+	var req dto.RedisInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ClusterTopology(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) ClusterRebalancePlan(c *gin.Context) {
+	var req dto.RedisClusterRebalanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ClusterRebalancePlan(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) ClusterMeet(c *gin.Context) {
+	var req dto.RedisClusterMeetReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ClusterMeet(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) ClusterAddSlots(c *gin.Context) {
+	var req dto.RedisClusterAddSlotsReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ClusterAddSlots(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) ClusterRebalance(c *gin.Context) {
+	var req dto.RedisClusterRebalanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ClusterRebalance(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) ClusterMigrate(c *gin.Context) {
+	var req dto.RedisClusterMigrateReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ClusterMigrate(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) ClusterFailover(c *gin.Context) {
+	var req dto.RedisClusterFailoverReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ClusterFailover(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) ClusterForget(c *gin.Context) {
+	var req dto.RedisClusterForgetReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ClusterForget(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) ConfigGet(c *gin.Context) {
+	var req dto.RedisInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ConfigGet(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) ConfigDiff(c *gin.Context) {
+	var req dto.RedisInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ConfigDiff(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) ConfigDiffAll(c *gin.Context) {
+	ginutils.RespData(c, redisservice.NewRedisService().ConfigDiffAll())
+}
+
+func (r Redis) ConfigSet(c *gin.Context) {
+	var req dto.RedisConfigSetReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().ConfigSet(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) Diagnose(c *gin.Context) {
+	var req dto.RedisInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := redisservice.NewRedisService().Diagnose(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (r Redis) DiagnoseAll(c *gin.Context) {
+	ginutils.RespData(c, redisservice.NewRedisService().DiagnoseAll())
+}
+
+func (r Redis) DiagnoseRules(c *gin.Context) {
+	ginutils.RespData(c, redisservice.NewRedisService().DiagnoseRules())
+}
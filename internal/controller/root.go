@@ -0,0 +1,28 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 16:02:33
+ */
+
+package controller
+
+import (
+	"errors"
+	"myadmin/internal/config"
+	"myadmin/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser 获取 JWTAuth.LoginRequired 中间件写入上下文的当前登录用户
+func currentUser(c *gin.Context) (model.User, error) {
+	v, ok := c.Get(config.GinCtxUserKey)
+	if !ok {
+		return model.User{}, errors.New("未找到用户信息")
+	}
+
+	user, ok := v.(model.User)
+	if !ok {
+		return model.User{}, errors.New("用户解析失败")
+	}
+	return user, nil
+}
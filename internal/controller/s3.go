@@ -0,0 +1,602 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-23 11:05:42
+ */
+
+package controller
+
+import (
+	"fmt"
+	"myadmin/internal/dto"
+	"myadmin/internal/service/s3service"
+	"myadmin/internal/utils/ginutils"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gin-gonic/gin"
+)
+
+type S3 struct {
+}
+
+func (s S3) Buckets(c *gin.Context) {
+	var req dto.S3InstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().Buckets(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) Objects(c *gin.Context) {
+	var req dto.S3ObjectListReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().Objects(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+// Upload 不能使用 c.FormFile, 超过 MaxMultipartMemory 的文件会写入临时文件, 这里直接读取请求体转发给 s3
+func (s S3) Upload(c *gin.Context) {
+	var req dto.S3UploadReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().Upload(user, req, reader)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) Download(c *gin.Context) {
+	var req dto.S3DownloadReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	out, err := s3service.NewS3Service().Download(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	defer out.Body.Close()
+
+	contentType := aws.StringValue(out.ContentType)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	headers := map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(path.Base(req.Key))),
+	}
+	c.DataFromReader(http.StatusOK, aws.Int64Value(out.ContentLength), contentType, out.Body, headers)
+}
+
+func (s S3) Delete(c *gin.Context) {
+	var req dto.S3DeleteReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().Delete(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) Versions(c *gin.Context) {
+	var req dto.S3VersionListReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().Versions(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) RestoreVersion(c *gin.Context) {
+	var req dto.S3VersionReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().RestoreVersion(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) DeleteVersion(c *gin.Context) {
+	var req dto.S3VersionReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := s3service.NewS3Service().DeleteVersion(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "删除成功")
+}
+
+func (s S3) Policy(c *gin.Context) {
+	var req dto.S3BucketReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().Policy(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) PolicyPut(c *gin.Context) {
+	var req dto.S3PolicyPutReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().PolicyPut(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) Lifecycle(c *gin.Context) {
+	var req dto.S3BucketReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().Lifecycle(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) CORS(c *gin.Context) {
+	var req dto.S3BucketReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().CORS(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) Versioning(c *gin.Context) {
+	var req dto.S3BucketReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().Versioning(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) VersioningPut(c *gin.Context) {
+	var req dto.S3VersioningReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().VersioningPut(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) Usage(c *gin.Context) {
+	var req dto.S3BucketReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().Usage(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) PolicyDelete(c *gin.Context) {
+	var req dto.S3BucketReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := s3service.NewS3Service().PolicyDelete(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "删除成功")
+}
+
+func (s S3) LifecyclePut(c *gin.Context) {
+	var req dto.S3LifecyclePutReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := s3service.NewS3Service().LifecyclePut(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "设置成功")
+}
+
+func (s S3) LifecycleDelete(c *gin.Context) {
+	var req dto.S3BucketReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := s3service.NewS3Service().LifecycleDelete(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "删除成功")
+}
+
+func (s S3) CORSPut(c *gin.Context) {
+	var req dto.S3CORSPutReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := s3service.NewS3Service().CORSPut(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "设置成功")
+}
+
+func (s S3) CORSDelete(c *gin.Context) {
+	var req dto.S3BucketReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := s3service.NewS3Service().CORSDelete(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "删除成功")
+}
+
+func (s S3) PresignPut(c *gin.Context) {
+	var req dto.S3PresignPutReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().PresignPut(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) PresignPost(c *gin.Context) {
+	var req dto.S3PresignPostReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().PresignPost(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) MultipartCreate(c *gin.Context) {
+	var req dto.S3MultipartCreateReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().MultipartCreate(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) Sts(c *gin.Context) {
+	var req dto.S3StsReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().Sts(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) GrantList(c *gin.Context) {
+	var req dto.S3GrantListReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().GrantList(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) GrantCreate(c *gin.Context) {
+	var req dto.S3GrantCreateReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := s3service.NewS3Service().GrantCreate(user, req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s S3) MultipartComplete(c *gin.Context) {
+	var req dto.S3MultipartCompleteReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := s3service.NewS3Service().MultipartComplete(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "上传完成")
+}
+
+func (s S3) MultipartAbort(c *gin.Context) {
+	var req dto.S3MultipartAbortReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := s3service.NewS3Service().MultipartAbort(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "已取消上传")
+}
+
+func (s S3) GrantDelete(c *gin.Context) {
+	var req dto.S3GrantDeleteReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if err := s3service.NewS3Service().GrantDelete(user, req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespOK(c, "删除成功")
+}
@@ -0,0 +1,92 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-11 10:58:02
+ */
+
+package controller
+
+import (
+	"myadmin/internal/dto"
+	"myadmin/internal/service/schemaservice"
+	"myadmin/internal/utils/ginutils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Schema struct {
+}
+
+func (s Schema) Databases(c *gin.Context) {
+	var req dto.SchemaInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := schemaservice.NewSchemaService().Databases(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s Schema) Schemas(c *gin.Context) {
+	var req dto.SchemaInstanceReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := schemaservice.NewSchemaService().Schemas(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s Schema) Tables(c *gin.Context) {
+	var req dto.SchemaTablesReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := schemaservice.NewSchemaService().Tables(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s Schema) Table(c *gin.Context) {
+	var req dto.SchemaTableReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := schemaservice.NewSchemaService().Table(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
+
+func (s Schema) Diff(c *gin.Context) {
+	var req dto.SchemaDiffReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	resp, err := schemaservice.NewSchemaService().Diff(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, resp)
+}
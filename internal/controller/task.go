@@ -0,0 +1,57 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-09 17:45:03
+ */
+
+package controller
+
+import (
+	"myadmin/internal/config"
+	"myadmin/internal/dto"
+	"myadmin/internal/task"
+	"myadmin/internal/utils/ginutils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Task struct {
+}
+
+func (t Task) Info(c *gin.Context) {
+	var req dto.TaskReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	tk, ok := task.Tasks.Get(req.ID)
+	if !ok {
+		ginutils.RespError(c, "任务不存在或已过期")
+		return
+	}
+	ginutils.RespData(c, tk.Snapshot())
+}
+
+func (t Task) List(c *gin.Context) {
+	var req dto.TaskListReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.Limit <= 0 {
+		req.Limit = config.DefaultPageSize
+	}
+
+	tasks := task.Tasks.List(req.Module)
+	resp := dto.TaskListResp{Total: int64(len(tasks)), Tasks: []task.TaskInfo{}}
+
+	start := (req.Page - 1) * req.Limit
+	for i := start; i < len(tasks) && i < start+req.Limit; i++ {
+		resp.Tasks = append(resp.Tasks, tasks[i].Snapshot())
+	}
+	ginutils.RespData(c, resp)
+}
@@ -6,12 +6,14 @@
 package controller
 
 import (
+	"fmt"
 	"myadmin/internal/config"
 	"myadmin/internal/dto"
 	"myadmin/internal/middleware"
 	"myadmin/internal/model"
 	"myadmin/internal/service/userservice"
 	"myadmin/internal/utils/ginutils"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -176,3 +178,79 @@ func (u User) Delete(c *gin.Context) {
 
 	ginutils.RespOK(c, "用户删除成功")
 }
+
+func (u User) UploadAvatar(c *gin.Context) {
+	// 限制请求体大小, 预留 1MB 给表单的其他字段
+	maxSize := userservice.AvatarMaxSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	var req dto.UserAvatarUploadReq
+	if err := c.ShouldBind(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if req.ID == 0 {
+		user, err := currentUser(c)
+		if err != nil {
+			ginutils.RespError(c, err.Error())
+			return
+		}
+		req.ID = user.ID
+	}
+
+	if err := middleware.JWTAuth.RootOrOwnerRequired(c, req.ID); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		ginutils.RespError(c, fmt.Sprintf("读取上传的图片失败: %v", err))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	defer file.Close()
+
+	userService := userservice.NewUserService()
+	avatarURL, err := userService.UploadAvatar(req.ID, file)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	ginutils.RespData(c, dto.UserAvatarUploadResp{AvatarURL: avatarURL})
+}
+
+// Avatar 读取头像, 不需要登录, 以便在 img 标签中直接使用
+func (u User) Avatar(c *gin.Context) {
+	var req dto.UserAvatarReq
+	if err := c.ShouldBindUri(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	userService := userservice.NewUserService()
+	file, err := userService.Avatar(req)
+	if err != nil {
+		ginutils.RespError(c, err.Error())
+		return
+	}
+
+	if file.RedirectURL != "" {
+		c.Redirect(http.StatusFound, file.RedirectURL)
+		return
+	}
+	defer file.Body.Close()
+
+	// 头像文件名包含内容的哈希, 内容不会变化, 可以长期缓存
+	headers := map[string]string{"Cache-Control": "public, max-age=31536000, immutable"}
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, file.Body, headers)
+}
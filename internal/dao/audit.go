@@ -0,0 +1,25 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 15:12:40
+ */
+
+package dao
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/model"
+)
+
+type AuditDao struct {
+	db db.DBClient
+}
+
+func NewAuditDao() *AuditDao {
+	return &AuditDao{
+		db: db.DB(),
+	}
+}
+
+func (dao *AuditDao) Create(log *model.AuditLog) error {
+	return dao.db.Conn().Create(log).Error
+}
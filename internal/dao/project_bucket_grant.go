@@ -0,0 +1,52 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-26 14:20:47
+ */
+
+package dao
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/model"
+)
+
+type ProjectBucketGrantDao struct {
+	db db.DBClient
+}
+
+func NewProjectBucketGrantDao() *ProjectBucketGrantDao {
+	return &ProjectBucketGrantDao{
+		db: db.DB(),
+	}
+}
+
+// List 项目的授权, projectID 为 0 时返回所有项目的授权
+func (dao *ProjectBucketGrantDao) List(projectID uint) (grants []model.ProjectBucketGrant, err error) {
+	query := dao.db.Conn().Order("project_id, instance, bucket, prefix")
+	if projectID != 0 {
+		query = query.Where("project_id = ?", projectID)
+	}
+	err = query.Find(&grants).Error
+	return grants, err
+}
+
+func (dao *ProjectBucketGrantDao) Create(grant *model.ProjectBucketGrant) error {
+	return dao.db.Conn().Create(grant).Error
+}
+
+func (dao *ProjectBucketGrantDao) Delete(id uint) (int64, error) {
+	result := dao.db.Conn().Delete(&model.ProjectBucketGrant{}, id)
+	return result.RowsAffected, result.Error
+}
+
+// UserGrants 用户所在的项目对 bucket 的授权, 只返回已激活的项目和成员关系的授权
+func (dao *ProjectBucketGrantDao) UserGrants(userID, projectID uint, instance, bucket string) (grants []model.ProjectBucketGrant, err error) {
+	err = dao.db.Conn().
+		Table("project_bucket_grants AS g").
+		Select("g.*").
+		Joins("JOIN project_user_relations AS r ON r.project_id = g.project_id AND r.is_del = 0 AND r.status = ?", model.ProjectStatusActive).
+		Joins("JOIN projects AS p ON p.id = g.project_id AND p.is_del = 0 AND p.status = ?", model.ProjectStatusActive).
+		Where("r.user_id = ? AND g.project_id = ? AND g.instance = ? AND g.bucket = ?", userID, projectID, instance, bucket).
+		Find(&grants).Error
+	return grants, err
+}
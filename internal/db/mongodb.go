@@ -14,8 +14,6 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type MongoDBClient struct {
@@ -24,61 +22,18 @@ type MongoDBClient struct {
 }
 
 func NewMongoDBClient(config *config.MongoConfig) (*MongoDBClient, error) {
-	var err error
-	var repl string
-	var connect string
-
-	URI := config.URI
-	if URI == "" {
-		if config.ReplSet != "" {
-			repl = fmt.Sprintf("&replicaSet=%s", repl)
-		}
-		if config.Connect != "" {
-			connect = fmt.Sprintf("&connect=%s", connect)
-		}
-		URI = fmt.Sprintf("mongodb://%s:%s@%s:%d/%s?authSource=%s&connectTimeoutMS=%d&socketTimeoutMS=%d&serverSelectionTimeoutMS=%d%s%s",
-			config.Username,
-			config.Password,
-			config.Host,
-			config.Port,
-			config.Database,
-			config.AuthDB,
-			config.ConnectTimeoutMS,
-			config.SocketTimeoutMS,
-			config.ServerSelectionTimeoutMS,
-			repl,
-			connect,
-		)
-	}
-	// QueryEscape 之后会报错 URI 格式不正确
-	// URI = url.QueryEscape(URI)
+	clientOptions, err := mongoClientOptions(config)
+	if err != nil {
+		return &MongoDBClient{config: config}, err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ConnectTimeoutMS)*time.Millisecond)
 	defer cancel()
 
-	// 创建MongoDB客户端
-	ClientOptions := options.Client().ApplyURI(URI)
-
-	switch config.ReadPreference {
-	case "", "Primary":
-		ClientOptions = ClientOptions.SetReadPreference(readpref.Primary())
-	case "PrimaryPreferred":
-		ClientOptions = ClientOptions.SetReadPreference(readpref.PrimaryPreferred())
-	case "SecondaryPreferred":
-		ClientOptions = ClientOptions.SetReadPreference(readpref.SecondaryPreferred())
-	case "Secondary":
-		ClientOptions = ClientOptions.SetReadPreference(readpref.Secondary())
-	case "Nearest":
-		ClientOptions = ClientOptions.SetReadPreference(readpref.Nearest())
-	default:
-		ClientOptions = ClientOptions.SetReadPreference(readpref.Primary())
-	}
-
 	// 连接到 MongoDB
-	conn, err := mongo.Connect(ctx, ClientOptions)
+	conn, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		var conn *mongo.Client
-		return &MongoDBClient{config: config, Conn: conn}, err
+		return &MongoDBClient{config: config}, err
 	}
 
 	// 检查连接是否可用
@@ -94,46 +49,78 @@ func NewMongoDBClient(config *config.MongoConfig) (*MongoDBClient, error) {
 func (m *MongoDBClient) RunCommand(dbname string, cmd bson.D) (bson.M, error) {
 	//opts := options.RunCmd().SetReadPreference(readpref.Primary())
 	var result bson.M
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
-	defer cancel()
-	if err := m.Conn.Database(dbname).RunCommand(ctx, cmd).Decode(&result); err != nil {
+	if err := m.runCommand(dbname, cmd, &result); err != nil {
 		return result, err
 	}
 	return result, nil
 }
 
+// mongodb 错误码
+const (
+	mongoErrAlreadyInitialized = 23
+	mongoErrCommandNotFound    = 59
+)
+
+// CommandOK 判断命令返回的 ok 字段是否为 1, 不同版本和命令返回的 ok 可能是 double, int32, int64 或 bool
+func CommandOK(result bson.M) bool {
+	switch ok := result["ok"].(type) {
+	case float64:
+		return ok == 1
+	case int32:
+		return ok == 1
+	case int64:
+		return ok == 1
+	case int:
+		return ok == 1
+	case bool:
+		return ok
+	}
+	return false
+}
+
+// runCommand 运行command命令, 并将结果解析到 result 中
+func (m *MongoDBClient) runCommand(dbname string, cmd bson.D, result any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+	return m.Conn.Database(dbname).RunCommand(ctx, cmd).Decode(result)
+}
+
 // GetReplSetName 获取副本集名称
 func (m *MongoDBClient) GetReplSetName() (string, error) {
-	var result bson.M
-	var err error
-	cmd := bson.D{{Key: "replSetGetStatus", Value: 1}}
-	if result, err = m.RunCommand("admin", cmd); err != nil {
+	status, err := m.GetReplStatus()
+	if err != nil {
 		return "", fmt.Errorf("获取副本集名称失败: %v", err)
 	}
-	if _, ok := result["set"]; ok {
-		return result["set"].(string), nil
-	}
-	return "", fmt.Errorf("获取副本集名称失败: %v", err)
+	return status.Set, nil
 }
 
-// GetReplSetName 获取副本集状态
-func (m *MongoDBClient) GetReplStatus() (bson.M, error) {
+// GetReplStatus 获取副本集状态, 并计算各成员的复制延迟
+func (m *MongoDBClient) GetReplStatus() (status ReplSetStatus, err error) {
 	cmd := bson.D{{Key: "replSetGetStatus", Value: 1}}
-	return m.RunCommand("admin", cmd)
+	if err = m.runCommand("admin", cmd, &status); err != nil {
+		return status, fmt.Errorf("执行rs.status()失败: %v", err)
+	}
+	status.computeLag()
+	return status, nil
 }
 
-// DBisMaster 判断是否为主库
-func (m *MongoDBClient) DBisMaster() (bson.M, error) {
-	var result bson.M
-	var err error
-	cmd := bson.D{{Key: "isMaster", Value: 1}}
-	if result, err = m.RunCommand("admin", cmd); err != nil {
-		return result, fmt.Errorf("执行db.isMaster()失败: %v", err)
+// Hello 获取节点角色信息, 低版本不支持 hello 命令时使用 isMaster
+func (m *MongoDBClient) Hello() (hello HelloResult, err error) {
+	err = m.runCommand("admin", bson.D{{Key: "hello", Value: 1}}, &hello)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == mongoErrCommandNotFound {
+		hello = HelloResult{}
+		err = m.runCommand("admin", bson.D{{Key: "isMaster", Value: 1}}, &hello)
 	}
-	if result["ok"].(float64) != 1 {
-		return result, fmt.Errorf("执行db.isMaster()失败\n")
+	if err != nil {
+		return hello, fmt.Errorf("执行db.hello()失败: %v", err)
 	}
-	return result, nil
+	return hello, nil
+}
+
+// DBisMaster 判断是否为主库
+func (m *MongoDBClient) DBisMaster() (HelloResult, error) {
+	return m.Hello()
 }
 
 // 初始化副本集
@@ -151,7 +138,7 @@ func (m *MongoDBClient) ReplSetInitiate() error {
 	//json, err1 := bson.MarshalExtJSON(result, true, true)
 	//logger.Warningf("添加日志 - 打印初始化副本集转json错误: %v\n", err1)
 	//logger.Warningf("添加日志 - 打印初始化副本集结果: %s\n", string(json))
-	if result["ok"].(float64) != 1 {
+	if !CommandOK(result) {
 		return fmt.Errorf("执行rs.initiate()失败\n")
 	}
 	return nil
@@ -165,10 +152,14 @@ func (m *MongoDBClient) GetReplConfig() (bson.M, error) {
 	if result, err = m.RunCommand("admin", cmd); err != nil {
 		return result, fmt.Errorf("执行rs.conf()失败: %v", err)
 	}
-	if result["ok"].(float64) != 1 {
+	if !CommandOK(result) {
 		return result, fmt.Errorf("执行rs.conf()失败\n")
 	}
-	return result["config"].(bson.M), nil
+	config, ok := result["config"].(bson.M)
+	if !ok {
+		return result, fmt.Errorf("执行rs.conf()失败: 返回结果中没有 config")
+	}
+	return config, nil
 }
 
 // 刷新副本集配置
@@ -182,7 +173,7 @@ func (m *MongoDBClient) ReplReConfig(config bson.M) error {
 	//json, err1 := bson.MarshalExtJSON(result, true, true)
 	//logger.Warningf("添加日志 - 打印重置配置转json错误: %v\n", err1)
 	//logger.Warningf("添加日志 - 打印重置配置结果: %s\n", string(json))
-	if result["ok"].(float64) != 1 {
+	if !CommandOK(result) {
 		return fmt.Errorf("执行rs.reconfig()失败\n")
 	}
 	return nil
@@ -190,7 +181,9 @@ func (m *MongoDBClient) ReplReConfig(config bson.M) error {
 
 // RSAdd 增加从节点
 func (m *MongoDBClient) RSAdd(ip string, port int) error {
-	return nil
+	member := ReplSetMember{Host: fmt.Sprintf("%s:%d", ip, port), Priority: 1, Votes: 1}
+	_, err := m.ReplSetAddMember(member, false)
+	return err
 }
 
 // Mongos 增加分片Shard
@@ -204,25 +197,22 @@ func (m *MongoDBClient) ShardingAdd(shardDB string) error {
 		return fmt.Errorf("执行sh.addShard()失败: %v", err)
 	}
 
-	if result["ok"].(float64) != 1 {
+	if !CommandOK(result) {
 		return fmt.Errorf("执行sh.addShard()失败\n")
 	}
 	return nil
 }
 
 // Mongos 查看分片列表
-func (m *MongoDBClient) ShardingList() (bson.M, error) {
-	var result bson.M
-	var err error
-
-	cmd := bson.D{{Key: "listShards", Value: 1}}
-	if result, err = m.RunCommand("admin", cmd); err != nil {
-		return result, fmt.Errorf("执行listShards失败了: %v", err)
+func (m *MongoDBClient) ShardingList() ([]Shard, error) {
+	var result struct {
+		Shards []Shard `bson:"shards"`
 	}
-	if result["ok"].(float64) != 1 {
-		return result, fmt.Errorf("执行listShards失败\n")
+	cmd := bson.D{{Key: "listShards", Value: 1}}
+	if err := m.runCommand("admin", cmd, &result); err != nil {
+		return nil, fmt.Errorf("执行listShards失败了: %v", err)
 	}
-	return result, nil
+	return result.Shards, nil
 }
 
 // 获取数据库列表
@@ -290,8 +280,8 @@ func (m *MongoDBClient) GetRole(roleName, dbname string) (roles bson.A, err erro
 	if result, err = m.RunCommand("admin", cmd); err != nil {
 		return roles, fmt.Errorf("获取用户:%s, 在库:%s 里的角色失败", roleName, dbname)
 	}
-	if _, ok := result["roles"]; ok {
-		roles = result["roles"].(bson.A)
+	if v, ok := result["roles"].(bson.A); ok {
+		roles = v
 	}
 	return roles, nil
 }
@@ -312,8 +302,8 @@ func (m *MongoDBClient) GetUser(username, dbname string) (users bson.A, err erro
 	if result, err = m.RunCommand("admin", cmd); err != nil {
 		return users, fmt.Errorf("获取用户:%s, 在库:%s 里的用户失败", username, dbname)
 	}
-	if _, ok := result["users"]; ok {
-		users = result["users"].(bson.A)
+	if v, ok := result["users"].(bson.A); ok {
+		users = v
 	}
 	return users, nil
 }
@@ -341,6 +331,11 @@ func (m *MongoDBClient) CreateDB(dbname string) error {
 func (m *MongoDBClient) EnableSharding(dbname string) error {
 	cmd := bson.D{{Key: "enableSharding", Value: dbname}}
 	if _, err := m.RunCommand("admin", cmd); err != nil {
+		// 4.x 之前对已经启用分片的库再次执行会返回 AlreadyInitialized
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Code == mongoErrAlreadyInitialized {
+			return nil
+		}
 		return err
 	}
 	return nil
@@ -515,9 +510,86 @@ func (m *MongoDBClient) DropCollection(dbname, colName string) error {
 	return nil
 }
 
+// 创建集合, 库不存在时同时创建库
+func (m *MongoDBClient) CreateCollection(dbname, colName string) error {
+	if dbname == "" || colName == "" {
+		return errors.New("库名,集合名都不能为空")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+	if err := m.Conn.Database(dbname).CreateCollection(ctx, colName); err != nil {
+		return fmt.Errorf("创建集合 %s.%s 失败: %v", dbname, colName, err)
+	}
+	return nil
+}
+
+// 删除库
+func (m *MongoDBClient) DropDatabase(dbname string) error {
+	if dbname == "" {
+		return errors.New("库名不能为空")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+	if err := m.Conn.Database(dbname).Drop(ctx); err != nil {
+		return fmt.Errorf("删除库 %s 失败: %v", dbname, err)
+	}
+	return nil
+}
+
+// 在指定库中创建用户, roles 为同一个库中的角色名
+func (m *MongoDBClient) CreateUser(username, password, dbname string, roles []string) error {
+	if username == "" || password == "" || dbname == "" {
+		return errors.New("用户名,密码,库名都不能为空")
+	}
+
+	userRoles := bson.A{}
+	for _, role := range roles {
+		userRoles = append(userRoles, bson.D{{Key: "role", Value: role}, {Key: "db", Value: dbname}})
+	}
+	cmd := bson.D{{Key: "createUser", Value: username}, {Key: "pwd", Value: password}, {Key: "roles", Value: userRoles}}
+	if _, err := m.RunCommand(dbname, cmd); err != nil {
+		return fmt.Errorf("为db: %s 创建用户: %s 失败: %v", dbname, username, err)
+	}
+	return nil
+}
+
+// 删除用户
+func (m *MongoDBClient) DropUser(username, dbname string) error {
+	if username == "" || dbname == "" {
+		return errors.New("用户名,库名都不能为空")
+	}
+
+	cmd := bson.D{{Key: "dropUser", Value: username}}
+	if _, err := m.RunCommand(dbname, cmd); err != nil {
+		return fmt.Errorf("删除用户 %s.%s 失败: %v", dbname, username, err)
+	}
+	return nil
+}
+
+// ShardedCollection 获取分片集合的信息, 集合没有分片时返回 nil, 需要连接 mongos
+func (m *MongoDBClient) ShardedCollection(dbname, colName string) (*Collection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	filter := bson.D{{Key: "_id", Value: dbname + "." + colName}, {Key: "dropped", Value: bson.D{{Key: "$ne", Value: true}}}}
+	var col Collection
+	err := m.Conn.Database("config").Collection("collections").FindOne(ctx, filter).Decode(&col)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取集合 %s.%s 的分片信息失败: %v", dbname, colName, err)
+	}
+	return &col, nil
+}
+
+// GetShardCollectionList 获取所有分片集合, 需要连接 mongos
 func (m *MongoDBClient) GetShardCollectionList() (cols []*Collection, err error) {
+	// 5.0 开始删除集合时直接删除 config.collections 中的记录, 不再有 dropped 字段
 	f := map[string]interface{}{
-		"dropped": false,
+		"dropped": map[string]interface{}{"$ne": true},
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
@@ -527,6 +599,7 @@ func (m *MongoDBClient) GetShardCollectionList() (cols []*Collection, err error)
 	if err != nil {
 		return nil, err
 	}
+	defer cur.Close(ctx)
 
 	for cur.Next(ctx) {
 		var elem Collection
@@ -540,22 +613,6 @@ func (m *MongoDBClient) GetShardCollectionList() (cols []*Collection, err error)
 	return cols, nil
 }
 
-func (m *MongoDBClient) ConfigModify(key string, value interface{}) error {
-	var conf string
-	switch key {
-	case "cache_size":
-		conf = fmt.Sprintf("cache_size=%dG", int(value.(float64)))
-	default:
-		return fmt.Errorf("参数: %s 不正确, 或暂不支持该参数的修改", key)
-	}
-
-	cmd := bson.D{{Key: "setParameter", Value: 1}, {Key: "wiredTigerEngineRuntimeConfig", Value: conf}}
-	if _, err := m.RunCommand("admin", cmd); err != nil {
-		return err
-	}
-	return nil
-}
-
 // 查找跨分片不一致的索引,  为官方文档: Find Inconsistent Indexes Across Shards 脚本的 go 实现
 func (m *MongoDBClient) InconsistentIndexPipeline() mongo.Pipeline {
 	indexStats := bson.D{{Key: "$indexStats", Value: bson.D{}}}
@@ -627,9 +684,22 @@ func (m *MongoDBClient) InconsistentIndexPipeline() mongo.Pipeline {
 	}
 }
 
-// 扫描集合各分片索引是否一致(TODO: 未完成, 还需要遍历游标。 暂时先直接 return 游标, 叫函数可用不报错)
-func (m *MongoDBClient) InconsistentIndex(dbname, colName string) (*mongo.Cursor, error) {
+// InconsistentIndex 扫描集合各分片索引是否一致, 需要连接 mongos, 4.2.4 开始 $indexStats 才返回 shard 和 spec 字段
+func (m *MongoDBClient) InconsistentIndex(dbname, colName string) ([]IndexInconsistency, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
 	defer cancel()
-	return m.Conn.Database(dbname).Collection(colName).Aggregate(ctx, m.InconsistentIndexPipeline())
+
+	cur, err := m.Conn.Database(dbname).Collection(colName).Aggregate(ctx, m.InconsistentIndexPipeline())
+	if err != nil {
+		return nil, fmt.Errorf("检查集合 %s.%s 的索引失败: %v", dbname, colName, err)
+	}
+
+	results := []IndexInconsistency{}
+	if err := cur.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("检查集合 %s.%s 的索引失败: %v", dbname, colName, err)
+	}
+	for i := range results {
+		results[i].Namespace = dbname + "." + colName
+	}
+	return results, nil
 }
@@ -0,0 +1,195 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-08 14:12:33
+ */
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 复制和集群内部使用的命令, 不允许 kill
+var internalCommands = map[string]bool{
+	"replSetHeartbeat":      true,
+	"replSetUpdatePosition": true,
+	"replSetRequestVotes":   true,
+	"replSetStepDown":       true,
+	"replSetReconfig":       true,
+	"moveChunk":             true,
+	"moveRange":             true,
+	"splitChunk":            true,
+	"mergeChunks":           true,
+}
+
+// CurrentOp 使用 $currentOp 获取正在执行的操作, 连接 mongos 时返回所有分片上的操作
+func (m *MongoDBClient) CurrentOp(filter CurrentOpFilter) ([]CurrentOp, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	pipeline := bson.A{bson.D{{Key: "$currentOp", Value: bson.D{{Key: "allUsers", Value: true}, {Key: "idleConnections", Value: false}}}}}
+	if match := currentOpMatch(filter); len(match) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: match}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "microsecs_running", Value: -1}}}})
+
+	cur, err := m.Conn.Database("admin").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("执行 $currentOp 失败: %v", err)
+	}
+
+	ops := []CurrentOp{}
+	if err := cur.All(ctx, &ops); err != nil {
+		return nil, fmt.Errorf("执行 $currentOp 失败: %v", err)
+	}
+	for i := range ops {
+		ops[i].InternalReason = ops[i].internalReason()
+		ops[i].Internal = ops[i].InternalReason != ""
+	}
+	return ops, nil
+}
+
+func currentOpMatch(filter CurrentOpFilter) bson.D {
+	var match bson.D
+	if filter.Namespace != "" {
+		if strings.Contains(filter.Namespace, ".") {
+			match = append(match, bson.E{Key: "ns", Value: filter.Namespace})
+		} else {
+			match = append(match, bson.E{Key: "ns", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(filter.Namespace+".")}}})
+		}
+	}
+	if filter.MinSecs > 0 {
+		match = append(match, bson.E{Key: "microsecs_running", Value: bson.D{{Key: "$gte", Value: filter.MinSecs * 1000000}}})
+	}
+	if filter.OpType != "" {
+		match = append(match, bson.E{Key: "op", Value: filter.OpType})
+	}
+	if filter.Client != "" {
+		match = append(match, bson.E{Key: "client", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(filter.Client)}}})
+	}
+	if filter.ActiveOnly {
+		match = append(match, bson.E{Key: "active", Value: true})
+	}
+	return match
+}
+
+// internalReason 判断是否为内部或复制相关的操作, 返回原因, 不是内部操作时返回空
+func (op CurrentOp) internalReason() string {
+	if op.Client == "" && !strings.HasPrefix(op.Desc, "conn") {
+		return "内部线程: " + op.Desc
+	}
+	if strings.HasPrefix(op.Ns, "local.") {
+		return "复制相关操作: " + op.Ns
+	}
+	if strings.HasPrefix(op.Ns, "config.") {
+		return "集群元数据操作: " + op.Ns
+	}
+	for _, user := range op.EffectiveUsers {
+		if user.User == "__system" {
+			return "集群内部用户 __system 的操作"
+		}
+	}
+	for name, value := range op.Command {
+		if internalCommands[name] || (strings.HasPrefix(name, "_") && name != "_id") {
+			return "内部命令: " + name
+		}
+		// 查询 currentOp 本身
+		if name == "currentOp" || (name == "pipeline" && isCurrentOpPipeline(value)) {
+			return "currentOp 查询本身"
+		}
+	}
+	return ""
+}
+
+func isCurrentOpPipeline(pipeline any) bool {
+	stages, ok := pipeline.(bson.A)
+	if !ok || len(stages) == 0 {
+		return false
+	}
+	switch stage := stages[0].(type) {
+	case bson.M:
+		_, ok = stage["$currentOp"]
+		return ok
+	case bson.D:
+		return len(stage) > 0 && stage[0].Key == "$currentOp"
+	}
+	return false
+}
+
+// KillOp kill 指定的操作, 内部或复制相关的操作不允许 kill
+func (m *MongoDBClient) KillOp(opid any) (CurrentOp, error) {
+	opid = normalizeOpID(opid)
+
+	ops, err := m.CurrentOp(CurrentOpFilter{})
+	if err != nil {
+		return CurrentOp{}, err
+	}
+
+	for _, op := range ops {
+		if fmt.Sprint(op.OpID) != fmt.Sprint(opid) {
+			continue
+		}
+		if op.Internal {
+			return op, fmt.Errorf("操作 %v 不允许 kill, %s", opid, op.InternalReason)
+		}
+		return op, m.killOp(op.OpID)
+	}
+	return CurrentOp{}, fmt.Errorf("操作 %v 不存在或已经结束", opid)
+}
+
+// KillMatching kill 所有匹配条件的活跃操作, 内部操作跳过; 没有过滤条件时拒绝执行, 防止 kill 所有操作
+func (m *MongoDBClient) KillMatching(filter CurrentOpFilter, dryRun bool) (result CurrentOpKillResult, err error) {
+	if filter.Empty() {
+		return result, errors.New("至少需要一个过滤条件: namespace, minSecs, opType, client")
+	}
+	filter.ActiveOnly = true
+
+	ops, err := m.CurrentOp(filter)
+	if err != nil {
+		return result, err
+	}
+
+	result = CurrentOpKillResult{DryRun: dryRun, Killed: []CurrentOp{}, Skipped: []CurrentOp{}, Failed: []CurrentOpError{}}
+	for _, op := range ops {
+		if op.Internal {
+			result.Skipped = append(result.Skipped, op)
+			continue
+		}
+		if !dryRun {
+			if err := m.killOp(op.OpID); err != nil {
+				result.Failed = append(result.Failed, CurrentOpError{OpID: op.OpID, Error: err.Error()})
+				continue
+			}
+		}
+		result.Killed = append(result.Killed, op)
+	}
+	return result, nil
+}
+
+func (m *MongoDBClient) killOp(opid any) error {
+	cmd := bson.D{{Key: "killOp", Value: 1}, {Key: "op", Value: opid}}
+	if err := m.runCommand("admin", cmd, &bson.M{}); err != nil {
+		return fmt.Errorf("执行 killOp %v 失败: %v", opid, err)
+	}
+	return nil
+}
+
+// normalizeOpID json 解析的数字为 float64, 转换为整数; mongos 的 "分片名:opid" 字符串保持不变
+func normalizeOpID(opid any) any {
+	switch v := opid.(type) {
+	case float64:
+		return int64(v)
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	}
+	return opid
+}
@@ -0,0 +1,64 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-08 16:40:52
+ */
+
+package db
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCurrentOpInternal(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       CurrentOp
+		internal bool
+	}{
+		{name: "user query", op: CurrentOp{Desc: "conn12", Client: "10.0.0.1:5000", Ns: "db01.orders", Command: bson.M{"find": "orders"}}},
+		{name: "internal thread", op: CurrentOp{Desc: "ReplBatcher"}, internal: true},
+		{name: "oplog", op: CurrentOp{Desc: "conn3", Client: "10.0.0.2:5000", Ns: "local.oplog.rs", Command: bson.M{"getMore": int64(1)}}, internal: true},
+		{name: "heartbeat", op: CurrentOp{Desc: "conn4", Client: "10.0.0.2:5000", Ns: "admin.$cmd", Command: bson.M{"replSetHeartbeat": "rs0"}}, internal: true},
+		{name: "shard internal command", op: CurrentOp{Desc: "conn5", Client: "10.0.0.3:5000", Ns: "admin.$cmd", Command: bson.M{"_shardsvrMoveRange": "db01.orders"}}, internal: true},
+		{name: "system user", op: CurrentOp{Desc: "conn6", Client: "10.0.0.3:5000", Ns: "db01.orders", EffectiveUsers: []struct {
+			User string `json:"user" bson:"user"`
+			DB   string `json:"db" bson:"db"`
+		}{{User: "__system", DB: "local"}}}, internal: true},
+		{name: "currentop itself", op: CurrentOp{Desc: "conn7", Client: "10.0.0.1:5000", Ns: "admin.$cmd.aggregate", Command: bson.M{"aggregate": int32(1), "pipeline": bson.A{bson.M{"$currentOp": bson.M{}}}}}, internal: true},
+	}
+
+	for _, tt := range tests {
+		reason := tt.op.internalReason()
+		if (reason != "") != tt.internal {
+			t.Errorf("%s: internalReason = %q, want internal %v", tt.name, reason, tt.internal)
+		}
+	}
+}
+
+func TestCurrentOpMatch(t *testing.T) {
+	if match := currentOpMatch(CurrentOpFilter{}); len(match) != 0 {
+		t.Errorf("empty filter got match %v", match)
+	}
+
+	match := currentOpMatch(CurrentOpFilter{Namespace: "db01", MinSecs: 5, OpType: "query", Client: "10.0.0.1", ActiveOnly: true})
+	got := make(map[string]any)
+	for _, e := range match {
+		got[e.Key] = e.Value
+	}
+	if ns, ok := got["ns"].(bson.D); !ok || ns[0].Value != `^db01\.` {
+		t.Errorf("ns match = %v", got["ns"])
+	}
+	if secs, ok := got["microsecs_running"].(bson.D); !ok || secs[0].Value != int64(5000000) {
+		t.Errorf("microsecs_running match = %v", got["microsecs_running"])
+	}
+	if got["op"] != "query" || got["active"] != true {
+		t.Errorf("match = %v", match)
+	}
+
+	match = currentOpMatch(CurrentOpFilter{Namespace: "db01.orders"})
+	if len(match) != 1 || match[0].Value != "db01.orders" {
+		t.Errorf("exact ns match = %v", match)
+	}
+}
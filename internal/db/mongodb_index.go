@@ -0,0 +1,191 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-06 09:41:27
+ */
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	IndexCheckDefaultConcurrency = 4  // 检查索引一致性时默认的并发数
+	IndexCheckMaxConcurrency     = 16 // 检查索引一致性时最大的并发数
+)
+
+// InconsistentIndexReport 检查所有分片集合的索引一致性, dbname 不为空时只检查该库
+// 单个集合检查失败记录到 Errors 中, 不影响其他集合; fix 为 true 时生成修复命令
+func (m *MongoDBClient) InconsistentIndexReport(dbname string, concurrency int, fix bool) (report IndexInconsistencyReport, err error) {
+	cols, err := m.GetShardCollectionList()
+	if err != nil {
+		return report, fmt.Errorf("获取分片集合列表失败: %v", err)
+	}
+
+	if concurrency <= 0 {
+		concurrency = IndexCheckDefaultConcurrency
+	}
+	if concurrency > IndexCheckMaxConcurrency {
+		concurrency = IndexCheckMaxConcurrency
+	}
+
+	report.Inconsistencies = []IndexInconsistency{}
+	report.Errors = []NamespaceError{}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, col := range cols {
+		db, colName, ok := strings.Cut(col.Id, ".")
+		if !ok || (dbname != "" && db != dbname) {
+			continue
+		}
+		report.Collections++
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(ns, db, colName string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			results, err := m.InconsistentIndex(db, colName)
+			if err == nil && fix && len(results) > 0 {
+				var specs map[string]map[string]bson.D
+				if specs, err = m.IndexSpecsByShard(db, colName); err == nil {
+					for i := range results {
+						results[i].FixCommands = IndexFixCommands(results[i], specs[results[i].IndexName])
+					}
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Errors = append(report.Errors, NamespaceError{Namespace: ns, Error: err.Error()})
+				return
+			}
+			report.Inconsistencies = append(report.Inconsistencies, results...)
+		}(col.Id, db, colName)
+	}
+	wg.Wait()
+
+	sort.Slice(report.Inconsistencies, func(i, j int) bool {
+		if report.Inconsistencies[i].Namespace != report.Inconsistencies[j].Namespace {
+			return report.Inconsistencies[i].Namespace < report.Inconsistencies[j].Namespace
+		}
+		return report.Inconsistencies[i].IndexName < report.Inconsistencies[j].IndexName
+	})
+	sort.Slice(report.Errors, func(i, j int) bool { return report.Errors[i].Namespace < report.Errors[j].Namespace })
+	return report, nil
+}
+
+// IndexSpecsByShard 获取集合在各分片上的索引定义, 返回 索引名 -> 分片名 -> 索引定义
+func (m *MongoDBClient) IndexSpecsByShard(dbname, colName string) (map[string]map[string]bson.D, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	pipeline := bson.A{
+		bson.D{{Key: "$indexStats", Value: bson.D{}}},
+		bson.D{{Key: "$project", Value: bson.D{{Key: "name", Value: 1}, {Key: "shard", Value: 1}, {Key: "spec", Value: 1}}}},
+	}
+	cur, err := m.Conn.Database(dbname).Collection(colName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("获取集合 %s.%s 各分片的索引失败: %v", dbname, colName, err)
+	}
+
+	var stats []struct {
+		Name  string `bson:"name"`
+		Shard string `bson:"shard"`
+		Spec  bson.D `bson:"spec"`
+	}
+	if err := cur.All(ctx, &stats); err != nil {
+		return nil, fmt.Errorf("获取集合 %s.%s 各分片的索引失败: %v", dbname, colName, err)
+	}
+
+	specs := make(map[string]map[string]bson.D)
+	for _, stat := range stats {
+		if specs[stat.Name] == nil {
+			specs[stat.Name] = make(map[string]bson.D)
+		}
+		specs[stat.Name][stat.Shard] = stat.Spec
+	}
+	return specs, nil
+}
+
+// IndexFixCommands 生成修复索引不一致的 mongo shell 命令, 在 mongos 上执行
+// 以拥有相同定义的分片最多的索引定义为准: 只是部分分片缺少索引时重新 createIndex; 属性不一致时先 dropIndex 再 createIndex
+// _id 索引不能删除, 不生成命令
+func IndexFixCommands(inc IndexInconsistency, specs map[string]bson.D) []string {
+	if inc.IndexName == "_id_" || len(specs) == 0 {
+		return nil
+	}
+
+	db, colName, _ := strings.Cut(inc.Namespace, ".")
+	collection := fmt.Sprintf("db.getSiblingDB(%q).getCollection(%q)", db, colName)
+
+	spec := majoritySpec(specs)
+	var key bson.D
+	var options bson.D
+	for _, e := range spec {
+		switch e.Key {
+		case "key":
+			key, _ = e.Value.(bson.D)
+		case "v", "ns":
+		default:
+			options = append(options, e)
+		}
+	}
+	if key == nil {
+		return nil
+	}
+
+	keyJSON, err := bson.MarshalExtJSON(key, false, false)
+	if err != nil {
+		return nil
+	}
+	optionsJSON, err := bson.MarshalExtJSON(options, false, false)
+	if err != nil {
+		return nil
+	}
+
+	var commands []string
+	if len(inc.InconsistentProperties) > 0 {
+		commands = append(commands, fmt.Sprintf("%s.dropIndex(%q)", collection, inc.IndexName))
+	}
+	commands = append(commands, fmt.Sprintf("%s.createIndex(%s, %s)", collection, keyJSON, optionsJSON))
+	return commands
+}
+
+// majoritySpec 返回拥有相同定义的分片最多的索引定义, 数量相同时取分片名最小的
+func majoritySpec(specs map[string]bson.D) bson.D {
+	shards := make([]string, 0, len(specs))
+	for shard := range specs {
+		shards = append(shards, shard)
+	}
+	sort.Strings(shards)
+
+	counts := make(map[string]int)
+	var best bson.D
+	bestCount := 0
+	for _, shard := range shards {
+		b, err := bson.Marshal(specs[shard])
+		if err != nil {
+			continue
+		}
+		counts[string(b)]++
+		if counts[string(b)] > bestCount {
+			bestCount = counts[string(b)]
+			best = specs[shard]
+		}
+	}
+	return best
+}
@@ -0,0 +1,43 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-06 11:02:15
+ */
+
+package db
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIndexFixCommands(t *testing.T) {
+	spec := func(unique bool) bson.D {
+		s := bson.D{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "uid", Value: int32(1)}, {Key: "ts", Value: int32(-1)}}}, {Key: "name", Value: "uid_1_ts_-1"}}
+		if unique {
+			s = append(s, bson.E{Key: "unique", Value: true})
+		}
+		return s
+	}
+
+	missing := IndexInconsistency{Namespace: "db01.orders", IndexName: "uid_1_ts_-1", MissingFromShards: []string{"shard02"}}
+	got := IndexFixCommands(missing, map[string]bson.D{"shard01": spec(false)})
+	want := []string{`db.getSiblingDB("db01").getCollection("orders").createIndex({"uid":1,"ts":-1}, {"name":"uid_1_ts_-1"})`}
+	if len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("missing index commands = %q, want %q", got, want)
+	}
+
+	inconsistent := IndexInconsistency{Namespace: "db01.orders", IndexName: "uid_1_ts_-1", InconsistentProperties: []IndexProperty{{K: "unique", V: true}}}
+	got = IndexFixCommands(inconsistent, map[string]bson.D{"shard01": spec(true), "shard02": spec(false), "shard03": spec(true)})
+	want = []string{
+		`db.getSiblingDB("db01").getCollection("orders").dropIndex("uid_1_ts_-1")`,
+		`db.getSiblingDB("db01").getCollection("orders").createIndex({"uid":1,"ts":-1}, {"name":"uid_1_ts_-1","unique":true})`,
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("inconsistent index commands = %q, want %q", got, want)
+	}
+
+	if got := IndexFixCommands(IndexInconsistency{Namespace: "db01.orders", IndexName: "_id_"}, map[string]bson.D{"shard01": spec(false)}); got != nil {
+		t.Errorf("_id_ index should not generate commands: %q", got)
+	}
+}
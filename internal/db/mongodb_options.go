@@ -0,0 +1,170 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-21 10:08:35
+ */
+
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"myadmin/internal/config"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// mongodb 认证方式
+const (
+	MongoAuthScramSHA1   = "SCRAM-SHA-1"
+	MongoAuthScramSHA256 = "SCRAM-SHA-256"
+	MongoAuthX509        = "MONGODB-X509"
+)
+
+// mongoClientOptions 根据配置生成连接参数
+// 配置了 URI 时以 URI 为准, 配置文件中的 TLS, 直连和读偏好作为补充; 否则按 host/port 等字段生成,
+// 用户名密码通过 options 传递, 不拼接到 URI 中, 不需要转义特殊字符
+func mongoClientOptions(conf *config.MongoConfig) (*options.ClientOptions, error) {
+	opts := options.Client()
+
+	if conf.URI != "" {
+		opts.ApplyURI(conf.URI)
+	} else {
+		if conf.Host == "" {
+			return nil, errors.New("mongodb 配置错误: URI 和 host 不能同时为空")
+		}
+		port := conf.Port
+		if port == 0 {
+			port = 27017
+		}
+		opts.SetHosts([]string{net.JoinHostPort(conf.Host, strconv.Itoa(int(port)))})
+
+		if conf.ReplSet != "" {
+			opts.SetReplicaSet(conf.ReplSet)
+		}
+		if conf.ConnectTimeoutMS > 0 {
+			opts.SetConnectTimeout(time.Duration(conf.ConnectTimeoutMS) * time.Millisecond)
+		}
+		if conf.SocketTimeoutMS > 0 {
+			opts.SetSocketTimeout(time.Duration(conf.SocketTimeoutMS) * time.Millisecond)
+		}
+		if conf.ServerSelectionTimeoutMS > 0 {
+			opts.SetServerSelectionTimeout(time.Duration(conf.ServerSelectionTimeoutMS) * time.Millisecond)
+		}
+
+		cred, err := mongoCredential(conf)
+		if err != nil {
+			return nil, err
+		}
+		if cred != nil {
+			opts.SetAuth(*cred)
+		}
+	}
+
+	if conf.DirectConnection || strings.EqualFold(conf.Connect, "direct") {
+		opts.SetDirect(true)
+	}
+
+	if conf.TLS {
+		tlsConfig, err := mongoTLSConfig(conf)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	// URI 中可能已经指定了读偏好, 配置文件中没有配置时不覆盖
+	if conf.URI == "" || conf.ReadPreference != "" {
+		opts.SetReadPreference(mongoReadPref(conf.ReadPreference))
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("mongodb 连接参数错误: %v", err)
+	}
+	return opts, nil
+}
+
+// mongoCredential 生成认证信息, 没有配置用户名并且不是 x.509 认证时返回 nil, 不认证
+// 认证库默认使用 auth_db, 其次是 database, 都为空时为 admin; x.509 认证固定为 $external
+func mongoCredential(conf *config.MongoConfig) (*options.Credential, error) {
+	mechanism := strings.ToUpper(conf.AuthMechanism)
+	switch mechanism {
+	case "", MongoAuthScramSHA1, MongoAuthScramSHA256:
+		if conf.Username == "" {
+			return nil, nil
+		}
+		source := conf.AuthDB
+		if source == "" {
+			source = conf.Database
+		}
+		if source == "" {
+			source = "admin"
+		}
+		return &options.Credential{AuthMechanism: mechanism, AuthSource: source, Username: conf.Username, Password: conf.Password}, nil
+
+	case MongoAuthX509:
+		if !conf.TLS || conf.TLSCertFile == "" {
+			return nil, errors.New("mongodb 配置错误: MONGODB-X509 认证需要开启 tls 并配置 tls_cert_file")
+		}
+		if conf.Password != "" {
+			return nil, errors.New("mongodb 配置错误: MONGODB-X509 认证不能配置密码")
+		}
+		// 用户名为空时服务端使用证书的 subject 作为用户名
+		return &options.Credential{AuthMechanism: MongoAuthX509, AuthSource: "$external", Username: conf.Username}, nil
+	}
+	return nil, fmt.Errorf("mongodb 配置错误: 不支持的认证方式 %s", conf.AuthMechanism)
+}
+
+// mongoTLSConfig 加载 CA 和客户端证书
+func mongoTLSConfig(conf *config.MongoConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: conf.TLSInsecure}
+
+	if conf.TLSCAFile != "" {
+		pem, err := os.ReadFile(conf.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 证书 %s 失败: %v", conf.TLSCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA 证书 %s 中没有有效的证书", conf.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.TLSCertFile != "" {
+		keyFile := conf.TLSKeyFile
+		if keyFile == "" {
+			keyFile = conf.TLSCertFile
+		}
+		cert, err := tls.LoadX509KeyPair(conf.TLSCertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书 %s 失败: %v", conf.TLSCertFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	} else if conf.TLSKeyFile != "" {
+		return nil, errors.New("mongodb 配置错误: 配置了 tls_key_file 但没有配置 tls_cert_file")
+	}
+	return tlsConfig, nil
+}
+
+// mongoReadPref 读偏好, 不支持的值使用 Primary
+func mongoReadPref(mode string) *readpref.ReadPref {
+	switch mode {
+	case "PrimaryPreferred":
+		return readpref.PrimaryPreferred()
+	case "SecondaryPreferred":
+		return readpref.SecondaryPreferred()
+	case "Secondary":
+		return readpref.Secondary()
+	case "Nearest":
+		return readpref.Nearest()
+	}
+	return readpref.Primary()
+}
@@ -0,0 +1,156 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-21 14:36:52
+ */
+
+package db
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"myadmin/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// writeTestCert 生成自签名证书, 返回证书和私钥文件路径
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "myadmin"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestMongoClientOptions(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	tests := []struct {
+		name    string
+		config  config.MongoConfig
+		check   func(opts *options.ClientOptions) bool
+		wantErr bool
+	}{
+		{
+			name:   "replica set and credentials with special chars",
+			config: config.MongoConfig{Host: "10.0.0.1", Port: 27018, Username: "app", Password: "p@ss:w/rd%", AuthDB: "admin", ReplSet: "rs0", ConnectTimeoutMS: 3000},
+			check: func(opts *options.ClientOptions) bool {
+				return opts.Hosts[0] == "10.0.0.1:27018" && *opts.ReplicaSet == "rs0" && opts.Auth.Password == "p@ss:w/rd%" &&
+					opts.Auth.AuthSource == "admin" && *opts.ConnectTimeout == 3*time.Second && opts.Direct == nil
+			},
+		},
+		{
+			name:   "default port and auth source from database",
+			config: config.MongoConfig{Host: "localhost", Username: "app", Password: "x", Database: "db01"},
+			check: func(opts *options.ClientOptions) bool {
+				return opts.Hosts[0] == "localhost:27017" && opts.Auth.AuthSource == "db01" && opts.ReplicaSet == nil
+			},
+		},
+		{
+			name:   "no auth",
+			config: config.MongoConfig{Host: "localhost"},
+			check:  func(opts *options.ClientOptions) bool { return opts.Auth == nil },
+		},
+		{
+			name:   "scram sha 256",
+			config: config.MongoConfig{Host: "localhost", Username: "app", Password: "x", AuthMechanism: "scram-sha-256"},
+			check:  func(opts *options.ClientOptions) bool { return opts.Auth.AuthMechanism == MongoAuthScramSHA256 },
+		},
+		{
+			name:   "direct connection",
+			config: config.MongoConfig{Host: "localhost", DirectConnection: true},
+			check:  func(opts *options.ClientOptions) bool { return opts.Direct != nil && *opts.Direct },
+		},
+		{
+			name:   "legacy connect direct",
+			config: config.MongoConfig{Host: "localhost", Connect: "direct"},
+			check:  func(opts *options.ClientOptions) bool { return opts.Direct != nil && *opts.Direct },
+		},
+		{
+			name:   "tls with ca and client cert",
+			config: config.MongoConfig{Host: "localhost", TLS: true, TLSCAFile: certFile, TLSCertFile: certFile, TLSKeyFile: keyFile},
+			check: func(opts *options.ClientOptions) bool {
+				return opts.TLSConfig != nil && opts.TLSConfig.RootCAs != nil && len(opts.TLSConfig.Certificates) == 1 && !opts.TLSConfig.InsecureSkipVerify
+			},
+		},
+		{
+			name:   "tls insecure",
+			config: config.MongoConfig{Host: "localhost", TLS: true, TLSInsecure: true},
+			check:  func(opts *options.ClientOptions) bool { return opts.TLSConfig.InsecureSkipVerify },
+		},
+		{
+			name:   "x509",
+			config: config.MongoConfig{Host: "localhost", TLS: true, TLSCertFile: certFile, TLSKeyFile: keyFile, AuthMechanism: MongoAuthX509},
+			check: func(opts *options.ClientOptions) bool {
+				return opts.Auth.AuthMechanism == MongoAuthX509 && opts.Auth.AuthSource == "$external" && opts.Auth.Username == ""
+			},
+		},
+		{
+			name:   "uri keeps its read preference",
+			config: config.MongoConfig{URI: "mongodb://a:27017,b:27017/?replicaSet=rs0&readPreference=secondary"},
+			check: func(opts *options.ClientOptions) bool {
+				return len(opts.Hosts) == 2 && *opts.ReplicaSet == "rs0" && opts.ReadPreference.Mode() == readpref.SecondaryMode
+			},
+		},
+		{
+			name:   "read preference",
+			config: config.MongoConfig{Host: "localhost", ReadPreference: "Nearest"},
+			check:  func(opts *options.ClientOptions) bool { return opts.ReadPreference.Mode() == readpref.NearestMode },
+		},
+		{name: "x509 without tls", config: config.MongoConfig{Host: "localhost", AuthMechanism: MongoAuthX509}, wantErr: true},
+		{name: "x509 with password", config: config.MongoConfig{Host: "localhost", TLS: true, TLSCertFile: certFile, TLSKeyFile: keyFile, AuthMechanism: MongoAuthX509, Password: "x"}, wantErr: true},
+		{name: "unknown mechanism", config: config.MongoConfig{Host: "localhost", Username: "app", AuthMechanism: "PLAIN"}, wantErr: true},
+		{name: "missing ca file", config: config.MongoConfig{Host: "localhost", TLS: true, TLSCAFile: "/nonexistent/ca.pem"}, wantErr: true},
+		{name: "key without cert", config: config.MongoConfig{Host: "localhost", TLS: true, TLSKeyFile: keyFile}, wantErr: true},
+		{name: "direct with multiple hosts", config: config.MongoConfig{URI: "mongodb://a:27017,b:27017", DirectConnection: true}, wantErr: true},
+		{name: "bad uri", config: config.MongoConfig{URI: "mysql://localhost"}, wantErr: true},
+		{name: "no host", config: config.MongoConfig{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		opts, err := mongoClientOptions(&tt.config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !tt.check(opts) {
+			t.Errorf("%s: unexpected options %+v", tt.name, opts)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-15 10:12:33
+ */
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 参数的修改方式
+const (
+	MongoParamApplySetParameter = "setParameter"                  // setParameter 命令
+	MongoParamApplyWiredTiger   = "wiredTigerEngineRuntimeConfig" // setParameter wiredTigerEngineRuntimeConfig, 值为 wiredTiger 配置字符串
+	MongoParamApplyProfiler     = "profile"                       // profile 命令, 对实例上所有库生效
+)
+
+// 参数值的类型
+const (
+	MongoParamInt    = "int"
+	MongoParamFloat  = "float"
+	MongoParamBool   = "bool"
+	MongoParamString = "string"
+)
+
+// MongoParamSpec 可以在线修改的参数定义
+type MongoParamSpec struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Apply       string   `json:"apply"`
+	Description string   `json:"description"`
+}
+
+// MongoParam 实例上参数的当前值, 低版本不支持的参数 Supported 为 false
+type MongoParam struct {
+	Name      string `json:"name"`
+	Apply     string `json:"apply"`
+	Value     any    `json:"value"`
+	Supported bool   `json:"supported"`
+	Error     string `json:"error,omitempty"`
+}
+
+// MongoParamDiff 实例参数与基线不一致的项
+type MongoParamDiff struct {
+	Param    string `json:"param"`
+	Expected any    `json:"expected"`
+	Actual   any    `json:"actual"`
+	Missing  bool   `json:"missing"` // 实例不支持该参数
+}
+
+func paramRange(min, max float64) (*float64, *float64) {
+	return &min, &max
+}
+
+// mongoParamCatalogue 参数目录, 只包含可以在线修改并且修改风险可控的参数
+var mongoParamCatalogue = func() map[string]MongoParamSpec {
+	specs := []MongoParamSpec{
+		{Name: "logLevel", Type: MongoParamInt, Apply: MongoParamApplySetParameter, Description: "日志级别"},
+		{Name: "cursorTimeoutMillis", Type: MongoParamInt, Apply: MongoParamApplySetParameter, Description: "空闲游标的超时时间, 单位: 毫秒"},
+		{Name: "notablescan", Type: MongoParamBool, Apply: MongoParamApplySetParameter, Description: "禁止全表扫描的查询"},
+		{Name: "ttlMonitorEnabled", Type: MongoParamBool, Apply: MongoParamApplySetParameter, Description: "是否执行 TTL 索引的过期删除"},
+		{Name: "transactionLifetimeLimitSeconds", Type: MongoParamInt, Apply: MongoParamApplySetParameter, Description: "事务的最长执行时间, 单位: 秒"},
+		{Name: "maxTransactionLockRequestTimeoutMillis", Type: MongoParamInt, Apply: MongoParamApplySetParameter, Description: "事务等待锁的超时时间, 单位: 毫秒, -1 为一直等待"},
+		{Name: "maxIndexBuildMemoryUsageMegabytes", Type: MongoParamInt, Apply: MongoParamApplySetParameter, Description: "创建索引使用的最大内存, 单位: MB"},
+		{Name: "internalQueryMaxBlockingSortMemoryUsageBytes", Type: MongoParamInt, Apply: MongoParamApplySetParameter, Description: "内存排序使用的最大内存, 单位: 字节, 4.4 开始支持"},
+		{Name: "wiredTigerConcurrentReadTransactions", Type: MongoParamInt, Apply: MongoParamApplySetParameter, Description: "wiredTiger 并发读事务数"},
+		{Name: "wiredTigerConcurrentWriteTransactions", Type: MongoParamInt, Apply: MongoParamApplySetParameter, Description: "wiredTiger 并发写事务数"},
+		{Name: "diagnosticDataCollectionEnabled", Type: MongoParamBool, Apply: MongoParamApplySetParameter, Description: "是否采集诊断数据(FTDC)"},
+		{Name: "cache_size", Type: MongoParamFloat, Apply: MongoParamApplyWiredTiger, Description: "wiredTiger 缓存大小, 单位: GB"},
+		{Name: "slowms", Type: MongoParamInt, Apply: MongoParamApplyProfiler, Description: "慢查询阈值, 单位: 毫秒"},
+		{Name: "sampleRate", Type: MongoParamFloat, Apply: MongoParamApplyProfiler, Description: "慢查询的采样比例"},
+	}
+
+	ranges := map[string][2]float64{
+		"logLevel":                                     {0, 5},
+		"cursorTimeoutMillis":                          {1000, math.MaxInt32},
+		"transactionLifetimeLimitSeconds":              {1, 3600},
+		"maxTransactionLockRequestTimeoutMillis":       {-1, 60000},
+		"maxIndexBuildMemoryUsageMegabytes":            {50, 1 << 20},
+		"internalQueryMaxBlockingSortMemoryUsageBytes": {1 << 20, 1 << 40},
+		"wiredTigerConcurrentReadTransactions":         {1, 1024},
+		"wiredTigerConcurrentWriteTransactions":        {1, 1024},
+		"cache_size":                                   {0.25, 10240},
+		"slowms":                                       {0, 3600000},
+		"sampleRate":                                   {0, 1},
+	}
+
+	catalogue := make(map[string]MongoParamSpec, len(specs))
+	for _, spec := range specs {
+		if r, ok := ranges[spec.Name]; ok {
+			spec.Min, spec.Max = paramRange(r[0], r[1])
+		}
+		catalogue[spec.Name] = spec
+	}
+	return catalogue
+}()
+
+// MongoParamCatalogue 返回按名称排序的参数目录
+func MongoParamCatalogue() []MongoParamSpec {
+	specs := make([]MongoParamSpec, 0, len(mongoParamCatalogue))
+	for _, spec := range mongoParamCatalogue {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// LookupMongoParam 在参数目录中查找参数
+func LookupMongoParam(name string) (MongoParamSpec, error) {
+	spec, ok := mongoParamCatalogue[name]
+	if !ok {
+		return spec, fmt.Errorf("参数: %s 不在参数目录中, 不支持修改", name)
+	}
+	return spec, nil
+}
+
+// Parse 把接口或配置文件传入的值转换为参数类型, 并校验取值范围
+func (s MongoParamSpec) Parse(value any) (any, error) {
+	v, err := s.convert(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n := v.(type) {
+	case int64:
+		err = s.checkRange(float64(n))
+	case float64:
+		err = s.checkRange(n)
+	case string:
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, n) {
+			err = fmt.Errorf("参数: %s 的值只能为 %v", s.Name, s.Enum)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s MongoParamSpec) checkRange(n float64) error {
+	if s.Min != nil && n < *s.Min {
+		return fmt.Errorf("参数: %s 的值不能小于 %v", s.Name, *s.Min)
+	}
+	if s.Max != nil && n > *s.Max {
+		return fmt.Errorf("参数: %s 的值不能大于 %v", s.Name, *s.Max)
+	}
+	return nil
+}
+
+// convert 只做类型转换, 不校验范围. 实例返回的数值可能是 int32, int64 或 double
+func (s MongoParamSpec) convert(value any) (any, error) {
+	invalid := fmt.Errorf("参数: %s 的值 %v 不是 %s 类型", s.Name, value, s.Type)
+
+	switch s.Type {
+	case MongoParamInt:
+		switch v := value.(type) {
+		case int:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, invalid
+			}
+			return int64(v), nil
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return nil, invalid
+			}
+			return n, nil
+		}
+	case MongoParamFloat:
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case int32:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, invalid
+			}
+			return n, nil
+		}
+	case MongoParamBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, invalid
+			}
+			return b, nil
+		}
+	case MongoParamString:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	}
+	return nil, invalid
+}
+
+// GetParameters 获取参数的当前值, names 为空时获取目录中的所有参数
+// 单个参数获取失败(如低版本不支持)不影响其他参数
+func (m *MongoDBClient) GetParameters(names []string) ([]MongoParam, error) {
+	if len(names) == 0 {
+		for _, spec := range MongoParamCatalogue() {
+			names = append(names, spec.Name)
+		}
+	}
+
+	params := make([]MongoParam, 0, len(names))
+	for _, name := range names {
+		spec, err := LookupMongoParam(name)
+		if err != nil {
+			return nil, err
+		}
+
+		param := MongoParam{Name: name, Apply: spec.Apply, Supported: true}
+		value, err := m.getParameter(spec)
+		if err == nil {
+			param.Value, err = spec.convert(value)
+		}
+		if err != nil {
+			param.Supported = false
+			param.Error = err.Error()
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+func (m *MongoDBClient) getParameter(spec MongoParamSpec) (any, error) {
+	switch spec.Apply {
+	case MongoParamApplySetParameter:
+		result, err := m.RunCommand("admin", bson.D{{Key: "getParameter", Value: 1}, {Key: spec.Name, Value: 1}})
+		if err != nil {
+			return nil, err
+		}
+		value, ok := result[spec.Name]
+		if !ok {
+			return nil, fmt.Errorf("实例不支持参数: %s", spec.Name)
+		}
+		return value, nil
+
+	case MongoParamApplyWiredTiger:
+		var status struct {
+			WiredTiger *struct {
+				Cache map[string]any `bson:"cache"`
+			} `bson:"wiredTiger"`
+		}
+		if err := m.runCommand("admin", bson.D{{Key: "serverStatus", Value: 1}}, &status); err != nil {
+			return nil, err
+		}
+		if status.WiredTiger == nil {
+			return nil, errors.New("实例没有使用 wiredTiger 存储引擎")
+		}
+		bytes, err := MongoParamSpec{Name: spec.Name, Type: MongoParamFloat}.convert(status.WiredTiger.Cache["maximum bytes configured"])
+		if err != nil {
+			return nil, err
+		}
+		return math.Round(bytes.(float64)/(1<<30)*100) / 100, nil
+
+	case MongoParamApplyProfiler:
+		var result bson.M
+		if err := m.runCommand("admin", bson.D{{Key: "profile", Value: -1}}, &result); err != nil {
+			return nil, err
+		}
+		value, ok := result[spec.Name]
+		if !ok {
+			return nil, fmt.Errorf("实例不支持参数: %s", spec.Name)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("参数: %s 的修改方式 %s 不正确", spec.Name, spec.Apply)
+}
+
+// SetParameter 校验并修改参数, 返回修改前后的值. 修改只在内存中生效, 重启后恢复为配置文件中的值
+func (m *MongoDBClient) SetParameter(name string, value any) (prev, next any, err error) {
+	spec, err := LookupMongoParam(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if next, err = spec.Parse(value); err != nil {
+		return nil, nil, err
+	}
+	if prev, err = m.getParameter(spec); err != nil {
+		return nil, nil, fmt.Errorf("获取参数: %s 失败: %v", name, err)
+	}
+	if prev, err = spec.convert(prev); err != nil {
+		return nil, nil, err
+	}
+
+	var cmd bson.D
+	dbname := "admin"
+	switch spec.Apply {
+	case MongoParamApplySetParameter:
+		cmd = bson.D{{Key: "setParameter", Value: 1}, {Key: name, Value: next}}
+	case MongoParamApplyWiredTiger:
+		conf := fmt.Sprintf("%s=%dM", name, int64(next.(float64)*1024))
+		cmd = bson.D{{Key: "setParameter", Value: 1}, {Key: "wiredTigerEngineRuntimeConfig", Value: conf}}
+	case MongoParamApplyProfiler:
+		// profile 命令需要带上 level, 使用 admin 库当前的 level, 只修改实例级别的 slowms 和 sampleRate
+		status, err := m.ProfilingLevel(dbname)
+		if err != nil {
+			return nil, nil, err
+		}
+		cmd = bson.D{{Key: "profile", Value: status.Was}, {Key: name, Value: next}}
+	}
+
+	if err = m.runCommand(dbname, cmd, &bson.M{}); err != nil {
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) {
+			return prev, next, fmt.Errorf("修改参数: %s 失败: %s", name, cmdErr.Message)
+		}
+		return prev, next, fmt.Errorf("修改参数: %s 失败: %v", name, err)
+	}
+	return prev, next, nil
+}
+
+// DiffMongoParams 对比实例参数与基线, 返回不一致的参数. 基线中的值按参数类型转换后比较
+func DiffMongoParams(actual []MongoParam, baseline map[string]string) ([]MongoParamDiff, error) {
+	values := make(map[string]MongoParam, len(actual))
+	for _, param := range actual {
+		values[param.Name] = param
+	}
+
+	diffs := []MongoParamDiff{}
+	for name, raw := range baseline {
+		spec, err := LookupMongoParam(name)
+		if err != nil {
+			return nil, fmt.Errorf("基线配置错误: %v", err)
+		}
+		expected, err := spec.convert(raw)
+		if err != nil {
+			return nil, fmt.Errorf("基线配置错误: %v", err)
+		}
+
+		param, ok := values[name]
+		if !ok || !param.Supported {
+			diffs = append(diffs, MongoParamDiff{Param: name, Expected: expected, Missing: true})
+			continue
+		}
+		if param.Value != expected {
+			diffs = append(diffs, MongoParamDiff{Param: name, Expected: expected, Actual: param.Value})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Param < diffs[j].Param })
+	return diffs, nil
+}
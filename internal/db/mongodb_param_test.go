@@ -0,0 +1,82 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-15 17:05:21
+ */
+
+package db
+
+import "testing"
+
+func TestMongoParamParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    any
+		wantErr bool
+	}{
+		{name: "logLevel", value: float64(2), want: int64(2)},
+		{name: "logLevel", value: "3", want: int64(3)},
+		{name: "logLevel", value: float64(1.5), wantErr: true},
+		{name: "logLevel", value: float64(6), wantErr: true},
+		{name: "logLevel", value: true, wantErr: true},
+		{name: "notablescan", value: true, want: true},
+		{name: "notablescan", value: "false", want: false},
+		{name: "notablescan", value: float64(1), wantErr: true},
+		{name: "cache_size", value: float64(0.5), want: 0.5},
+		{name: "cache_size", value: int32(8), want: float64(8)},
+		{name: "cache_size", value: float64(0.1), wantErr: true},
+		{name: "sampleRate", value: "1.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		spec, err := LookupMongoParam(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := spec.Parse(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s(%v): err = %v, wantErr %v", tt.name, tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s(%v): got %v(%T), want %v(%T)", tt.name, tt.value, got, got, tt.want, tt.want)
+		}
+	}
+
+	if _, err := LookupMongoParam("cache_size_gb"); err == nil {
+		t.Error("unknown param should return error")
+	}
+}
+
+func TestDiffMongoParams(t *testing.T) {
+	actual := []MongoParam{
+		{Name: "cache_size", Value: float64(8), Supported: true},
+		{Name: "slowms", Value: int64(200), Supported: true},
+		{Name: "notablescan", Value: false, Supported: true},
+		{Name: "internalQueryMaxBlockingSortMemoryUsageBytes", Supported: false},
+	}
+	baseline := map[string]string{
+		"cache_size":  "8",
+		"slowms":      "100",
+		"notablescan": "false",
+		"internalQueryMaxBlockingSortMemoryUsageBytes": "104857600",
+	}
+
+	diffs, err := DiffMongoParams(actual, baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("got %d diffs, want 2: %+v", len(diffs), diffs)
+	}
+	if diffs[0].Param != "internalQueryMaxBlockingSortMemoryUsageBytes" || !diffs[0].Missing {
+		t.Errorf("unexpected diff: %+v", diffs[0])
+	}
+	if diffs[1].Param != "slowms" || diffs[1].Expected != int64(100) || diffs[1].Actual != int64(200) {
+		t.Errorf("unexpected diff: %+v", diffs[1])
+	}
+
+	if _, err := DiffMongoParams(actual, map[string]string{"slowms": "fast"}); err == nil {
+		t.Error("invalid baseline value should return error")
+	}
+}
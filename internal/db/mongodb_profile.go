@@ -0,0 +1,173 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-11 10:05:48
+ */
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 查询模式中保留原值的字段, 这些字段的值是查询结构的一部分
+var queryShapeKeepValues = map[string]bool{
+	"sort":        true,
+	"$sort":       true,
+	"projection":  true,
+	"$project":    true,
+	"fields":      true,
+	"hint":        true,
+	"$collStats":  true,
+	"$indexStats": true,
+}
+
+// 查询模式中需要提取的命令字段, 其他字段(如 limit, batchSize, lsid) 与查询结构无关
+var queryShapeFields = map[string]bool{
+	"filter":     true,
+	"query":      true,
+	"q":          true,
+	"sort":       true,
+	"projection": true,
+	"pipeline":   true,
+	"key":        true,
+	"hint":       true,
+}
+
+// ProfilingLevel 获取库的 profiler 设置
+func (m *MongoDBClient) ProfilingLevel(dbname string) (status ProfileStatus, err error) {
+	if err = m.runCommand(dbname, bson.D{{Key: "profile", Value: -1}}, &status); err != nil {
+		return status, fmt.Errorf("获取库 %s 的 profiler 设置失败: %v", dbname, err)
+	}
+	return status, nil
+}
+
+// SetProfilingLevel 设置库的 profiler, 返回修改前的设置. level: 0 关闭, 1 只记录慢查询, 2 记录所有操作
+// 注意 slowms 是实例级别的参数, 修改后对实例上所有库生效; slowms <= 0 时不修改
+func (m *MongoDBClient) SetProfilingLevel(dbname string, level int, slowms int64) (prev ProfileStatus, err error) {
+	if level < 0 || level > 2 {
+		return prev, fmt.Errorf("profiler level 只能为 0, 1, 2")
+	}
+
+	cmd := bson.D{{Key: "profile", Value: level}}
+	if slowms > 0 {
+		cmd = append(cmd, bson.E{Key: "slowms", Value: slowms})
+	}
+	if err = m.runCommand(dbname, cmd, &prev); err != nil {
+		return prev, fmt.Errorf("设置库 %s 的 profiler 失败: %v", dbname, err)
+	}
+	return prev, nil
+}
+
+// ProfileEntries 按插入顺序读取 system.profile 中 ts >= since 并且没有读取过的记录, 最多 limit 条
+// system.profile 中的记录没有 _id, ts 只精确到毫秒, 同一个 ts 的多条记录没有稳定的顺序, 所以按 capped 集合的插入顺序($natural)读取,
+// 用 (ts, $recordId) 判断记录是否已经读取过, 见 ProfileEntryAfter
+func (m *MongoDBClient) ProfileEntries(dbname string, since time.Time, afterRecordID int64, limit int64) ([]ProfileEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	filter := bson.D{{Key: "ts", Value: bson.D{{Key: "$gte", Value: since}}}}
+	opts := options.Find().SetSort(bson.D{{Key: "$natural", Value: 1}}).SetShowRecordID(true)
+	cur, err := m.Conn.Database(dbname).Collection("system.profile").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s.system.profile 失败: %v", dbname, err)
+	}
+	defer cur.Close(ctx)
+
+	entries := []ProfileEntry{}
+	for int64(len(entries)) < limit && cur.Next(ctx) {
+		var entry ProfileEntry
+		if err := cur.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("读取 %s.system.profile 失败: %v", dbname, err)
+		}
+		if ProfileEntryAfter(entry, since, afterRecordID) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("读取 %s.system.profile 失败: %v", dbname, err)
+	}
+	return entries, nil
+}
+
+// ProfileEntryAfter 记录是否在水位 (since, afterRecordID) 之后, since 为已经读取的记录中最大的 ts, afterRecordID 为最后读取的记录的 $recordId
+// ts 大于 since 的记录一定没有读取过; ts 等于 since 的记录按插入顺序判断. 集合重建后 $recordId 从头开始, 靠 ts 继续采集
+func ProfileEntryAfter(entry ProfileEntry, since time.Time, afterRecordID int64) bool {
+	return entry.Ts.After(since) || entry.RecordID > afterRecordID
+}
+
+// QueryShape 归一化查询模式: 提取过滤条件, 排序, 投影, 聚合管道等字段, 把其中的值替换为 "?"
+// 排序和投影等字段保留原值; 只有标量的数组(如 $in 的参数)合并为 ["?"], 使不同长度的参数归为同一个模式
+func QueryShape(entry ProfileEntry) string {
+	cmd := entry.Command
+	if entry.Op == "getmore" && len(entry.OriginatingCommand) > 0 {
+		cmd = entry.OriginatingCommand
+	}
+	if len(cmd) == 0 {
+		cmd = entry.Query
+	}
+
+	shape := bson.D{}
+	if len(cmd) > 0 {
+		shape = append(shape, bson.E{Key: cmd[0].Key, Value: "?"})
+	}
+	for _, e := range cmd {
+		switch {
+		case queryShapeFields[e.Key]:
+			shape = append(shape, bson.E{Key: e.Key, Value: normalizeShapeValue(e.Key, e.Value)})
+		case e.Key == "updates" || e.Key == "deletes":
+			// 批量写命令只取第一个语句的条件
+			if stmts, ok := e.Value.(bson.A); ok && len(stmts) > 0 {
+				if stmt, ok := stmts[0].(bson.D); ok {
+					for _, se := range stmt {
+						if se.Key == "q" {
+							shape = append(shape, bson.E{Key: "q", Value: normalizeShapeValue("q", se.Value)})
+						}
+					}
+				}
+			}
+		}
+	}
+
+	b, err := bson.MarshalExtJSON(shape, false, false)
+	if err != nil {
+		return entry.Op
+	}
+	return entry.Op + " " + string(b)
+}
+
+func normalizeShapeValue(key string, value any) any {
+	if queryShapeKeepValues[key] {
+		return value
+	}
+
+	switch v := value.(type) {
+	case bson.D:
+		doc := make(bson.D, 0, len(v))
+		for _, e := range v {
+			doc = append(doc, bson.E{Key: e.Key, Value: normalizeShapeValue(e.Key, e.Value)})
+		}
+		return doc
+	case bson.A:
+		scalar := true
+		for _, item := range v {
+			switch item.(type) {
+			case bson.D, bson.A:
+				scalar = false
+			}
+		}
+		if scalar {
+			return bson.A{"?"}
+		}
+		arr := make(bson.A, 0, len(v))
+		for _, item := range v {
+			arr = append(arr, normalizeShapeValue("", item))
+		}
+		return arr
+	}
+	return "?"
+}
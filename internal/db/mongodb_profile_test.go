@@ -0,0 +1,69 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-11 16:40:05
+ */
+
+package db
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestQueryShape(t *testing.T) {
+	find := func(name string, age int, ids bson.A) ProfileEntry {
+		return ProfileEntry{Op: "query", Command: bson.D{
+			{Key: "find", Value: "users"},
+			{Key: "filter", Value: bson.D{{Key: "name", Value: name}, {Key: "age", Value: bson.D{{Key: "$gt", Value: age}}}, {Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}},
+			{Key: "sort", Value: bson.D{{Key: "age", Value: -1}}},
+			{Key: "limit", Value: 10},
+			{Key: "lsid", Value: bson.D{{Key: "id", Value: "x"}}},
+		}}
+	}
+
+	a := QueryShape(find("tom", 18, bson.A{1, 2, 3}))
+	b := QueryShape(find("jerry", 30, bson.A{4}))
+	if a != b {
+		t.Errorf("same query shape expected:\n%s\n%s", a, b)
+	}
+	want := `query {"find":"?","filter":{"name":"?","age":{"$gt":"?"},"_id":{"$in":["?"]}},"sort":{"age":-1}}`
+	if a != want {
+		t.Errorf("got %s, want %s", a, want)
+	}
+
+	// 过滤字段不同时是不同的查询模式
+	other := find("tom", 18, bson.A{1})
+	other.Command[1].Value = bson.D{{Key: "email", Value: "a@b.c"}}
+	if QueryShape(other) == a {
+		t.Errorf("different filter should have different shape")
+	}
+
+	// getmore 使用原始命令
+	getmore := ProfileEntry{Op: "getmore", Command: bson.D{{Key: "getMore", Value: int64(123)}}, OriginatingCommand: find("tom", 18, bson.A{1}).Command}
+	if got := QueryShape(getmore); got != "getmore"+a[len("query"):] {
+		t.Errorf("getmore shape: %s", got)
+	}
+
+	// 聚合管道中 $or 等文档数组逐个归一化
+	agg := ProfileEntry{Op: "command", Command: bson.D{
+		{Key: "aggregate", Value: "orders"},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{bson.D{{Key: "a", Value: 1}}, bson.D{{Key: "b", Value: "x"}}}}}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "ts", Value: 1}}}},
+		}},
+	}}
+	want = `command {"aggregate":"?","pipeline":[{"$match":{"$or":[{"a":"?"},{"b":"?"}]}},{"$sort":{"ts":1}}]}`
+	if got := QueryShape(agg); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	update := ProfileEntry{Op: "update", Command: bson.D{
+		{Key: "update", Value: "users"},
+		{Key: "updates", Value: bson.A{bson.D{{Key: "q", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "u", Value: bson.D{{Key: "$set", Value: bson.D{{Key: "x", Value: 1}}}}}}}},
+	}}
+	want = `update {"update":"?","q":{"_id":"?"}}`
+	if got := QueryShape(update); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
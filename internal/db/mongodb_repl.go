@@ -0,0 +1,278 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-30 10:16:48
+ */
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	ReplSetMaxMembers        = 50 // 副本集最多成员数
+	ReplSetMaxVotingMembers  = 7  // 副本集最多投票成员数
+	ReplSetConfigWaitTimeout = 60 * time.Second
+)
+
+// GetReplSetConfig 获取副本集配置
+func (m *MongoDBClient) GetReplSetConfig() (conf ReplSetConfig, err error) {
+	var result struct {
+		Config ReplSetConfig `bson:"config"`
+	}
+	cmd := bson.D{{Key: "replSetGetConfig", Value: 1}}
+	if err = m.runCommand("admin", cmd, &result); err != nil {
+		return conf, fmt.Errorf("执行rs.conf()失败: %v", err)
+	}
+	return result.Config, nil
+}
+
+// ReplSetReconfig 校验并提交新的副本集配置, 然后等待多数投票成员应用新配置
+// 4.4 开始每次非强制 reconfig 只允许增减一个投票成员, 所以每个操作只修改一个成员
+func (m *MongoDBClient) ReplSetReconfig(conf ReplSetConfig, allowEvenVoters bool) error {
+	if err := ValidateReplSetConfig(conf, allowEvenVoters); err != nil {
+		return err
+	}
+
+	conf.Version++
+	// term 由主节点维护, 提交时不需要带上
+	conf.Term = 0
+	cmd := bson.D{{Key: "replSetReconfig", Value: conf}}
+	if err := m.runCommand("admin", cmd, &bson.M{}); err != nil {
+		return fmt.Errorf("执行rs.reconfig()失败: %v", err)
+	}
+	return m.waitReplSetConfig(conf, ReplSetConfigWaitTimeout)
+}
+
+// waitReplSetConfig 等待多数投票成员的配置版本达到 conf.Version
+func (m *MongoDBClient) waitReplSetConfig(conf ReplSetConfig, timeout time.Duration) error {
+	voting := make(map[string]bool)
+	for _, member := range conf.Members {
+		if member.Votes > 0 {
+			voting[strings.ToLower(member.Host)] = true
+		}
+	}
+	majority := len(voting)/2 + 1
+
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := m.GetReplStatus()
+		if err == nil {
+			acked := 0
+			for _, member := range status.Members {
+				if voting[strings.ToLower(member.Name)] && member.Health == 1 && member.ConfigVersion >= conf.Version {
+					acked++
+				}
+			}
+			if acked >= majority {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("等待多数投票成员应用副本集配置 version %d 超时", conf.Version)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// ReplSetAddMember 增加成员, 成员 _id 自动分配
+func (m *MongoDBClient) ReplSetAddMember(member ReplSetMember, allowEvenVoters bool) (ReplSetConfig, error) {
+	conf, err := m.GetReplSetConfig()
+	if err != nil {
+		return conf, err
+	}
+
+	if member.Host == "" {
+		return conf, errors.New("成员地址不能为空")
+	}
+	if _, ok := findReplSetMember(conf, member.Host); ok {
+		return conf, fmt.Errorf("%s 已经是副本集成员", member.Host)
+	}
+
+	member.ID = 0
+	for _, mem := range conf.Members {
+		if mem.ID >= member.ID {
+			member.ID = mem.ID + 1
+		}
+	}
+	conf.Members = append(conf.Members, member)
+
+	if err := m.ReplSetReconfig(conf, allowEvenVoters); err != nil {
+		return conf, err
+	}
+	return m.GetReplSetConfig()
+}
+
+// ReplSetAddArbiter 增加仲裁节点
+func (m *MongoDBClient) ReplSetAddArbiter(host string, allowEvenVoters bool) (ReplSetConfig, error) {
+	return m.ReplSetAddMember(ReplSetMember{Host: host, ArbiterOnly: true, Priority: 0, Votes: 1}, allowEvenVoters)
+}
+
+// ReplSetRemoveMember 删除成员, 不允许删除主节点, 需要先 stepDown
+func (m *MongoDBClient) ReplSetRemoveMember(host string, allowEvenVoters bool) (ReplSetConfig, error) {
+	conf, err := m.GetReplSetConfig()
+	if err != nil {
+		return conf, err
+	}
+
+	index, ok := findReplSetMember(conf, host)
+	if !ok {
+		return conf, fmt.Errorf("%s 不是副本集成员", host)
+	}
+
+	hello, err := m.Hello()
+	if err != nil {
+		return conf, err
+	}
+	if strings.EqualFold(hello.Primary, conf.Members[index].Host) {
+		return conf, fmt.Errorf("%s 是主节点, 请先执行 stepDown", host)
+	}
+
+	conf.Members = append(conf.Members[:index], conf.Members[index+1:]...)
+	if err := m.ReplSetReconfig(conf, allowEvenVoters); err != nil {
+		return conf, err
+	}
+	return m.GetReplSetConfig()
+}
+
+// ReplSetUpdateMember 修改成员的 priority, votes, hidden, 延迟秒数
+func (m *MongoDBClient) ReplSetUpdateMember(host string, change ReplSetMemberChange, allowEvenVoters bool) (ReplSetConfig, error) {
+	conf, err := m.GetReplSetConfig()
+	if err != nil {
+		return conf, err
+	}
+
+	index, ok := findReplSetMember(conf, host)
+	if !ok {
+		return conf, fmt.Errorf("%s 不是副本集成员", host)
+	}
+
+	member := &conf.Members[index]
+	if member.ArbiterOnly {
+		return conf, fmt.Errorf("%s 是仲裁节点, 不支持修改属性", host)
+	}
+	if change.Priority != nil {
+		member.Priority = *change.Priority
+	}
+	if change.Votes != nil {
+		member.Votes = *change.Votes
+	}
+	if change.Hidden != nil {
+		member.Hidden = *change.Hidden
+	}
+	if change.SecondaryDelaySecs != nil {
+		member.SetDelay(*change.SecondaryDelaySecs)
+	}
+
+	if err := m.ReplSetReconfig(conf, allowEvenVoters); err != nil {
+		return conf, err
+	}
+	return m.GetReplSetConfig()
+}
+
+// ReplSetStepDown 主节点降级, stepDownSecs 秒内不能再次成为主节点, 等待从节点追平的时间为 catchUpSecs 秒
+func (m *MongoDBClient) ReplSetStepDown(stepDownSecs, catchUpSecs int) error {
+	if stepDownSecs <= 0 {
+		stepDownSecs = 60
+	}
+	if catchUpSecs <= 0 {
+		catchUpSecs = 10
+	}
+	if catchUpSecs >= stepDownSecs {
+		return fmt.Errorf("catchUpSecs(%d) 必须小于 stepDownSecs(%d)", catchUpSecs, stepDownSecs)
+	}
+
+	cmd := bson.D{{Key: "replSetStepDown", Value: stepDownSecs}, {Key: "secondaryCatchUpPeriodSecs", Value: catchUpSecs}}
+	err := m.runCommand("admin", cmd, &bson.M{})
+	// 4.2 之前 stepDown 会关闭所有连接, 连接断开说明已经降级成功
+	if err != nil && !mongo.IsNetworkError(err) {
+		return fmt.Errorf("执行rs.stepDown()失败: %v", err)
+	}
+	return nil
+}
+
+func findReplSetMember(conf ReplSetConfig, host string) (int, bool) {
+	for i, member := range conf.Members {
+		if strings.EqualFold(member.Host, host) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// ValidateReplSetConfig 校验副本集配置, 规则与 mongod 一致, 另外默认要求投票成员数为奇数
+func ValidateReplSetConfig(conf ReplSetConfig, allowEvenVoters bool) error {
+	if len(conf.Members) == 0 {
+		return errors.New("副本集至少需要一个成员")
+	}
+	if len(conf.Members) > ReplSetMaxMembers {
+		return fmt.Errorf("副本集成员数 %d 超过上限 %d", len(conf.Members), ReplSetMaxMembers)
+	}
+
+	ids := make(map[int]bool)
+	hosts := make(map[string]bool)
+	voters := 0
+	electable := 0
+	for _, member := range conf.Members {
+		if ids[member.ID] {
+			return fmt.Errorf("成员 _id %d 重复", member.ID)
+		}
+		ids[member.ID] = true
+
+		host := strings.ToLower(member.Host)
+		if host == "" {
+			return fmt.Errorf("成员 _id %d 的地址为空", member.ID)
+		}
+		if hosts[host] {
+			return fmt.Errorf("成员地址 %s 重复", member.Host)
+		}
+		hosts[host] = true
+
+		if member.Votes != 0 && member.Votes != 1 {
+			return fmt.Errorf("成员 %s 的 votes 只能为 0 或 1", member.Host)
+		}
+		if member.Priority < 0 || member.Priority > 1000 {
+			return fmt.Errorf("成员 %s 的 priority 必须在 0 到 1000 之间", member.Host)
+		}
+		if member.Delay() < 0 {
+			return fmt.Errorf("成员 %s 的延迟秒数不能为负数", member.Host)
+		}
+		if member.ArbiterOnly && (member.Priority != 0 || member.Hidden || member.Delay() != 0) {
+			return fmt.Errorf("仲裁节点 %s 的 priority 必须为 0, 并且不能是隐藏或延迟节点", member.Host)
+		}
+		if (member.Hidden || member.Delay() > 0) && member.Priority != 0 {
+			return fmt.Errorf("隐藏或延迟节点 %s 的 priority 必须为 0", member.Host)
+		}
+		if member.Votes == 0 && member.Priority != 0 {
+			return fmt.Errorf("不投票的成员 %s 的 priority 必须为 0", member.Host)
+		}
+
+		if member.Votes > 0 {
+			voters++
+		}
+		if member.Priority > 0 {
+			electable++
+		}
+	}
+
+	if voters == 0 {
+		return errors.New("副本集至少需要一个投票成员")
+	}
+	if voters > ReplSetMaxVotingMembers {
+		return fmt.Errorf("投票成员数 %d 超过上限 %d", voters, ReplSetMaxVotingMembers)
+	}
+	if voters%2 == 0 && !allowEvenVoters {
+		return fmt.Errorf("投票成员数为 %d, 偶数个投票成员不能提高可用性, 建议增加仲裁节点或将一个成员设置为不投票", voters)
+	}
+	if electable == 0 {
+		return errors.New("副本集至少需要一个 priority 大于 0 的成员")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-30 16:21:45
+ */
+
+package db
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidateReplSetConfig(t *testing.T) {
+	member := func(id int, host string, priority float64, votes int) ReplSetMember {
+		return ReplSetMember{ID: id, Host: host, Priority: priority, Votes: votes}
+	}
+	three := []ReplSetMember{member(0, "a:27017", 1, 1), member(1, "b:27017", 1, 1), member(2, "c:27017", 1, 1)}
+
+	tests := []struct {
+		name      string
+		members   []ReplSetMember
+		allowEven bool
+		wantErr   bool
+	}{
+		{name: "three voters", members: three},
+		{name: "even voters", members: append(append([]ReplSetMember{}, three...), member(3, "d:27017", 1, 1)), wantErr: true},
+		{name: "even voters allowed", members: append(append([]ReplSetMember{}, three...), member(3, "d:27017", 1, 1)), allowEven: true},
+		{name: "non voting member", members: append(append([]ReplSetMember{}, three...), member(3, "d:27017", 0, 0))},
+		{name: "non voting with priority", members: append(append([]ReplSetMember{}, three...), member(3, "d:27017", 1, 0)), wantErr: true},
+		{name: "duplicate host", members: []ReplSetMember{member(0, "a:27017", 1, 1), member(1, "A:27017", 1, 1), member(2, "c:27017", 1, 1)}, wantErr: true},
+		{name: "duplicate id", members: []ReplSetMember{member(0, "a:27017", 1, 1), member(0, "b:27017", 1, 1), member(2, "c:27017", 1, 1)}, wantErr: true},
+		{name: "hidden with priority", members: []ReplSetMember{member(0, "a:27017", 1, 1), member(1, "b:27017", 1, 1), {ID: 2, Host: "c:27017", Priority: 1, Votes: 1, Hidden: true}}, wantErr: true},
+		{name: "arbiter", members: []ReplSetMember{member(0, "a:27017", 1, 1), member(1, "b:27017", 1, 1), {ID: 2, Host: "c:27017", Votes: 1, ArbiterOnly: true}}},
+		{name: "arbiter with priority", members: []ReplSetMember{member(0, "a:27017", 1, 1), member(1, "b:27017", 1, 1), {ID: 2, Host: "c:27017", Priority: 1, Votes: 1, ArbiterOnly: true}}, wantErr: true},
+		{name: "no electable", members: []ReplSetMember{member(0, "a:27017", 0, 1)}, wantErr: true},
+		{name: "bad votes", members: []ReplSetMember{member(0, "a:27017", 1, 2)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateReplSetConfig(ReplSetConfig{ID: "rs0", Members: tt.members}, tt.allowEven)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// 重新配置时, 结构体中没有定义的字段需要原样写回
+func TestReplSetConfigKeepUnknownFields(t *testing.T) {
+	raw := bson.D{
+		{Key: "_id", Value: "rs0"},
+		{Key: "version", Value: int32(3)},
+		{Key: "writeConcernMajorityJournalDefault", Value: true},
+		{Key: "members", Value: bson.A{
+			bson.D{{Key: "_id", Value: int32(0)}, {Key: "host", Value: "a:27017"}, {Key: "priority", Value: int32(1)}, {Key: "votes", Value: int32(1)}, {Key: "slaveDelay", Value: int64(0)}, {Key: "futureField", Value: true}},
+		}},
+	}
+	b, err := bson.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var conf ReplSetConfig
+	if err := bson.Unmarshal(b, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Members[0].Priority != 1 || conf.Version != 3 {
+		t.Fatalf("decode config wrong: %+v", conf)
+	}
+
+	conf.Members[0].SetDelay(3600)
+	if conf.Members[0].SlaveDelay == nil || *conf.Members[0].SlaveDelay != 3600 || conf.Members[0].SecondaryDelaySecs != nil {
+		t.Errorf("SetDelay should keep slaveDelay field: %+v", conf.Members[0])
+	}
+
+	b, err = bson.Marshal(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bson.M
+	if err := bson.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["writeConcernMajorityJournalDefault"] != true {
+		t.Errorf("unknown config field lost: %v", out)
+	}
+	m := out["members"].(bson.A)[0].(bson.M)
+	if m["futureField"] != true {
+		t.Errorf("unknown member field lost: %v", m)
+	}
+}
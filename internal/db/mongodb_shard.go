@@ -0,0 +1,286 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-17 10:21:44
+ */
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 均衡器时间窗口的格式
+var balancerWindowRegexp = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// 单次最多返回的 jumbo chunk 数
+const maxJumboChunks = 1000
+
+// ShardOverview 分片集群概况: 分片列表, 均衡器状态, 各集合的 chunk 分布, 正在进行的迁移. 需要连接 mongos
+func (m *MongoDBClient) ShardOverview() (overview ShardOverview, err error) {
+	if overview.Shards, err = m.ShardingList(); err != nil {
+		return overview, err
+	}
+	if overview.Balancer, err = m.BalancerStatus(); err != nil {
+		return overview, err
+	}
+	if overview.Collections, err = m.ChunkDistributions(""); err != nil {
+		return overview, err
+	}
+	if overview.Migrations, err = m.Migrations(); err != nil {
+		return overview, err
+	}
+	return overview, nil
+}
+
+// ChunkDistributions 统计分片集合的 chunk 在各分片上的分布, ns 为空时统计所有分片集合
+// 没有 chunk 的分片也会列出, 便于发现新加入还没有数据的分片
+func (m *MongoDBClient) ChunkDistributions(ns string) ([]ChunkDistribution, error) {
+	shards, err := m.ShardingList()
+	if err != nil {
+		return nil, err
+	}
+
+	cols, err := m.GetShardCollectionList()
+	if err != nil {
+		return nil, fmt.Errorf("读取 config.collections 失败: %v", err)
+	}
+
+	// 5.0 之前 chunk 通过 ns 关联集合, 5.0 开始通过 uuid 关联
+	dists := make(map[string]*ChunkDistribution)
+	byUUID := make(map[string]string)
+	uuids := bson.A{}
+	for _, col := range cols {
+		if ns != "" && col.Id != ns {
+			continue
+		}
+		dist := &ChunkDistribution{Namespace: col.Id, Key: col.Key, NoBalance: col.NoBalance, Shards: []ShardChunks{}}
+		for _, shard := range shards {
+			dist.Shards = append(dist.Shards, ShardChunks{Shard: shard.ID})
+		}
+		dists[col.Id] = dist
+		if col.UUID != nil {
+			byUUID[string(col.UUID.Data)] = col.Id
+			uuids = append(uuids, *col.UUID)
+		}
+	}
+	if ns != "" && len(dists) == 0 {
+		return nil, fmt.Errorf("集合 %s 没有分片", ns)
+	}
+
+	var match bson.D
+	if ns != "" {
+		match = bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "ns", Value: ns}},
+			bson.D{{Key: "uuid", Value: bson.D{{Key: "$in", Value: uuids}}}},
+		}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "ns", Value: "$ns"}, {Key: "uuid", Value: "$uuid"}, {Key: "shard", Value: "$shard"}}},
+			{Key: "chunks", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "jumbo", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{"$jumbo", true}}}, 1, 0}}}}}},
+		}}},
+	}
+	if match == nil {
+		pipeline = pipeline[1:]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+	cur, err := m.Conn.Database("config").Collection("chunks").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("统计 config.chunks 失败: %v", err)
+	}
+
+	var groups []struct {
+		ID struct {
+			Ns    string            `bson:"ns"`
+			UUID  *primitive.Binary `bson:"uuid"`
+			Shard string            `bson:"shard"`
+		} `bson:"_id"`
+		Chunks int64 `bson:"chunks"`
+		Jumbo  int64 `bson:"jumbo"`
+	}
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, fmt.Errorf("统计 config.chunks 失败: %v", err)
+	}
+
+	for _, g := range groups {
+		name := g.ID.Ns
+		if g.ID.UUID != nil {
+			name = byUUID[string(g.ID.UUID.Data)]
+		}
+		dist, ok := dists[name]
+		if !ok {
+			continue
+		}
+		dist.addShardChunks(g.ID.Shard, g.Chunks, g.Jumbo)
+	}
+
+	result := make([]ChunkDistribution, 0, len(dists))
+	for _, dist := range dists {
+		dist.summarize()
+		result = append(result, *dist)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Namespace < result[j].Namespace })
+	return result, nil
+}
+
+func (d *ChunkDistribution) addShardChunks(shard string, chunks, jumbo int64) {
+	for i := range d.Shards {
+		if d.Shards[i].Shard == shard {
+			d.Shards[i].Chunks += chunks
+			d.Shards[i].Jumbo += jumbo
+			return
+		}
+	}
+	// chunk 所在的分片不在 listShards 中, 一般是分片正在删除
+	d.Shards = append(d.Shards, ShardChunks{Shard: shard, Chunks: chunks, Jumbo: jumbo})
+}
+
+// summarize 计算总数和不均衡程度, 分片按名称排序
+func (d *ChunkDistribution) summarize() {
+	sort.Slice(d.Shards, func(i, j int) bool { return d.Shards[i].Shard < d.Shards[j].Shard })
+
+	d.Total, d.Jumbo, d.Imbalance = 0, 0, 0
+	if len(d.Shards) == 0 {
+		return
+	}
+	least, most := d.Shards[0].Chunks, d.Shards[0].Chunks
+	for _, s := range d.Shards {
+		d.Total += s.Chunks
+		d.Jumbo += s.Jumbo
+		least = min(least, s.Chunks)
+		most = max(most, s.Chunks)
+	}
+	d.Imbalance = most - least
+}
+
+// JumboChunks 获取集合中被标记为 jumbo 的 chunk, 最多返回 1000 个
+func (m *MongoDBClient) JumboChunks(ns string) ([]Chunk, error) {
+	or := bson.A{bson.D{{Key: "ns", Value: ns}}}
+	cols, err := m.GetShardCollectionList()
+	if err != nil {
+		return nil, err
+	}
+	for _, col := range cols {
+		if col.Id == ns && col.UUID != nil {
+			or = append(or, bson.D{{Key: "uuid", Value: *col.UUID}})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	filter := bson.D{{Key: "jumbo", Value: true}, {Key: "$or", Value: or}}
+	opts := options.Find().SetSort(bson.D{{Key: "min", Value: 1}}).SetLimit(maxJumboChunks)
+	cur, err := m.Conn.Database("config").Collection("chunks").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("读取 config.chunks 失败: %v", err)
+	}
+
+	chunks := []Chunk{}
+	if err := cur.All(ctx, &chunks); err != nil {
+		return nil, fmt.Errorf("读取 config.chunks 失败: %v", err)
+	}
+	for i := range chunks {
+		chunks[i].Ns = ns
+	}
+	return chunks, nil
+}
+
+// BalancerStatus 获取均衡器状态和运行时间窗口
+func (m *MongoDBClient) BalancerStatus() (status BalancerStatus, err error) {
+	if err = m.runCommand("admin", bson.D{{Key: "balancerStatus", Value: 1}}, &status); err != nil {
+		return status, fmt.Errorf("执行sh.getBalancerState()失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	var settings struct {
+		ActiveWindow *BalancerWindow `bson:"activeWindow"`
+	}
+	err = m.Conn.Database("config").Collection("settings").FindOne(ctx, bson.D{{Key: "_id", Value: "balancer"}}).Decode(&settings)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return status, fmt.Errorf("读取 config.settings 失败: %v", err)
+	}
+	status.Window = settings.ActiveWindow
+	return status, nil
+}
+
+// BalancerStart 开启均衡器
+func (m *MongoDBClient) BalancerStart() error {
+	if err := m.runCommand("admin", bson.D{{Key: "balancerStart", Value: 1}}, &bson.M{}); err != nil {
+		return fmt.Errorf("执行sh.startBalancer()失败: %v", err)
+	}
+	return nil
+}
+
+// BalancerStop 关闭均衡器, 会等待正在进行的一轮均衡结束
+func (m *MongoDBClient) BalancerStop() error {
+	if err := m.runCommand("admin", bson.D{{Key: "balancerStop", Value: 1}}, &bson.M{}); err != nil {
+		return fmt.Errorf("执行sh.stopBalancer()失败: %v", err)
+	}
+	return nil
+}
+
+// SetBalancerWindow 设置均衡器的运行时间窗口, window 为空时删除时间窗口
+func (m *MongoDBClient) SetBalancerWindow(window *BalancerWindow) error {
+	update := bson.D{{Key: "$unset", Value: bson.D{{Key: "activeWindow", Value: true}}}}
+	if window != nil {
+		if err := ValidateBalancerWindow(*window); err != nil {
+			return err
+		}
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "activeWindow", Value: window}}}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	opts := options.Update().SetUpsert(true)
+	if _, err := m.Conn.Database("config").Collection("settings").UpdateOne(ctx, bson.D{{Key: "_id", Value: "balancer"}}, update, opts); err != nil {
+		return fmt.Errorf("设置均衡器时间窗口失败: %v", err)
+	}
+	return nil
+}
+
+// ValidateBalancerWindow 校验时间窗口格式, 开始和结束时间不能相同, 结束时间小于开始时间表示跨天
+func ValidateBalancerWindow(window BalancerWindow) error {
+	if !balancerWindowRegexp.MatchString(window.Start) || !balancerWindowRegexp.MatchString(window.Stop) {
+		return fmt.Errorf("时间窗口 %s - %s 格式不正确, 格式为 HH:MM", window.Start, window.Stop)
+	}
+	if window.Start == window.Stop {
+		return errors.New("时间窗口的开始和结束时间不能相同")
+	}
+	return nil
+}
+
+// Migrations 获取正在进行的 chunk 迁移
+func (m *MongoDBClient) Migrations() ([]Migration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	cur, err := m.Conn.Database("config").Collection("migrations").Find(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("读取 config.migrations 失败: %v", err)
+	}
+
+	migrations := []Migration{}
+	if err := cur.All(ctx, &migrations); err != nil {
+		return nil, fmt.Errorf("读取 config.migrations 失败: %v", err)
+	}
+	return migrations, nil
+}
@@ -0,0 +1,46 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-17 17:31:09
+ */
+
+package db
+
+import "testing"
+
+func TestChunkDistributionSummarize(t *testing.T) {
+	dist := ChunkDistribution{Shards: []ShardChunks{{Shard: "shard02"}, {Shard: "shard01"}, {Shard: "shard03"}}}
+	dist.addShardChunks("shard01", 10, 1)
+	dist.addShardChunks("shard02", 4, 0)
+	dist.addShardChunks("shard01", 2, 0)
+	dist.addShardChunks("shard04", 3, 2) // 正在删除的分片
+	dist.summarize()
+
+	if dist.Total != 19 || dist.Jumbo != 3 || dist.Imbalance != 12 {
+		t.Errorf("unexpected summary: total %d, jumbo %d, imbalance %d", dist.Total, dist.Jumbo, dist.Imbalance)
+	}
+	want := []ShardChunks{{"shard01", 12, 1}, {"shard02", 4, 0}, {"shard03", 0, 0}, {"shard04", 3, 2}}
+	for i, s := range want {
+		if dist.Shards[i] != s {
+			t.Errorf("shard %d: got %+v, want %+v", i, dist.Shards[i], s)
+		}
+	}
+}
+
+func TestValidateBalancerWindow(t *testing.T) {
+	tests := []struct {
+		window  BalancerWindow
+		wantErr bool
+	}{
+		{window: BalancerWindow{Start: "23:00", Stop: "06:00"}},
+		{window: BalancerWindow{Start: "01:30", Stop: "05:00"}},
+		{window: BalancerWindow{Start: "1:30", Stop: "05:00"}, wantErr: true},
+		{window: BalancerWindow{Start: "24:00", Stop: "05:00"}, wantErr: true},
+		{window: BalancerWindow{Start: "05:00", Stop: "05:00"}, wantErr: true},
+		{window: BalancerWindow{Start: "05:00"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := ValidateBalancerWindow(tt.window); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: err = %v, wantErr %v", tt.window, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-19 10:40:17
+ */
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	DefaultStatsPageSize = 20
+	MaxStatsPageSize     = 100
+)
+
+// CollectionStatsPage 分页获取集合的统计信息和索引使用情况, dbname 为空时统计所有非系统库
+// 只对当前页的集合执行统计, 单个集合失败时错误记录在该集合的 Error 中
+func (m *MongoDBClient) CollectionStatsPage(dbname string, page, pageSize int) (CollectionStatsPage, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultStatsPageSize
+	}
+	pageSize = min(pageSize, MaxStatsPageSize)
+	result := CollectionStatsPage{Page: page, PageSize: pageSize, Items: []CollectionStats{}}
+
+	dbs := []string{dbname}
+	if dbname == "" {
+		names, err := m.GetDBList()
+		if err != nil {
+			return result, err
+		}
+		dbs = dbs[:0]
+		for _, name := range names {
+			if name != "admin" && name != "local" && name != "config" {
+				dbs = append(dbs, name)
+			}
+		}
+	}
+
+	namespaces := []string{}
+	for _, db := range dbs {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+		cols, err := m.Conn.Database(db).ListCollectionNames(ctx, bson.D{{Key: "type", Value: "collection"}})
+		cancel()
+		if err != nil {
+			return result, fmt.Errorf("从库: %s 中获取集合列表失败: %v", db, err)
+		}
+		for _, col := range cols {
+			if !strings.HasPrefix(col, "system.") {
+				namespaces = append(namespaces, db+"."+col)
+			}
+		}
+	}
+	sort.Strings(namespaces)
+	result.Total = len(namespaces)
+
+	start := (page - 1) * pageSize
+	if start >= len(namespaces) {
+		return result, nil
+	}
+	end := min(start+pageSize, len(namespaces))
+
+	hello, err := m.Hello()
+	if err != nil {
+		return result, err
+	}
+	for _, ns := range namespaces[start:end] {
+		db, col, _ := strings.Cut(ns, ".")
+		stats, err := m.collectionStats(db, col, hello.IsMongos())
+		if err != nil {
+			stats = CollectionStats{Namespace: ns, Indexes: []IndexUsage{}, Error: err.Error()}
+		}
+		result.Items = append(result.Items, stats)
+	}
+	return result, nil
+}
+
+// CollectionStats 获取集合的统计信息和索引使用情况
+func (m *MongoDBClient) CollectionStats(dbname, colName string) (CollectionStats, error) {
+	hello, err := m.Hello()
+	if err != nil {
+		return CollectionStats{}, err
+	}
+	return m.collectionStats(dbname, colName, hello.IsMongos())
+}
+
+func (m *MongoDBClient) collectionStats(dbname, colName string, mongos bool) (CollectionStats, error) {
+	stats := CollectionStats{Namespace: dbname + "." + colName, Indexes: []IndexUsage{}}
+
+	sizes, err := m.storageStats(dbname, colName, &stats)
+	if err != nil {
+		return stats, err
+	}
+
+	var shardKey bson.D
+	if mongos {
+		sharded, err := m.ShardedCollection(dbname, colName)
+		if err != nil {
+			return stats, err
+		}
+		if sharded != nil {
+			stats.Sharded = true
+			shardKey = sharded.Key
+		}
+	}
+
+	if stats.Indexes, err = m.indexUsages(dbname, colName); err != nil {
+		return stats, err
+	}
+	for i := range stats.Indexes {
+		stats.Indexes[i].Size = sizes[stats.Indexes[i].Name]
+	}
+	AnalyzeIndexes(stats.Indexes, shardKey)
+	return stats, nil
+}
+
+// storageStats 通过 $collStats 获取集合大小, 分片集合每个分片返回一条记录, 累加得到合计. 返回 索引名 -> 索引大小
+func (m *MongoDBClient) storageStats(dbname, colName string, stats *CollectionStats) (map[string]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	pipeline := bson.A{bson.D{{Key: "$collStats", Value: bson.D{{Key: "storageStats", Value: bson.D{}}}}}}
+	cur, err := m.Conn.Database(dbname).Collection(colName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("获取集合 %s 的统计信息失败: %v", stats.Namespace, err)
+	}
+
+	var docs []struct {
+		StorageStats struct {
+			Count          int64            `bson:"count"`
+			Size           int64            `bson:"size"`
+			StorageSize    int64            `bson:"storageSize"`
+			TotalIndexSize int64            `bson:"totalIndexSize"`
+			Capped         bool             `bson:"capped"`
+			IndexSizes     map[string]int64 `bson:"indexSizes"`
+		} `bson:"storageStats"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("获取集合 %s 的统计信息失败: %v", stats.Namespace, err)
+	}
+
+	sizes := make(map[string]int64)
+	for _, doc := range docs {
+		s := doc.StorageStats
+		stats.Count += s.Count
+		stats.Size += s.Size
+		stats.StorageSize += s.StorageSize
+		stats.TotalIndexSize += s.TotalIndexSize
+		stats.Capped = stats.Capped || s.Capped
+		for name, size := range s.IndexSizes {
+			sizes[name] += size
+		}
+	}
+	if stats.Count > 0 {
+		stats.AvgObjSize = stats.Size / stats.Count
+	}
+	return sizes, nil
+}
+
+// indexUsages 通过 listIndexes 获取索引定义, 通过 $indexStats 获取访问次数. 分片集合的访问次数为各分片的合计
+func (m *MongoDBClient) indexUsages(dbname, colName string) ([]IndexUsage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.ExecWaitTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	coll := m.Conn.Database(dbname).Collection(colName)
+	cur, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取集合 %s.%s 的索引失败: %v", dbname, colName, err)
+	}
+	var specs []struct {
+		Name               string   `bson:"name"`
+		Key                bson.D   `bson:"key"`
+		Unique             bool     `bson:"unique"`
+		Sparse             bool     `bson:"sparse"`
+		Partial            bson.D   `bson:"partialFilterExpression"`
+		ExpireAfterSeconds *float64 `bson:"expireAfterSeconds"`
+		Collation          bson.D   `bson:"collation"`
+	}
+	if err := cur.All(ctx, &specs); err != nil {
+		return nil, fmt.Errorf("获取集合 %s.%s 的索引失败: %v", dbname, colName, err)
+	}
+
+	cur, err = coll.Aggregate(ctx, bson.A{bson.D{{Key: "$indexStats", Value: bson.D{}}}})
+	if err != nil {
+		return nil, fmt.Errorf("获取集合 %s.%s 的索引使用情况失败: %v", dbname, colName, err)
+	}
+	var accesses []struct {
+		Name     string `bson:"name"`
+		Accesses struct {
+			Ops   int64     `bson:"ops"`
+			Since time.Time `bson:"since"`
+		} `bson:"accesses"`
+	}
+	if err := cur.All(ctx, &accesses); err != nil {
+		return nil, fmt.Errorf("获取集合 %s.%s 的索引使用情况失败: %v", dbname, colName, err)
+	}
+
+	indexes := make([]IndexUsage, 0, len(specs))
+	for _, spec := range specs {
+		index := IndexUsage{
+			Name:      spec.Name,
+			Key:       spec.Key,
+			Unique:    spec.Unique,
+			Sparse:    spec.Sparse,
+			Partial:   len(spec.Partial) > 0,
+			TTL:       spec.ExpireAfterSeconds != nil,
+			Collation: spec.Collation,
+		}
+		for _, access := range accesses {
+			if access.Name != spec.Name {
+				continue
+			}
+			index.Ops += access.Accesses.Ops
+			if access.Accesses.Since.After(index.Since) {
+				index.Since = access.Accesses.Since
+			}
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// AnalyzeIndexes 标记没有使用的索引和冗余索引
+// 冗余索引: 索引字段和方向是另一个普通(非 sparse, 非 partial)索引的前缀, 并且 collation 相同; 唯一和 TTL 索引有约束作用, 不标记
+// 片键索引不标记为没有使用, 删除后无法执行分片相关的操作
+func AnalyzeIndexes(indexes []IndexUsage, shardKey bson.D) {
+	for i := range indexes {
+		a := &indexes[i]
+		a.Unused, a.RedundantWith = false, ""
+		if a.Name == "_id_" || a.Unique || a.TTL {
+			continue
+		}
+
+		a.Unused = a.Ops == 0 && !(len(shardKey) > 0 && keyHasPrefix(a.Key, shardKey))
+
+		if !plainIndexKey(a.Key) {
+			continue
+		}
+		var best *IndexUsage
+		for j := range indexes {
+			b := &indexes[j]
+			if i == j || b.Sparse || b.Partial || len(b.Key) <= len(a.Key) || !plainIndexKey(b.Key) {
+				continue
+			}
+			if !reflect.DeepEqual(a.Collation, b.Collation) || !keyHasPrefix(b.Key, a.Key) {
+				continue
+			}
+			if best == nil || len(b.Key) < len(best.Key) || (len(b.Key) == len(best.Key) && b.Name < best.Name) {
+				best = b
+			}
+		}
+		if best != nil {
+			a.RedundantWith = best.Name
+		}
+	}
+}
+
+// keyHasPrefix 判断索引字段 key 是否以 prefix 开头. prefix 只有一个字段时不区分方向, 索引可以反向遍历
+func keyHasPrefix(key, prefix bson.D) bool {
+	if len(prefix) > len(key) {
+		return false
+	}
+	for i, e := range prefix {
+		if key[i].Key != e.Key {
+			return false
+		}
+		kd, pd := indexDirection(key[i].Value), indexDirection(e.Value)
+		switch {
+		case kd == 0 || pd == 0:
+			// hashed 等特殊索引的类型必须相同
+			if key[i].Value != e.Value {
+				return false
+			}
+		case len(prefix) > 1 && kd != pd:
+			return false
+		}
+	}
+	return true
+}
+
+// plainIndexKey 是否为普通的升序/降序索引, hashed, text, 2dsphere 等特殊索引不参与冗余检查
+func plainIndexKey(key bson.D) bool {
+	for _, e := range key {
+		if indexDirection(e.Value) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// indexDirection 索引字段的方向, 1 升序, -1 降序, 0 特殊索引
+func indexDirection(value any) int {
+	var n float64
+	switch v := value.(type) {
+	case int32:
+		n = float64(v)
+	case int64:
+		n = float64(v)
+	case int:
+		n = float64(v)
+	case float64:
+		n = v
+	default:
+		return 0
+	}
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
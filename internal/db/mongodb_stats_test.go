@@ -0,0 +1,69 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-19 17:14:26
+ */
+
+package db
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAnalyzeIndexes(t *testing.T) {
+	key := func(fields ...any) bson.D {
+		d := bson.D{}
+		for i := 0; i < len(fields); i += 2 {
+			d = append(d, bson.E{Key: fields[i].(string), Value: fields[i+1]})
+		}
+		return d
+	}
+
+	indexes := []IndexUsage{
+		{Name: "_id_", Key: key("_id", int32(1))},
+		{Name: "a_1", Key: key("a", int32(1)), Ops: 10},
+		{Name: "a_-1", Key: key("a", int32(-1)), Ops: 3},
+		{Name: "a_1_b_1", Key: key("a", int32(1), "b", int32(1)), Ops: 5},
+		{Name: "a_1_b_-1_c_1", Key: key("a", int32(1), "b", int32(-1), "c", int32(1)), Ops: 5},
+		{Name: "a_1_b_1_c_1", Key: key("a", int32(1), "b", int32(1), "c", int32(1)), Ops: 0},
+		{Name: "u_1", Key: key("u", int32(1)), Unique: true},
+		{Name: "u_1_v_1", Key: key("u", int32(1), "v", int32(1)), Ops: 1},
+		{Name: "ts_1", Key: key("ts", int32(1)), TTL: true},
+		{Name: "x_1", Key: key("x", int32(1)), Ops: 2},
+		{Name: "x_1_y_1", Key: key("x", int32(1), "y", int32(1)), Partial: true},
+		{Name: "s_hashed", Key: key("s", "hashed")},
+		{Name: "s_1_t_1", Key: key("s", int32(1), "t", int32(1)), Ops: 1},
+		{Name: "k_1", Key: key("k", int32(1))},
+		{Name: "k_1_ci", Key: key("k", int32(1), "z", int32(1)), Collation: bson.D{{Key: "locale", Value: "en"}}},
+	}
+	AnalyzeIndexes(indexes, key("s", "hashed"))
+
+	want := map[string]struct {
+		unused    bool
+		redundant string
+	}{
+		"_id_":         {},
+		"a_1":          {redundant: "a_1_b_1"}, // 多个候选时取字段最少的
+		"a_-1":         {redundant: "a_1_b_1"}, // 单字段不区分方向
+		"a_1_b_1":      {redundant: "a_1_b_1_c_1"},
+		"a_1_b_-1_c_1": {},
+		"a_1_b_1_c_1":  {unused: true},
+		"u_1":          {}, // 唯一索引有约束作用
+		"u_1_v_1":      {},
+		"ts_1":         {},
+		"x_1":          {}, // 更长的索引是 partial 索引
+		"x_1_y_1":      {unused: true},
+		"s_hashed":     {}, // 片键索引
+		"s_1_t_1":      {},
+		"k_1":          {unused: true}, // collation 不同
+		"k_1_ci":       {unused: true},
+	}
+
+	for _, index := range indexes {
+		w := want[index.Name]
+		if index.Unused != w.unused || index.RedundantWith != w.redundant {
+			t.Errorf("%s: unused %v, redundantWith %q, want %v, %q", index.Name, index.Unused, index.RedundantWith, w.unused, w.redundant)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-02 11:20:06
+ */
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCommandOK(t *testing.T) {
+	tests := []struct {
+		result bson.M
+		want   bool
+	}{
+		{bson.M{"ok": float64(1)}, true},
+		{bson.M{"ok": int32(1)}, true},
+		{bson.M{"ok": int64(1)}, true},
+		{bson.M{"ok": true}, true},
+		{bson.M{"ok": float64(0)}, false},
+		{bson.M{"ok": int32(0)}, false},
+		{bson.M{"ok": "1"}, false},
+		{bson.M{}, false},
+	}
+	for _, tt := range tests {
+		if got := CommandOK(tt.result); got != tt.want {
+			t.Errorf("CommandOK(%v) = %v, want %v", tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestReplSetStatusDecode(t *testing.T) {
+	now := time.Now().Truncate(time.Millisecond)
+	raw := bson.D{
+		{Key: "set", Value: "rs0"},
+		{Key: "myState", Value: int32(1)},
+		{Key: "term", Value: int64(3)},
+		{Key: "members", Value: bson.A{
+			bson.D{{Key: "_id", Value: int32(0)}, {Key: "name", Value: "a:27017"}, {Key: "health", Value: float64(1)}, {Key: "state", Value: int32(1)}, {Key: "stateStr", Value: "PRIMARY"}, {Key: "uptime", Value: int32(100)}, {Key: "optimeDate", Value: now}, {Key: "self", Value: true}},
+			bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "b:27017"}, {Key: "health", Value: int32(1)}, {Key: "state", Value: int32(2)}, {Key: "stateStr", Value: "SECONDARY"}, {Key: "optimeDate", Value: now.Add(-30 * time.Second)}, {Key: "syncSourceHost", Value: "a:27017"}},
+			bson.D{{Key: "_id", Value: int32(2)}, {Key: "name", Value: "c:27017"}, {Key: "health", Value: float64(1)}, {Key: "state", Value: int32(7)}, {Key: "stateStr", Value: "ARBITER"}},
+		}},
+		{Key: "ok", Value: float64(1)},
+	}
+	b, err := bson.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var status ReplSetStatus
+	if err := bson.Unmarshal(b, &status); err != nil {
+		t.Fatal(err)
+	}
+	status.computeLag()
+
+	primary, ok := status.Primary()
+	if !ok || primary.Name != "a:27017" {
+		t.Fatalf("primary = %+v, %v", primary, ok)
+	}
+	if lag := status.Members[1].LagSeconds; lag != 30 {
+		t.Errorf("secondary lag = %v, want 30", lag)
+	}
+	if status.Members[1].Health != 1 {
+		t.Errorf("int32 health decoded wrong: %v", status.Members[1].Health)
+	}
+	if status.Members[2].LagSeconds != 0 {
+		t.Errorf("arbiter should have no lag: %v", status.Members[2].LagSeconds)
+	}
+}
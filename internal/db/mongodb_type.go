@@ -6,6 +6,8 @@
 package db
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -17,5 +19,408 @@ type Collection struct {
 	Dropped      bool               `json:"dropped" bson:"dropped"`
 	Key          bson.D             `json:"key" bson:"key"`
 	Unique       bool               `json:"unique" bson:"unique"`
-	//Uuid         string              `json:"uuid" bson:"uuid"`
+	UUID         *primitive.Binary  `json:"-" bson:"uuid,omitempty"` // 5.0 开始 config.chunks 使用 uuid 关联集合, 不再有 ns 字段
+	NoBalance    bool               `json:"noBalance" bson:"noBalance"`
+}
+
+// ReplSetConfig 副本集配置, replSetGetConfig 的返回. Extra 保存未定义的字段, 重新配置时原样写回
+type ReplSetConfig struct {
+	ID              string          `json:"_id" bson:"_id"`
+	Version         int64           `json:"version" bson:"version"`
+	Term            int64           `json:"term,omitempty" bson:"term,omitempty"`
+	ProtocolVersion int64           `json:"protocolVersion,omitempty" bson:"protocolVersion,omitempty"`
+	ConfigSvr       bool            `json:"configsvr,omitempty" bson:"configsvr,omitempty"`
+	Members         []ReplSetMember `json:"members" bson:"members"`
+	Settings        bson.M          `json:"settings,omitempty" bson:"settings,omitempty"`
+	Extra           bson.M          `json:"-" bson:",inline"`
+}
+
+// ReplSetMember 副本集成员配置. 延迟从库的字段 5.0 之前为 slaveDelay, 5.0 开始为 secondaryDelaySecs
+type ReplSetMember struct {
+	ID                 int               `json:"_id" bson:"_id"`
+	Host               string            `json:"host" bson:"host"`
+	ArbiterOnly        bool              `json:"arbiterOnly" bson:"arbiterOnly"`
+	BuildIndexes       *bool             `json:"buildIndexes,omitempty" bson:"buildIndexes,omitempty"`
+	Hidden             bool              `json:"hidden" bson:"hidden"`
+	Priority           float64           `json:"priority" bson:"priority"`
+	Tags               map[string]string `json:"tags,omitempty" bson:"tags,omitempty"`
+	SecondaryDelaySecs *int64            `json:"secondaryDelaySecs,omitempty" bson:"secondaryDelaySecs,omitempty"`
+	SlaveDelay         *int64            `json:"slaveDelay,omitempty" bson:"slaveDelay,omitempty"`
+	Votes              int               `json:"votes" bson:"votes"`
+	Extra              bson.M            `json:"-" bson:",inline"`
+}
+
+// Delay 延迟从库的延迟秒数
+func (m ReplSetMember) Delay() int64 {
+	if m.SecondaryDelaySecs != nil {
+		return *m.SecondaryDelaySecs
+	}
+	if m.SlaveDelay != nil {
+		return *m.SlaveDelay
+	}
+	return 0
+}
+
+// SetDelay 设置延迟秒数, 使用成员配置中已有的字段名, 都没有时使用 secondaryDelaySecs
+func (m *ReplSetMember) SetDelay(secs int64) {
+	if m.SlaveDelay != nil {
+		m.SlaveDelay = &secs
+		return
+	}
+	m.SecondaryDelaySecs = &secs
+}
+
+// ReplSetMemberChange 修改成员属性, 为 nil 的字段不修改
+type ReplSetMemberChange struct {
+	Priority           *float64 `json:"priority"`
+	Votes              *int     `json:"votes"`
+	Hidden             *bool    `json:"hidden"`
+	SecondaryDelaySecs *int64   `json:"secondaryDelaySecs"`
+}
+
+// 副本集成员状态, replSetGetStatus 中 members.state 的值
+const (
+	ReplStateStartup    = 0
+	ReplStatePrimary    = 1
+	ReplStateSecondary  = 2
+	ReplStateRecovering = 3
+	ReplStateStartup2   = 5
+	ReplStateUnknown    = 6
+	ReplStateArbiter    = 7
+	ReplStateDown       = 8
+	ReplStateRollback   = 9
+	ReplStateRemoved    = 10
+)
+
+// ReplSetStatus replSetGetStatus 的返回
+type ReplSetStatus struct {
+	Set                     string                `json:"set" bson:"set"`
+	Date                    time.Time             `json:"date" bson:"date"`
+	MyState                 int                   `json:"myState" bson:"myState"`
+	Term                    int64                 `json:"term" bson:"term"`
+	SyncSourceHost          string                `json:"syncSourceHost" bson:"syncSourceHost"`
+	HeartbeatIntervalMillis int64                 `json:"heartbeatIntervalMillis" bson:"heartbeatIntervalMillis"`
+	MajorityVoteCount       int                   `json:"majorityVoteCount" bson:"majorityVoteCount"`
+	VotingMembersCount      int                   `json:"votingMembersCount" bson:"votingMembersCount"`
+	Members                 []ReplSetMemberStatus `json:"members" bson:"members"`
+}
+
+// ReplSetMemberStatus 副本集成员状态, LagSeconds 不是命令返回的字段, 由 optimeDate 计算得出
+type ReplSetMemberStatus struct {
+	ID                   int                 `json:"_id" bson:"_id"`
+	Name                 string              `json:"name" bson:"name"`
+	Health               float64             `json:"health" bson:"health"` // 1 为可达, 0 为不可达
+	State                int                 `json:"state" bson:"state"`
+	StateStr             string              `json:"stateStr" bson:"stateStr"`
+	Uptime               int64               `json:"uptime" bson:"uptime"`
+	Optime               OpTime              `json:"optime" bson:"optime"`
+	OptimeDate           time.Time           `json:"optimeDate" bson:"optimeDate"`
+	LastHeartbeat        time.Time           `json:"lastHeartbeat" bson:"lastHeartbeat"`
+	LastHeartbeatRecv    time.Time           `json:"lastHeartbeatRecv" bson:"lastHeartbeatRecv"`
+	LastHeartbeatMessage string              `json:"lastHeartbeatMessage" bson:"lastHeartbeatMessage"`
+	PingMs               int64               `json:"pingMs" bson:"pingMs"`
+	SyncSourceHost       string              `json:"syncSourceHost" bson:"syncSourceHost"`
+	SyncSourceID         int                 `json:"syncSourceId" bson:"syncSourceId"`
+	SyncingTo            string              `json:"syncingTo,omitempty" bson:"syncingTo"` // 4.4 之前的同步源字段
+	InfoMessage          string              `json:"infoMessage" bson:"infoMessage"`
+	ElectionTime         primitive.Timestamp `json:"electionTime" bson:"electionTime"`
+	ElectionDate         time.Time           `json:"electionDate" bson:"electionDate"`
+	ConfigVersion        int64               `json:"configVersion" bson:"configVersion"`
+	ConfigTerm           int64               `json:"configTerm" bson:"configTerm"`
+	Self                 bool                `json:"self" bson:"self"`
+	LagSeconds           float64             `json:"lagSeconds" bson:"-"`
+}
+
+type OpTime struct {
+	Ts primitive.Timestamp `json:"ts" bson:"ts"`
+	T  int64               `json:"t" bson:"t"`
+}
+
+// SyncSource 成员的同步源, 4.4 开始为 syncSourceHost, 之前为 syncingTo
+func (m ReplSetMemberStatus) SyncSource() string {
+	if m.SyncSourceHost != "" {
+		return m.SyncSourceHost
+	}
+	return m.SyncingTo
+}
+
+// Primary 返回主节点状态, 没有主节点时 ok 为 false
+func (s ReplSetStatus) Primary() (ReplSetMemberStatus, bool) {
+	for _, member := range s.Members {
+		if member.State == ReplStatePrimary {
+			return member, true
+		}
+	}
+	return ReplSetMemberStatus{}, false
+}
+
+// computeLag 以主节点的 optimeDate 为基准计算各数据节点的复制延迟, 没有主节点时以最新的 optimeDate 为基准
+func (s *ReplSetStatus) computeLag() {
+	var latest time.Time
+	if primary, ok := s.Primary(); ok {
+		latest = primary.OptimeDate
+	} else {
+		for _, member := range s.Members {
+			if member.OptimeDate.After(latest) {
+				latest = member.OptimeDate
+			}
+		}
+	}
+
+	for i := range s.Members {
+		member := &s.Members[i]
+		if member.State == ReplStateArbiter || member.OptimeDate.IsZero() || latest.IsZero() {
+			continue
+		}
+		if lag := latest.Sub(member.OptimeDate).Seconds(); lag > 0 {
+			member.LagSeconds = lag
+		}
+	}
+}
+
+// HelloResult hello(低版本为 isMaster) 命令的返回
+type HelloResult struct {
+	IsWritablePrimary bool               `json:"isWritablePrimary" bson:"isWritablePrimary"`
+	IsMaster          bool               `json:"ismaster" bson:"ismaster"` // isMaster 命令返回的字段
+	Secondary         bool               `json:"secondary" bson:"secondary"`
+	ArbiterOnly       bool               `json:"arbiterOnly" bson:"arbiterOnly"`
+	Hidden            bool               `json:"hidden" bson:"hidden"`
+	SetName           string             `json:"setName" bson:"setName"`
+	SetVersion        int64              `json:"setVersion" bson:"setVersion"`
+	Hosts             []string           `json:"hosts" bson:"hosts"`
+	Passives          []string           `json:"passives" bson:"passives"`
+	Arbiters          []string           `json:"arbiters" bson:"arbiters"`
+	Primary           string             `json:"primary" bson:"primary"`
+	Me                string             `json:"me" bson:"me"`
+	Msg               string             `json:"msg" bson:"msg"` // mongos 返回 isdbgrid
+	ElectionID        primitive.ObjectID `json:"electionId" bson:"electionId"`
+	LastWrite         struct {
+		OpTime        OpTime    `json:"opTime" bson:"opTime"`
+		LastWriteDate time.Time `json:"lastWriteDate" bson:"lastWriteDate"`
+	} `json:"lastWrite" bson:"lastWrite"`
+	LocalTime      time.Time `json:"localTime" bson:"localTime"`
+	MaxWireVersion int       `json:"maxWireVersion" bson:"maxWireVersion"`
+}
+
+// IsPrimary 是否为主节点
+func (h HelloResult) IsPrimary() bool {
+	return h.IsWritablePrimary || h.IsMaster
+}
+
+// IsMongos 是否为 mongos
+func (h HelloResult) IsMongos() bool {
+	return h.Msg == "isdbgrid"
+}
+
+// IndexInconsistency 集合的一个索引在各分片上不一致, InconsistentIndexPipeline 的返回
+type IndexInconsistency struct {
+	Namespace              string          `json:"namespace" bson:"-"`
+	IndexName              string          `json:"indexName" bson:"indexName"`
+	MissingFromShards      []string        `json:"missingFromShards" bson:"missingFromShards"`           // 缺少该索引的分片
+	InconsistentProperties []IndexProperty `json:"inconsistentProperties" bson:"inconsistentProperties"` // 各分片不一致的索引属性
+	FixCommands            []string        `json:"fixCommands,omitempty" bson:"-"`                       // 修复命令, 在 mongos 上执行
+}
+
+// IndexProperty 索引定义中的一个属性, $objectToArray 的结果
+type IndexProperty struct {
+	K string `json:"k" bson:"k"`
+	V any    `json:"v" bson:"v"`
+}
+
+// IndexInconsistencyReport 所有分片集合的索引一致性检查结果
+type IndexInconsistencyReport struct {
+	Collections     int                  `json:"collections"` // 检查的集合数
+	Inconsistencies []IndexInconsistency `json:"inconsistencies"`
+	Errors          []NamespaceError     `json:"errors"` // 检查失败的集合
+}
+
+type NamespaceError struct {
+	Namespace string `json:"namespace"`
+	Error     string `json:"error"`
+}
+
+// CurrentOpFilter currentOp 的过滤条件, 为空的条件不过滤
+type CurrentOpFilter struct {
+	Namespace  string `json:"namespace"`  // 包含 "." 时精确匹配集合, 否则匹配库下所有集合
+	MinSecs    int64  `json:"minSecs"`    // 最少运行秒数
+	OpType     string `json:"opType"`     // query, insert, update, remove, getmore, command, killcursors, none
+	Client     string `json:"client"`     // 客户端地址前缀, 如 10.0.0.1 或 10.0.0.1:53412
+	ActiveOnly bool   `json:"activeOnly"` // 只返回活跃的操作
+}
+
+// Empty 是否没有任何过滤条件
+func (f CurrentOpFilter) Empty() bool {
+	return f.Namespace == "" && f.MinSecs <= 0 && f.OpType == "" && f.Client == ""
+}
+
+// CurrentOp $currentOp 返回的一个操作. 连接 mongos 时 OpID 为 "分片名:opid" 格式的字符串, 连接 mongod 时为数字
+type CurrentOp struct {
+	Shard            string `json:"shard" bson:"shard"`
+	Host             string `json:"host" bson:"host"`
+	Desc             string `json:"desc" bson:"desc"`
+	ConnectionID     int64  `json:"connectionId" bson:"connectionId"`
+	Client           string `json:"client" bson:"client"`
+	AppName          string `json:"appName" bson:"appName"`
+	Active           bool   `json:"active" bson:"active"`
+	OpID             any    `json:"opid" bson:"opid"`
+	SecsRunning      int64  `json:"secs_running" bson:"secs_running"`
+	MicrosecsRunning int64  `json:"microsecs_running" bson:"microsecs_running"`
+	Op               string `json:"op" bson:"op"`
+	Ns               string `json:"ns" bson:"ns"`
+	Command          bson.M `json:"command" bson:"command"`
+	PlanSummary      string `json:"planSummary" bson:"planSummary"`
+	NumYields        int64  `json:"numYields" bson:"numYields"`
+	WaitingForLock   bool   `json:"waitingForLock" bson:"waitingForLock"`
+	EffectiveUsers   []struct {
+		User string `json:"user" bson:"user"`
+		DB   string `json:"db" bson:"db"`
+	} `json:"effectiveUsers" bson:"effectiveUsers"`
+	Internal       bool   `json:"internal" bson:"-"`       // 是否为内部或复制相关的操作, 不允许 kill
+	InternalReason string `json:"internalReason" bson:"-"` // 判断为内部操作的原因
+}
+
+// CurrentOpKillResult 批量 kill 的结果
+type CurrentOpKillResult struct {
+	DryRun  bool             `json:"dryRun"`
+	Killed  []CurrentOp      `json:"killed"`  // dry run 时为将要 kill 的操作
+	Skipped []CurrentOp      `json:"skipped"` // 内部操作, 不会 kill
+	Failed  []CurrentOpError `json:"failed"`
+}
+
+type CurrentOpError struct {
+	OpID  any    `json:"opid"`
+	Error string `json:"error"`
+}
+
+// ProfileStatus 库的 profiler 设置, profile 命令的返回. Was 为 0 关闭, 1 只记录慢查询, 2 记录所有操作
+type ProfileStatus struct {
+	Was        int     `json:"was" bson:"was"`
+	Slowms     int64   `json:"slowms" bson:"slowms"`
+	SampleRate float64 `json:"sampleRate" bson:"sampleRate"`
+}
+
+// ProfileEntry system.profile 中的一条记录
+type ProfileEntry struct {
+	Op                 string    `json:"op" bson:"op"`
+	Ns                 string    `json:"ns" bson:"ns"`
+	Command            bson.D    `json:"command" bson:"command"`
+	OriginatingCommand bson.D    `json:"originatingCommand" bson:"originatingCommand"` // getMore 的原始命令
+	Query              bson.D    `json:"query" bson:"query"`                           // 3.2 之前的版本使用
+	KeysExamined       int64     `json:"keysExamined" bson:"keysExamined"`
+	DocsExamined       int64     `json:"docsExamined" bson:"docsExamined"`
+	NReturned          int64     `json:"nreturned" bson:"nreturned"`
+	Millis             int64     `json:"millis" bson:"millis"`
+	PlanSummary        string    `json:"planSummary" bson:"planSummary"`
+	Ts                 time.Time `json:"ts" bson:"ts"`
+	Client             string    `json:"client" bson:"client"`
+	AppName            string    `json:"appName" bson:"appName"`
+	User               string    `json:"user" bson:"user"`
+	RecordID           int64     `json:"recordId" bson:"$recordId"` // 记录在 capped 集合中的位置, 按插入顺序递增
+}
+
+// Shard listShards 返回的分片
+type Shard struct {
+	ID       string   `json:"id" bson:"_id"`
+	Host     string   `json:"host" bson:"host"`
+	State    int      `json:"state" bson:"state"`
+	Draining bool     `json:"draining" bson:"draining"` // 正在删除的分片
+	Tags     []string `json:"tags" bson:"tags"`
+}
+
+// ShardChunks 集合在一个分片上的 chunk 数
+type ShardChunks struct {
+	Shard  string `json:"shard"`
+	Chunks int64  `json:"chunks"`
+	Jumbo  int64  `json:"jumbo"`
+}
+
+// ChunkDistribution 集合的 chunk 在各分片上的分布. Imbalance 为 chunk 最多和最少的分片的差值
+type ChunkDistribution struct {
+	Namespace string        `json:"namespace"`
+	Key       bson.D        `json:"key"`
+	NoBalance bool          `json:"noBalance"`
+	Total     int64         `json:"total"`
+	Jumbo     int64         `json:"jumbo"`
+	Imbalance int64         `json:"imbalance"`
+	Shards    []ShardChunks `json:"shards"`
+}
+
+// Chunk config.chunks 中的一个 chunk
+type Chunk struct {
+	ID    any    `json:"id" bson:"_id"`
+	Ns    string `json:"ns" bson:"ns"`
+	Min   bson.D `json:"min" bson:"min"`
+	Max   bson.D `json:"max" bson:"max"`
+	Shard string `json:"shard" bson:"shard"`
+	Jumbo bool   `json:"jumbo" bson:"jumbo"`
+}
+
+// BalancerStatus 均衡器状态, balancerStatus 命令和 config.settings 中的设置
+type BalancerStatus struct {
+	Mode              string          `json:"mode" bson:"mode"` // full 或 off
+	InBalancerRound   bool            `json:"inBalancerRound" bson:"inBalancerRound"`
+	NumBalancerRounds int64           `json:"numBalancerRounds" bson:"numBalancerRounds"`
+	Window            *BalancerWindow `json:"window" bson:"-"` // 为空时全天都可以迁移
+}
+
+// BalancerWindow 均衡器的运行时间窗口, 格式为 HH:MM
+type BalancerWindow struct {
+	Start string `json:"start" bson:"start"`
+	Stop  string `json:"stop" bson:"stop"`
+}
+
+// Migration config.migrations 中正在进行的 chunk 迁移
+type Migration struct {
+	ID        string `json:"id" bson:"_id"`
+	Ns        string `json:"ns" bson:"ns"`
+	Min       bson.D `json:"min" bson:"min"`
+	Max       bson.D `json:"max" bson:"max"`
+	FromShard string `json:"fromShard" bson:"fromShard"`
+	ToShard   string `json:"toShard" bson:"toShard"`
+}
+
+// ShardOverview 分片集群概况
+type ShardOverview struct {
+	Shards      []Shard             `json:"shards"`
+	Balancer    BalancerStatus      `json:"balancer"`
+	Collections []ChunkDistribution `json:"collections"`
+	Migrations  []Migration         `json:"migrations"`
+}
+
+// CollectionStats 集合的统计信息, 分片集合为各分片的合计
+type CollectionStats struct {
+	Namespace      string       `json:"namespace"`
+	Count          int64        `json:"count"`
+	Size           int64        `json:"size"` // 未压缩的数据大小, 单位: 字节
+	AvgObjSize     int64        `json:"avgObjSize"`
+	StorageSize    int64        `json:"storageSize"` // 数据占用的磁盘空间
+	TotalIndexSize int64        `json:"totalIndexSize"`
+	Capped         bool         `json:"capped"`
+	Sharded        bool         `json:"sharded"`
+	Indexes        []IndexUsage `json:"indexes"`
+	Error          string       `json:"error,omitempty"`
+}
+
+// IndexUsage 索引的大小和使用情况. Ops 为实例(分片集合为各分片主节点)重启以来的访问次数
+type IndexUsage struct {
+	Name          string    `json:"name"`
+	Key           bson.D    `json:"key"`
+	Size          int64     `json:"size"`
+	Ops           int64     `json:"ops"`
+	Since         time.Time `json:"since"` // 开始统计的时间, 多个分片时取最晚的时间
+	Unique        bool      `json:"unique"`
+	Sparse        bool      `json:"sparse"`
+	Partial       bool      `json:"partial"`
+	TTL           bool      `json:"ttl"`
+	Collation     bson.D    `json:"collation,omitempty"`
+	Unused        bool      `json:"unused"`        // 统计期间没有被访问过, 并且不是唯一, TTL, 片键等有约束作用的索引
+	RedundantWith string    `json:"redundantWith"` // 索引字段是另一个索引的前缀, 可以被另一个索引替代
+}
+
+// CollectionStatsPage 分页返回的集合统计
+type CollectionStatsPage struct {
+	Total    int               `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"pageSize"`
+	Items    []CollectionStats `json:"items"`
 }
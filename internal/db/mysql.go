@@ -21,6 +21,21 @@ type MysqlClient struct {
 	conn   *gorm.DB
 }
 
+// mysqlURI 配置中的连接地址, 没有配置 URI 时按各项参数生成
+func mysqlURI(config *config.DBConfig) string {
+	if config.URI != "" {
+		return config.URI
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=%s&parseTime=%s&loc=%s",
+		config.Username,
+		config.Password,
+		config.Host,
+		config.Database,
+		config.Charset,
+		config.ParseTime,
+		config.Loc)
+}
+
 func NewMysqlClient(config *config.DBConfig) (mysqlCli *MysqlClient, err error) {
 
 	var db *gorm.DB
@@ -30,17 +45,7 @@ func NewMysqlClient(config *config.DBConfig) (mysqlCli *MysqlClient, err error)
 		loc = "Local"
 	}
 
-	URI := config.URI
-	if URI == "" {
-		URI = fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=%s&parseTime=%s&loc=%s",
-			config.Username,
-			config.Password,
-			config.Host,
-			config.Database,
-			config.Charset,
-			config.ParseTime,
-			config.Loc)
-	}
+	URI := mysqlURI(config)
 	// QueryEscape 之后会报错 URI 格式不正确
 	// URI = url.QueryEscape(URI)
 
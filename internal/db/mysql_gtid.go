@@ -0,0 +1,175 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-30 10:06:18
+ */
+
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GTIDSet mysql 的 gtid 集合, source -> 有序且不重叠的区间. source 为 server uuid, 8.3 之后带 tag 的 gtid 为 "uuid:tag"
+type GTIDSet map[string][]GTIDInterval
+
+// GTIDInterval 闭区间 [Start, End]
+type GTIDInterval struct {
+	Start int64
+	End   int64
+}
+
+// ParseGTIDSet 解析 gtid_executed 格式的字符串, 如 "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:7, 4D8B564F-...:1-3"
+func ParseGTIDSet(s string) (GTIDSet, error) {
+	set := GTIDSet{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ":")
+		uuid := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(uuid) != 36 || len(parts) < 2 {
+			return nil, fmt.Errorf("gtid 格式不正确: %s", item)
+		}
+
+		source := uuid
+		for _, part := range parts[1:] {
+			part = strings.TrimSpace(part)
+			if part == "" || part[0] < '0' || part[0] > '9' {
+				// 带 tag 的 gtid, 之后的区间属于 uuid:tag
+				if !isGTIDTag(part) {
+					return nil, fmt.Errorf("gtid 格式不正确: %s", item)
+				}
+				source = uuid + ":" + strings.ToLower(part)
+				continue
+			}
+
+			start, end, found := strings.Cut(part, "-")
+			first, err := strconv.ParseInt(start, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("gtid 格式不正确: %s", item)
+			}
+			last := first
+			if found {
+				if last, err = strconv.ParseInt(end, 10, 64); err != nil || last < first {
+					return nil, fmt.Errorf("gtid 格式不正确: %s", item)
+				}
+			}
+			set[source] = append(set[source], GTIDInterval{Start: first, End: last})
+		}
+	}
+
+	for source, intervals := range set {
+		set[source] = mergeGTIDIntervals(intervals)
+	}
+	return set, nil
+}
+
+// isGTIDTag tag 由字母, 数字和下划线组成, 不能以数字开头, 最长 32 个字符
+func isGTIDTag(s string) bool {
+	if s == "" || len(s) > 32 {
+		return false
+	}
+	for i, c := range strings.ToLower(s) {
+		if c != '_' && (c < 'a' || c > 'z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func mergeGTIDIntervals(intervals []GTIDInterval) []GTIDInterval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start < intervals[j].Start })
+	merged := []GTIDInterval{}
+	for _, iv := range intervals {
+		if n := len(merged); n > 0 && iv.Start <= merged[n-1].End+1 {
+			merged[n-1].End = max(merged[n-1].End, iv.End)
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// String 按 source 排序输出, 格式和 gtid_executed 相同
+func (s GTIDSet) String() string {
+	sources := make([]string, 0, len(s))
+	for source, intervals := range s {
+		if len(intervals) > 0 {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+
+	items := make([]string, 0, len(sources))
+	for _, source := range sources {
+		var b strings.Builder
+		b.WriteString(source)
+		for _, iv := range s[source] {
+			if iv.Start == iv.End {
+				fmt.Fprintf(&b, ":%d", iv.Start)
+			} else {
+				fmt.Fprintf(&b, ":%d-%d", iv.Start, iv.End)
+			}
+		}
+		items = append(items, b.String())
+	}
+	return strings.Join(items, ",")
+}
+
+// Count 集合中 gtid 的数量
+func (s GTIDSet) Count() int64 {
+	var n int64
+	for _, intervals := range s {
+		for _, iv := range intervals {
+			n += iv.End - iv.Start + 1
+		}
+	}
+	return n
+}
+
+// Union 返回两个集合的并集
+func (s GTIDSet) Union(o GTIDSet) GTIDSet {
+	result := GTIDSet{}
+	for _, set := range []GTIDSet{s, o} {
+		for source, intervals := range set {
+			result[source] = append(result[source], intervals...)
+		}
+	}
+	for source, intervals := range result {
+		result[source] = mergeGTIDIntervals(intervals)
+	}
+	return result
+}
+
+// Subtract 返回在 s 中但不在 o 中的 gtid
+func (s GTIDSet) Subtract(o GTIDSet) GTIDSet {
+	result := GTIDSet{}
+	for source, intervals := range s {
+		remaining := append([]GTIDInterval{}, intervals...)
+		for _, cut := range o[source] {
+			var next []GTIDInterval
+			for _, iv := range remaining {
+				if cut.End < iv.Start || cut.Start > iv.End {
+					next = append(next, iv)
+					continue
+				}
+				if iv.Start < cut.Start {
+					next = append(next, GTIDInterval{Start: iv.Start, End: cut.Start - 1})
+				}
+				if iv.End > cut.End {
+					next = append(next, GTIDInterval{Start: cut.End + 1, End: iv.End})
+				}
+			}
+			remaining = next
+		}
+		if len(remaining) > 0 {
+			result[source] = remaining
+		}
+	}
+	return result
+}
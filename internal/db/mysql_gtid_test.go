@@ -0,0 +1,61 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-30 16:02:37
+ */
+
+package db
+
+import "testing"
+
+const (
+	testUUIDA = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	testUUIDB = "4d8b564f-03f4-4975-856a-0bba3c5a5bd1"
+)
+
+func TestParseGTIDSet(t *testing.T) {
+	set, err := ParseGTIDSet("3E11FA47-71CA-11E1-9E33-C80AA9429562:6-9:1-5:12,\n" + testUUIDB + ":1-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := set.String(); got != testUUIDA+":1-9:12,"+testUUIDB+":1-3" {
+		t.Errorf("got %s", got)
+	}
+	if set.Count() != 13 {
+		t.Errorf("count %d", set.Count())
+	}
+
+	tagged, err := ParseGTIDSet(testUUIDA + ":1-2:Batch:1-4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tagged.Count() != 6 || len(tagged[testUUIDA+":batch"]) != 1 {
+		t.Errorf("tagged got %v", tagged)
+	}
+
+	if empty, err := ParseGTIDSet(""); err != nil || empty.Count() != 0 {
+		t.Errorf("empty got %v, %v", empty, err)
+	}
+	for _, s := range []string{"abc:1-2", testUUIDA, testUUIDA + ":5-3", testUUIDA + ":x-3"} {
+		if _, err := ParseGTIDSet(s); err == nil {
+			t.Errorf("%s should be invalid", s)
+		}
+	}
+}
+
+func TestGTIDSetOperations(t *testing.T) {
+	a, _ := ParseGTIDSet(testUUIDA + ":1-10:20-30," + testUUIDB + ":1-5")
+	b, _ := ParseGTIDSet(testUUIDA + ":3-4:10-25")
+
+	if got := a.Subtract(b).String(); got != testUUIDA+":1-2:5-9:26-30,"+testUUIDB+":1-5" {
+		t.Errorf("subtract got %s", got)
+	}
+	if got := b.Subtract(a).String(); got != testUUIDA+":11-19" {
+		t.Errorf("subtract got %s", got)
+	}
+	if got := a.Union(b).String(); got != testUUIDA+":1-30,"+testUUIDB+":1-5" {
+		t.Errorf("union got %s", got)
+	}
+	if a.Subtract(a).Count() != 0 {
+		t.Error("a - a should be empty")
+	}
+}
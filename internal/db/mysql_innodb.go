@@ -0,0 +1,273 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-29 14:05:52
+ */
+
+package db
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	innodbTrxActiveRegexp  = regexp.MustCompile(`^(ACTIVE(?: \(PREPARED\))?) (\d+) sec(?: (.*))?$`)
+	innodbTrxLocksRegexp   = regexp.MustCompile(`(\d+) lock struct\(s\), heap size \d+, (\d+) row lock\(s\)(?:, undo log entries (\d+))?`)
+	innodbTrxThreadRegexp  = regexp.MustCompile(`^MySQL thread id (\d+), OS thread handle \S+, query id (\d+)(?: (.*))?$`)
+	innodbTrxWaitingRegexp = regexp.MustCompile(`^------- TRX HAS BEEN WAITING (\d+) SEC`)
+	innodbDeadlockTrx      = regexp.MustCompile(`^\*\*\* \((\d+)\) TRANSACTION:`)
+	innodbDeadlockRollback = regexp.MustCompile(`^\*\*\* WE ROLL BACK TRANSACTION \((\d+)\)`)
+	innodbBufferPoolRegexp = regexp.MustCompile(`^(Buffer pool size|Free buffers|Database pages|Modified db pages)\s+(\d+)`)
+	innodbHitRateRegexp    = regexp.MustCompile(`^Buffer pool hit rate (\d+) / (\d+)`)
+	innodbPagesRegexp      = regexp.MustCompile(`^Pages read (\d+), created (\d+), written (\d+)`)
+)
+
+// 事务信息中这些行之后不再是 SQL
+var innodbTrxQueryEnd = []string{"---", "*** ", "Trx read view", "TABLE LOCK", "RECORD LOCKS", "mysql tables in use"}
+
+// ParseInnodbStatus 解析 show engine innodb status 的输出, 段落的格式为:
+//
+//	------------
+//	TRANSACTIONS
+//	------------
+func ParseInnodbStatus(text string) MysqlInnodbStatus {
+	status := MysqlInnodbStatus{Sections: []MysqlInnodbSection{}, Transactions: MysqlInnodbTransactions{Transactions: []MysqlInnodbTrx{}}}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var current *MysqlInnodbSection
+	var content []string
+	flush := func() {
+		if current != nil {
+			current.Content = strings.TrimSpace(strings.Join(content, "\n"))
+			status.Sections = append(status.Sections, *current)
+		}
+		content = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		if i+2 < len(lines) && isInnodbRule(lines[i]) && !isInnodbRule(lines[i+1]) && isInnodbRule(lines[i+2]) {
+			title := strings.TrimSpace(lines[i+1])
+			i += 2
+			// 开头是 "时间 INNODB MONITOR OUTPUT", 结尾是 END OF INNODB MONITOR OUTPUT
+			if strings.Contains(title, "INNODB MONITOR OUTPUT") {
+				if !strings.HasPrefix(title, "END OF") {
+					status.Time = innodbTime(title)
+				}
+				flush()
+				current = nil
+				continue
+			}
+			flush()
+			current = &MysqlInnodbSection{Name: title}
+			continue
+		}
+		content = append(content, lines[i])
+	}
+	flush()
+
+	for _, section := range status.Sections {
+		switch section.Name {
+		case "LATEST DETECTED DEADLOCK":
+			status.Deadlock = parseInnodbDeadlock(section.Content)
+		case "TRANSACTIONS":
+			status.Transactions = parseInnodbTransactions(section.Content)
+		case "BUFFER POOL AND MEMORY":
+			status.BufferPool = parseInnodbBufferPool(section.Content)
+		}
+	}
+	return status
+}
+
+// isInnodbRule 是否为段落标题上下的分隔线
+func isInnodbRule(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) >= 3 && (strings.Trim(line, "-") == "" || strings.Trim(line, "=") == "")
+}
+
+// innodbTime 取行首的 "2024-04-29 10:00:00" 时间
+func innodbTime(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) >= 2 && strings.Count(fields[0], "-") == 2 && strings.Count(fields[1], ":") == 2 {
+		return fields[0] + " " + fields[1]
+	}
+	return ""
+}
+
+func parseInnodbDeadlock(content string) *MysqlInnodbDeadlock {
+	lines := strings.Split(content, "\n")
+	deadlock := &MysqlInnodbDeadlock{Transactions: []MysqlInnodbTrx{}}
+	if len(lines) > 0 {
+		deadlock.Time = innodbTime(lines[0])
+	}
+
+	// 每个事务从 "*** (n) TRANSACTION:" 开始, 到下一个事务或者回滚信息结束
+	var block []string
+	flush := func() {
+		if len(block) > 0 {
+			deadlock.Transactions = append(deadlock.Transactions, parseInnodbTrx(block))
+		}
+		block = nil
+	}
+	for _, line := range lines {
+		if innodbDeadlockTrx.MatchString(line) {
+			flush()
+			block = []string{}
+			continue
+		}
+		if m := innodbDeadlockRollback.FindStringSubmatch(line); m != nil {
+			flush()
+			deadlock.RolledBack, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if block != nil {
+			block = append(block, line)
+		}
+	}
+	flush()
+
+	if len(deadlock.Transactions) == 0 {
+		return nil
+	}
+	return deadlock
+}
+
+func parseInnodbTransactions(content string) MysqlInnodbTransactions {
+	result := MysqlInnodbTransactions{Transactions: []MysqlInnodbTrx{}}
+
+	var block []string
+	flush := func() {
+		if len(block) > 0 {
+			if trx := parseInnodbTrx(block); trx.State != "not started" {
+				result.Transactions = append(result.Transactions, trx)
+			}
+		}
+		block = nil
+	}
+	for _, line := range strings.Split(content, "\n") {
+		switch {
+		case strings.HasPrefix(line, "Trx id counter "):
+			result.TrxIDCounter = innodbInt(strings.TrimPrefix(line, "Trx id counter "))
+		case strings.HasPrefix(line, "History list length "):
+			result.HistoryListLength = innodbInt(strings.TrimPrefix(line, "History list length "))
+		case strings.HasPrefix(line, "---TRANSACTION "):
+			flush()
+			block = []string{strings.TrimPrefix(line, "---")}
+		case block != nil:
+			block = append(block, line)
+		}
+	}
+	flush()
+	return result
+}
+
+// parseInnodbTrx 解析一个事务, 第一行为 "TRANSACTION 1837, ACTIVE 17 sec starting index read"
+func parseInnodbTrx(lines []string) MysqlInnodbTrx {
+	var trx MysqlInnodbTrx
+	// 死锁信息中锁的描述在 HOLDS THE LOCK(S) 和 WAITING FOR THIS LOCK 的下一行
+	var nextLock *string
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if nextLock != nil {
+			if line != "" {
+				*nextLock = line
+				nextLock = nil
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "TRANSACTION "):
+			id, state, _ := strings.Cut(strings.TrimPrefix(line, "TRANSACTION "), ", ")
+			trx.ID = id
+			trx.State = state
+			if m := innodbTrxActiveRegexp.FindStringSubmatch(state); m != nil {
+				trx.State = m[1]
+				trx.ActiveSecs = innodbInt(m[2])
+				trx.Operation = m[3]
+			}
+		case innodbTrxLocksRegexp.MatchString(line):
+			m := innodbTrxLocksRegexp.FindStringSubmatch(line)
+			trx.LockStructs = innodbInt(m[1])
+			trx.RowLocks = innodbInt(m[2])
+			trx.UndoEntries = innodbInt(m[3])
+			trx.LockWait = trx.LockWait || strings.HasPrefix(line, "LOCK WAIT")
+		case innodbTrxThreadRegexp.MatchString(line):
+			m := innodbTrxThreadRegexp.FindStringSubmatch(line)
+			trx.ThreadID = innodbInt(m[1])
+			trx.QueryID = innodbInt(m[2])
+			trx.Client = m[3]
+
+			// 线程信息的下一行开始是正在执行的 SQL
+			var query []string
+			for i+1 < len(lines) && !isInnodbTrxQueryEnd(lines[i+1]) {
+				i++
+				query = append(query, lines[i])
+			}
+			trx.Query = strings.TrimSpace(strings.Join(query, "\n"))
+		case innodbTrxWaitingRegexp.MatchString(line):
+			trx.LockWait = true
+			trx.WaitSecs = innodbInt(innodbTrxWaitingRegexp.FindStringSubmatch(line)[1])
+			nextLock = &trx.WaitingFor
+		case strings.Contains(line, "WAITING FOR THIS LOCK TO BE GRANTED"):
+			trx.LockWait = true
+			nextLock = &trx.WaitingFor
+		case strings.Contains(line, "HOLDS THE LOCK(S)"):
+			nextLock = &trx.Holds
+		}
+	}
+	return trx
+}
+
+func isInnodbTrxQueryEnd(line string) bool {
+	for _, prefix := range innodbTrxQueryEnd {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseInnodbBufferPool(content string) MysqlInnodbBufferPool {
+	var pool MysqlInnodbBufferPool
+	seen := map[string]bool{}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if m := innodbBufferPoolRegexp.FindStringSubmatch(line); m != nil {
+			// 多个 buffer pool 实例时只取第一次出现的汇总值
+			if seen[m[1]] {
+				continue
+			}
+			seen[m[1]] = true
+			value := innodbInt(m[2])
+			switch m[1] {
+			case "Buffer pool size":
+				pool.TotalPages = value
+			case "Free buffers":
+				pool.FreePages = value
+			case "Database pages":
+				pool.DatabasePages = value
+			case "Modified db pages":
+				pool.ModifiedPages = value
+			}
+		} else if m := innodbHitRateRegexp.FindStringSubmatch(line); m != nil && pool.HitRate == nil {
+			if total := innodbInt(m[2]); total > 0 {
+				rate := float64(innodbInt(m[1])) / float64(total)
+				pool.HitRate = &rate
+			}
+		} else if m := innodbPagesRegexp.FindStringSubmatch(line); m != nil && !seen["pages"] {
+			seen["pages"] = true
+			pool.PagesRead = innodbInt(m[1])
+			pool.PagesCreated = innodbInt(m[2])
+			pool.PagesWritten = innodbInt(m[3])
+		}
+	}
+	return pool
+}
+
+func innodbInt(s string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	return n
+}
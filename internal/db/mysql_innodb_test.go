@@ -0,0 +1,152 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-29 16:32:10
+ */
+
+package db
+
+import "testing"
+
+const innodbStatusSample = `
+=====================================
+2024-04-29 16:20:31 0x7f3c5c1f8700 INNODB MONITOR OUTPUT
+=====================================
+Per second averages calculated from the last 5 seconds
+-----------------
+BACKGROUND THREAD
+-----------------
+srv_master_thread loops: 10 srv_active, 0 srv_shutdown, 3000 srv_idle
+------------------------
+LATEST DETECTED DEADLOCK
+------------------------
+2024-04-29 16:18:02 0x7f3c5c2fa700
+*** (1) TRANSACTION:
+TRANSACTION 1840, ACTIVE 8 sec starting index read
+mysql tables in use 1, locked 1
+LOCK WAIT 3 lock struct(s), heap size 1136, 2 row lock(s)
+MySQL thread id 9, OS thread handle 139, query id 80 localhost root updating
+update t set a = 2 where id = 2
+*** (1) HOLDS THE LOCK(S):
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`test`.`t`" + ` trx id 1840 lock_mode X locks rec but not gap
+Record lock, heap no 2 PHYSICAL RECORD: n_fields 3; compact format; info bits 0
+*** (1) WAITING FOR THIS LOCK TO BE GRANTED:
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`test`.`t`" + ` trx id 1840 lock_mode X locks rec but not gap waiting
+Record lock, heap no 3 PHYSICAL RECORD: n_fields 3; compact format; info bits 0
+*** (2) TRANSACTION:
+TRANSACTION 1841, ACTIVE 5 sec starting index read
+mysql tables in use 1, locked 1
+LOCK WAIT 3 lock struct(s), heap size 1136, 2 row lock(s)
+MySQL thread id 10, OS thread handle 140, query id 81 localhost root updating
+update t set a = 1
+ where id = 1
+*** (2) HOLDS THE LOCK(S):
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`test`.`t`" + ` trx id 1841 lock_mode X locks rec but not gap
+*** (2) WAITING FOR THIS LOCK TO BE GRANTED:
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`test`.`t`" + ` trx id 1841 lock_mode X locks rec but not gap waiting
+*** WE ROLL BACK TRANSACTION (2)
+------------
+TRANSACTIONS
+------------
+Trx id counter 1850
+Purge done for trx's n:o < 1848 undo n:o < 0 state: running but idle
+History list length 27
+LIST OF TRANSACTIONS FOR EACH SESSION:
+---TRANSACTION 421580433069016, not started
+0 lock struct(s), heap size 1128, 0 row lock(s)
+---TRANSACTION 1849, ACTIVE 17 sec starting index read
+mysql tables in use 1, locked 1
+LOCK WAIT 2 lock struct(s), heap size 1136, 1 row lock(s)
+MySQL thread id 12, OS thread handle 141, query id 95 10.0.0.8 app updating
+delete from orders where id = 7
+------- TRX HAS BEEN WAITING 17 SEC FOR THIS LOCK TO BE GRANTED:
+RECORD LOCKS space id 3 page no 4 n bits 80 index PRIMARY of table ` + "`shop`.`orders`" + ` trx id 1849 lock_mode X locks rec but not gap waiting
+------------------
+---TRANSACTION 1848, ACTIVE 300 sec
+2 lock struct(s), heap size 1136, 1 row lock(s), undo log entries 1
+MySQL thread id 11, OS thread handle 142, query id 90 10.0.0.7 app
+--------
+FILE I/O
+--------
+I/O thread 0 state: waiting for completed aio requests (insert buffer thread)
+----------------------
+BUFFER POOL AND MEMORY
+----------------------
+Total large memory allocated 137428992
+Buffer pool size   8192
+Free buffers       7011
+Database pages     1177
+Modified db pages  12
+Pages read 1005, created 172, written 1223
+Buffer pool hit rate 995 / 1000, young-making rate 0 / 1000 not 0 / 1000
+----------------------
+INDIVIDUAL BUFFER POOL INFO
+----------------------
+---BUFFER POOL 0
+Buffer pool size   4096
+----------------------------
+END OF INNODB MONITOR OUTPUT
+============================
+`
+
+func TestParseInnodbStatus(t *testing.T) {
+	status := ParseInnodbStatus(innodbStatusSample)
+
+	if status.Time != "2024-04-29 16:20:31" {
+		t.Errorf("time = %q", status.Time)
+	}
+	var names []string
+	for _, s := range status.Sections {
+		names = append(names, s.Name)
+	}
+	if len(names) != 6 || names[0] != "BACKGROUND THREAD" || names[5] != "INDIVIDUAL BUFFER POOL INFO" {
+		t.Errorf("sections = %q", names)
+	}
+
+	deadlock := status.Deadlock
+	if deadlock == nil || len(deadlock.Transactions) != 2 {
+		t.Fatalf("deadlock = %+v", deadlock)
+	}
+	if deadlock.Time != "2024-04-29 16:18:02" || deadlock.RolledBack != 2 {
+		t.Errorf("deadlock time = %q, rolled back = %d", deadlock.Time, deadlock.RolledBack)
+	}
+	first, second := deadlock.Transactions[0], deadlock.Transactions[1]
+	if first.ID != "1840" || first.ThreadID != 9 || first.Query != "update t set a = 2 where id = 2" || first.RowLocks != 2 || !first.LockWait {
+		t.Errorf("first deadlock trx = %+v", first)
+	}
+	if first.Holds == "" || first.WaitingFor == "" || first.Holds == first.WaitingFor {
+		t.Errorf("first deadlock locks: holds %q, waiting %q", first.Holds, first.WaitingFor)
+	}
+	if second.Query != "update t set a = 1\n where id = 1" {
+		t.Errorf("second deadlock query = %q", second.Query)
+	}
+
+	trxs := status.Transactions
+	if trxs.TrxIDCounter != 1850 || trxs.HistoryListLength != 27 || len(trxs.Transactions) != 2 {
+		t.Fatalf("transactions = %+v", trxs)
+	}
+	waiting, idle := trxs.Transactions[0], trxs.Transactions[1]
+	if waiting.ActiveSecs != 17 || waiting.Operation != "starting index read" || waiting.WaitSecs != 17 || waiting.Client != "10.0.0.8 app updating" {
+		t.Errorf("waiting trx = %+v", waiting)
+	}
+	if waiting.Query != "delete from orders where id = 7" || waiting.WaitingFor == "" {
+		t.Errorf("waiting trx query = %q, waiting for %q", waiting.Query, waiting.WaitingFor)
+	}
+	if idle.State != "ACTIVE" || idle.ActiveSecs != 300 || idle.UndoEntries != 1 || idle.Query != "" || idle.LockWait {
+		t.Errorf("idle trx = %+v", idle)
+	}
+
+	pool := status.BufferPool
+	if pool.TotalPages != 8192 || pool.FreePages != 7011 || pool.DatabasePages != 1177 || pool.ModifiedPages != 12 {
+		t.Errorf("buffer pool = %+v", pool)
+	}
+	if pool.HitRate == nil || *pool.HitRate != 0.995 || pool.PagesRead != 1005 || pool.PagesWritten != 1223 {
+		t.Errorf("buffer pool = %+v", pool)
+	}
+}
+
+func TestParseInnodbStatusNoDeadlock(t *testing.T) {
+	status := ParseInnodbStatus("------------\nTRANSACTIONS\n------------\nTrx id counter 5\nHistory list length 0\n")
+	if status.Deadlock != nil || status.Transactions.TrxIDCounter != 5 || len(status.Transactions.Transactions) != 0 {
+		t.Errorf("status = %+v", status)
+	}
+}
@@ -0,0 +1,314 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-29 10:48:36
+ */
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 复制和后台线程的 Command, 不允许 kill
+var mysqlInternalCommands = map[string]string{
+	"Binlog Dump":      "从库的复制连接",
+	"Binlog Dump GTID": "从库的复制连接",
+	"Daemon":           "后台线程",
+}
+
+// 瞬时值的状态, 其他数字状态都是累计值
+var mysqlGaugeStatus = map[string]bool{
+	"Uptime":                         true,
+	"Uptime_since_flush_status":      true,
+	"Max_used_connections":           true,
+	"Open_files":                     true,
+	"Open_streams":                   true,
+	"Open_table_definitions":         true,
+	"Open_tables":                    true,
+	"Innodb_row_lock_current_waits":  true,
+	"Innodb_row_lock_time_avg":       true,
+	"Innodb_row_lock_time_max":       true,
+	"Innodb_buffer_pool_bytes_data":  true,
+	"Innodb_buffer_pool_bytes_dirty": true,
+	"Innodb_page_size":               true,
+	"Innodb_num_open_files":          true,
+	"Innodb_history_list_length":     true,
+	"Prepared_stmt_count":            true,
+	"Qcache_free_blocks":             true,
+	"Qcache_free_memory":             true,
+	"Qcache_queries_in_cache":        true,
+	"Qcache_total_blocks":            true,
+	"Performance_schema_session_connect_attrs_longest_seen": true,
+}
+
+// 以这些前缀开头的状态是瞬时值
+var mysqlGaugeStatusPrefixes = []string{"Threads_", "Innodb_buffer_pool_pages_", "Ssl_", "Rpl_semi_sync_", "Slave_", "Replica_"}
+
+// Processlist 执行 show full processlist, 按过滤条件返回连接, 并标记不允许 kill 的连接
+func (db *MysqlClient) Processlist(filter MysqlProcessFilter, protectedUsers []string) ([]MysqlProcess, error) {
+	var processes []MysqlProcess
+	err := db.conn.Connection(func(tx *gorm.DB) error {
+		var err error
+		processes, err = processlist(tx, filter, protectedUsers)
+		return err
+	})
+	return processes, err
+}
+
+func processlist(tx *gorm.DB, filter MysqlProcessFilter, protectedUsers []string) ([]MysqlProcess, error) {
+	var selfID int64
+	if err := tx.Raw("SELECT CONNECTION_ID()").Scan(&selfID).Error; err != nil {
+		return nil, fmt.Errorf("获取当前连接 id 失败: %v", err)
+	}
+
+	var all []MysqlProcess
+	if err := tx.Raw("SHOW FULL PROCESSLIST").Scan(&all).Error; err != nil {
+		return nil, fmt.Errorf("执行 show full processlist 失败: %v", err)
+	}
+
+	processes := []MysqlProcess{}
+	for _, p := range all {
+		if !filter.match(p) {
+			continue
+		}
+		p.ProtectedReason = p.protectedReason(selfID, protectedUsers)
+		p.Protected = p.ProtectedReason != ""
+		processes = append(processes, p)
+	}
+	sort.SliceStable(processes, func(i, j int) bool { return processes[i].Time > processes[j].Time })
+	return processes, nil
+}
+
+func (f MysqlProcessFilter) match(p MysqlProcess) bool {
+	if f.User != "" && p.User != f.User {
+		return false
+	}
+	if f.Host != "" && f.Host != p.Host && f.Host != processHost(p.Host) {
+		return false
+	}
+	if f.DB != "" && p.DB != f.DB {
+		return false
+	}
+	if f.Command != "" && !strings.EqualFold(p.Command, f.Command) {
+		return false
+	}
+	if f.State != "" && !strings.Contains(strings.ToLower(p.State), strings.ToLower(f.State)) {
+		return false
+	}
+	if f.Info != "" && !strings.Contains(strings.ToLower(p.Info), strings.ToLower(f.Info)) {
+		return false
+	}
+	if f.MinTime > 0 && p.Time < f.MinTime {
+		return false
+	}
+	if f.ActiveOnly && p.Command == "Sleep" {
+		return false
+	}
+	return true
+}
+
+// processHost 去掉 processlist 中 host 的端口, 如 10.0.0.1:53412 返回 10.0.0.1, 本地 socket 连接为 localhost
+func processHost(host string) string {
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		return host[:i]
+	}
+	return host
+}
+
+// protectedReason 判断连接是否不允许 kill, 返回原因, 允许时返回空
+func (p MysqlProcess) protectedReason(selfID int64, protectedUsers []string) string {
+	if p.ID == selfID {
+		return "当前管理连接"
+	}
+	switch p.User {
+	case "system user":
+		return "系统线程(复制 IO/SQL 线程或 innodb 后台线程)"
+	case "event_scheduler":
+		return "事件调度线程"
+	}
+	if reason, ok := mysqlInternalCommands[p.Command]; ok {
+		return reason
+	}
+	if slices.Contains(protectedUsers, p.User) {
+		return "受保护的用户 " + p.User
+	}
+	return ""
+}
+
+// Kill 执行 kill query 或 kill connection, 连接不存在或者受保护时返回错误
+// 查询连接和 kill 在同一个数据库连接上执行, 避免 kill 掉自己
+func (db *MysqlClient) Kill(id int64, query bool, protectedUsers []string) (MysqlProcess, error) {
+	var target MysqlProcess
+	err := db.conn.Connection(func(tx *gorm.DB) error {
+		processes, err := processlist(tx, MysqlProcessFilter{}, protectedUsers)
+		if err != nil {
+			return err
+		}
+
+		i := slices.IndexFunc(processes, func(p MysqlProcess) bool { return p.ID == id })
+		if i < 0 {
+			return fmt.Errorf("连接 %d 不存在或已经断开", id)
+		}
+		target = processes[i]
+		if target.Protected {
+			return fmt.Errorf("连接 %d 不允许 kill, %s", id, target.ProtectedReason)
+		}
+		return killProcess(tx, id, query)
+	})
+	return target, err
+}
+
+// KillMatching kill 所有匹配条件的连接, 受保护的连接跳过; 没有过滤条件时拒绝执行, 防止 kill 所有连接
+func (db *MysqlClient) KillMatching(filter MysqlProcessFilter, query, dryRun bool, protectedUsers []string) (result MysqlKillResult, err error) {
+	if filter.Empty() {
+		return result, errors.New("至少需要一个过滤条件: user, host, db, command, state, info, min_time")
+	}
+
+	result = MysqlKillResult{DryRun: dryRun, Killed: []MysqlProcess{}, Skipped: []MysqlProcess{}, Failed: []MysqlKillError{}}
+	err = db.conn.Connection(func(tx *gorm.DB) error {
+		processes, err := processlist(tx, filter, protectedUsers)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range processes {
+			if p.Protected {
+				result.Skipped = append(result.Skipped, p)
+				continue
+			}
+			if !dryRun {
+				if err := killProcess(tx, p.ID, query); err != nil {
+					result.Failed = append(result.Failed, MysqlKillError{ID: p.ID, Error: err.Error()})
+					continue
+				}
+			}
+			result.Killed = append(result.Killed, p)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func killProcess(tx *gorm.DB, id int64, query bool) error {
+	kind := "CONNECTION"
+	if query {
+		kind = "QUERY"
+	}
+	if err := tx.Exec(fmt.Sprintf("KILL %s %d", kind, id)).Error; err != nil {
+		return fmt.Errorf("执行 kill %s %d 失败: %v", strings.ToLower(kind), id, err)
+	}
+	return nil
+}
+
+// GlobalVariables 执行 show global variables, like 为空时返回所有变量
+func (db *MysqlClient) GlobalVariables(like string) ([]MysqlVariable, error) {
+	return db.showVariables("SHOW GLOBAL VARIABLES", like)
+}
+
+// GlobalStatus 执行 show global status, like 为空时返回所有状态
+func (db *MysqlClient) GlobalStatus(like string) ([]MysqlVariable, error) {
+	return db.showVariables("SHOW GLOBAL STATUS", like)
+}
+
+func (db *MysqlClient) showVariables(sql, like string) ([]MysqlVariable, error) {
+	variables := []MysqlVariable{}
+	var err error
+	if like == "" {
+		err = db.conn.Raw(sql).Scan(&variables).Error
+	} else {
+		err = db.conn.Raw(sql+" LIKE ?", like).Scan(&variables).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("执行 %s 失败: %v", strings.ToLower(sql), err)
+	}
+	return variables, nil
+}
+
+// StatusSample 采集一次 global status
+func (db *MysqlClient) StatusSample() (MysqlStatusSample, error) {
+	status, err := db.GlobalStatus("")
+	if err != nil {
+		return MysqlStatusSample{}, err
+	}
+
+	sample := MysqlStatusSample{Time: time.Now(), Values: make(map[string]string, len(status))}
+	for _, s := range status {
+		sample.Values[s.Name] = s.Value
+	}
+	return sample, nil
+}
+
+// StatusRates 计算两次采集之间状态的变化, 只返回数字类型的状态, prefix 不为空时只返回该前缀的状态(不区分大小写)
+func StatusRates(prev, cur MysqlStatusSample, prefix string) MysqlStatusRates {
+	interval := cur.Time.Sub(prev.Time).Seconds()
+	rates := MysqlStatusRates{IntervalSec: interval, Status: []MysqlStatusRate{}}
+	if interval <= 0 {
+		return rates
+	}
+
+	rate := func(name string) float64 {
+		before, err1 := strconv.ParseFloat(prev.Values[name], 64)
+		after, err2 := strconv.ParseFloat(cur.Values[name], 64)
+		// 中间重启或者 flush status 后计数器会变小
+		if err1 != nil || err2 != nil || after < before {
+			return 0
+		}
+		return (after - before) / interval
+	}
+	rates.QPS = rate("Questions")
+	rates.TPS = rate("Com_commit") + rate("Com_rollback")
+
+	for name, value := range cur.Values {
+		if prefix != "" && !strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)) {
+			continue
+		}
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			continue
+		}
+
+		s := MysqlStatusRate{Name: name, Value: value, Counter: isMysqlCounter(name)}
+		if s.Counter {
+			s.Rate = rate(name)
+			s.Delta = s.Rate * interval
+		}
+		rates.Status = append(rates.Status, s)
+	}
+	sort.Slice(rates.Status, func(i, j int) bool { return rates.Status[i].Name < rates.Status[j].Name })
+	return rates
+}
+
+func isMysqlCounter(name string) bool {
+	if mysqlGaugeStatus[name] {
+		return false
+	}
+	for _, prefix := range mysqlGaugeStatusPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// InnodbStatus 执行 show engine innodb status 并解析
+func (db *MysqlClient) InnodbStatus() (MysqlInnodbStatus, error) {
+	var rows []struct {
+		Type   string `gorm:"column:Type"`
+		Name   string `gorm:"column:Name"`
+		Status string `gorm:"column:Status"`
+	}
+	if err := db.conn.Raw("SHOW ENGINE INNODB STATUS").Scan(&rows).Error; err != nil {
+		return MysqlInnodbStatus{}, fmt.Errorf("执行 show engine innodb status 失败: %v", err)
+	}
+	if len(rows) == 0 {
+		return MysqlInnodbStatus{}, errors.New("show engine innodb status 没有返回结果")
+	}
+	return ParseInnodbStatus(rows[0].Status), nil
+}
@@ -0,0 +1,107 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-29 17:05:44
+ */
+
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMysqlProcessFilter(t *testing.T) {
+	p := MysqlProcess{ID: 7, User: "app", Host: "10.0.0.8:53412", DB: "shop", Command: "Query", Time: 30, State: "Sending data", Info: "SELECT * FROM orders"}
+
+	for _, f := range []MysqlProcessFilter{
+		{},
+		{User: "app", Host: "10.0.0.8", DB: "shop"},
+		{Command: "query", State: "sending", Info: "from ORDERS", MinTime: 30, ActiveOnly: true},
+	} {
+		if !f.match(p) {
+			t.Errorf("%+v should match", f)
+		}
+	}
+	for _, f := range []MysqlProcessFilter{
+		{User: "root"},
+		{Host: "10.0.0.9"},
+		{Host: "10.0.0.80"},
+		{Host: "10.0.0.8:5341"},
+		{MinTime: 31},
+		{Info: "update"},
+	} {
+		if f.match(p) {
+			t.Errorf("%+v should not match", f)
+		}
+	}
+
+	// 按地址过滤时不能匹配到地址前缀相同的其他客户端
+	f := MysqlProcessFilter{Host: "10.0.0.1"}
+	for host, want := range map[string]bool{"10.0.0.1:3306": true, "10.0.0.1": true, "10.0.0.10:3306": false, "10.0.0.100:3306": false, "localhost": false} {
+		if got := f.match(MysqlProcess{Host: host}); got != want {
+			t.Errorf("host %s match = %v, want %v", host, got, want)
+		}
+	}
+	if !(MysqlProcessFilter{Host: "localhost"}).match(MysqlProcess{Host: "localhost"}) {
+		t.Error("localhost should match")
+	}
+
+	if !(MysqlProcessFilter{ActiveOnly: true}).Empty() {
+		t.Error("active_only alone should be empty")
+	}
+	if (MysqlProcessFilter{ActiveOnly: true}).match(MysqlProcess{Command: "Sleep"}) {
+		t.Error("active_only should exclude sleeping connections")
+	}
+}
+
+func TestMysqlProcessProtected(t *testing.T) {
+	protected := []string{"repl", "monitor"}
+	for _, tc := range []struct {
+		p         MysqlProcess
+		protected bool
+	}{
+		{MysqlProcess{ID: 1, User: "app", Command: "Query"}, false},
+		{MysqlProcess{ID: 99, User: "app", Command: "Query"}, true}, // 当前连接
+		{MysqlProcess{ID: 2, User: "system user", Command: "Connect"}, true},
+		{MysqlProcess{ID: 3, User: "event_scheduler", Command: "Daemon"}, true},
+		{MysqlProcess{ID: 4, User: "app", Command: "Binlog Dump GTID"}, true},
+		{MysqlProcess{ID: 5, User: "monitor", Command: "Sleep"}, true},
+	} {
+		if got := tc.p.protectedReason(99, protected) != ""; got != tc.protected {
+			t.Errorf("%+v protected = %v, want %v", tc.p, got, tc.protected)
+		}
+	}
+}
+
+func TestStatusRates(t *testing.T) {
+	now := time.Now()
+	prev := MysqlStatusSample{Time: now, Values: map[string]string{
+		"Questions": "1000", "Com_commit": "100", "Com_rollback": "10", "Threads_running": "5", "Bytes_sent": "5000", "Com_select": "900",
+	}}
+	cur := MysqlStatusSample{Time: now.Add(2 * time.Second), Values: map[string]string{
+		"Questions": "1400", "Com_commit": "140", "Com_rollback": "12", "Threads_running": "8", "Bytes_sent": "4000", "Com_select": "1200", "Rsa_public_key": "-----BEGIN",
+	}}
+
+	rates := StatusRates(prev, cur, "")
+	if rates.IntervalSec != 2 || rates.QPS != 200 || rates.TPS != 21 {
+		t.Errorf("rates = %+v", rates)
+	}
+	byName := map[string]MysqlStatusRate{}
+	for _, s := range rates.Status {
+		byName[s.Name] = s
+	}
+	if _, ok := byName["Rsa_public_key"]; ok {
+		t.Error("non numeric status should be skipped")
+	}
+	if s := byName["Threads_running"]; s.Counter || s.Value != "8" || s.Rate != 0 {
+		t.Errorf("gauge = %+v", s)
+	}
+	// 计数器变小时(重启或 flush status)速率为 0
+	if s := byName["Bytes_sent"]; !s.Counter || s.Rate != 0 {
+		t.Errorf("reset counter = %+v", s)
+	}
+
+	if got := StatusRates(prev, cur, "com_").Status; len(got) != 3 {
+		t.Errorf("prefix com_ = %+v", got)
+	}
+}
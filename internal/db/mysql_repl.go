@@ -0,0 +1,339 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-30 11:20:41
+ */
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"myadmin/internal/config"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+var mysqlServerInfoVariables = []string{"server_id", "server_uuid", "version", "hostname", "port", "read_only", "super_read_only", "log_bin", "gtid_mode", "gtid_executed"}
+
+// mysqlReplicaSyntax 8.0.22 开始使用 show replica status/show replicas, 之前的版本和 MariaDB 使用 show slave status/show slave hosts
+func mysqlReplicaSyntax(version string) bool {
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		return false
+	}
+
+	var nums [3]int
+	for i, part := range strings.SplitN(strings.SplitN(version, "-", 2)[0], ".", 3) {
+		nums[i], _ = strconv.Atoi(part)
+	}
+	major, minor, patch := nums[0], nums[1], nums[2]
+	return major > 8 || (major == 8 && (minor > 0 || patch >= 22))
+}
+
+// ServerInfo 读取实例的基本信息和复制相关的变量, 不存在的变量(如 MariaDB 没有 server_uuid)为空值
+func (db *MysqlClient) ServerInfo() (MysqlServerInfo, error) {
+	var variables []MysqlVariable
+	if err := db.conn.Raw("SHOW GLOBAL VARIABLES WHERE Variable_name IN ?", mysqlServerInfoVariables).Scan(&variables).Error; err != nil {
+		return MysqlServerInfo{}, fmt.Errorf("读取实例信息失败: %v", err)
+	}
+
+	values := map[string]string{}
+	for _, v := range variables {
+		values[v.Name] = v.Value
+	}
+	port, _ := strconv.Atoi(values["port"])
+	serverID, _ := strconv.ParseInt(values["server_id"], 10, 64)
+	return MysqlServerInfo{
+		ServerID:      serverID,
+		ServerUUID:    values["server_uuid"],
+		Version:       values["version"],
+		Hostname:      values["hostname"],
+		Port:          port,
+		ReadOnly:      values["read_only"] == "ON",
+		SuperReadOnly: values["super_read_only"] == "ON",
+		LogBin:        values["log_bin"] == "ON",
+		GTIDMode:      values["gtid_mode"],
+		GTIDExecuted:  strings.ReplaceAll(values["gtid_executed"], "\n", ""),
+	}, nil
+}
+
+// ReplicaStatus 读取所有复制通道的状态, 不是从库时返回空列表
+func (db *MysqlClient) ReplicaStatus(version string) ([]MysqlReplicaStatus, error) {
+	query := "SHOW SLAVE STATUS"
+	if mysqlReplicaSyntax(version) {
+		query = "SHOW REPLICA STATUS"
+	}
+
+	rows, err := db.stringRows(query)
+	if err != nil {
+		return nil, fmt.Errorf("执行 %s 失败: %v", strings.ToLower(query), err)
+	}
+
+	channels := make([]MysqlReplicaStatus, 0, len(rows))
+	for _, row := range rows {
+		channels = append(channels, replicaStatusFromRow(row))
+	}
+	return channels, nil
+}
+
+// replicaStatusFromRow 按字段名解析, 8.0.22 之后 Master/Slave 改名为 Source/Replica
+func replicaStatusFromRow(row map[string]string) MysqlReplicaStatus {
+	get := func(names ...string) string { return rowString(row, names...) }
+	getInt := func(names ...string) int64 { return rowInt(row, names...) }
+
+	status := MysqlReplicaStatus{
+		Channel:            get("Channel_Name", "Connection_name"),
+		SourceHost:         get("Source_Host", "Master_Host"),
+		SourcePort:         int(getInt("Source_Port", "Master_Port")),
+		SourceUser:         get("Source_User", "Master_User"),
+		SourceServerID:     getInt("Source_Server_Id", "Master_Server_Id"),
+		SourceUUID:         get("Source_UUID", "Master_UUID"),
+		IOThread:           get("Replica_IO_Running", "Slave_IO_Running"),
+		SQLThread:          get("Replica_SQL_Running", "Slave_SQL_Running"),
+		SQLState:           get("Replica_SQL_Running_State", "Slave_SQL_Running_State"),
+		SQLDelay:           getInt("SQL_Delay"),
+		LastIOErrno:        getInt("Last_IO_Errno"),
+		LastIOError:        get("Last_IO_Error"),
+		LastSQLErrno:       getInt("Last_SQL_Errno"),
+		LastSQLError:       get("Last_SQL_Error"),
+		SourceLogFile:      get("Source_Log_File", "Master_Log_File"),
+		ReadSourceLogPos:   getInt("Read_Source_Log_Pos", "Read_Master_Log_Pos"),
+		RelaySourceLogFile: get("Relay_Source_Log_File", "Relay_Master_Log_File"),
+		ExecSourceLogPos:   getInt("Exec_Source_Log_Pos", "Exec_Master_Log_Pos"),
+		AutoPosition:       get("Auto_Position") == "1",
+		RetrievedGTIDSet:   strings.ReplaceAll(get("Retrieved_Gtid_Set"), "\n", ""),
+		ExecutedGTIDSet:    strings.ReplaceAll(get("Executed_Gtid_Set"), "\n", ""),
+	}
+	if v := get("Seconds_Behind_Source", "Seconds_Behind_Master"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			status.SecondsBehind = &n
+		}
+	}
+	return status
+}
+
+// Replicas 读取注册到当前实例的从库
+func (db *MysqlClient) Replicas(version string) ([]MysqlReplicaHost, error) {
+	query := "SHOW SLAVE HOSTS"
+	if mysqlReplicaSyntax(version) {
+		query = "SHOW REPLICAS"
+	}
+
+	rows, err := db.stringRows(query)
+	if err != nil {
+		return nil, fmt.Errorf("执行 %s 失败: %v", strings.ToLower(query), err)
+	}
+
+	replicas := make([]MysqlReplicaHost, 0, len(rows))
+	for _, row := range rows {
+		replicas = append(replicas, MysqlReplicaHost{
+			ServerID: rowInt(row, "Server_Id", "Server_id"),
+			Host:     rowString(row, "Host"),
+			Port:     int(rowInt(row, "Port")),
+			SourceID: rowInt(row, "Source_Id", "Master_Id", "Master_id"),
+			UUID:     rowString(row, "Replica_UUID", "Slave_UUID"),
+		})
+	}
+	return replicas, nil
+}
+
+// rowString 按顺序取第一个存在的列
+func rowString(row map[string]string, names ...string) string {
+	for _, name := range names {
+		if v, ok := row[name]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func rowInt(row map[string]string, names ...string) int64 {
+	n, _ := strconv.ParseInt(rowString(row, names...), 10, 64)
+	return n
+}
+
+// stringRows 执行查询, 每行按列名返回字符串, NULL 的列不在结果中. 用于列名随版本变化的 show 语句
+func (db *MysqlClient) stringRows(query string) ([]map[string]string, error) {
+	rows, err := db.conn.Raw(query).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]string{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			if values[i].Valid {
+				row[column] = values[i].String
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// ReplNode 读取节点的实例信息, 上游复制通道, 下游从库和 Binlog Dump 连接
+func (db *MysqlClient) ReplNode() (MysqlReplNode, error) {
+	info, err := db.ServerInfo()
+	if err != nil {
+		return MysqlReplNode{}, err
+	}
+	node := MysqlReplNode{MysqlServerInfo: info, DumpClients: []string{}}
+
+	if node.Channels, err = db.ReplicaStatus(info.Version); err != nil {
+		return node, err
+	}
+	if node.Replicas, err = db.Replicas(info.Version); err != nil {
+		return node, err
+	}
+
+	processes, err := db.Processlist(MysqlProcessFilter{}, nil)
+	if err != nil {
+		return node, err
+	}
+	for _, p := range processes {
+		if strings.HasPrefix(p.Command, "Binlog Dump") {
+			node.DumpClients = append(node.DumpClients, p.Host)
+		}
+	}
+	return node, nil
+}
+
+// neighbors 节点的上游和下游地址. 没有配置 report_host 的下游只能从 Binlog Dump 连接获取 ip, 假定端口和当前节点相同
+func (n MysqlReplNode) neighbors() []string {
+	var addrs []string
+	for _, ch := range n.Channels {
+		if ch.SourceHost != "" {
+			addrs = append(addrs, net.JoinHostPort(ch.SourceHost, strconv.Itoa(ch.SourcePort)))
+		}
+	}
+
+	reported := map[string]bool{}
+	for _, r := range n.Replicas {
+		if r.Host != "" {
+			addrs = append(addrs, net.JoinHostPort(r.Host, strconv.Itoa(r.Port)))
+			reported[r.Host] = true
+		}
+	}
+	for _, client := range n.DumpClients {
+		host := client
+		if h, _, err := net.SplitHostPort(client); err == nil {
+			host = h
+		}
+		if !reported[host] && n.Port > 0 {
+			addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(n.Port)))
+		}
+	}
+	return addrs
+}
+
+// DiscoverReplTopology 从 start 开始按复制关系广度优先遍历上游和下游, 最多 maxNodes 个节点, 超过时 truncated 为 true
+// 同一个实例通过不同地址发现时按 server_uuid 去重
+func DiscoverReplTopology(start string, maxNodes int, probe func(addr string) (MysqlReplNode, error)) (nodes []MysqlReplNode, truncated bool) {
+	nodes = []MysqlReplNode{}
+	queue := []string{start}
+	queued := map[string]bool{strings.ToLower(start): true}
+	seen := map[string]bool{}
+
+	for len(queue) > 0 {
+		if len(nodes) >= maxNodes {
+			return nodes, true
+		}
+		addr := queue[0]
+		queue = queue[1:]
+
+		node, err := probe(addr)
+		node.Addr = addr
+		if err != nil {
+			node.Error = err.Error()
+			nodes = append(nodes, node)
+			continue
+		}
+		if node.ServerUUID != "" {
+			if seen[node.ServerUUID] {
+				continue
+			}
+			seen[node.ServerUUID] = true
+		}
+		nodes = append(nodes, node)
+
+		for _, next := range node.neighbors() {
+			if !queued[strings.ToLower(next)] {
+				queued[strings.ToLower(next)] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nodes, false
+}
+
+// MysqlAddr 配置中实例的连接地址 host:port
+func MysqlAddr(cfg *config.DBConfig) string {
+	if cfg.URI != "" {
+		if dsn, err := gomysql.ParseDSN(cfg.URI); err == nil {
+			return dsn.Addr
+		}
+	}
+	return cfg.Host
+}
+
+// NewMysqlProbeClient 使用 base 实例的连接参数(账号, TLS 等)连接 addr, 用于读取自动发现的节点, 使用后需要 Close
+// 调用方需要先检查 addr 是否允许连接, 避免把实例的账号发送到复制状态中出现的任意地址
+func NewMysqlProbeClient(base *config.DBConfig, addr string, timeout time.Duration) (*MysqlClient, error) {
+	uri, err := mysqlProbeURI(base, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config.DBConfig{Dialect: "mysql", URI: uri, MaxIdleConns: 1, MaxOpenConns: 1, MaxLifetime: 60}
+	client, err := NewMysqlClient(&cfg)
+	if err != nil {
+		if client != nil && client.conn != nil {
+			client.Close()
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// mysqlProbeURI 复制 base 实例的全部连接参数, 只替换地址和超时时间
+func mysqlProbeURI(base *config.DBConfig, addr string, timeout time.Duration) (string, error) {
+	parsed, err := gomysql.ParseDSN(mysqlURI(base))
+	if err != nil {
+		return "", fmt.Errorf("解析实例的连接地址失败: %v", err)
+	}
+	dsn := parsed.Clone()
+	dsn.Net = "tcp"
+	dsn.Addr = addr
+	dsn.Timeout = timeout
+	dsn.ReadTimeout = timeout
+	dsn.WriteTimeout = timeout
+	return dsn.FormatDSN(), nil
+}
+
+// Close 关闭连接池, 用于临时创建的连接
+func (db *MysqlClient) Close() error {
+	sqlDB, err := db.conn.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
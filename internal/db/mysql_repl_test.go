@@ -0,0 +1,114 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-30 16:20:05
+ */
+
+package db
+
+import (
+	"errors"
+	"myadmin/internal/config"
+	"testing"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+func TestMysqlReplicaSyntax(t *testing.T) {
+	for version, want := range map[string]bool{
+		"5.7.44-log":              false,
+		"8.0.21":                  false,
+		"8.0.22":                  true,
+		"8.0.36-0ubuntu0.22.04.1": true,
+		"8.4.0":                   true,
+		"9.0.1":                   true,
+		"10.11.6-MariaDB-log":     false,
+	} {
+		if got := mysqlReplicaSyntax(version); got != want {
+			t.Errorf("%s: got %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestReplicaStatusFromRow(t *testing.T) {
+	legacy := replicaStatusFromRow(map[string]string{
+		"Master_Host":           "10.0.0.1",
+		"Master_Port":           "3306",
+		"Master_UUID":           testUUIDA,
+		"Slave_IO_Running":      "Yes",
+		"Slave_SQL_Running":     "No",
+		"Last_SQL_Errno":        "1062",
+		"Executed_Gtid_Set":     testUUIDA + ":1-5,\n" + testUUIDB + ":1",
+		"Seconds_Behind_Master": "",
+	})
+	if legacy.SourceHost != "10.0.0.1" || legacy.SourcePort != 3306 || legacy.SourceUUID != testUUIDA ||
+		legacy.IOThread != "Yes" || legacy.SQLThread != "No" || legacy.LastSQLErrno != 1062 || legacy.SecondsBehind != nil {
+		t.Errorf("legacy got %+v", legacy)
+	}
+	if legacy.ExecutedGTIDSet != testUUIDA+":1-5,"+testUUIDB+":1" {
+		t.Errorf("executed gtid got %q", legacy.ExecutedGTIDSet)
+	}
+
+	current := replicaStatusFromRow(map[string]string{
+		"Channel_Name":          "east",
+		"Source_Host":           "10.0.0.2",
+		"Source_Port":           "3307",
+		"Replica_IO_Running":    "Yes",
+		"Replica_SQL_Running":   "Yes",
+		"Seconds_Behind_Source": "12",
+		"SQL_Delay":             "10",
+		"Auto_Position":         "1",
+	})
+	if current.Channel != "east" || current.SourcePort != 3307 || current.SecondsBehind == nil || *current.SecondsBehind != 12 ||
+		current.SQLDelay != 10 || !current.AutoPosition {
+		t.Errorf("current got %+v", current)
+	}
+}
+
+func TestDiscoverReplTopology(t *testing.T) {
+	// a -> b -> c, c 没有配置 report_host, 只能从 Binlog Dump 连接发现; a 同时通过别名 primary:3306 被发现
+	nodes := map[string]MysqlReplNode{
+		"a:3306": {MysqlServerInfo: MysqlServerInfo{ServerUUID: "a", Port: 3306},
+			Replicas: []MysqlReplicaHost{{Host: "b", Port: 3306}}},
+		"b:3306": {MysqlServerInfo: MysqlServerInfo{ServerUUID: "b", Port: 3306},
+			Channels:    []MysqlReplicaStatus{{SourceHost: "primary", SourcePort: 3306}},
+			DumpClients: []string{"c:41022"}},
+		"primary:3306": {MysqlServerInfo: MysqlServerInfo{ServerUUID: "a", Port: 3306}},
+	}
+	probe := func(addr string) (MysqlReplNode, error) {
+		if node, ok := nodes[addr]; ok {
+			return node, nil
+		}
+		return MysqlReplNode{}, errors.New("connection refused")
+	}
+
+	found, truncated := DiscoverReplTopology("a:3306", 10, probe)
+	if truncated || len(found) != 3 {
+		t.Fatalf("got %d nodes, truncated %v: %+v", len(found), truncated, found)
+	}
+	if found[0].Addr != "a:3306" || found[1].Addr != "b:3306" || found[2].Addr != "c:3306" || found[2].Error == "" {
+		t.Errorf("got %+v", found)
+	}
+
+	if found, truncated = DiscoverReplTopology("a:3306", 1, probe); !truncated || len(found) != 1 {
+		t.Errorf("got %d nodes, truncated %v", len(found), truncated)
+	}
+}
+
+func TestMysqlProbeURI(t *testing.T) {
+	base := &config.DBConfig{URI: "admin:secret@tcp(10.0.0.1:3306)/mysql?tls=skip-verify&charset=utf8mb4&parseTime=true"}
+	uri, err := mysqlProbeURI(base, "10.0.0.2:3307", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsn, err := gomysql.ParseDSN(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dsn.Addr != "10.0.0.2:3307" || dsn.User != "admin" || dsn.Passwd != "secret" || dsn.DBName != "mysql" {
+		t.Errorf("got %+v", dsn)
+	}
+	if dsn.TLSConfig != "skip-verify" || !dsn.ParseTime || dsn.Timeout != time.Second {
+		t.Errorf("connection params not kept: %s", uri)
+	}
+}
@@ -0,0 +1,199 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-29 10:20:14
+ */
+
+package db
+
+import "time"
+
+// MysqlProcessFilter processlist 的过滤条件, 所有条件同时满足
+type MysqlProcessFilter struct {
+	User       string `json:"user"`
+	Host       string `json:"host"`        // 客户端地址, 如 10.0.0.1 匹配该地址的所有连接, 10.0.0.1:53412 只匹配该端口的连接
+	DB         string `json:"db"`          // 当前使用的库
+	Command    string `json:"command"`     // Query, Sleep, Connect, Binlog Dump 等
+	State      string `json:"state"`       // 状态包含的内容, 不区分大小写
+	Info       string `json:"info"`        // SQL 包含的内容, 不区分大小写
+	MinTime    int64  `json:"min_time"`    // 当前状态持续的最少秒数
+	ActiveOnly bool   `json:"active_only"` // 排除 Sleep 的连接
+}
+
+// Empty 是否没有任何过滤条件
+func (f MysqlProcessFilter) Empty() bool {
+	return f.User == "" && f.Host == "" && f.DB == "" && f.Command == "" && f.State == "" && f.Info == "" && f.MinTime <= 0
+}
+
+// MysqlProcess show full processlist 返回的一个连接
+type MysqlProcess struct {
+	ID              int64  `json:"id" gorm:"column:Id"`
+	User            string `json:"user" gorm:"column:User"`
+	Host            string `json:"host" gorm:"column:Host"`
+	DB              string `json:"db" gorm:"column:db"`
+	Command         string `json:"command" gorm:"column:Command"`
+	Time            int64  `json:"time" gorm:"column:Time"`
+	State           string `json:"state" gorm:"column:State"`
+	Info            string `json:"info" gorm:"column:Info"`
+	Protected       bool   `json:"protected" gorm:"-"`        // 是否为系统, 复制或受保护用户的连接, 不允许 kill
+	ProtectedReason string `json:"protected_reason" gorm:"-"` // 不允许 kill 的原因
+}
+
+// MysqlKillResult 批量 kill 的结果
+type MysqlKillResult struct {
+	DryRun  bool             `json:"dry_run"`
+	Killed  []MysqlProcess   `json:"killed"`  // dry run 时为将要 kill 的连接
+	Skipped []MysqlProcess   `json:"skipped"` // 受保护的连接, 不会 kill
+	Failed  []MysqlKillError `json:"failed"`
+}
+
+type MysqlKillError struct {
+	ID    int64  `json:"id"`
+	Error string `json:"error"`
+}
+
+// MysqlVariable show variables/show status 返回的一行
+type MysqlVariable struct {
+	Name  string `json:"name" gorm:"column:Variable_name"`
+	Value string `json:"value" gorm:"column:Value"`
+}
+
+// MysqlStatusSample 一次采集的 global status
+type MysqlStatusSample struct {
+	Time   time.Time         `json:"time"`
+	Values map[string]string `json:"values"`
+}
+
+// MysqlStatusRate 两次采集之间一个状态值的变化, 累计值计算每秒速率, 瞬时值只返回当前值
+type MysqlStatusRate struct {
+	Name    string  `json:"name"`
+	Value   string  `json:"value"`   // 第二次采集的值
+	Counter bool    `json:"counter"` // 是否为累计值
+	Delta   float64 `json:"delta"`   // 两次采集的差值
+	Rate    float64 `json:"rate"`    // 每秒速率
+}
+
+// MysqlStatusRates 两次采集的结果, QPS 按 Questions 计算, TPS 按 Com_commit + Com_rollback 计算
+type MysqlStatusRates struct {
+	IntervalSec float64           `json:"interval_sec"`
+	QPS         float64           `json:"qps"`
+	TPS         float64           `json:"tps"`
+	Status      []MysqlStatusRate `json:"status"`
+}
+
+// MysqlInnodbStatus 解析后的 show engine innodb status
+type MysqlInnodbStatus struct {
+	Time         string                  `json:"time"`
+	Sections     []MysqlInnodbSection    `json:"sections"` // 按输出顺序的所有段落原文
+	Deadlock     *MysqlInnodbDeadlock    `json:"deadlock"` // 最近一次死锁, 没有死锁时为 null
+	Transactions MysqlInnodbTransactions `json:"transactions"`
+	BufferPool   MysqlInnodbBufferPool   `json:"buffer_pool"`
+}
+
+type MysqlInnodbSection struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// MysqlInnodbDeadlock LATEST DETECTED DEADLOCK 段落
+type MysqlInnodbDeadlock struct {
+	Time         string           `json:"time"`
+	Transactions []MysqlInnodbTrx `json:"transactions"`
+	RolledBack   int              `json:"rolled_back"` // 被回滚的事务序号, 从 1 开始, 未知时为 0
+}
+
+// MysqlInnodbTrx innodb 事务
+type MysqlInnodbTrx struct {
+	ID          string `json:"id"`
+	State       string `json:"state"` // ACTIVE, ACTIVE (PREPARED), not started 等
+	ActiveSecs  int64  `json:"active_secs"`
+	Operation   string `json:"operation"` // 正在执行的操作, 如 starting index read
+	ThreadID    int64  `json:"thread_id"` // 对应 processlist 的 id
+	QueryID     int64  `json:"query_id"`
+	Client      string `json:"client"` // 客户端地址, 用户和状态
+	LockStructs int64  `json:"lock_structs"`
+	RowLocks    int64  `json:"row_locks"`
+	UndoEntries int64  `json:"undo_entries"`
+	LockWait    bool   `json:"lock_wait"`
+	WaitSecs    int64  `json:"wait_secs"`
+	WaitingFor  string `json:"waiting_for"` // 等待的锁
+	Holds       string `json:"holds"`       // 持有的锁, 只有死锁信息中有
+	Query       string `json:"query"`
+}
+
+// MysqlInnodbTransactions TRANSACTIONS 段落
+type MysqlInnodbTransactions struct {
+	TrxIDCounter      int64            `json:"trx_id_counter"`
+	HistoryListLength int64            `json:"history_list_length"` // undo 中未 purge 的事务数, 持续增长说明有长事务
+	Transactions      []MysqlInnodbTrx `json:"transactions"`        // 不包含 not started 的事务
+}
+
+// MysqlInnodbBufferPool BUFFER POOL AND MEMORY 段落, 单位为页
+type MysqlInnodbBufferPool struct {
+	TotalPages    int64    `json:"total_pages"`
+	FreePages     int64    `json:"free_pages"`
+	DatabasePages int64    `json:"database_pages"`
+	ModifiedPages int64    `json:"modified_pages"`
+	HitRate       *float64 `json:"hit_rate"` // 0-1, 两次输出之间没有读取时为 null
+	PagesRead     int64    `json:"pages_read"`
+	PagesCreated  int64    `json:"pages_created"`
+	PagesWritten  int64    `json:"pages_written"`
+}
+
+// MysqlServerInfo 实例的基本信息和复制相关的变量
+type MysqlServerInfo struct {
+	ServerID      int64  `json:"server_id"`
+	ServerUUID    string `json:"server_uuid"`
+	Version       string `json:"version"`
+	Hostname      string `json:"hostname"`
+	Port          int    `json:"port"`
+	ReadOnly      bool   `json:"read_only"`
+	SuperReadOnly bool   `json:"super_read_only"`
+	LogBin        bool   `json:"log_bin"`
+	GTIDMode      string `json:"gtid_mode"`
+	GTIDExecuted  string `json:"gtid_executed"`
+}
+
+// MysqlReplicaStatus show replica status 中的一个复制通道, 兼容 show slave status 的字段名
+type MysqlReplicaStatus struct {
+	Channel            string `json:"channel"`
+	SourceHost         string `json:"source_host"`
+	SourcePort         int    `json:"source_port"`
+	SourceUser         string `json:"source_user"`
+	SourceServerID     int64  `json:"source_server_id"`
+	SourceUUID         string `json:"source_uuid"`
+	IOThread           string `json:"io_thread"`  // Yes, No, Connecting
+	SQLThread          string `json:"sql_thread"` // Yes, No
+	SQLState           string `json:"sql_state"`
+	SecondsBehind      *int64 `json:"seconds_behind"` // SQL 线程没有运行时为 null
+	SQLDelay           int64  `json:"sql_delay"`      // 延迟复制配置的秒数
+	LastIOErrno        int64  `json:"last_io_errno"`
+	LastIOError        string `json:"last_io_error"`
+	LastSQLErrno       int64  `json:"last_sql_errno"`
+	LastSQLError       string `json:"last_sql_error"`
+	SourceLogFile      string `json:"source_log_file"`
+	ReadSourceLogPos   int64  `json:"read_source_log_pos"`
+	RelaySourceLogFile string `json:"relay_source_log_file"`
+	ExecSourceLogPos   int64  `json:"exec_source_log_pos"`
+	AutoPosition       bool   `json:"auto_position"`
+	RetrievedGTIDSet   string `json:"retrieved_gtid_set"`
+	ExecutedGTIDSet    string `json:"executed_gtid_set"`
+}
+
+// MysqlReplicaHost show replicas 中的一个从库, 从库没有配置 report_host 时 Host 为空
+type MysqlReplicaHost struct {
+	ServerID int64  `json:"server_id"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	SourceID int64  `json:"source_id"`
+	UUID     string `json:"uuid"`
+}
+
+// MysqlReplNode 复制拓扑中的一个节点
+type MysqlReplNode struct {
+	Addr string `json:"addr"` // 连接使用的地址
+	MysqlServerInfo
+	Channels    []MysqlReplicaStatus `json:"channels"`     // 上游复制通道, 多源复制时有多个
+	Replicas    []MysqlReplicaHost   `json:"replicas"`     // show replicas 返回的下游
+	DumpClients []string             `json:"dump_clients"` // Binlog Dump 连接的客户端地址, 包括没有配置 report_host 的下游
+	Error       string               `json:"error"`        // 连接或读取失败时的错误信息
+}
@@ -86,3 +86,16 @@ func (db *PgSQLClient) Raw(sql string, values ...interface{}) *gorm.DB {
 func (db *PgSQLClient) Exec(sql string, values ...interface{}) *gorm.DB {
 	return db.conn.Exec(sql, values...)
 }
+
+// ServerVersion 实例的版本号 server_version_num, 如 90624, 150004
+func (db *PgSQLClient) ServerVersion() (int, error) {
+	return pgServerVersion(db.conn)
+}
+
+func pgServerVersion(conn *gorm.DB) (int, error) {
+	var version int
+	if err := conn.Raw("SELECT current_setting('server_version_num')::int").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("查询版本失败: %v", err)
+	}
+	return version, nil
+}
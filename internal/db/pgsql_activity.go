@@ -0,0 +1,290 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-06 10:40:18
+ */
+
+package db
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// pgActivitySQL backend_type 由 pgBackendTypeColumn 按版本填充
+const pgActivitySQL = `SELECT pid, COALESCE(usename, '') AS usename, COALESCE(datname, '') AS datname,
+	COALESCE(application_name, '') AS application_name, COALESCE(client_addr::text, '') AS client_addr,
+	%s AS backend_type, COALESCE(state, '') AS state,
+	COALESCE(wait_event_type, '') AS wait_event_type, COALESCE(wait_event, '') AS wait_event,
+	backend_start, xact_start, query_start, state_change,
+	COALESCE(EXTRACT(EPOCH FROM now() - xact_start), 0)::float8 AS xact_sec,
+	COALESCE(EXTRACT(EPOCH FROM now() - query_start), 0)::float8 AS query_sec,
+	COALESCE(EXTRACT(EPOCH FROM now() - state_change), 0)::float8 AS state_sec,
+	COALESCE(backend_xmin::text, '') AS backend_xmin, COALESCE(query, '') AS query,
+	array_to_string(pg_blocking_pids(pid), ',') AS blocked_by
+FROM pg_stat_activity`
+
+// Activity 查询 pg_stat_activity, 按过滤条件返回进程, 并标记不允许 cancel/terminate 的进程
+func (db *PgSQLClient) Activity(filter PgActivityFilter, protectedUsers []string) ([]PgActivity, error) {
+	var activities []PgActivity
+	err := db.conn.Connection(func(tx *gorm.DB) error {
+		var err error
+		activities, err = pgActivity(tx, filter, protectedUsers)
+		return err
+	})
+	return activities, err
+}
+
+func pgActivity(tx *gorm.DB, filter PgActivityFilter, protectedUsers []string) ([]PgActivity, error) {
+	var selfPID int64
+	if err := tx.Raw("SELECT pg_backend_pid()").Scan(&selfPID).Error; err != nil {
+		return nil, fmt.Errorf("获取当前连接 pid 失败: %v", err)
+	}
+
+	version, err := pgServerVersion(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []PgActivity
+	if err := tx.Raw(fmt.Sprintf(pgActivitySQL, pgBackendTypeColumn(version))).Scan(&all).Error; err != nil {
+		return nil, fmt.Errorf("查询 pg_stat_activity 失败: %v", err)
+	}
+
+	activities := []PgActivity{}
+	for _, a := range all {
+		if !filter.match(a) {
+			continue
+		}
+		a.BlockedBy = parsePgPIDs(a.BlockedByText)
+		a.ProtectedReason = a.protectedReason(selfPID, protectedUsers)
+		a.Protected = a.ProtectedReason != ""
+		activities = append(activities, a)
+	}
+	sort.SliceStable(activities, func(i, j int) bool { return activities[i].QuerySec > activities[j].QuerySec })
+	return activities, nil
+}
+
+// pgBackendTypeColumn 10 开始 pg_stat_activity 才有 backend_type 并且包含后台进程, 之前的版本只有客户端连接, 返回空
+func pgBackendTypeColumn(version int) string {
+	if version >= 100000 {
+		return "COALESCE(backend_type, '')"
+	}
+	return "''"
+}
+
+func parsePgPIDs(s string) []int64 {
+	pids := []int64{}
+	for _, item := range strings.Split(s, ",") {
+		if pid, err := strconv.ParseInt(strings.TrimSpace(item), 10, 64); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+func (f PgActivityFilter) match(a PgActivity) bool {
+	if f.User != "" && a.User != f.User {
+		return false
+	}
+	if f.DB != "" && a.DB != f.DB {
+		return false
+	}
+	if f.Application != "" && a.Application != f.Application {
+		return false
+	}
+	if f.ClientAddr != "" && !strings.HasPrefix(a.ClientAddr, f.ClientAddr) {
+		return false
+	}
+	if f.State != "" && !strings.EqualFold(a.State, f.State) {
+		return false
+	}
+	if f.Query != "" && !strings.Contains(strings.ToLower(a.Query), strings.ToLower(f.Query)) {
+		return false
+	}
+	if f.MinQuerySec > 0 && (a.State != "active" || a.QuerySec < f.MinQuerySec) {
+		return false
+	}
+	if f.ClientOnly && a.BackendType != "" && a.BackendType != "client backend" {
+		return false
+	}
+	return true
+}
+
+// protectedReason 判断进程是否不允许 cancel/terminate, 返回原因, 允许时返回空
+func (a PgActivity) protectedReason(selfPID int64, protectedUsers []string) string {
+	if a.PID == selfPID {
+		return "当前管理连接"
+	}
+	// 10 之前的版本没有 backend_type, pg_stat_activity 中只有客户端连接
+	if a.BackendType != "" && a.BackendType != "client backend" {
+		return "后台进程 " + a.BackendType
+	}
+	if slices.Contains(protectedUsers, a.User) {
+		return "受保护的用户 " + a.User
+	}
+	return ""
+}
+
+// Locks 查询 pg_locks, pid 不为 0 时只返回该进程的锁, waitingOnly 时只返回等待中的锁
+func (db *PgSQLClient) Locks(pid int64, waitingOnly bool) ([]PgLock, error) {
+	sql := `SELECT l.pid, l.locktype, COALESCE(d.datname, '') AS datname,
+	COALESCE(CASE WHEN l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		THEN l.relation::regclass::text ELSE l.relation::text END, '') AS relation,
+	l.mode, l.granted, COALESCE(l.transactionid::text, '') AS transactionid,
+	COALESCE(l.virtualxid, '') AS virtualxid, l.fastpath
+FROM pg_locks l LEFT JOIN pg_database d ON d.oid = l.database
+WHERE l.pid IS NOT NULL`
+	var values []any
+	if pid != 0 {
+		sql += " AND l.pid = ?"
+		values = append(values, pid)
+	}
+	if waitingOnly {
+		sql += " AND NOT l.granted"
+	}
+	sql += " ORDER BY l.pid, l.granted DESC, l.locktype"
+
+	locks := []PgLock{}
+	if err := db.conn.Raw(sql, values...).Scan(&locks).Error; err != nil {
+		return nil, fmt.Errorf("查询 pg_locks 失败: %v", err)
+	}
+	return locks, nil
+}
+
+// BlockingChains 按 pg_blocking_pids() 生成阻塞链, 根节点为没有被阻塞的阻塞者, 按阻塞的进程数排序
+// 相互等待(死锁检测之前)的进程没有根节点, 选择事务最早开始的进程作为根
+func BlockingChains(activities []PgActivity) []*PgBlockingNode {
+	byPID := map[int64]PgActivity{}
+	blocked := map[int64][]int64{}
+	for _, a := range activities {
+		byPID[a.PID] = a
+		for _, blocker := range a.BlockedBy {
+			blocked[blocker] = append(blocked[blocker], a.PID)
+		}
+	}
+
+	var build func(pid int64, path map[int64]bool, isBlocked bool) *PgBlockingNode
+	build = func(pid int64, path map[int64]bool, isBlocked bool) *PgBlockingNode {
+		a, ok := byPID[pid]
+		if !ok {
+			// 阻塞者不在过滤后的结果中
+			a = PgActivity{PID: pid, BlockedBy: []int64{}}
+		}
+		node := &PgBlockingNode{PgActivity: a, Children: []*PgBlockingNode{}}
+		if isBlocked {
+			node.WaitSec = a.QuerySec
+		}
+
+		path[pid] = true
+		for _, child := range blocked[pid] {
+			if path[child] {
+				continue
+			}
+			c := build(child, path, true)
+			node.Children = append(node.Children, c)
+			node.Blocking += 1 + c.Blocking
+		}
+		delete(path, pid)
+		sort.SliceStable(node.Children, func(i, j int) bool { return node.Children[i].WaitSec > node.Children[j].WaitSec })
+		return node
+	}
+
+	chains := []*PgBlockingNode{}
+	covered := map[int64]bool{}
+	var mark func(n *PgBlockingNode)
+	mark = func(n *PgBlockingNode) {
+		covered[n.PID] = true
+		for _, c := range n.Children {
+			mark(c)
+		}
+	}
+
+	blockers := make([]int64, 0, len(blocked))
+	for pid := range blocked {
+		blockers = append(blockers, pid)
+	}
+	sort.Slice(blockers, func(i, j int) bool { return byPID[blockers[i]].XactSec > byPID[blockers[j]].XactSec })
+	for _, pid := range blockers {
+		if len(byPID[pid].BlockedBy) == 0 {
+			chain := build(pid, map[int64]bool{}, false)
+			mark(chain)
+			chains = append(chains, chain)
+		}
+	}
+	for _, pid := range blockers {
+		if !covered[pid] {
+			chain := build(pid, map[int64]bool{}, false)
+			mark(chain)
+			chains = append(chains, chain)
+		}
+	}
+
+	sort.SliceStable(chains, func(i, j int) bool { return chains[i].Blocking > chains[j].Blocking })
+	return chains
+}
+
+// LongRunning 找出执行超过 querySec 秒的语句, 超过 xactSec 秒的事务和 idle in transaction 超过 idleSec 秒的连接
+func LongRunning(activities []PgActivity, querySec, xactSec, idleSec float64) PgLongRunning {
+	result := PgLongRunning{Queries: []PgActivity{}, Transactions: []PgActivity{}, IdleInTransaction: []PgActivity{}}
+	for _, a := range activities {
+		if a.BackendType != "" && a.BackendType != "client backend" {
+			continue
+		}
+		if a.State == "active" && a.QuerySec >= querySec {
+			result.Queries = append(result.Queries, a)
+		}
+		if a.XactStart != nil && a.XactSec >= xactSec {
+			result.Transactions = append(result.Transactions, a)
+		}
+		if strings.HasPrefix(a.State, "idle in transaction") && a.StateSec >= idleSec {
+			result.IdleInTransaction = append(result.IdleInTransaction, a)
+		}
+	}
+
+	sort.SliceStable(result.Queries, func(i, j int) bool { return result.Queries[i].QuerySec > result.Queries[j].QuerySec })
+	sort.SliceStable(result.Transactions, func(i, j int) bool { return result.Transactions[i].XactSec > result.Transactions[j].XactSec })
+	sort.SliceStable(result.IdleInTransaction, func(i, j int) bool {
+		return result.IdleInTransaction[i].StateSec > result.IdleInTransaction[j].StateSec
+	})
+	return result
+}
+
+// SignalBackend 执行 pg_cancel_backend 或 pg_terminate_backend, 进程不存在或者受保护时返回错误
+// 查询进程和发送信号在同一个数据库连接上执行, 避免终止自己
+func (db *PgSQLClient) SignalBackend(pid int64, terminate bool, protectedUsers []string) (PgActivity, error) {
+	var target PgActivity
+	err := db.conn.Connection(func(tx *gorm.DB) error {
+		activities, err := pgActivity(tx, PgActivityFilter{}, protectedUsers)
+		if err != nil {
+			return err
+		}
+
+		i := slices.IndexFunc(activities, func(a PgActivity) bool { return a.PID == pid })
+		if i < 0 {
+			return fmt.Errorf("进程 %d 不存在或已经退出", pid)
+		}
+		target = activities[i]
+		if target.Protected {
+			return fmt.Errorf("进程 %d 不允许 cancel/terminate, %s", pid, target.ProtectedReason)
+		}
+
+		fn := "pg_cancel_backend"
+		if terminate {
+			fn = "pg_terminate_backend"
+		}
+		var ok bool
+		if err := tx.Raw(fmt.Sprintf("SELECT %s(?)", fn), pid).Scan(&ok).Error; err != nil {
+			return fmt.Errorf("执行 %s(%d) 失败: %v", fn, pid, err)
+		}
+		if !ok {
+			return fmt.Errorf("执行 %s(%d) 失败, 进程已经退出", fn, pid)
+		}
+		return nil
+	})
+	return target, err
+}
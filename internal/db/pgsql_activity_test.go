@@ -0,0 +1,124 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-06 16:18:52
+ */
+
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPgActivityFilter(t *testing.T) {
+	a := PgActivity{PID: 10, User: "app", DB: "shop", Application: "api", ClientAddr: "10.0.0.8", BackendType: "client backend",
+		State: "active", QuerySec: 30, Query: "SELECT * FROM orders"}
+
+	for _, f := range []PgActivityFilter{
+		{},
+		{User: "app", DB: "shop", Application: "api", ClientAddr: "10.0.0"},
+		{State: "ACTIVE", Query: "from ORDERS", MinQuerySec: 30, ClientOnly: true},
+	} {
+		if !f.match(a) {
+			t.Errorf("%+v should match", f)
+		}
+	}
+	for _, f := range []PgActivityFilter{
+		{User: "postgres"},
+		{ClientAddr: "10.0.1"},
+		{MinQuerySec: 31},
+		{State: "idle"},
+	} {
+		if f.match(a) {
+			t.Errorf("%+v should not match", f)
+		}
+	}
+	if (PgActivityFilter{ClientOnly: true}).match(PgActivity{BackendType: "autovacuum worker"}) {
+		t.Error("client_only should exclude background workers")
+	}
+	// 10 之前的版本没有 backend_type, 都是客户端连接
+	if !(PgActivityFilter{ClientOnly: true}).match(PgActivity{}) {
+		t.Error("client_only should keep connections without backend_type")
+	}
+	if pgBackendTypeColumn(90624) != "''" || pgBackendTypeColumn(100000) != "COALESCE(backend_type, '')" {
+		t.Error("backend_type column should depend on server version")
+	}
+}
+
+func TestPgActivityProtected(t *testing.T) {
+	protected := []string{"repl"}
+	for _, tc := range []struct {
+		a         PgActivity
+		protected bool
+	}{
+		{PgActivity{PID: 1, User: "app", BackendType: "client backend"}, false},
+		{PgActivity{PID: 2, User: "app"}, false},
+		{PgActivity{PID: 3, User: "app", BackendType: "client backend"}, true},
+		{PgActivity{PID: 4, User: "postgres", BackendType: "autovacuum worker"}, true},
+		{PgActivity{PID: 5, User: "repl", BackendType: "walsender"}, true},
+		{PgActivity{PID: 6, User: "repl", BackendType: "client backend"}, true},
+	} {
+		if got := tc.a.protectedReason(3, protected) != ""; got != tc.protected {
+			t.Errorf("pid %d: protected %v, want %v", tc.a.PID, got, tc.protected)
+		}
+	}
+
+	if got := parsePgPIDs("12, 34,"); !slices.Equal(got, []int64{12, 34}) {
+		t.Errorf("parse pids got %v", got)
+	}
+	if got := parsePgPIDs(""); len(got) != 0 {
+		t.Errorf("parse empty got %v", got)
+	}
+}
+
+func TestBlockingChains(t *testing.T) {
+	// 1 阻塞 2 和 3, 2 阻塞 4; 5 和 6 相互等待; 7 没有阻塞关系
+	activities := []PgActivity{
+		{PID: 1, XactSec: 100, BlockedBy: []int64{}},
+		{PID: 2, QuerySec: 50, BlockedBy: []int64{1}},
+		{PID: 3, QuerySec: 80, BlockedBy: []int64{1}},
+		{PID: 4, QuerySec: 20, BlockedBy: []int64{2}},
+		{PID: 5, XactSec: 10, QuerySec: 5, BlockedBy: []int64{6}},
+		{PID: 6, XactSec: 20, QuerySec: 3, BlockedBy: []int64{5}},
+		{PID: 7, BlockedBy: []int64{}},
+	}
+	chains := BlockingChains(activities)
+	if len(chains) != 2 {
+		t.Fatalf("got %d chains", len(chains))
+	}
+
+	root := chains[0]
+	if root.PID != 1 || root.Blocking != 3 || root.WaitSec != 0 || len(root.Children) != 2 {
+		t.Fatalf("root got %+v", root)
+	}
+	if root.Children[0].PID != 3 || root.Children[0].WaitSec != 80 || root.Children[1].PID != 2 || root.Children[1].Children[0].PID != 4 {
+		t.Errorf("children got %+v, %+v", root.Children[0], root.Children[1])
+	}
+
+	cycle := chains[1]
+	if cycle.PID != 6 || cycle.Blocking != 1 || cycle.Children[0].PID != 5 || len(cycle.Children[0].Children) != 0 {
+		t.Errorf("cycle got %+v", cycle)
+	}
+}
+
+func TestLongRunning(t *testing.T) {
+	start := time.Now()
+	activities := []PgActivity{
+		{PID: 1, BackendType: "client backend", State: "active", QuerySec: 90, XactStart: &start, XactSec: 90},
+		{PID: 2, BackendType: "client backend", State: "idle in transaction", StateSec: 120, XactStart: &start, XactSec: 400},
+		{PID: 3, BackendType: "client backend", State: "idle", QuerySec: 1000},
+		{PID: 4, BackendType: "autovacuum worker", State: "active", QuerySec: 1000, XactStart: &start, XactSec: 1000},
+		{PID: 5, BackendType: "client backend", State: "idle in transaction (aborted)", StateSec: 30, XactStart: &start, XactSec: 30},
+	}
+	result := LongRunning(activities, 60, 300, 60)
+	if len(result.Queries) != 1 || result.Queries[0].PID != 1 {
+		t.Errorf("queries got %+v", result.Queries)
+	}
+	if len(result.Transactions) != 1 || result.Transactions[0].PID != 2 {
+		t.Errorf("transactions got %+v", result.Transactions)
+	}
+	if len(result.IdleInTransaction) != 1 || result.IdleInTransaction[0].PID != 2 {
+		t.Errorf("idle in transaction got %+v", result.IdleInTransaction)
+	}
+}
@@ -0,0 +1,251 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-08 10:25:13
+ */
+
+package db
+
+import "fmt"
+
+// pgWAL 10 开始 xlog 相关的函数和 pg_stat_replication 的列改名为 wal/lsn, 之前的版本没有 write_lag, flush_lag 和 replay_lag
+type pgWAL struct {
+	currentLSN string // 当前 wal 位置, 从库使用接收到的位置
+	receiveLSN string
+	replayLSN  string
+	lsnDiff    string
+	lsnColumn  string // pg_stat_replication 中 sent_lsn 等列的后缀
+	hasLag     bool
+}
+
+func pgWALNames(version int) pgWAL {
+	if version >= 100000 {
+		return pgWAL{
+			currentLSN: `(CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn() ELSE pg_current_wal_lsn() END)`,
+			receiveLSN: "pg_last_wal_receive_lsn()",
+			replayLSN:  "pg_last_wal_replay_lsn()",
+			lsnDiff:    "pg_wal_lsn_diff",
+			lsnColumn:  "lsn",
+			hasLag:     true,
+		}
+	}
+	return pgWAL{
+		currentLSN: `(CASE WHEN pg_is_in_recovery() THEN pg_last_xlog_receive_location() ELSE pg_current_xlog_location() END)`,
+		receiveLSN: "pg_last_xlog_receive_location()",
+		replayLSN:  "pg_last_xlog_replay_location()",
+		lsnDiff:    "pg_xlog_location_diff",
+		lsnColumn:  "location",
+	}
+}
+
+// lag pg_stat_replication 中的延迟列, 10 之前的版本为 null
+func (w pgWAL) lag(column string) string {
+	if w.hasLag {
+		return fmt.Sprintf("EXTRACT(EPOCH FROM %s)::float8", column)
+	}
+	return "NULL::float8"
+}
+
+// wal 按实例的版本返回 wal 相关的函数名
+func (db *PgSQLClient) wal() (pgWAL, error) {
+	version, err := db.ServerVersion()
+	if err != nil {
+		return pgWAL{}, err
+	}
+	return pgWALNames(version), nil
+}
+
+// Recovery 实例是否为从库, 从库回放的延迟
+func (db *PgSQLClient) Recovery() (PgRecovery, error) {
+	wal, err := db.wal()
+	if err != nil {
+		return PgRecovery{}, err
+	}
+
+	var recovery PgRecovery
+	err = db.conn.Raw(fmt.Sprintf(`SELECT pg_is_in_recovery() AS in_recovery,
+	CASE WHEN NOT pg_is_in_recovery() THEN NULL
+		WHEN %s = %s THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::float8 END AS replay_delay_sec`, wal.receiveLSN, wal.replayLSN)).Scan(&recovery).Error
+	if err != nil {
+		return PgRecovery{}, fmt.Errorf("查询复制状态失败: %v", err)
+	}
+	return recovery, nil
+}
+
+// Replication 查询 pg_stat_replication, 返回所有下游(包括级联从库和 pg_basebackup)
+func (db *PgSQLClient) Replication() ([]PgReplication, error) {
+	wal, err := db.wal()
+	if err != nil {
+		return nil, err
+	}
+
+	replication := []PgReplication{}
+	err = db.conn.Raw(fmt.Sprintf(`SELECT pid, COALESCE(usename, '') AS usename, COALESCE(application_name, '') AS application_name,
+	COALESCE(client_addr::text, '') AS client_addr, COALESCE(state, '') AS state, COALESCE(sync_state, '') AS sync_state,
+	COALESCE(sent_%[1]s::text, '') AS sent_lsn, COALESCE(write_%[1]s::text, '') AS write_lsn,
+	COALESCE(flush_%[1]s::text, '') AS flush_lsn, COALESCE(replay_%[1]s::text, '') AS replay_lsn,
+	%[2]s AS write_lag_sec,
+	%[3]s AS flush_lag_sec,
+	%[4]s AS replay_lag_sec,
+	COALESCE(%[5]s(%[6]s, replay_%[1]s), 0)::int8 AS replay_lag_bytes
+FROM pg_stat_replication ORDER BY application_name, pid`,
+		wal.lsnColumn, wal.lag("write_lag"), wal.lag("flush_lag"), wal.lag("replay_lag"), wal.lsnDiff, wal.currentLSN)).Scan(&replication).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询 pg_stat_replication 失败: %v", err)
+	}
+	return replication, nil
+}
+
+// ReplicationSlots 查询 pg_replication_slots 和每个复制槽保留的 wal 大小
+func (db *PgSQLClient) ReplicationSlots() ([]PgReplicationSlot, error) {
+	wal, err := db.wal()
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []PgReplicationSlot{}
+	err = db.conn.Raw(fmt.Sprintf(`SELECT slot_name, COALESCE(plugin, '') AS plugin, slot_type, COALESCE(database, '') AS database,
+	active, COALESCE(active_pid, 0) AS active_pid, COALESCE(restart_lsn::text, '') AS restart_lsn,
+	COALESCE(%s(%s, restart_lsn), 0)::int8 AS retained_bytes
+FROM pg_replication_slots ORDER BY slot_name`, wal.lsnDiff, wal.currentLSN)).Scan(&slots).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询 pg_replication_slots 失败: %v", err)
+	}
+	return slots, nil
+}
+
+// 按 pg_stats 估算表的膨胀, 从来没有 analyze 过(reltuples = -1)的表跳过, 来自 https://github.com/ioguix/pgsql-bloat-estimation
+const pgTableBloatSQL = `SELECT schemaname, tblname, ''::text AS idxname, (bs * tblpages)::int8 AS real_size,
+	(CASE WHEN tblpages > est_tblpages_ff THEN (tblpages - est_tblpages_ff) * bs ELSE 0 END)::int8 AS bloat_size, fillfactor
+FROM (
+	SELECT ceil(reltuples / ((bs - page_hdr) * fillfactor / (tpl_size * 100))) + ceil(toasttuples / 4) AS est_tblpages_ff,
+		tblpages, fillfactor, bs, schemaname, tblname
+	FROM (
+		SELECT (4 + tpl_hdr_size + tpl_data_size + (2 * ma)
+				- CASE WHEN tpl_hdr_size % ma = 0 THEN ma ELSE tpl_hdr_size % ma END
+				- CASE WHEN ceil(tpl_data_size)::int % ma = 0 THEN ma ELSE ceil(tpl_data_size)::int % ma END
+			) AS tpl_size, heappages + toastpages AS tblpages, reltuples, toasttuples, bs, page_hdr, schemaname, tblname, fillfactor
+		FROM (
+			SELECT ns.nspname AS schemaname, tbl.relname AS tblname, tbl.reltuples, tbl.relpages AS heappages,
+				COALESCE(toast.relpages, 0) AS toastpages, COALESCE(toast.reltuples, 0) AS toasttuples,
+				COALESCE(substring(array_to_string(tbl.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 100) AS fillfactor,
+				current_setting('block_size')::numeric AS bs,
+				CASE WHEN version() ~ 'mingw32' OR version() ~ '64-bit|x86_64|ppc64|ia64|amd64' THEN 8 ELSE 4 END AS ma,
+				24 AS page_hdr,
+				23 + CASE WHEN max(COALESCE(s.null_frac, 0)) > 0 THEN (7 + count(s.attname)) / 8 ELSE 0::int END AS tpl_hdr_size,
+				sum((1 - COALESCE(s.null_frac, 0)) * COALESCE(s.avg_width, 0)) AS tpl_data_size
+			FROM pg_attribute AS att
+				JOIN pg_class AS tbl ON att.attrelid = tbl.oid
+				JOIN pg_namespace AS ns ON ns.oid = tbl.relnamespace
+				LEFT JOIN pg_stats AS s ON s.schemaname = ns.nspname AND s.tablename = tbl.relname AND s.inherited = false AND s.attname = att.attname
+				LEFT JOIN pg_class AS toast ON tbl.reltoastrelid = toast.oid
+			WHERE NOT att.attisdropped AND att.attnum > 0 AND tbl.relkind IN ('r', 'm') AND tbl.relpages > 0 AND tbl.reltuples >= 0
+				AND ns.nspname NOT IN ('pg_catalog', 'information_schema')
+			GROUP BY 1, 2, 3, 4, 5, 6, 7, 8, 9, 10
+		) AS s
+	) AS s2
+) AS s3
+ORDER BY bloat_size DESC LIMIT ?`
+
+// 按 pg_stats 估算 btree 索引的膨胀, 表达式索引的列没有统计信息, 不在结果中
+const pgIndexBloatSQL = `SELECT nspname AS schemaname, tblname, idxname, (bs * relpages)::int8 AS real_size,
+	(CASE WHEN relpages > est_pages_ff THEN bs * (relpages - est_pages_ff) ELSE 0 END)::int8 AS bloat_size, fillfactor
+FROM (
+	SELECT COALESCE(1 + ceil(reltuples / floor((bs - pageopqdata - pagehdr) * fillfactor / (100 * (4 + nulldatahdrwidth)::float))), 0) AS est_pages_ff,
+		bs, nspname, tblname, idxname, relpages, fillfactor
+	FROM (
+		SELECT bs, nspname, tblname, idxname, reltuples, relpages, fillfactor, pagehdr, pageopqdata,
+			(index_tuple_hdr_bm + maxalign
+				- CASE WHEN index_tuple_hdr_bm % maxalign = 0 THEN maxalign ELSE index_tuple_hdr_bm % maxalign END
+				+ nulldatawidth + maxalign
+				- CASE WHEN nulldatawidth = 0 THEN 0 WHEN nulldatawidth::integer % maxalign = 0 THEN maxalign ELSE nulldatawidth::integer % maxalign END
+			)::numeric AS nulldatahdrwidth
+		FROM (
+			SELECT n.nspname, i.tblname, i.idxname, i.reltuples, i.relpages, i.fillfactor,
+				current_setting('block_size')::numeric AS bs,
+				CASE WHEN version() ~ 'mingw32' OR version() ~ '64-bit|x86_64|ppc64|ia64|amd64' THEN 8 ELSE 4 END AS maxalign,
+				24 AS pagehdr, 16 AS pageopqdata,
+				CASE WHEN max(COALESCE(s.null_frac, 0)) = 0 THEN 8 ELSE 8 + ((32 + 8 - 1) / 8) END AS index_tuple_hdr_bm,
+				sum((1 - COALESCE(s.null_frac, 0)) * COALESCE(s.avg_width, 1024)) AS nulldatawidth
+			FROM pg_attribute AS a
+				JOIN (
+					SELECT idx.relnamespace, tbl.relname AS tblname, idx.relname AS idxname, idx.reltuples, idx.relpages, idx.relam,
+						pg_index.indrelid, pg_index.indkey, generate_series(0, pg_index.indnatts - 1) AS attpos,
+						COALESCE(substring(array_to_string(idx.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 90) AS fillfactor
+					FROM pg_index
+						JOIN pg_class AS idx ON idx.oid = pg_index.indexrelid
+						JOIN pg_class AS tbl ON tbl.oid = pg_index.indrelid
+					WHERE idx.relpages > 0 AND idx.reltuples >= 0
+				) AS i ON a.attrelid = i.indrelid AND a.attnum = i.indkey[i.attpos]
+				JOIN pg_namespace AS n ON n.oid = i.relnamespace
+				JOIN pg_am AS am ON am.oid = i.relam AND am.amname = 'btree'
+				LEFT JOIN pg_stats AS s ON s.schemaname = n.nspname AND s.tablename = i.tblname AND s.inherited = false AND s.attname = a.attname
+			WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+			GROUP BY 1, 2, 3, 4, 5, 6, 7, 8, 9, 10
+		) AS s1
+	) AS s2
+) AS s3
+ORDER BY bloat_size DESC LIMIT ?`
+
+// TableBloat 估算当前库中膨胀最多的 limit 个表
+func (db *PgSQLClient) TableBloat(limit int) ([]PgBloat, error) {
+	return db.bloat(pgTableBloatSQL, limit)
+}
+
+// IndexBloat 估算当前库中膨胀最多的 limit 个 btree 索引
+func (db *PgSQLClient) IndexBloat(limit int) ([]PgBloat, error) {
+	return db.bloat(pgIndexBloatSQL, limit)
+}
+
+func (db *PgSQLClient) bloat(sql string, limit int) ([]PgBloat, error) {
+	bloat := []PgBloat{}
+	if err := db.conn.Raw(sql, limit).Scan(&bloat).Error; err != nil {
+		return nil, fmt.Errorf("估算膨胀失败: %v", err)
+	}
+	for i := range bloat {
+		if bloat[i].RealBytes > 0 {
+			bloat[i].BloatRatio = float64(bloat[i].BloatBytes) / float64(bloat[i].RealBytes)
+		}
+	}
+	return bloat, nil
+}
+
+// VacuumProgress 查询 pg_stat_progress_vacuum, 包括手动 vacuum 和 autovacuum
+func (db *PgSQLClient) VacuumProgress() ([]PgVacuumProgress, error) {
+	progress := []PgVacuumProgress{}
+	err := db.conn.Raw(`SELECT p.pid, COALESCE(p.datname, '') AS datname,
+	CASE WHEN p.datname = current_database() THEN p.relid::regclass::text ELSE p.relid::text END AS relation,
+	p.phase, p.heap_blks_total, p.heap_blks_scanned, p.heap_blks_vacuumed, p.index_vacuum_count,
+	COALESCE(a.query LIKE 'autovacuum:%', false) AS autovacuum,
+	COALESCE(a.query LIKE '%to prevent wraparound%', false) AS wraparound,
+	COALESCE(EXTRACT(EPOCH FROM now() - a.xact_start), 0)::float8 AS running_sec, COALESCE(a.query, '') AS query
+FROM pg_stat_progress_vacuum AS p LEFT JOIN pg_stat_activity AS a ON a.pid = p.pid
+ORDER BY running_sec DESC`).Scan(&progress).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询 pg_stat_progress_vacuum 失败: %v", err)
+	}
+	return progress, nil
+}
+
+// Wraparound 查询所有库和当前库中年龄最大的 limit 个表的事务 id 年龄
+func (db *PgSQLClient) Wraparound(limit int) (PgWraparound, error) {
+	wraparound := PgWraparound{Databases: []PgXIDAge{}, Tables: []PgXIDAge{}}
+	if err := db.conn.Raw("SELECT current_setting('autovacuum_freeze_max_age')::int8").Scan(&wraparound.FreezeMaxAge).Error; err != nil {
+		return wraparound, fmt.Errorf("查询 autovacuum_freeze_max_age 失败: %v", err)
+	}
+
+	err := db.conn.Raw(`SELECT datname, age(datfrozenxid)::int8 AS xid_age, mxid_age(datminmxid)::int8 AS mxid_age
+FROM pg_database WHERE datallowconn ORDER BY xid_age DESC`).Scan(&wraparound.Databases).Error
+	if err != nil {
+		return wraparound, fmt.Errorf("查询库的事务 id 年龄失败: %v", err)
+	}
+
+	err = db.conn.Raw(`SELECT current_database() AS datname, c.oid::regclass::text AS relation,
+	age(c.relfrozenxid)::int8 AS xid_age, mxid_age(c.relminmxid)::int8 AS mxid_age, pg_total_relation_size(c.oid) AS total_bytes
+FROM pg_class AS c WHERE c.relkind IN ('r', 'm', 't') AND c.relfrozenxid <> '0'
+ORDER BY xid_age DESC LIMIT ?`, limit).Scan(&wraparound.Tables).Error
+	if err != nil {
+		return wraparound, fmt.Errorf("查询表的事务 id 年龄失败: %v", err)
+	}
+	return wraparound, nil
+}
@@ -0,0 +1,17 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-08 11:02:37
+ */
+
+package db
+
+import "testing"
+
+func TestPgWALNames(t *testing.T) {
+	if w := pgWALNames(90624); w.lsnDiff != "pg_xlog_location_diff" || w.lsnColumn != "location" || w.lag("replay_lag") != "NULL::float8" {
+		t.Errorf("9.6 got %+v", w)
+	}
+	if w := pgWALNames(100000); w.lsnDiff != "pg_wal_lsn_diff" || w.lsnColumn != "lsn" || w.lag("replay_lag") != "EXTRACT(EPOCH FROM replay_lag)::float8" {
+		t.Errorf("10 got %+v", w)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-06 10:12:40
+ */
+
+package db
+
+import "time"
+
+// PgActivityFilter pg_stat_activity 的过滤条件, 所有条件同时满足
+type PgActivityFilter struct {
+	User        string  `json:"user"`
+	DB          string  `json:"db"`
+	Application string  `json:"application"`
+	ClientAddr  string  `json:"client_addr"`   // 客户端地址前缀
+	State       string  `json:"state"`         // active, idle, idle in transaction 等
+	Query       string  `json:"query"`         // SQL 包含的内容, 不区分大小写
+	MinQuerySec float64 `json:"min_query_sec"` // 当前语句执行的最少秒数
+	ClientOnly  bool    `json:"client_only"`   // 只返回客户端连接, 排除 autovacuum, walsender 等后台进程
+}
+
+// PgActivity pg_stat_activity 中的一个进程
+type PgActivity struct {
+	PID             int64      `json:"pid" gorm:"column:pid"`
+	User            string     `json:"user" gorm:"column:usename"`
+	DB              string     `json:"db" gorm:"column:datname"`
+	Application     string     `json:"application" gorm:"column:application_name"`
+	ClientAddr      string     `json:"client_addr" gorm:"column:client_addr"`
+	BackendType     string     `json:"backend_type" gorm:"column:backend_type"` // client backend, autovacuum worker, walsender 等, 10 之前的版本为空
+	State           string     `json:"state" gorm:"column:state"`
+	WaitEventType   string     `json:"wait_event_type" gorm:"column:wait_event_type"`
+	WaitEvent       string     `json:"wait_event" gorm:"column:wait_event"`
+	BackendStart    *time.Time `json:"backend_start" gorm:"column:backend_start"`
+	XactStart       *time.Time `json:"xact_start" gorm:"column:xact_start"` // 没有事务时为 null
+	QueryStart      *time.Time `json:"query_start" gorm:"column:query_start"`
+	StateChange     *time.Time `json:"state_change" gorm:"column:state_change"`
+	XactSec         float64    `json:"xact_sec" gorm:"column:xact_sec"`   // 事务已经执行的秒数
+	QuerySec        float64    `json:"query_sec" gorm:"column:query_sec"` // 当前(或最后一条)语句已经执行的秒数
+	StateSec        float64    `json:"state_sec" gorm:"column:state_sec"` // 处于当前状态的秒数
+	BackendXmin     string     `json:"backend_xmin" gorm:"column:backend_xmin"`
+	Query           string     `json:"query" gorm:"column:query"`
+	BlockedBy       []int64    `json:"blocked_by" gorm:"-"` // pg_blocking_pids() 返回的阻塞当前进程的进程
+	BlockedByText   string     `json:"-" gorm:"column:blocked_by"`
+	Protected       bool       `json:"protected" gorm:"-"` // 是否为后台进程, 当前管理连接或受保护用户的连接, 不允许 cancel/terminate
+	ProtectedReason string     `json:"protected_reason" gorm:"-"`
+}
+
+// PgLock pg_locks 中的一个锁
+type PgLock struct {
+	PID           int64  `json:"pid" gorm:"column:pid"`
+	LockType      string `json:"lock_type" gorm:"column:locktype"` // relation, transactionid, tuple, advisory 等
+	DB            string `json:"db" gorm:"column:datname"`
+	Relation      string `json:"relation" gorm:"column:relation"` // 其他库的表只能显示 oid
+	Mode          string `json:"mode" gorm:"column:mode"`
+	Granted       bool   `json:"granted" gorm:"column:granted"`
+	TransactionID string `json:"transaction_id" gorm:"column:transactionid"`
+	VirtualXID    string `json:"virtual_xid" gorm:"column:virtualxid"`
+	FastPath      bool   `json:"fast_path" gorm:"column:fastpath"`
+}
+
+// PgBlockingNode 阻塞链中的一个进程, Children 为被它直接阻塞的进程
+// 被多个进程阻塞的进程会出现在每个阻塞者下面
+type PgBlockingNode struct {
+	PgActivity
+	WaitSec  float64           `json:"wait_sec"` // 被阻塞的秒数, 按当前语句开始时间计算, 根节点为 0
+	Blocking int               `json:"blocking"` // 直接和间接阻塞的进程数
+	Children []*PgBlockingNode `json:"children"`
+}
+
+// PgLongRunning 长时间运行的语句, 长事务和长时间 idle in transaction 的连接
+type PgLongRunning struct {
+	Queries           []PgActivity `json:"queries"`
+	Transactions      []PgActivity `json:"transactions"`
+	IdleInTransaction []PgActivity `json:"idle_in_transaction"`
+}
+
+// PgRecovery 实例是否为从库, 以及从库回放的延迟
+type PgRecovery struct {
+	InRecovery     bool     `json:"in_recovery" gorm:"column:in_recovery"`
+	ReplayDelaySec *float64 `json:"replay_delay_sec" gorm:"column:replay_delay_sec"` // 已经回放完接收到的 wal 时为 0, 主库或者没有回放过事务时为 null
+}
+
+// PgReplication pg_stat_replication 中的一个下游, lag 在下游没有新的 wal 需要处理时为 null, 10 之前的版本没有 lag, 也为 null
+type PgReplication struct {
+	PID            int64    `json:"pid" gorm:"column:pid"`
+	User           string   `json:"user" gorm:"column:usename"`
+	Application    string   `json:"application" gorm:"column:application_name"`
+	ClientAddr     string   `json:"client_addr" gorm:"column:client_addr"`
+	State          string   `json:"state" gorm:"column:state"`           // startup, catchup, streaming, backup, stopping
+	SyncState      string   `json:"sync_state" gorm:"column:sync_state"` // async, potential, sync, quorum
+	SentLSN        string   `json:"sent_lsn" gorm:"column:sent_lsn"`
+	WriteLSN       string   `json:"write_lsn" gorm:"column:write_lsn"`
+	FlushLSN       string   `json:"flush_lsn" gorm:"column:flush_lsn"`
+	ReplayLSN      string   `json:"replay_lsn" gorm:"column:replay_lsn"`
+	WriteLagSec    *float64 `json:"write_lag_sec" gorm:"column:write_lag_sec"`
+	FlushLagSec    *float64 `json:"flush_lag_sec" gorm:"column:flush_lag_sec"`
+	ReplayLagSec   *float64 `json:"replay_lag_sec" gorm:"column:replay_lag_sec"`
+	ReplayLagBytes int64    `json:"replay_lag_bytes" gorm:"column:replay_lag_bytes"` // 当前 wal 位置和 replay_lsn 的差值
+}
+
+// PgReplicationSlot pg_replication_slots 中的一个复制槽
+type PgReplicationSlot struct {
+	Name          string `json:"name" gorm:"column:slot_name"`
+	Plugin        string `json:"plugin" gorm:"column:plugin"`
+	SlotType      string `json:"slot_type" gorm:"column:slot_type"` // physical, logical
+	DB            string `json:"db" gorm:"column:database"`
+	Active        bool   `json:"active" gorm:"column:active"`
+	ActivePID     int64  `json:"active_pid" gorm:"column:active_pid"`
+	RestartLSN    string `json:"restart_lsn" gorm:"column:restart_lsn"`
+	RetainedBytes int64  `json:"retained_bytes" gorm:"column:retained_bytes"` // 复制槽保留的 wal 大小
+}
+
+// PgBloat 表或 btree 索引的膨胀估算, 按 pg_stats 的统计信息估算, 没有 analyze 过的表不准确
+type PgBloat struct {
+	Schema     string  `json:"schema" gorm:"column:schemaname"`
+	Table      string  `json:"table" gorm:"column:tblname"`
+	Index      string  `json:"index,omitempty" gorm:"column:idxname"`
+	RealBytes  int64   `json:"real_bytes" gorm:"column:real_size"`
+	BloatBytes int64   `json:"bloat_bytes" gorm:"column:bloat_size"`
+	BloatRatio float64 `json:"bloat_ratio" gorm:"-"` // BloatBytes / RealBytes
+	Fillfactor int     `json:"fillfactor" gorm:"column:fillfactor"`
+}
+
+// PgVacuumProgress pg_stat_progress_vacuum 中正在执行的 vacuum
+type PgVacuumProgress struct {
+	PID              int64   `json:"pid" gorm:"column:pid"`
+	DB               string  `json:"db" gorm:"column:datname"`
+	Relation         string  `json:"relation" gorm:"column:relation"` // 其他库的表只能显示 oid
+	Phase            string  `json:"phase" gorm:"column:phase"`
+	HeapBlksTotal    int64   `json:"heap_blks_total" gorm:"column:heap_blks_total"`
+	HeapBlksScanned  int64   `json:"heap_blks_scanned" gorm:"column:heap_blks_scanned"`
+	HeapBlksVacuumed int64   `json:"heap_blks_vacuumed" gorm:"column:heap_blks_vacuumed"`
+	IndexVacuumCount int64   `json:"index_vacuum_count" gorm:"column:index_vacuum_count"`
+	Autovacuum       bool    `json:"autovacuum" gorm:"column:autovacuum"`
+	Wraparound       bool    `json:"wraparound" gorm:"column:wraparound"` // 是否为防止事务 id 回卷的 autovacuum
+	RunningSec       float64 `json:"running_sec" gorm:"column:running_sec"`
+	Query            string  `json:"query" gorm:"column:query"`
+}
+
+// PgXIDAge 库或表的事务 id 年龄, 达到 2^31 时实例会拒绝写入
+type PgXIDAge struct {
+	DB         string `json:"db" gorm:"column:datname"`
+	Relation   string `json:"relation,omitempty" gorm:"column:relation"`
+	XIDAge     int64  `json:"xid_age" gorm:"column:xid_age"`
+	MXIDAge    int64  `json:"mxid_age" gorm:"column:mxid_age"`
+	TotalBytes int64  `json:"total_bytes,omitempty" gorm:"column:total_bytes"`
+}
+
+// PgWraparound 事务 id 回卷的风险, Tables 只包含当前库中年龄最大的表
+type PgWraparound struct {
+	FreezeMaxAge int64      `json:"freeze_max_age"` // autovacuum_freeze_max_age
+	Databases    []PgXIDAge `json:"databases"`
+	Tables       []PgXIDAge `json:"tables"`
+}
@@ -13,18 +13,62 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// redis 部署模式
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
 type RedisClient struct {
 	config *config.RedisConfig
-	Conn   *redis.Client
+	Conn   redis.UniversalClient
 }
 
+// NewRedisClient 根据部署模式创建连接:
+// standalone 使用 URI 或 host/port 连接单个节点; sentinel 通过哨兵连接主库, 主从切换后自动重连新主库; cluster 通过种子节点连接集群
 func NewRedisClient(config *config.RedisConfig) (*RedisClient, error) {
+	var conn redis.UniversalClient
+
+	switch config.Mode {
+	case "", RedisModeStandalone:
+		opt, err := redisOptions(config)
+		if err != nil {
+			return &RedisClient{config: config}, err
+		}
+		conn = redis.NewClient(opt)
+	case RedisModeSentinel:
+		opt, err := redisFailoverOptions(config)
+		if err != nil {
+			return &RedisClient{config: config}, err
+		}
+		conn = redis.NewFailoverClient(opt)
+	case RedisModeCluster:
+		opt, err := redisClusterOptions(config)
+		if err != nil {
+			return &RedisClient{config: config}, err
+		}
+		conn = redis.NewClusterClient(opt)
+	default:
+		return &RedisClient{config: config}, fmt.Errorf("不支持的 redis 部署模式: %s", config.Mode)
+	}
+
+	// 不检查, 防止使用连接时,空指针崩溃
+	// if _, err := conn.Ping(context.Background()).Result(); err != nil {
+	// 	return nil, err
+	// }
+
+	return &RedisClient{config: config, Conn: conn}, nil
+}
 
+// redisOptions 根据配置生成单节点连接参数
+func redisOptions(config *config.RedisConfig) (*redis.Options, error) {
 	URI := config.URI
 	if URI == "" {
 		URI = fmt.Sprintf("redis://%s:%s@%s:%d/%s",
@@ -40,8 +84,7 @@ func NewRedisClient(config *config.RedisConfig) (*RedisClient, error) {
 
 	opt, err := redis.ParseURL(URI)
 	if err != nil {
-		var conn *redis.Client
-		return &RedisClient{config: config, Conn: conn}, err
+		return nil, err
 	}
 	opt.MinIdleConns = config.MinIdleConns
 	opt.MaxIdleConns = config.MaxIdleConns
@@ -50,15 +93,77 @@ func NewRedisClient(config *config.RedisConfig) (*RedisClient, error) {
 	opt.DialTimeout = time.Duration(config.Timeout) * time.Second
 	opt.ReadTimeout = time.Duration(config.Timeout) * time.Second
 	opt.WriteTimeout = time.Duration(config.Timeout) * time.Second
+	return opt, nil
+}
 
-	conn := redis.NewClient(opt)
+// redisFailoverOptions 根据配置生成 sentinel 模式的连接参数
+func redisFailoverOptions(config *config.RedisConfig) (*redis.FailoverOptions, error) {
+	if config.MasterName == "" {
+		return nil, fmt.Errorf("sentinel 模式必须配置 master_name")
+	}
+	if len(config.SentinelAddrs) == 0 {
+		return nil, fmt.Errorf("sentinel 模式必须配置 sentinel_addrs")
+	}
 
-	// 不检查, 防止使用连接时,空指针崩溃
-	// if _, err := conn.Ping(context.Background()).Result(); err != nil {
-	// 	return nil, err
-	// }
+	database := 0
+	if config.Database != "" {
+		var err error
+		if database, err = strconv.Atoi(config.Database); err != nil {
+			return nil, fmt.Errorf("database 格式不正确: %s", config.Database)
+		}
+	}
 
-	return &RedisClient{config: config, Conn: conn}, nil
+	return &redis.FailoverOptions{
+		MasterName:       config.MasterName,
+		SentinelAddrs:    config.SentinelAddrs,
+		SentinelUsername: config.SentinelUsername,
+		SentinelPassword: config.SentinelPassword,
+		Username:         config.Username,
+		Password:         config.Password,
+		DB:               database,
+		MinIdleConns:     config.MinIdleConns,
+		MaxIdleConns:     config.MaxIdleConns,
+		MaxActiveConns:   config.MaxOpenConns,
+		ConnMaxIdleTime:  time.Duration(config.MaxIdleTime) * time.Second,
+		DialTimeout:      time.Duration(config.Timeout) * time.Second,
+		ReadTimeout:      time.Duration(config.Timeout) * time.Second,
+		WriteTimeout:     time.Duration(config.Timeout) * time.Second,
+	}, nil
+}
+
+// redisClusterOptions 根据配置生成 cluster 模式的连接参数, 集群模式只有 0 号库
+func redisClusterOptions(config *config.RedisConfig) (*redis.ClusterOptions, error) {
+	if len(config.Addrs) == 0 {
+		return nil, fmt.Errorf("cluster 模式必须配置 addrs")
+	}
+	if config.Database != "" && config.Database != "0" {
+		return nil, fmt.Errorf("cluster 模式不支持 database: %s", config.Database)
+	}
+
+	return &redis.ClusterOptions{
+		Addrs:           config.Addrs,
+		Username:        config.Username,
+		Password:        config.Password,
+		MinIdleConns:    config.MinIdleConns,
+		MaxIdleConns:    config.MaxIdleConns,
+		MaxActiveConns:  config.MaxOpenConns,
+		ConnMaxIdleTime: time.Duration(config.MaxIdleTime) * time.Second,
+		DialTimeout:     time.Duration(config.Timeout) * time.Second,
+		ReadTimeout:     time.Duration(config.Timeout) * time.Second,
+		WriteTimeout:    time.Duration(config.Timeout) * time.Second,
+	}, nil
+}
+
+// Mode 返回实例的部署模式
+func (c *RedisClient) Mode() string {
+	if c.config.Mode == "" {
+		return RedisModeStandalone
+	}
+	return c.config.Mode
+}
+
+func (c *RedisClient) Config() *config.RedisConfig {
+	return c.config
 }
 
 func (c *RedisClient) Close() {
@@ -71,12 +176,47 @@ func (c *RedisClient) Ping() error {
 }
 
 func (c *RedisClient) Info() (info RedisInfo, err error) {
-	slaveRegexp := regexp.MustCompile(`^slave[0-9]{1,5}$`)
-	dbRegexp := regexp.MustCompile(`^db[0-9]{1,5}$`)
 	s, err := c.Conn.Info(context.Background(), "ALL").Result()
 	if err != nil {
 		return info, err
 	}
+	return parseRedisInfo(s)
+}
+
+// NodeInfos 获取每个节点的 info, 返回 节点地址 -> info
+// cluster 模式下为所有主从节点; 其他模式只有当前连接的节点, 地址为空
+func (c *RedisClient) NodeInfos() (map[string]RedisInfo, error) {
+	cluster, ok := c.Conn.(*redis.ClusterClient)
+	if !ok {
+		info, err := c.Info()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]RedisInfo{"": info}, nil
+	}
+
+	var mu sync.Mutex
+	infos := make(map[string]RedisInfo)
+	err := cluster.ForEachShard(context.Background(), func(ctx context.Context, node *redis.Client) error {
+		s, err := node.Info(ctx, "ALL").Result()
+		if err != nil {
+			return fmt.Errorf("节点 %s: %v", node.Options().Addr, err)
+		}
+		info, err := parseRedisInfo(s)
+		if err != nil {
+			return fmt.Errorf("节点 %s: %v", node.Options().Addr, err)
+		}
+		mu.Lock()
+		infos[node.Options().Addr] = info
+		mu.Unlock()
+		return nil
+	})
+	return infos, err
+}
+
+func parseRedisInfo(s string) (info RedisInfo, err error) {
+	slaveRegexp := regexp.MustCompile(`^slave[0-9]{1,5}$`)
+	dbRegexp := regexp.MustCompile(`^db[0-9]{1,5}$`)
 	for _, line := range strings.Split(s, "\r\n") {
 		if strings.Contains(line, ":") {
 			kv := strings.Split(line, ":")
@@ -165,6 +305,12 @@ func (c *RedisClient) Info() (info RedisInfo, err error) {
 				}
 			case key == "role":
 				info.Role = value
+			case key == "master_link_status":
+				info.MasterLinkStatus = value
+			case key == "master_last_io_seconds_ago":
+				if info.MasterLastIoSecondsAgo, err = strconv.ParseInt(value, 10, 64); err != nil {
+					return info, err
+				}
 			case slaveRegexp.MatchString(key):
 				slave, err := parseSlave(value)
 				if err != nil {
@@ -257,45 +403,12 @@ func (c *RedisClient) ClusterInfo() (clusterinfo RedisClusterInfo, err error) {
 }
 
 func (c *RedisClient) ClusterNodes() ([]ClusterNode, error) {
-	var nodes []ClusterNode
 	// s, err := redis.String(c.Conn.Do("cluster", "nodes"))
 	s, err := c.Conn.ClusterNodes(context.Background()).Result()
 	if err != nil {
-		return nodes, err
-	}
-
-	for _, line := range strings.Split(s, "\n") {
-		if line == "" {
-			continue
-		}
-		nodeInfo := strings.Split(line, " ")
-		hostPort := strings.Split(strings.Split(nodeInfo[1], "@")[0], ":")
-		port, err := strconv.Atoi(hostPort[1])
-		if err != nil {
-			return nodes, err
-		}
-
-		var role string = "master"
-		var status string = "online"
-		if strings.Contains(nodeInfo[2], "slave") {
-			role = "slave"
-		}
-
-		if strings.Contains(nodeInfo[2], "fail") {
-			status = "fail"
-		}
-
-		nodes = append(nodes, ClusterNode{
-			NodeID:       nodeInfo[0],
-			Host:         hostPort[0],
-			Port:         port,
-			Role:         role,
-			Status:       status,
-			MasterNodeID: nodeInfo[3],
-			Connected:    nodeInfo[7],
-		})
+		return []ClusterNode{}, err
 	}
-	return nodes, nil
+	return parseClusterNodes(s)
 }
 
 func (c *RedisClient) ModuleList() (modules []Module, err error) {
@@ -0,0 +1,530 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-09 15:32:08
+ */
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RedisMigrateDefaultBatch = 100   // 每次 migrate 迁移的 key 数量
+	RedisMigrateTimeoutMS    = 60000 // migrate 命令的超时时间
+	RedisClusterWaitTimeout  = 60 * time.Second
+)
+
+// ProgressFunc 长时间执行的集群操作通过该函数输出执行过程
+type ProgressFunc func(format string, args ...any)
+
+func (p ProgressFunc) logf(format string, args ...any) {
+	if p != nil {
+		p(format, args...)
+	}
+}
+
+// newNodeClient 使用当前实例的认证和超时参数, 创建到集群中指定节点的连接, 用完需要 Close
+func (c *RedisClient) newNodeClient(addr string) (*redis.Client, error) {
+	opt := &redis.Options{
+		Username:     c.config.Username,
+		Password:     c.config.Password,
+		DialTimeout:  time.Duration(c.config.Timeout) * time.Second,
+		ReadTimeout:  time.Duration(c.config.Timeout) * time.Second,
+		WriteTimeout: time.Duration(c.config.Timeout) * time.Second,
+	}
+	// standalone 模式可能只配置了 URI, 认证和 TLS 参数以 URI 为准
+	if c.Mode() == RedisModeStandalone {
+		var err error
+		if opt, err = redisOptions(c.config); err != nil {
+			return nil, err
+		}
+	}
+	opt.Addr = addr
+	opt.DB = 0
+	opt.MinIdleConns = 0
+	return redis.NewClient(opt), nil
+}
+
+// ClusterTopology 获取集群拓扑, 从节点挂在各自主节点下
+func (c *RedisClient) ClusterTopology() (topology RedisClusterTopology, err error) {
+	if topology.Info, err = c.ClusterInfo(); err != nil {
+		return topology, err
+	}
+	if topology.Nodes, err = c.ClusterNodes(); err != nil {
+		return topology, err
+	}
+
+	index := make(map[string]int)
+	for _, node := range topology.Nodes {
+		if node.Role == "master" {
+			index[node.NodeID] = len(topology.Shards)
+			topology.Shards = append(topology.Shards, ClusterShard{Master: node, Replicas: []ClusterNode{}})
+		}
+	}
+	for _, node := range topology.Nodes {
+		if node.Role != "slave" {
+			continue
+		}
+		if i, ok := index[node.MasterNodeID]; ok {
+			topology.Shards[i].Replicas = append(topology.Shards[i].Replicas, node)
+		} else {
+			topology.Orphans = append(topology.Orphans, node)
+		}
+	}
+	return topology, nil
+}
+
+// ClusterNodeByID 根据节点ID查找节点
+func (c *RedisClient) ClusterNodeByID(nodeID string) (ClusterNode, error) {
+	nodes, err := c.ClusterNodes()
+	if err != nil {
+		return ClusterNode{}, err
+	}
+	for _, node := range nodes {
+		if node.NodeID == nodeID {
+			return node, nil
+		}
+	}
+	return ClusterNode{}, fmt.Errorf("集群中不存在节点: %s", nodeID)
+}
+
+// ClusterMeet 将新节点加入集群, 并等待握手完成
+func (c *RedisClient) ClusterMeet(host string, port int, progress ProgressFunc) error {
+	ctx := context.Background()
+	addr := fmt.Sprintf("%s:%d", host, port)
+
+	nodes, err := c.ClusterNodes()
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if node.Addr() == addr && !node.Handshake {
+			return fmt.Errorf("节点 %s 已经在集群中, 节点ID: %s", addr, node.NodeID)
+		}
+	}
+
+	progress.logf("cluster meet %s", addr)
+	if err := c.Conn.ClusterMeet(ctx, host, strconv.Itoa(port)).Err(); err != nil {
+		return fmt.Errorf("cluster meet %s 失败: %v", addr, err)
+	}
+
+	return c.waitCluster(func(nodes []ClusterNode) bool {
+		for _, node := range nodes {
+			if node.Addr() == addr && !node.Handshake && node.LinkState == "connected" {
+				progress.logf("节点 %s 已加入集群, 节点ID: %s", addr, node.NodeID)
+				return true
+			}
+		}
+		progress.logf("等待节点 %s 完成握手", addr)
+		return false
+	})
+}
+
+// ClusterAddSlots 给主节点分配未被分配的 slot
+func (c *RedisClient) ClusterAddSlots(nodeID string, slots []int, progress ProgressFunc) error {
+	if len(slots) == 0 {
+		return errors.New("slot 不能为空")
+	}
+
+	nodes, err := c.ClusterNodes()
+	if err != nil {
+		return err
+	}
+
+	var target *ClusterNode
+	owner := make(map[int]string)
+	for i, node := range nodes {
+		if node.NodeID == nodeID {
+			target = &nodes[i]
+		}
+		for _, slot := range node.SlotList() {
+			owner[slot] = node.NodeID
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("集群中不存在节点: %s", nodeID)
+	}
+	if target.Role != "master" {
+		return fmt.Errorf("节点 %s 不是主节点, 不能分配 slot", nodeID)
+	}
+	for _, slot := range slots {
+		if id, ok := owner[slot]; ok {
+			return fmt.Errorf("slot %d 已经分配给节点 %s", slot, id)
+		}
+	}
+
+	client, err := c.newNodeClient(target.Addr())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	progress.logf("给节点 %s(%s) 分配 %d 个 slot", target.NodeID, target.Addr(), len(slots))
+	if err := client.ClusterAddSlots(context.Background(), slots...).Err(); err != nil {
+		return fmt.Errorf("分配 slot 失败: %v", err)
+	}
+
+	// 更新 config epoch, 让其他节点尽快接受新的 slot 归属, 失败不影响结果
+	_ = client.Do(context.Background(), "cluster", "bumpepoch").Err()
+	return nil
+}
+
+// migrateAuthArgs migrate 连接目标节点使用的认证参数, 与 newNodeClient 使用的认证参数一致, 只配置了 URI 时以 URI 为准
+func migrateAuthArgs(opt *redis.Options) []interface{} {
+	if opt.Password == "" {
+		return nil
+	}
+	if opt.Username != "" {
+		return []interface{}{"auth2", opt.Username, opt.Password}
+	}
+	return []interface{}{"auth", opt.Password}
+}
+
+// ClusterMigrateSlot 将一个 slot 及其中的 key 从源节点迁移到目标节点, 返回迁移的 key 数量
+func (c *RedisClient) ClusterMigrateSlot(source, target ClusterNode, slot int, batch int, progress ProgressFunc) (int, error) {
+	ctx := context.Background()
+	if batch <= 0 {
+		batch = RedisMigrateDefaultBatch
+	}
+
+	src, err := c.newNodeClient(source.Addr())
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dst, err := c.newNodeClient(target.Addr())
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	// 失败时 slot 会停留在 importing/migrating 状态, 尽量恢复, 不能自动恢复时把恢复命令写入任务日志
+	stage := migrateStageImporting
+	fail := func(err error) error {
+		if stage == migrateStageImporting || stage == migrateStageMigrating {
+			// 还没有 key 迁移到目标节点, 可以直接恢复为 stable
+			stableErr := dst.Do(ctx, "cluster", "setslot", slot, "stable").Err()
+			if e := src.Do(ctx, "cluster", "setslot", slot, "stable").Err(); e != nil && stableErr == nil {
+				stableErr = e
+			}
+			if stableErr == nil {
+				progress.logf("已将源节点和目标节点的 slot %d 恢复为 stable", slot)
+				return err
+			}
+			progress.logf("恢复 slot %d 为 stable 失败: %v", slot, stableErr)
+		}
+		for _, cmd := range migrateRecovery(source, target, slot, stage) {
+			progress.logf("%s", cmd)
+		}
+		return err
+	}
+
+	// 顺序不能反: 先在目标节点设置 importing, 再在源节点设置 migrating
+	if err := dst.Do(ctx, "cluster", "setslot", slot, "importing", source.NodeID).Err(); err != nil {
+		return 0, fail(fmt.Errorf("目标节点设置 slot %d importing 失败: %v", slot, err))
+	}
+	stage = migrateStageMigrating
+	if err := src.Do(ctx, "cluster", "setslot", slot, "migrating", target.NodeID).Err(); err != nil {
+		return 0, fail(fmt.Errorf("源节点设置 slot %d migrating 失败: %v", slot, err))
+	}
+
+	moved := 0
+	for {
+		keys, err := src.ClusterGetKeysInSlot(ctx, slot, batch).Result()
+		if err != nil {
+			return moved, fail(fmt.Errorf("获取 slot %d 中的 key 失败: %v", slot, err))
+		}
+		if len(keys) == 0 {
+			break
+		}
+
+		args := []interface{}{"migrate", target.Host, target.Port, "", 0, RedisMigrateTimeoutMS}
+		args = append(args, migrateAuthArgs(src.Options())...)
+		args = append(args, "keys")
+		for _, key := range keys {
+			args = append(args, key)
+		}
+		// migrate 失败时部分 key 可能已经写入目标节点
+		stage = migrateStageKeys
+		if err := src.Do(ctx, args...).Err(); err != nil && err != redis.Nil {
+			return moved, fail(fmt.Errorf("迁移 slot %d 中的 key 失败: %v", slot, err))
+		}
+		moved += len(keys)
+		progress.logf("slot %d 已迁移 %d 个 key", slot, moved)
+	}
+
+	// 先通知目标节点和源节点, 再通知其他主节点
+	stage = migrateStageTargetNode
+	if err := dst.Do(ctx, "cluster", "setslot", slot, "node", target.NodeID).Err(); err != nil {
+		return moved, fail(fmt.Errorf("目标节点设置 slot %d 归属失败: %v", slot, err))
+	}
+	stage = migrateStageSourceNode
+	if err := src.Do(ctx, "cluster", "setslot", slot, "node", target.NodeID).Err(); err != nil {
+		return moved, fail(fmt.Errorf("源节点设置 slot %d 归属失败: %v", slot, err))
+	}
+
+	nodes, err := c.ClusterNodes()
+	if err != nil {
+		return moved, nil
+	}
+	for _, node := range nodes {
+		if node.Role != "master" || node.NodeID == source.NodeID || node.NodeID == target.NodeID || node.Status == "fail" || node.NoAddr {
+			continue
+		}
+		client, err := c.newNodeClient(node.Addr())
+		if err != nil {
+			continue
+		}
+		// 其他节点会通过 gossip 最终得到新的归属, 这里只是加快传播, 失败不影响结果
+		_ = client.Do(ctx, "cluster", "setslot", slot, "node", target.NodeID).Err()
+		client.Close()
+	}
+
+	return moved, nil
+}
+
+// slot 迁移的阶段, 用于失败时生成恢复命令
+const (
+	migrateStageImporting  = "importing"   // 目标节点设置 importing
+	migrateStageMigrating  = "migrating"   // 源节点设置 migrating
+	migrateStageKeys       = "keys"        // 迁移 key
+	migrateStageTargetNode = "target_node" // 目标节点设置 slot 归属
+	migrateStageSourceNode = "source_node" // 源节点设置 slot 归属
+)
+
+// migrateRecovery slot 迁移在 stage 阶段失败后, 手动恢复的步骤和命令
+func migrateRecovery(source, target ClusterNode, slot int, stage string) []string {
+	stable := []string{
+		fmt.Sprintf("恢复命令: 在目标节点 %s 上执行 CLUSTER SETSLOT %d STABLE", target.Addr(), slot),
+		fmt.Sprintf("恢复命令: 在源节点 %s 上执行 CLUSTER SETSLOT %d STABLE", source.Addr(), slot),
+	}
+	assign := []string{
+		fmt.Sprintf("恢复命令: 在目标节点 %s 上执行 CLUSTER SETSLOT %d NODE %s", target.Addr(), slot, target.NodeID),
+		fmt.Sprintf("恢复命令: 在源节点 %s 上执行 CLUSTER SETSLOT %d NODE %s", source.Addr(), slot, target.NodeID),
+	}
+
+	switch stage {
+	case migrateStageImporting, migrateStageMigrating:
+		return stable
+	case migrateStageKeys:
+		return []string{
+			fmt.Sprintf("slot %d 中已有 key 迁移到目标节点 %s, 不能直接恢复为 stable, 否则这些 key 无法访问", slot, target.Addr()),
+			"恢复方式一: 重新提交相同的迁移任务, 继续迁移剩余的 key",
+			fmt.Sprintf("恢复方式二: 执行 redis-cli --cluster fix %s, 由 redis-cli 处理处于迁移状态的 slot", source.Addr()),
+		}
+	case migrateStageTargetNode:
+		// key 已经全部迁移到目标节点, 完成剩余的归属设置即可
+		return assign
+	case migrateStageSourceNode:
+		return assign[1:]
+	}
+	return nil
+}
+
+// ClusterFailover 在从节点上执行手动故障转移, 并等待其成为主节点
+// mode 为空表示正常切换, 可选 force, takeover
+func (c *RedisClient) ClusterFailover(replicaID string, mode string, progress ProgressFunc) error {
+	replica, err := c.ClusterNodeByID(replicaID)
+	if err != nil {
+		return err
+	}
+	if replica.Role != "slave" {
+		return fmt.Errorf("节点 %s 不是从节点, 只能在从节点上执行 failover", replicaID)
+	}
+
+	args := []interface{}{"cluster", "failover"}
+	switch mode {
+	case "":
+	case "force", "takeover":
+		args = append(args, mode)
+	default:
+		return fmt.Errorf("不支持的 failover 模式: %s", mode)
+	}
+
+	client, err := c.newNodeClient(replica.Addr())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	progress.logf("在从节点 %s(%s) 上执行 cluster failover %s", replica.NodeID, replica.Addr(), mode)
+	if err := client.Do(ctx, args...).Err(); err != nil {
+		return fmt.Errorf("cluster failover 失败: %v", err)
+	}
+
+	deadline := time.Now().Add(RedisClusterWaitTimeout)
+	for time.Now().Before(deadline) {
+		s, err := client.ClusterNodes(ctx).Result()
+		if err == nil {
+			nodes, err := parseClusterNodes(s)
+			if err == nil {
+				for _, node := range nodes {
+					if node.Myself && node.Role == "master" {
+						progress.logf("节点 %s 已切换为主节点", replica.Addr())
+						return nil
+					}
+				}
+			}
+		}
+		progress.logf("等待节点 %s 切换为主节点", replica.Addr())
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("等待节点 %s 切换为主节点超时", replica.Addr())
+}
+
+// ClusterForget 让集群中其他所有节点忘记指定节点
+// 被忘记的节点需要先下线或执行 cluster reset, 否则会在 60 秒后通过 gossip 重新加入集群
+func (c *RedisClient) ClusterForget(nodeID string, progress ProgressFunc) error {
+	nodes, err := c.ClusterNodes()
+	if err != nil {
+		return err
+	}
+
+	var target *ClusterNode
+	for i, node := range nodes {
+		if node.NodeID == nodeID {
+			target = &nodes[i]
+		}
+		if node.MasterNodeID == nodeID {
+			return fmt.Errorf("节点 %s 还有从节点 %s, 不能忘记", nodeID, node.NodeID)
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("集群中不存在节点: %s", nodeID)
+	}
+	if target.Myself {
+		return fmt.Errorf("节点 %s 是当前连接的节点, 不能忘记自己", nodeID)
+	}
+	if target.SlotCount > 0 {
+		return fmt.Errorf("节点 %s 还负责 %d 个 slot, 需要先迁移走", nodeID, target.SlotCount)
+	}
+
+	ctx := context.Background()
+	for _, node := range nodes {
+		if node.NodeID == nodeID || node.NoAddr {
+			continue
+		}
+		if node.Status == "fail" {
+			progress.logf("节点 %s(%s) 处于 fail 状态, 跳过", node.NodeID, node.Addr())
+			continue
+		}
+		client, err := c.newNodeClient(node.Addr())
+		if err != nil {
+			return err
+		}
+		err = client.ClusterForget(ctx, nodeID).Err()
+		client.Close()
+		if err != nil {
+			return fmt.Errorf("节点 %s 执行 cluster forget 失败: %v", node.Addr(), err)
+		}
+		progress.logf("节点 %s(%s) 已忘记节点 %s", node.NodeID, node.Addr(), nodeID)
+	}
+	return nil
+}
+
+// waitCluster 轮询 cluster nodes 直到 done 返回 true 或超时
+func (c *RedisClient) waitCluster(done func(nodes []ClusterNode) bool) error {
+	deadline := time.Now().Add(RedisClusterWaitTimeout)
+	for time.Now().Before(deadline) {
+		nodes, err := c.ClusterNodes()
+		if err == nil && done(nodes) {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return errors.New("等待集群状态变更超时")
+}
+
+// PlanClusterRebalance 计算让所有主节点 slot 数量均衡需要的迁移计划
+// threshold 为允许的不均衡百分比, 所有主节点偏差都不超过该值时返回空计划
+func PlanClusterRebalance(nodes []ClusterNode, threshold float64) []ClusterSlotMove {
+	var masters []ClusterNode
+	for _, node := range nodes {
+		if node.Role == "master" && node.Status != "fail" && !node.NoAddr && !node.Handshake {
+			masters = append(masters, node)
+		}
+	}
+	if len(masters) == 0 {
+		return nil
+	}
+
+	// 固定排序, 保证同样的拓扑得到同样的计划
+	sort.Slice(masters, func(i, j int) bool { return masters[i].NodeID < masters[j].NodeID })
+
+	total := 0
+	for _, m := range masters {
+		total += m.SlotCount
+	}
+	if total == 0 {
+		return nil
+	}
+
+	type balance struct {
+		node  ClusterNode
+		slots []int // 可迁出的 slot
+		diff  int   // 正数表示需要迁出, 负数表示需要迁入
+	}
+
+	expected := total / len(masters)
+	remainder := total % len(masters)
+	balances := make([]*balance, 0, len(masters))
+	exceeded := false
+	for i, m := range masters {
+		want := expected
+		if i < remainder {
+			want++
+		}
+		b := &balance{node: m, slots: m.SlotList(), diff: m.SlotCount - want}
+		if want > 0 && float64(abs(b.diff))*100/float64(want) > threshold {
+			exceeded = true
+		}
+		balances = append(balances, b)
+	}
+	if !exceeded {
+		return nil
+	}
+
+	var sources, targets []*balance
+	for _, b := range balances {
+		if b.diff > 0 {
+			sources = append(sources, b)
+		} else if b.diff < 0 {
+			targets = append(targets, b)
+		}
+	}
+
+	var moves []ClusterSlotMove
+	for _, t := range targets {
+		for t.diff < 0 && len(sources) > 0 {
+			s := sources[0]
+			n := min(-t.diff, s.diff)
+			// 从源节点 slot 列表末尾迁出, 尽量保持剩余 slot 连续
+			slots := append([]int{}, s.slots[len(s.slots)-n:]...)
+			s.slots = s.slots[:len(s.slots)-n]
+			s.diff -= n
+			t.diff += n
+			moves = append(moves, ClusterSlotMove{SourceID: s.node.NodeID, TargetID: t.node.NodeID, Slots: slots})
+			if s.diff == 0 {
+				sources = sources[1:]
+			}
+		}
+	}
+	return moves
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
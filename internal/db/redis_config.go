@@ -0,0 +1,222 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-16 14:02:51
+ */
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 带单位的内存大小, 单位规则与 redis.conf 一致: k=1000, kb=1024, m=1000^2, mb=1024^2, g=1000^3, gb=1024^3
+var redisMemoryRegexp = regexp.MustCompile(`^([0-9]+)(k|kb|m|mb|g|gb)$`)
+
+// RedisSecretMask 密码类参数返回给前端时使用的占位符
+const RedisSecretMask = "******"
+
+// redisSecretParams 保存密码或者 acl 账号的参数, 不返回实际的值
+var redisSecretParams = []string{"requirepass", "masterauth", "masteruser"}
+
+// ConfigGetAll 执行 config get * 获取所有运行时参数
+func (c *RedisClient) ConfigGetAll() (conf RedisRuntimeConfig, err error) {
+	return configGetAll(c.Conn)
+}
+
+func configGetAll(conn redis.Cmdable) (conf RedisRuntimeConfig, err error) {
+	params, err := conn.ConfigGet(context.Background(), "*").Result()
+	if err != nil {
+		return conf, fmt.Errorf("config get * 失败: %v", err)
+	}
+	return parseRedisConfig(params)
+}
+
+// NodeConfigs 获取每个节点的运行时参数, 返回 节点地址 -> 参数
+// cluster 模式下为所有主从节点, 部分节点失败时返回其他节点的参数和错误; 其他模式只有当前连接的节点, 地址为空
+func (c *RedisClient) NodeConfigs() (map[string]RedisRuntimeConfig, error) {
+	cluster, ok := c.Conn.(*redis.ClusterClient)
+	if !ok {
+		conf, err := c.ConfigGetAll()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]RedisRuntimeConfig{"": conf}, nil
+	}
+
+	var mu sync.Mutex
+	confs := make(map[string]RedisRuntimeConfig)
+	var errs []error
+	_ = cluster.ForEachShard(context.Background(), func(ctx context.Context, node *redis.Client) error {
+		conf, err := configGetAll(node)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("节点 %s: %v", node.Options().Addr, err))
+			return nil
+		}
+		confs[node.Options().Addr] = conf
+		return nil
+	})
+	return confs, errors.Join(errs...)
+}
+
+// ConfigSet 逐个执行 config set, rewrite 为 true 时最后执行 config rewrite 写回配置文件
+// 低版本 redis 不支持一次 set 多个参数, 所以逐个执行; 中途失败时已经设置的参数不会回滚
+// cluster 模式下在所有节点上执行; sentinel 模式下只在主库上执行
+func (c *RedisClient) ConfigSet(params map[string]string, rewrite bool) error {
+	if cluster, ok := c.Conn.(*redis.ClusterClient); ok {
+		return cluster.ForEachShard(context.Background(), func(ctx context.Context, node *redis.Client) error {
+			if err := configSet(node, params, rewrite); err != nil {
+				return fmt.Errorf("节点 %s: %v", node.Options().Addr, err)
+			}
+			return nil
+		})
+	}
+	return configSet(c.Conn, params, rewrite)
+}
+
+func configSet(conn redis.Cmdable, params map[string]string, rewrite bool) error {
+	ctx := context.Background()
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := conn.ConfigSet(ctx, name, params[name]).Err(); err != nil {
+			return fmt.Errorf("config set %s %s 失败: %v", name, params[name], err)
+		}
+	}
+
+	if rewrite {
+		if err := conn.ConfigRewrite(ctx).Err(); err != nil {
+			return fmt.Errorf("config rewrite 失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// DiffRedisConfig 对比实例参数与基线, 返回不一致的参数
+func DiffRedisConfig(actual map[string]string, baseline map[string]string) []RedisConfigDiff {
+	diffs := []RedisConfigDiff{}
+	for name, expected := range baseline {
+		name = strings.ToLower(name)
+		value, ok := actual[name]
+		if !ok {
+			diffs = append(diffs, RedisConfigDiff{Param: name, Expected: MaskRedisConfigValue(name, expected), Missing: true})
+			continue
+		}
+		if NormalizeRedisConfigValue(value) != NormalizeRedisConfigValue(expected) {
+			diffs = append(diffs, RedisConfigDiff{Param: name, Expected: MaskRedisConfigValue(name, expected), Actual: MaskRedisConfigValue(name, value)})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Param < diffs[j].Param })
+	return diffs
+}
+
+// MaskRedisConfigValue 密码类参数的值替换为占位符, 没有设置时保留空值
+func MaskRedisConfigValue(name, value string) string {
+	if value != "" && slices.Contains(redisSecretParams, strings.ToLower(name)) {
+		return RedisSecretMask
+	}
+	return value
+}
+
+// MaskRedisConfig 返回密码类参数替换为占位符后的参数副本
+func MaskRedisConfig(params map[string]string) map[string]string {
+	masked := make(map[string]string, len(params))
+	for name, value := range params {
+		masked[name] = MaskRedisConfigValue(name, value)
+	}
+	return masked
+}
+
+// NormalizeRedisConfigValue 统一参数值的格式, 便于比较: 去掉首尾空白, 转小写, 合并多余空格, 内存单位转换为字节
+func NormalizeRedisConfigValue(value string) string {
+	value = strings.ToLower(strings.Join(strings.Fields(value), " "))
+	if m := redisMemoryRegexp.FindStringSubmatch(value); m != nil {
+		n, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return value
+		}
+		switch m[2] {
+		case "k":
+			n *= 1000
+		case "kb":
+			n *= 1024
+		case "m":
+			n *= 1000 * 1000
+		case "mb":
+			n *= 1024 * 1024
+		case "g":
+			n *= 1000 * 1000 * 1000
+		case "gb":
+			n *= 1024 * 1024 * 1024
+		}
+		return strconv.FormatUint(n, 10)
+	}
+	return value
+}
+
+func parseRedisConfig(params map[string]string) (conf RedisRuntimeConfig, err error) {
+	conf.Params = params
+	for key, value := range params {
+		switch key {
+		case "maxmemory":
+			if conf.Maxmemory, err = strconv.ParseUint(value, 10, 64); err != nil {
+				return conf, err
+			}
+		case "maxmemory-policy":
+			conf.MaxmemoryPolicy = value
+		case "maxclients":
+			if conf.Maxclients, err = strconv.ParseUint(value, 10, 64); err != nil {
+				return conf, err
+			}
+		case "timeout":
+			if conf.Timeout, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return conf, err
+			}
+		case "tcp-keepalive":
+			if conf.TcpKeepalive, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return conf, err
+			}
+		case "databases":
+			if conf.Databases, err = strconv.Atoi(value); err != nil {
+				return conf, err
+			}
+		case "appendonly":
+			conf.Appendonly = value == "yes"
+		case "appendfsync":
+			conf.Appendfsync = value
+		case "save":
+			conf.Save = value
+		case "cluster-enabled":
+			conf.ClusterEnabled = value == "yes"
+		case "repl-backlog-size":
+			if conf.ReplBacklogSize, err = strconv.ParseUint(value, 10, 64); err != nil {
+				return conf, err
+			}
+		case "slowlog-log-slower-than":
+			if conf.SlowlogLogSlowerThan, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return conf, err
+			}
+		case "slowlog-max-len":
+			if conf.SlowlogMaxLen, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return conf, err
+			}
+		}
+	}
+	return conf, nil
+}
@@ -0,0 +1,379 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 14:20:36
+ */
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RedisScanDefaultCount  = 100  // scan 每次默认返回的 key 数量
+	RedisScanMaxCount      = 1000 // scan 每次最多返回的 key 数量
+	RedisValueDefaultCount = 100  // 读取集合类型 value 时每页默认条数, string 类型时为字节数
+	RedisValueMaxCount     = 1000 // 读取集合类型 value 时每页最大条数
+	RedisStringMaxBytes    = 1 << 20
+)
+
+var ErrRedisReadOnly = errors.New("redis 实例为只读模式, 不允许修改数据")
+
+// 支持浏览的 key 类型
+var RedisKeyTypes = []string{"string", "hash", "list", "set", "zset", "stream"}
+
+// ScanKeys 使用 scan 游标遍历 key, keyType 为空时不过滤类型
+func (c *RedisClient) ScanKeys(cursor uint64, pattern string, keyType string, count int64) (result RedisScanResult, err error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	if count <= 0 {
+		count = RedisScanDefaultCount
+	}
+	if count > RedisScanMaxCount {
+		count = RedisScanMaxCount
+	}
+
+	var keys []string
+	if c.Mode() == RedisModeCluster {
+		keys, cursor, err = c.scanCluster(cursor, pattern, keyType, count)
+	} else {
+		keys, cursor, err = scanNode(c.Conn, cursor, pattern, keyType, count)
+	}
+	if err != nil {
+		return result, fmt.Errorf("scan key 失败: %v", err)
+	}
+
+	result.Cursor = cursor
+	result.Keys = keys
+	if result.Keys == nil {
+		result.Keys = []string{}
+	}
+	return result, nil
+}
+
+// 集群模式的 scan 游标: 高 16 位为主节点序号, 低 48 位为该节点上的 scan 游标
+const redisClusterCursorBits = 48
+
+// scanCluster 集群模式下 scan 只在单个节点上执行, 所以按 node id 顺序逐个主节点遍历
+// 遍历过程中集群拓扑发生变化时, 可能重复或遗漏部分 key
+func (c *RedisClient) scanCluster(cursor uint64, pattern string, keyType string, count int64) ([]string, uint64, error) {
+	nodes, err := c.ClusterNodes()
+	if err != nil {
+		return nil, 0, err
+	}
+	var masters []ClusterNode
+	for _, node := range nodes {
+		if node.Role == "master" && node.SlotCount > 0 {
+			masters = append(masters, node)
+		}
+	}
+	sort.Slice(masters, func(i, j int) bool { return masters[i].NodeID < masters[j].NodeID })
+
+	index, nodeCursor := decodeClusterCursor(cursor)
+	if index >= len(masters) {
+		return []string{}, 0, nil
+	}
+
+	client, err := c.newNodeClient(masters[index].Addr())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer client.Close()
+
+	keys, next, err := scanNode(client, nodeCursor, pattern, keyType, count)
+	if err != nil {
+		return nil, 0, fmt.Errorf("节点 %s: %v", masters[index].Addr(), err)
+	}
+	cursor, err = nextClusterCursor(index, len(masters), next)
+	if err != nil {
+		return nil, 0, fmt.Errorf("节点 %s: %v", masters[index].Addr(), err)
+	}
+	return keys, cursor, nil
+}
+
+// decodeClusterCursor 拆分集群模式的 scan 游标, 返回主节点序号和该节点上的 scan 游标
+func decodeClusterCursor(cursor uint64) (index int, nodeCursor uint64) {
+	return int(cursor >> redisClusterCursorBits), cursor & (1<<redisClusterCursorBits - 1)
+}
+
+// nextClusterCursor 第 index 个主节点的 scan 返回 next 后, 下一次 scan 的集群游标, 所有主节点都遍历结束时返回 0
+func nextClusterCursor(index, masters int, next uint64) (uint64, error) {
+	if next >= 1<<redisClusterCursorBits {
+		return 0, fmt.Errorf("返回的游标 %d 超出范围", next)
+	}
+	// 当前节点遍历结束, 下一次从下一个主节点开始
+	if next == 0 {
+		index++
+		if index >= masters {
+			return 0, nil
+		}
+	}
+	return uint64(index)<<redisClusterCursorBits | next, nil
+}
+
+func scanNode(conn redis.Cmdable, cursor uint64, pattern string, keyType string, count int64) ([]string, uint64, error) {
+	if keyType == "" {
+		return conn.Scan(context.Background(), cursor, pattern, count).Result()
+	}
+	return conn.ScanType(context.Background(), cursor, pattern, count, keyType).Result()
+}
+
+// KeyMeta 获取 key 的元数据: 类型, ttl, 编码, 内存占用
+func (c *RedisClient) KeyMeta(key string) (meta RedisKeyMeta, err error) {
+	ctx := context.Background()
+
+	pipeline := c.Conn.Pipeline()
+	typeCmd := pipeline.Type(ctx, key)
+	ttlCmd := pipeline.PTTL(ctx, key)
+	encodingCmd := pipeline.ObjectEncoding(ctx, key)
+	memoryCmd := pipeline.MemoryUsage(ctx, key)
+	// 部分云厂商或低版本 redis 禁用了 memory usage, object encoding, 不影响其他结果, 所以逐个检查命令结果
+	_, _ = pipeline.Exec(ctx)
+
+	meta.Key = key
+	if meta.Type, err = typeCmd.Result(); err != nil {
+		return meta, fmt.Errorf("获取 key: %s 类型失败: %v", key, err)
+	}
+	if meta.Type == "none" {
+		return meta, fmt.Errorf("key: %s 不存在", key)
+	}
+
+	ttl, err := ttlCmd.Result()
+	if err != nil {
+		return meta, fmt.Errorf("获取 key: %s ttl 失败: %v", key, err)
+	}
+	// ttl 为 -1 表示永不过期
+	meta.TTL = -1
+	if ttl > 0 {
+		meta.TTL = ttl.Milliseconds()
+	}
+
+	meta.Encoding, _ = encodingCmd.Result()
+	meta.MemoryUsage, _ = memoryCmd.Result()
+	if meta.Length, err = c.keyLength(key, meta.Type); err != nil {
+		return meta, err
+	}
+	return meta, nil
+}
+
+// keyLength 获取 key 的元素个数, string 类型为字节数
+func (c *RedisClient) keyLength(key string, keyType string) (int64, error) {
+	ctx := context.Background()
+	switch keyType {
+	case "string":
+		return c.Conn.StrLen(ctx, key).Result()
+	case "hash":
+		return c.Conn.HLen(ctx, key).Result()
+	case "list":
+		return c.Conn.LLen(ctx, key).Result()
+	case "set":
+		return c.Conn.SCard(ctx, key).Result()
+	case "zset":
+		return c.Conn.ZCard(ctx, key).Result()
+	case "stream":
+		return c.Conn.XLen(ctx, key).Result()
+	}
+	return 0, fmt.Errorf("不支持的 key 类型: %s", keyType)
+}
+
+// KeyValue 按类型分页读取 key 的值
+// hash, set 使用 query.Cursor 翻页; string, list, zset 使用 query.Offset 翻页; stream 使用 query.StreamStart 翻页
+func (c *RedisClient) KeyValue(key string, query RedisValueQuery) (value RedisKeyValue, err error) {
+	ctx := context.Background()
+
+	if query.Count <= 0 {
+		query.Count = RedisValueDefaultCount
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	keyType, err := c.Conn.Type(ctx, key).Result()
+	if err != nil {
+		return value, fmt.Errorf("获取 key: %s 类型失败: %v", key, err)
+	}
+	if keyType == "none" {
+		return value, fmt.Errorf("key: %s 不存在", key)
+	}
+
+	value.Key = key
+	value.Type = keyType
+	if value.Total, err = c.keyLength(key, keyType); err != nil {
+		return value, err
+	}
+
+	switch keyType {
+	case "string":
+		if query.Count > RedisStringMaxBytes {
+			query.Count = RedisStringMaxBytes
+		}
+		if value.Value, err = c.Conn.GetRange(ctx, key, query.Offset, query.Offset+query.Count-1).Result(); err != nil {
+			return value, err
+		}
+		if next := query.Offset + int64(len(value.Value)); next < value.Total {
+			value.NextOffset = next
+		}
+		return value, nil
+	}
+
+	if query.Count > RedisValueMaxCount {
+		query.Count = RedisValueMaxCount
+	}
+
+	switch keyType {
+	case "hash":
+		var kvs []string
+		if kvs, value.Cursor, err = c.Conn.HScan(ctx, key, query.Cursor, query.Match, query.Count).Result(); err != nil {
+			return value, err
+		}
+		for i := 0; i+1 < len(kvs); i += 2 {
+			value.Items = append(value.Items, RedisValueItem{Field: kvs[i], Value: kvs[i+1]})
+		}
+	case "set":
+		var members []string
+		if members, value.Cursor, err = c.Conn.SScan(ctx, key, query.Cursor, query.Match, query.Count).Result(); err != nil {
+			return value, err
+		}
+		for _, member := range members {
+			value.Items = append(value.Items, RedisValueItem{Value: member})
+		}
+	case "list":
+		var elems []string
+		if elems, err = c.Conn.LRange(ctx, key, query.Offset, query.Offset+query.Count-1).Result(); err != nil {
+			return value, err
+		}
+		for i, elem := range elems {
+			value.Items = append(value.Items, RedisValueItem{Index: query.Offset + int64(i), Value: elem})
+		}
+		if next := query.Offset + int64(len(elems)); next < value.Total {
+			value.NextOffset = next
+		}
+	case "zset":
+		var zs []redis.Z
+		if zs, err = c.Conn.ZRangeWithScores(ctx, key, query.Offset, query.Offset+query.Count-1).Result(); err != nil {
+			return value, err
+		}
+		for i, z := range zs {
+			value.Items = append(value.Items, RedisValueItem{Index: query.Offset + int64(i), Value: fmt.Sprint(z.Member), Score: z.Score})
+		}
+		if next := query.Offset + int64(len(zs)); next < value.Total {
+			value.NextOffset = next
+		}
+	case "stream":
+		start := query.StreamStart
+		if start == "" {
+			start = "-"
+		}
+		var msgs []redis.XMessage
+		if msgs, err = c.Conn.XRangeN(ctx, key, start, "+", query.Count).Result(); err != nil {
+			return value, err
+		}
+		for _, msg := range msgs {
+			value.Items = append(value.Items, RedisValueItem{ID: msg.ID, Fields: msg.Values})
+		}
+		// 以 "(" 开头表示不包含该 ID, 需要 redis 6.2 及以上版本
+		if int64(len(msgs)) == query.Count {
+			value.NextStreamStart = "(" + msgs[len(msgs)-1].ID
+		}
+	default:
+		return value, fmt.Errorf("不支持的 key 类型: %s", keyType)
+	}
+
+	if value.Items == nil {
+		value.Items = []RedisValueItem{}
+	}
+	return value, nil
+}
+
+// KeyExpire 设置 key 的过期时间, ttl <= 0 时移除过期时间
+func (c *RedisClient) KeyExpire(key string, ttl time.Duration) error {
+	if c.config.ReadOnly {
+		return ErrRedisReadOnly
+	}
+
+	ctx := context.Background()
+
+	var ok bool
+	var err error
+	if ttl <= 0 {
+		if ok, err = c.Conn.Persist(ctx, key).Result(); err != nil {
+			return fmt.Errorf("移除 key: %s 过期时间失败: %v", key, err)
+		}
+		if !ok {
+			// persist 对不存在的 key 和本来就没有过期时间的 key 都返回 0
+			if n, err := c.Conn.Exists(ctx, key).Result(); err == nil && n == 0 {
+				return fmt.Errorf("key: %s 不存在", key)
+			}
+		}
+		return nil
+	}
+
+	if ok, err = c.Conn.PExpire(ctx, key, ttl).Result(); err != nil {
+		return fmt.Errorf("设置 key: %s 过期时间失败: %v", key, err)
+	}
+	if !ok {
+		return fmt.Errorf("key: %s 不存在", key)
+	}
+	return nil
+}
+
+// KeyDelete 删除 key, 返回实际删除的个数
+// 使用 unlink 在后台释放内存, 避免删除大 key 时阻塞实例
+func (c *RedisClient) KeyDelete(keys ...string) (int64, error) {
+	if c.config.ReadOnly {
+		return 0, ErrRedisReadOnly
+	}
+	if len(keys) == 0 {
+		return 0, errors.New("key 不能为空")
+	}
+
+	var deleted int64
+	ctx := context.Background()
+	// 逐个删除, 集群模式下多个 key 可能不在同一个 slot
+	for _, key := range keys {
+		n, err := c.Conn.Unlink(ctx, key).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("删除 key: %s 失败: %v", key, err)
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// KeyRename 重命名 key, overwrite 为 false 时目标 key 已存在则报错
+func (c *RedisClient) KeyRename(key string, newKey string, overwrite bool) error {
+	if c.config.ReadOnly {
+		return ErrRedisReadOnly
+	}
+	if key == "" || newKey == "" {
+		return errors.New("key 和新 key 都不能为空")
+	}
+	if key == newKey {
+		return errors.New("新 key 不能与原 key 相同")
+	}
+
+	ctx := context.Background()
+
+	if overwrite {
+		if err := c.Conn.Rename(ctx, key, newKey).Err(); err != nil {
+			return fmt.Errorf("重命名 key: %s 为 %s 失败: %v", key, newKey, err)
+		}
+		return nil
+	}
+
+	ok, err := c.Conn.RenameNX(ctx, key, newKey).Result()
+	if err != nil {
+		return fmt.Errorf("重命名 key: %s 为 %s 失败: %v", key, newKey, err)
+	}
+	if !ok {
+		return fmt.Errorf("目标 key: %s 已存在", newKey)
+	}
+	return nil
+}
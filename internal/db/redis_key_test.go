@@ -0,0 +1,78 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 16:05:48
+ */
+
+package db
+
+import (
+	"errors"
+	"myadmin/internal/config"
+	"testing"
+	"time"
+)
+
+func TestClusterCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		index   int
+		masters int
+		next    uint64
+		want    uint64
+		wantErr bool
+	}{
+		{name: "first master continues", index: 0, masters: 3, next: 17, want: 17},
+		{name: "middle master continues", index: 1, masters: 3, next: 42, want: 1<<48 | 42},
+		{name: "first master done", index: 0, masters: 3, next: 0, want: 1 << 48},
+		{name: "last master continues", index: 2, masters: 3, next: 5, want: 2<<48 | 5},
+		{name: "last master done", index: 2, masters: 3, next: 0, want: 0},
+		{name: "largest node cursor", index: 1, masters: 3, next: 1<<48 - 1, want: 1<<48 | (1<<48 - 1)},
+		{name: "node cursor overflow", index: 1, masters: 3, next: 1 << 48, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := nextClusterCursor(tt.index, tt.masters, tt.next)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: cursor = %d, want %d", tt.name, got, tt.want)
+		}
+		if tt.wantErr || got == 0 {
+			continue
+		}
+
+		// 编码后的游标可以还原出下一次 scan 的节点和节点游标
+		index, nodeCursor := decodeClusterCursor(got)
+		wantIndex, wantNodeCursor := tt.index, tt.next
+		if tt.next == 0 {
+			wantIndex++
+		}
+		if index != wantIndex || nodeCursor != wantNodeCursor {
+			t.Errorf("%s: decode = %d, %d, want %d, %d", tt.name, index, nodeCursor, wantIndex, wantNodeCursor)
+		}
+	}
+
+	// 序号超过主节点数时(如遍历过程中主节点减少), scanCluster 按遍历结束处理
+	if index, nodeCursor := decodeClusterCursor(5<<48 | 9); index != 5 || nodeCursor != 9 {
+		t.Errorf("decode = %d, %d, want 5, 9", index, nodeCursor)
+	}
+	if index, nodeCursor := decodeClusterCursor(0); index != 0 || nodeCursor != 0 {
+		t.Errorf("decode(0) = %d, %d", index, nodeCursor)
+	}
+}
+
+func TestRedisReadOnly(t *testing.T) {
+	// 只读实例在执行命令之前拒绝, 不需要连接
+	c := &RedisClient{config: &config.RedisConfig{ReadOnly: true}}
+
+	if err := c.KeyExpire("a", time.Minute); !errors.Is(err, ErrRedisReadOnly) {
+		t.Errorf("KeyExpire err = %v", err)
+	}
+	if _, err := c.KeyDelete("a"); !errors.Is(err, ErrRedisReadOnly) {
+		t.Errorf("KeyDelete err = %v", err)
+	}
+	if err := c.KeyRename("a", "b", false); !errors.Is(err, ErrRedisReadOnly) {
+		t.Errorf("KeyRename err = %v", err)
+	}
+}
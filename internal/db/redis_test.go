@@ -0,0 +1,88 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-23 10:41:17
+ */
+
+package db
+
+import (
+	"myadmin/internal/config"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewRedisClientMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.RedisConfig
+		check   func(conn redis.UniversalClient) bool
+		wantErr bool
+	}{
+		{
+			name:   "standalone",
+			config: config.RedisConfig{Host: "localhost", Port: 6379, Database: "0"},
+			check:  func(conn redis.UniversalClient) bool { _, ok := conn.(*redis.Client); return ok },
+		},
+		{
+			name:   "sentinel",
+			config: config.RedisConfig{Mode: RedisModeSentinel, MasterName: "mymaster", SentinelAddrs: []string{"localhost:26379"}, Database: "1"},
+			check:  func(conn redis.UniversalClient) bool { _, ok := conn.(*redis.Client); return ok },
+		},
+		{
+			name:   "cluster",
+			config: config.RedisConfig{Mode: RedisModeCluster, Addrs: []string{"localhost:30001"}},
+			check:  func(conn redis.UniversalClient) bool { _, ok := conn.(*redis.ClusterClient); return ok },
+		},
+		{name: "sentinel without master name", config: config.RedisConfig{Mode: RedisModeSentinel, SentinelAddrs: []string{"localhost:26379"}}, wantErr: true},
+		{name: "sentinel bad database", config: config.RedisConfig{Mode: RedisModeSentinel, MasterName: "mymaster", SentinelAddrs: []string{"localhost:26379"}, Database: "a"}, wantErr: true},
+		{name: "cluster without addrs", config: config.RedisConfig{Mode: RedisModeCluster}, wantErr: true},
+		{name: "cluster with database", config: config.RedisConfig{Mode: RedisModeCluster, Addrs: []string{"localhost:30001"}, Database: "2"}, wantErr: true},
+		{name: "unknown mode", config: config.RedisConfig{Mode: "proxy"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		client, err := NewRedisClient(&tt.config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			if client.Conn != nil {
+				t.Errorf("%s: Conn should be nil on error", tt.name)
+			}
+			continue
+		}
+		if !tt.check(client.Conn) {
+			t.Errorf("%s: unexpected client type %T", tt.name, client.Conn)
+		}
+		client.Close()
+	}
+}
+
+func TestDiffRedisConfigMask(t *testing.T) {
+	actual := map[string]string{"requirepass": "secret", "masterauth": "", "maxmemory": "1073741824"}
+	baseline := map[string]string{"requirepass": "other", "masterauth": "x", "maxmemory": "1gb"}
+
+	diffs := DiffRedisConfig(actual, baseline)
+	if len(diffs) != 2 {
+		t.Fatalf("got %+v", diffs)
+	}
+	if diffs[0].Param != "masterauth" || diffs[0].Actual != "" || diffs[0].Expected != RedisSecretMask {
+		t.Errorf("masterauth got %+v", diffs[0])
+	}
+	if diffs[1].Param != "requirepass" || diffs[1].Actual != RedisSecretMask || diffs[1].Expected != RedisSecretMask {
+		t.Errorf("requirepass got %+v", diffs[1])
+	}
+
+	// 实例上没有的参数也不返回基线中的密码
+	missing := DiffRedisConfig(map[string]string{}, map[string]string{"masterauth": "x"})
+	if len(missing) != 1 || !missing[0].Missing || missing[0].Expected != RedisSecretMask {
+		t.Errorf("missing got %+v", missing)
+	}
+
+	masked := MaskRedisConfig(actual)
+	if masked["requirepass"] != RedisSecretMask || masked["maxmemory"] != "1073741824" || actual["requirepass"] != "secret" {
+		t.Errorf("mask got %v, original %v", masked, actual)
+	}
+}
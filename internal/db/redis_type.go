@@ -6,6 +6,8 @@
 package db
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -33,6 +35,8 @@ type RedisInfo struct {
 	KeyspaceHits             uint64
 	KeyspaceMisses           uint64
 	Role                     string
+	MasterLinkStatus         string // 从库与主库的连接状态<up|down>, 只有从库有
+	MasterLastIoSecondsAgo   int64  // 从库最近一次与主库交互距今的秒数, 只有从库有
 	Slaves                   []SlaveInfo
 	UsedCpuSys               float64
 	UsedCpuUser              float64
@@ -52,14 +56,59 @@ type SlaveInfo struct {
 	Lag    uint64
 }
 
+// ClusterNode cluster nodes 命令输出的一行, 格式:
+// <id> <ip:port@cport[,hostname]> <flags> <master> <ping-sent> <pong-recv> <config-epoch> <link-state> <slot> ... <slot>
 type ClusterNode struct {
-	NodeID       string // 节点ID
-	Host         string // 节点IP
-	Port         int    // 节点端口
-	Role         string // 节点角色
-	Status       string // 状态<online|fail>
-	MasterNodeID string // 如果本节点是个从节点(Role: slave), 那这里会显示他同步的主节点的节点ID
-	Connected    string // (没用)
+	NodeID       string         `json:"node_id"`        // 节点ID
+	Host         string         `json:"host"`           // 节点IP, noaddr 时为空
+	Port         int            `json:"port"`           // 节点端口
+	BusPort      int            `json:"bus_port"`       // 集群总线端口
+	Hostname     string         `json:"hostname"`       // redis 7.0 以上配置了 cluster-announce-hostname 时才有
+	Role         string         `json:"role"`           // 节点角色<master|slave>
+	Status       string         `json:"status"`         // 状态<online|pfail|fail>
+	Flags        []string       `json:"flags"`          // 原始 flags
+	Myself       bool           `json:"myself"`         // 是否为当前连接的节点
+	Handshake    bool           `json:"handshake"`      // 是否处于握手阶段(刚 meet 还未加入集群)
+	NoAddr       bool           `json:"noaddr"`         // 节点地址未知
+	NoFailover   bool           `json:"nofailover"`     // 从节点不参与自动故障转移
+	MasterNodeID string         `json:"master_node_id"` // 如果本节点是个从节点(Role: slave), 那这里会显示他同步的主节点的节点ID, 主节点为 "-"
+	PingSent     int64          `json:"ping_sent"`      // 最近一次发送 ping 的时间戳, 毫秒, 0 表示没有待回复的 ping
+	PongRecv     int64          `json:"pong_recv"`      // 最近一次收到 pong 的时间戳, 毫秒
+	ConfigEpoch  uint64         `json:"config_epoch"`
+	LinkState    string         `json:"link_state"` // 集群总线连接状态<connected|disconnected>
+	Slots        []SlotRange    `json:"slots"`
+	SlotCount    int            `json:"slot_count"`
+	Migrating    map[int]string `json:"migrating"` // 正在迁出的 slot -> 目标节点ID
+	Importing    map[int]string `json:"importing"` // 正在迁入的 slot -> 源节点ID
+}
+
+// Addr 节点的 ip:port 地址
+func (n ClusterNode) Addr() string {
+	return fmt.Sprintf("%s:%d", n.Host, n.Port)
+}
+
+// SlotList 展开节点负责的所有 slot
+func (n ClusterNode) SlotList() []int {
+	slots := make([]int, 0, n.SlotCount)
+	for _, r := range n.Slots {
+		for slot := r.Start; slot <= r.End; slot++ {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// SlotRange 连续的 slot 范围, 单个 slot 时 Start 等于 End
+type SlotRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (r SlotRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
 }
 
 type RedisClusterInfo struct {
@@ -74,6 +123,53 @@ type RedisClusterInfo struct {
 	ClusterMyEpoch       uint64
 }
 
+type RedisClusterTopology struct {
+	Info    RedisClusterInfo `json:"info"`
+	Nodes   []ClusterNode    `json:"nodes"`
+	Shards  []ClusterShard   `json:"shards"`
+	Orphans []ClusterNode    `json:"orphans"` // 找不到主节点的从节点
+}
+
+// ClusterShard 一个主节点及其从节点
+type ClusterShard struct {
+	Master   ClusterNode   `json:"master"`
+	Replicas []ClusterNode `json:"replicas"`
+}
+
+// ClusterSlotMove 迁移计划中的一步
+type ClusterSlotMove struct {
+	SourceID string `json:"source_id"`
+	TargetID string `json:"target_id"`
+	Slots    []int  `json:"slots"`
+}
+
+// RedisRuntimeConfig config get * 的结果, 常用参数解析为对应类型, 全部参数在 Params 中
+type RedisRuntimeConfig struct {
+	Maxmemory            uint64            `json:"maxmemory"` // 单位: byte, 0 表示不限制
+	MaxmemoryPolicy      string            `json:"maxmemory_policy"`
+	Maxclients           uint64            `json:"maxclients"`
+	Timeout              int64             `json:"timeout"`       // 客户端空闲超时, 单位: 秒
+	TcpKeepalive         int64             `json:"tcp_keepalive"` // 单位: 秒
+	Databases            int               `json:"databases"`
+	Appendonly           bool              `json:"appendonly"`
+	Appendfsync          string            `json:"appendfsync"`
+	Save                 string            `json:"save"` // rdb 持久化策略, 为空表示关闭
+	ClusterEnabled       bool              `json:"cluster_enabled"`
+	ReplBacklogSize      uint64            `json:"repl_backlog_size"`
+	SlowlogLogSlowerThan int64             `json:"slowlog_log_slower_than"` // 单位: 微秒
+	SlowlogMaxLen        int64             `json:"slowlog_max_len"`
+	Params               map[string]string `json:"params"`
+}
+
+// RedisConfigDiff 与基线不一致的参数
+type RedisConfigDiff struct {
+	Node     string `json:"node"` // cluster 模式下为节点地址, 其他模式为空
+	Param    string `json:"param"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Missing  bool   `json:"missing"` // 实例上不存在该参数, 一般是版本不支持
+}
+
 type DatabaseInfo struct {
 	DBName  string //db0
 	Keys    uint64
@@ -93,6 +189,49 @@ type SlowLogInfo struct {
 	ClientAddr  string // 客户端IP地址
 }
 
+type RedisScanResult struct {
+	Cursor uint64   `json:"cursor"` // 下一次 scan 使用的游标, 为 0 表示遍历结束
+	Keys   []string `json:"keys"`
+}
+
+type RedisKeyMeta struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	TTL         int64  `json:"ttl"` // 剩余过期时间, 单位: 毫秒. -1 表示永不过期
+	Encoding    string `json:"encoding"`
+	MemoryUsage int64  `json:"memory_usage"` // 内存占用, 单位: byte
+	Length      int64  `json:"length"`       // 元素个数, string 类型为字节数
+}
+
+type RedisValueQuery struct {
+	Cursor      uint64 // hash, set 使用
+	Offset      int64  // string, list, zset 使用
+	Count       int64
+	Match       string // hash, set 的 field/member 过滤
+	StreamStart string // stream 使用, 起始 ID
+}
+
+type RedisKeyValue struct {
+	Key             string           `json:"key"`
+	Type            string           `json:"type"`
+	Total           int64            `json:"total"`
+	Value           string           `json:"value,omitempty"` // string 类型的值
+	Items           []RedisValueItem `json:"items,omitempty"` // 集合类型的元素
+	Cursor          uint64           `json:"cursor"`          // hash, set 下一页的游标, 为 0 表示结束
+	NextOffset      int64            `json:"next_offset"`     // string, list, zset 下一页的偏移量, 为 0 表示结束
+	NextStreamStart string           `json:"next_stream_start"`
+}
+
+// RedisValueItem 下标 0, 分数 0 和空字符串都是有效的值, 所以 index, value 和 score 总是返回
+type RedisValueItem struct {
+	Index  int64                  `json:"index"`           // list, zset 的下标
+	Field  string                 `json:"field,omitempty"` // hash 的 field
+	Value  string                 `json:"value"`
+	Score  float64                `json:"score"`            // zset 的分数
+	ID     string                 `json:"id,omitempty"`     // stream 的消息 ID
+	Fields map[string]interface{} `json:"fields,omitempty"` // stream 的消息内容
+}
+
 func parseSlave(s string) (slaveinfo SlaveInfo, err error) {
 	for _, line := range strings.Split(s, ",") {
 		if strings.Contains(line, "=") {
@@ -148,3 +287,165 @@ func parseDatabase(key, s string) (dbinfo DatabaseInfo, err error) {
 	}
 	return dbinfo, nil
 }
+
+// RedisClusterSlots 集群 slot 总数
+const RedisClusterSlots = 16384
+
+func parseClusterNodes(s string) ([]ClusterNode, error) {
+	nodes := []ClusterNode{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		node, err := parseClusterNode(line)
+		if err != nil {
+			return nodes, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func parseClusterNode(line string) (node ClusterNode, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 8 {
+		return node, fmt.Errorf("cluster nodes 输出格式不正确: %s", line)
+	}
+
+	node.NodeID = fields[0]
+	node.MasterNodeID = fields[3]
+	node.LinkState = fields[7]
+
+	// ip:port@cport[,hostname]
+	addr := fields[1]
+	if i := strings.Index(addr, ","); i >= 0 {
+		node.Hostname = addr[i+1:]
+		addr = addr[:i]
+	}
+	if i := strings.Index(addr, "@"); i >= 0 {
+		if node.BusPort, err = strconv.Atoi(addr[i+1:]); err != nil {
+			return node, fmt.Errorf("解析节点 %s 的总线端口失败: %v", node.NodeID, err)
+		}
+		addr = addr[:i]
+	}
+	i := strings.LastIndex(addr, ":")
+	if i < 0 {
+		return node, fmt.Errorf("解析节点 %s 的地址失败: %s", node.NodeID, fields[1])
+	}
+	node.Host = addr[:i]
+	if node.Port, err = strconv.Atoi(addr[i+1:]); err != nil {
+		return node, fmt.Errorf("解析节点 %s 的端口失败: %v", node.NodeID, err)
+	}
+
+	node.Role = "master"
+	node.Status = "online"
+	node.Flags = strings.Split(fields[2], ",")
+	for _, flag := range node.Flags {
+		switch flag {
+		case "myself":
+			node.Myself = true
+		case "slave":
+			node.Role = "slave"
+		case "fail?":
+			node.Status = "pfail"
+		case "fail":
+			node.Status = "fail"
+		case "handshake":
+			node.Handshake = true
+		case "noaddr":
+			node.NoAddr = true
+		case "nofailover":
+			node.NoFailover = true
+		}
+	}
+
+	if node.PingSent, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+		return node, fmt.Errorf("解析节点 %s 的 ping-sent 失败: %v", node.NodeID, err)
+	}
+	if node.PongRecv, err = strconv.ParseInt(fields[5], 10, 64); err != nil {
+		return node, fmt.Errorf("解析节点 %s 的 pong-recv 失败: %v", node.NodeID, err)
+	}
+	if node.ConfigEpoch, err = strconv.ParseUint(fields[6], 10, 64); err != nil {
+		return node, fmt.Errorf("解析节点 %s 的 config-epoch 失败: %v", node.NodeID, err)
+	}
+
+	node.Slots = []SlotRange{}
+	for _, field := range fields[8:] {
+		// 迁移中的 slot: [slot->-nodeid] 迁出, [slot-<-nodeid] 迁入
+		if strings.HasPrefix(field, "[") {
+			field = strings.Trim(field, "[]")
+			if parts := strings.SplitN(field, "->-", 2); len(parts) == 2 {
+				slot, err := strconv.Atoi(parts[0])
+				if err != nil {
+					return node, fmt.Errorf("解析节点 %s 迁出的 slot 失败: %v", node.NodeID, err)
+				}
+				if node.Migrating == nil {
+					node.Migrating = make(map[int]string)
+				}
+				node.Migrating[slot] = parts[1]
+			} else if parts := strings.SplitN(field, "-<-", 2); len(parts) == 2 {
+				slot, err := strconv.Atoi(parts[0])
+				if err != nil {
+					return node, fmt.Errorf("解析节点 %s 迁入的 slot 失败: %v", node.NodeID, err)
+				}
+				if node.Importing == nil {
+					node.Importing = make(map[int]string)
+				}
+				node.Importing[slot] = parts[1]
+			}
+			continue
+		}
+
+		r, err := parseSlotRange(field)
+		if err != nil {
+			return node, fmt.Errorf("解析节点 %s 的 slot 失败: %v", node.NodeID, err)
+		}
+		node.Slots = append(node.Slots, r)
+		node.SlotCount += r.End - r.Start + 1
+	}
+
+	return node, nil
+}
+
+func parseSlotRange(s string) (r SlotRange, err error) {
+	start, end, found := strings.Cut(s, "-")
+	if r.Start, err = strconv.Atoi(start); err != nil {
+		return r, err
+	}
+	r.End = r.Start
+	if found {
+		if r.End, err = strconv.Atoi(end); err != nil {
+			return r, err
+		}
+	}
+	if r.Start < 0 || r.End >= RedisClusterSlots || r.Start > r.End {
+		return r, fmt.Errorf("slot 范围不正确: %s", s)
+	}
+	return r, nil
+}
+
+// ParseSlots 解析 "0-100,200,300-400" 格式的 slot 列表, 返回去重排序后的 slot
+func ParseSlots(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		r, err := parseSlotRange(part)
+		if err != nil {
+			return nil, err
+		}
+		for slot := r.Start; slot <= r.End; slot++ {
+			seen[slot] = true
+		}
+	}
+
+	slots := make([]int, 0, len(seen))
+	for slot := range seen {
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+	return slots, nil
+}
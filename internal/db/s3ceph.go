@@ -7,6 +7,7 @@ package db
 
 import (
 	"fmt"
+	"io"
 	"myadmin/internal/config"
 	"os"
 	"time"
@@ -19,6 +20,9 @@ import (
 	"github.com/aws/aws-sdk-go/service/sts"
 )
 
+// DeleteObjects 单次请求最多删除的 object 数
+const maxDeleteObjects = 1000
+
 type S3Ceph struct {
 	config  *config.S3Config
 	Session *session.Session
@@ -90,30 +94,61 @@ func (c *S3Ceph) ListBuckets() (result []S3Bucket, err error) {
 
 }
 
-// 查询 object 列表
-func (c *S3Ceph) ListObjectFromBucket(bucketName string, objectPrefix string) (result []S3Object, err error) {
-
-	s3Input := &s3.ListObjectsInput{
+// PutObjectStream 从 reader 上传 object, 大文件自动分片上传, 不需要落地到本地文件
+func (c *S3Ceph) PutObjectStream(bucketName, objectName, contentType string, body io.Reader) error {
+	s3Input := &s3manager.UploadInput{
 		Bucket: aws.String(bucketName),
-		Prefix: aws.String(objectPrefix),
+		Key:    aws.String(objectName),
+		Body:   body,
+	}
+	if contentType != "" {
+		s3Input.ContentType = aws.String(contentType)
 	}
 
+	uploader := s3manager.NewUploader(c.Session)
+	_, err := uploader.Upload(s3Input)
+	return err
+}
+
+// GetObjectStream 获取 object 的内容, 调用方读取完后需要关闭 Body
+func (c *S3Ceph) GetObjectStream(bucketName, objectName string) (*s3.GetObjectOutput, error) {
 	svc := s3.New(c.Session)
-	resp, err := svc.ListObjects(s3Input)
+	return svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+}
 
-	if err != nil {
-		return result, err
-	}
+// DeleteObjects 批量删除 object, 每次请求最多 1000 个; 单个 object 删除失败不影响其他 object
+func (c *S3Ceph) DeleteObjects(bucketName string, objectNames []string) (result S3DeleteResult, err error) {
+	result = S3DeleteResult{Deleted: []string{}, Errors: []S3DeleteError{}}
+	svc := s3.New(c.Session)
 
-	for _, item := range resp.Contents {
-		result = append(result, S3Object{
-			Key:          aws.StringValue(item.Key),
-			LastModified: aws.TimeValue(item.LastModified),
-			Size:         aws.Int64Value(item.Size),
-			StorageClass: aws.StringValue(item.StorageClass),
+	for start := 0; start < len(objectNames); start += maxDeleteObjects {
+		batch := objectNames[start:min(start+maxDeleteObjects, len(objectNames))]
+		objects := make([]*s3.ObjectIdentifier, 0, len(batch))
+		for _, name := range batch {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(name)})
+		}
+
+		resp, err := svc.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(false)},
 		})
+		if err != nil {
+			return result, err
+		}
+		for _, d := range resp.Deleted {
+			result.Deleted = append(result.Deleted, aws.StringValue(d.Key))
+		}
+		for _, e := range resp.Errors {
+			result.Errors = append(result.Errors, S3DeleteError{
+				Key:     aws.StringValue(e.Key),
+				Code:    aws.StringValue(e.Code),
+				Message: aws.StringValue(e.Message),
+			})
+		}
 	}
-
 	return result, nil
 }
 
@@ -0,0 +1,407 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-25 09:52:40
+ */
+
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// bucket 权限
+const (
+	S3AccessRead      = "read"
+	S3AccessReadWrite = "readwrite"
+)
+
+// 只读和读写授权对应的 action
+var (
+	s3ReadObjectActions      = []string{"s3:GetObject", "s3:GetObjectVersion"}
+	s3ReadBucketActions      = []string{"s3:ListBucket", "s3:ListBucketVersions", "s3:GetBucketLocation"}
+	s3ReadWriteObjectActions = []string{"s3:PutObject", "s3:DeleteObject", "s3:DeleteObjectVersion", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"}
+	s3ReadWriteBucketActions = []string{"s3:ListBucketMultipartUploads"}
+)
+
+var s3CORSMethods = []string{"GET", "PUT", "POST", "DELETE", "HEAD"}
+
+// isS3ErrCode 判断是否为指定错误码的 s3 错误
+func isS3ErrCode(err error, codes ...string) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && slices.Contains(codes, aerr.Code())
+}
+
+// BuildBucketPolicy 根据授权生成 bucket 策略, 每个授权生成一组语句
+// users 为 rgw 的用户名, 带租户时为 tenant$user; 也可以直接使用完整的 arn
+func BuildBucketPolicy(bucketName string, grants []S3PolicyGrant) (S3Policy, error) {
+	policy := S3Policy{Version: S3PolicyVersion, Statement: []S3PolicyStatement{}}
+	if len(grants) == 0 {
+		return policy, errors.New("至少需要一个授权")
+	}
+
+	for i, g := range grants {
+		if len(g.Users) == 0 {
+			return policy, fmt.Errorf("第 %d 个授权没有指定用户", i+1)
+		}
+		if strings.HasPrefix(g.Prefix, "/") {
+			return policy, fmt.Errorf("第 %d 个授权的前缀 %s 不能以 / 开头", i+1, g.Prefix)
+		}
+
+		principal := S3Principal{"AWS": {}}
+		for _, user := range g.Users {
+			principal["AWS"] = append(principal["AWS"], s3UserArn(user))
+		}
+
+		stmts, err := grantStatements(fmt.Sprintf("grant%d", i+1), bucketName, g.Prefix, g.Access, principal)
+		if err != nil {
+			return policy, fmt.Errorf("第 %d 个授权%v", i+1, err)
+		}
+		policy.Statement = append(policy.Statement, stmts...)
+	}
+	return policy, nil
+}
+
+// BuildSessionPolicy 生成临时凭证的会话策略, 只允许访问 bucket 中前缀下的 object. 会话策略没有 Principal
+func BuildSessionPolicy(bucketName, prefix, access string) (S3Policy, error) {
+	if strings.HasPrefix(prefix, "/") {
+		return S3Policy{}, fmt.Errorf("前缀 %s 不能以 / 开头", prefix)
+	}
+	stmts, err := grantStatements("session", bucketName, prefix, access, nil)
+	if err != nil {
+		return S3Policy{}, fmt.Errorf("会话%v", err)
+	}
+	return S3Policy{Version: S3PolicyVersion, Statement: stmts}, nil
+}
+
+// grantStatements 授权对应的两条语句: bucket 级别的 action 作用于 bucket, 对象级别的 action 作用于 bucket/prefix*
+func grantStatements(sid, bucketName, prefix, access string, principal S3Principal) ([]S3PolicyStatement, error) {
+	objectActions := slices.Clone(s3ReadObjectActions)
+	bucketActions := slices.Clone(s3ReadBucketActions)
+	switch access {
+	case S3AccessRead:
+	case S3AccessReadWrite:
+		objectActions = append(objectActions, s3ReadWriteObjectActions...)
+		bucketActions = append(bucketActions, s3ReadWriteBucketActions...)
+	default:
+		return nil, fmt.Errorf("的权限 %s 不正确, 只支持 %s 和 %s", access, S3AccessRead, S3AccessReadWrite)
+	}
+
+	bucketStmt := S3PolicyStatement{
+		Sid:       sid + "-bucket",
+		Effect:    "Allow",
+		Principal: principal,
+		Action:    bucketActions,
+		Resource:  S3StringList{"arn:aws:s3:::" + bucketName},
+	}
+	// 只授权了前缀时, 只能列出前缀下的 object
+	if prefix != "" {
+		bucketStmt.Condition = map[string]map[string]S3StringList{"StringLike": {"s3:prefix": {prefix + "*"}}}
+	}
+
+	return []S3PolicyStatement{bucketStmt, {
+		Sid:       sid + "-object",
+		Effect:    "Allow",
+		Principal: principal,
+		Action:    objectActions,
+		Resource:  S3StringList{"arn:aws:s3:::" + bucketName + "/" + prefix + "*"},
+	}}, nil
+}
+
+// S3AccessCovers 判断已有的权限是否包含请求的权限, 读写包含只读
+func S3AccessCovers(granted, requested string) bool {
+	return granted == requested || (granted == S3AccessReadWrite && requested == S3AccessRead)
+}
+
+func s3UserArn(user string) string {
+	if strings.HasPrefix(user, "arn:") {
+		return user
+	}
+	tenant, uid, ok := strings.Cut(user, "$")
+	if !ok {
+		tenant, uid = "", user
+	}
+	return fmt.Sprintf("arn:aws:iam::%s:user/%s", tenant, uid)
+}
+
+// GetBucketPolicy 获取 bucket 策略, 没有策略时返回 nil
+func (c *S3Ceph) GetBucketPolicy(bucketName string) (*S3Policy, error) {
+	svc := s3.New(c.Session)
+	resp, err := svc.GetBucketPolicy(&s3.GetBucketPolicyInput{Bucket: aws.String(bucketName)})
+	if isS3ErrCode(err, "NoSuchBucketPolicy") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var policy S3Policy
+	if err := json.Unmarshal([]byte(aws.StringValue(resp.Policy)), &policy); err != nil {
+		return nil, fmt.Errorf("解析 bucket %s 的策略失败: %v", bucketName, err)
+	}
+	return &policy, nil
+}
+
+// PutBucketPolicy 设置 bucket 策略, 替换原有的策略
+func (c *S3Ceph) PutBucketPolicy(bucketName string, policy S3Policy) error {
+	if len(policy.Statement) == 0 {
+		return errors.New("策略中至少需要一条语句")
+	}
+	if policy.Version == "" {
+		policy.Version = S3PolicyVersion
+	}
+	body, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	svc := s3.New(c.Session)
+	_, err = svc.PutBucketPolicy(&s3.PutBucketPolicyInput{Bucket: aws.String(bucketName), Policy: aws.String(string(body))})
+	return err
+}
+
+// DeleteBucketPolicy 删除 bucket 策略
+func (c *S3Ceph) DeleteBucketPolicy(bucketName string) error {
+	svc := s3.New(c.Session)
+	_, err := svc.DeleteBucketPolicy(&s3.DeleteBucketPolicyInput{Bucket: aws.String(bucketName)})
+	return err
+}
+
+// ValidateLifecycleRules 校验生命周期规则, 每条规则至少有一个动作, ID 不能重复
+func ValidateLifecycleRules(rules []S3LifecycleRule) error {
+	ids := make(map[string]bool)
+	for i, r := range rules {
+		if r.ID == "" {
+			return fmt.Errorf("第 %d 条生命周期规则没有 id", i+1)
+		}
+		if ids[r.ID] {
+			return fmt.Errorf("生命周期规则 %s 重复", r.ID)
+		}
+		ids[r.ID] = true
+
+		if r.ExpirationDays < 0 || r.NoncurrentDays < 0 || r.AbortMultipartDays < 0 {
+			return fmt.Errorf("生命周期规则 %s 的天数不能小于 0", r.ID)
+		}
+		if r.ExpirationDays == 0 && r.NoncurrentDays == 0 && r.AbortMultipartDays == 0 {
+			return fmt.Errorf("生命周期规则 %s 至少需要一个动作", r.ID)
+		}
+	}
+	return nil
+}
+
+func lifecycleRulesToS3(rules []S3LifecycleRule) []*s3.LifecycleRule {
+	result := make([]*s3.LifecycleRule, 0, len(rules))
+	for _, r := range rules {
+		rule := &s3.LifecycleRule{
+			ID:     aws.String(r.ID),
+			Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(r.Prefix)},
+			Status: aws.String(s3.ExpirationStatusDisabled),
+		}
+		if r.Enabled {
+			rule.Status = aws.String(s3.ExpirationStatusEnabled)
+		}
+		if r.ExpirationDays > 0 {
+			rule.Expiration = &s3.LifecycleExpiration{Days: aws.Int64(r.ExpirationDays)}
+		}
+		if r.NoncurrentDays > 0 {
+			rule.NoncurrentVersionExpiration = &s3.NoncurrentVersionExpiration{NoncurrentDays: aws.Int64(r.NoncurrentDays)}
+		}
+		if r.AbortMultipartDays > 0 {
+			rule.AbortIncompleteMultipartUpload = &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int64(r.AbortMultipartDays)}
+		}
+		result = append(result, rule)
+	}
+	return result
+}
+
+func lifecycleRulesFromS3(rules []*s3.LifecycleRule) []S3LifecycleRule {
+	result := make([]S3LifecycleRule, 0, len(rules))
+	for _, r := range rules {
+		rule := S3LifecycleRule{
+			ID:      aws.StringValue(r.ID),
+			Prefix:  aws.StringValue(r.Prefix), // 旧格式的规则前缀不在 Filter 中
+			Enabled: aws.StringValue(r.Status) == s3.ExpirationStatusEnabled,
+		}
+		if r.Filter != nil && r.Filter.Prefix != nil {
+			rule.Prefix = aws.StringValue(r.Filter.Prefix)
+		}
+		if r.Expiration != nil {
+			rule.ExpirationDays = aws.Int64Value(r.Expiration.Days)
+		}
+		if r.NoncurrentVersionExpiration != nil {
+			rule.NoncurrentDays = aws.Int64Value(r.NoncurrentVersionExpiration.NoncurrentDays)
+		}
+		if r.AbortIncompleteMultipartUpload != nil {
+			rule.AbortMultipartDays = aws.Int64Value(r.AbortIncompleteMultipartUpload.DaysAfterInitiation)
+		}
+		result = append(result, rule)
+	}
+	return result
+}
+
+// GetBucketLifecycle 获取生命周期规则, 没有配置时返回空列表
+func (c *S3Ceph) GetBucketLifecycle(bucketName string) ([]S3LifecycleRule, error) {
+	svc := s3.New(c.Session)
+	resp, err := svc.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(bucketName)})
+	if isS3ErrCode(err, "NoSuchLifecycleConfiguration") {
+		return []S3LifecycleRule{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lifecycleRulesFromS3(resp.Rules), nil
+}
+
+// PutBucketLifecycle 设置生命周期规则, 替换原有的所有规则
+func (c *S3Ceph) PutBucketLifecycle(bucketName string, rules []S3LifecycleRule) error {
+	if len(rules) == 0 {
+		return errors.New("至少需要一条生命周期规则, 清空规则请使用删除")
+	}
+	if err := ValidateLifecycleRules(rules); err != nil {
+		return err
+	}
+
+	svc := s3.New(c.Session)
+	_, err := svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucketName),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: lifecycleRulesToS3(rules)},
+	})
+	return err
+}
+
+// DeleteBucketLifecycle 删除所有生命周期规则
+func (c *S3Ceph) DeleteBucketLifecycle(bucketName string) error {
+	svc := s3.New(c.Session)
+	_, err := svc.DeleteBucketLifecycle(&s3.DeleteBucketLifecycleInput{Bucket: aws.String(bucketName)})
+	return err
+}
+
+// ValidateCORSRules 校验跨域规则, 来源和方法不能为空
+func ValidateCORSRules(rules []S3CORSRule) error {
+	for i, r := range rules {
+		if len(r.AllowedOrigins) == 0 || len(r.AllowedMethods) == 0 {
+			return fmt.Errorf("第 %d 条跨域规则的 allowed_origins 和 allowed_methods 不能为空", i+1)
+		}
+		for _, m := range r.AllowedMethods {
+			if !slices.Contains(s3CORSMethods, m) {
+				return fmt.Errorf("第 %d 条跨域规则的方法 %s 不正确, 只支持 %v", i+1, m, s3CORSMethods)
+			}
+		}
+		if r.MaxAgeSeconds < 0 {
+			return fmt.Errorf("第 %d 条跨域规则的 max_age_seconds 不能小于 0", i+1)
+		}
+	}
+	return nil
+}
+
+// GetBucketCORS 获取跨域规则, 没有配置时返回空列表
+func (c *S3Ceph) GetBucketCORS(bucketName string) ([]S3CORSRule, error) {
+	svc := s3.New(c.Session)
+	resp, err := svc.GetBucketCors(&s3.GetBucketCorsInput{Bucket: aws.String(bucketName)})
+	if isS3ErrCode(err, "NoSuchCORSConfiguration") {
+		return []S3CORSRule{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]S3CORSRule, 0, len(resp.CORSRules))
+	for _, r := range resp.CORSRules {
+		rules = append(rules, S3CORSRule{
+			AllowedOrigins: aws.StringValueSlice(r.AllowedOrigins),
+			AllowedMethods: aws.StringValueSlice(r.AllowedMethods),
+			AllowedHeaders: aws.StringValueSlice(r.AllowedHeaders),
+			ExposeHeaders:  aws.StringValueSlice(r.ExposeHeaders),
+			MaxAgeSeconds:  aws.Int64Value(r.MaxAgeSeconds),
+		})
+	}
+	return rules, nil
+}
+
+// PutBucketCORS 设置跨域规则, 替换原有的所有规则
+func (c *S3Ceph) PutBucketCORS(bucketName string, rules []S3CORSRule) error {
+	if len(rules) == 0 {
+		return errors.New("至少需要一条跨域规则, 清空规则请使用删除")
+	}
+	if err := ValidateCORSRules(rules); err != nil {
+		return err
+	}
+
+	corsRules := make([]*s3.CORSRule, 0, len(rules))
+	for _, r := range rules {
+		rule := &s3.CORSRule{
+			AllowedOrigins: aws.StringSlice(r.AllowedOrigins),
+			AllowedMethods: aws.StringSlice(r.AllowedMethods),
+		}
+		if len(r.AllowedHeaders) > 0 {
+			rule.AllowedHeaders = aws.StringSlice(r.AllowedHeaders)
+		}
+		if len(r.ExposeHeaders) > 0 {
+			rule.ExposeHeaders = aws.StringSlice(r.ExposeHeaders)
+		}
+		if r.MaxAgeSeconds > 0 {
+			rule.MaxAgeSeconds = aws.Int64(r.MaxAgeSeconds)
+		}
+		corsRules = append(corsRules, rule)
+	}
+
+	svc := s3.New(c.Session)
+	_, err := svc.PutBucketCors(&s3.PutBucketCorsInput{
+		Bucket:            aws.String(bucketName),
+		CORSConfiguration: &s3.CORSConfiguration{CORSRules: corsRules},
+	})
+	return err
+}
+
+// DeleteBucketCORS 删除所有跨域规则
+func (c *S3Ceph) DeleteBucketCORS(bucketName string) error {
+	svc := s3.New(c.Session)
+	_, err := svc.DeleteBucketCors(&s3.DeleteBucketCorsInput{Bucket: aws.String(bucketName)})
+	return err
+}
+
+// GetBucketVersioning 获取版本控制状态: 从未开启时为空, 开启后为 Enabled 或 Suspended
+func (c *S3Ceph) GetBucketVersioning(bucketName string) (string, error) {
+	svc := s3.New(c.Session)
+	resp, err := svc.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(resp.Status), nil
+}
+
+// SetBucketVersioning 开启或暂停版本控制, 版本控制开启后不能关闭, 只能暂停, 已有的历史版本保留
+func (c *S3Ceph) SetBucketVersioning(bucketName string, enabled bool) error {
+	status := s3.BucketVersioningStatusSuspended
+	if enabled {
+		status = s3.BucketVersioningStatusEnabled
+	}
+
+	svc := s3.New(c.Session)
+	_, err := svc.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucketName),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(status)},
+	})
+	return err
+}
+
+// BucketUsage 遍历 bucket 统计 object 数量和大小, 只统计最新版本. progress 不为空时每一页回调一次
+func (c *S3Ceph) BucketUsage(bucketName string, progress func(usage S3BucketUsage)) (S3BucketUsage, error) {
+	usage := S3BucketUsage{Bucket: bucketName}
+	it := c.Objects(bucketName, "", "")
+	for it.Next() {
+		usage.Objects++
+		usage.Size += it.Object().Size
+		if progress != nil && usage.Objects%s3ListPageSize == 0 {
+			progress(usage)
+		}
+	}
+	return usage, it.Err()
+}
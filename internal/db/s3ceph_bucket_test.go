@@ -0,0 +1,127 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-25 16:40:12
+ */
+
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestBuildBucketPolicy(t *testing.T) {
+	policy, err := BuildBucketPolicy("photos", []S3PolicyGrant{
+		{Users: []string{"alice", "tenant1$bob"}, Access: S3AccessRead},
+		{Users: []string{"arn:aws:iam:::user/carol"}, Prefix: "upload/", Access: S3AccessReadWrite},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Statement) != 4 {
+		t.Fatalf("got %d statements, want 4", len(policy.Statement))
+	}
+
+	read, readObj := policy.Statement[0], policy.Statement[1]
+	if want := (S3StringList{"arn:aws:iam:::user/alice", "arn:aws:iam::tenant1:user/bob"}); !reflect.DeepEqual(read.Principal["AWS"], want) {
+		t.Errorf("principal = %v, want %v", read.Principal["AWS"], want)
+	}
+	if read.Resource[0] != "arn:aws:s3:::photos" || read.Condition != nil {
+		t.Errorf("read bucket statement = %+v", read)
+	}
+	if readObj.Resource[0] != "arn:aws:s3:::photos/*" || slices.Contains(readObj.Action, "s3:PutObject") {
+		t.Errorf("read object statement = %+v", readObj)
+	}
+
+	rw, rwObj := policy.Statement[2], policy.Statement[3]
+	if got := rw.Condition["StringLike"]["s3:prefix"]; !reflect.DeepEqual(got, S3StringList{"upload/*"}) {
+		t.Errorf("prefix condition = %v", got)
+	}
+	if rwObj.Resource[0] != "arn:aws:s3:::photos/upload/*" || !slices.Contains(rwObj.Action, "s3:PutObject") {
+		t.Errorf("readwrite object statement = %+v", rwObj)
+	}
+
+	for _, grants := range [][]S3PolicyGrant{
+		nil,
+		{{Access: S3AccessRead}},
+		{{Users: []string{"a"}, Access: "admin"}},
+		{{Users: []string{"a"}, Prefix: "/x/", Access: S3AccessRead}},
+	} {
+		if _, err := BuildBucketPolicy("photos", grants); err == nil {
+			t.Errorf("BuildBucketPolicy(%+v) should fail", grants)
+		}
+	}
+}
+
+func TestS3PolicyUnmarshal(t *testing.T) {
+	raw := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":["arn:aws:s3:::b/*"]}]}`
+	var policy S3Policy
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil {
+		t.Fatal(err)
+	}
+	stmt := policy.Statement[0]
+	if !reflect.DeepEqual(stmt.Principal, S3Principal{"AWS": {"*"}}) || !reflect.DeepEqual(stmt.Action, S3StringList{"s3:GetObject"}) {
+		t.Errorf("statement = %+v", stmt)
+	}
+}
+
+func TestLifecycleRules(t *testing.T) {
+	rules := []S3LifecycleRule{
+		{ID: "logs", Prefix: "logs/", Enabled: true, ExpirationDays: 30, AbortMultipartDays: 7},
+		{ID: "versions", NoncurrentDays: 90},
+	}
+	if err := ValidateLifecycleRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	if got := lifecycleRulesFromS3(lifecycleRulesToS3(rules)); !reflect.DeepEqual(got, rules) {
+		t.Errorf("round trip = %+v, want %+v", got, rules)
+	}
+
+	for _, bad := range [][]S3LifecycleRule{
+		{{ExpirationDays: 1}},
+		{{ID: "a"}},
+		{{ID: "a", ExpirationDays: -1}},
+		{{ID: "a", ExpirationDays: 1}, {ID: "a", NoncurrentDays: 1}},
+	} {
+		if err := ValidateLifecycleRules(bad); err == nil {
+			t.Errorf("ValidateLifecycleRules(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestValidateCORSRules(t *testing.T) {
+	if err := ValidateCORSRules([]S3CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "PUT"}}}); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range []S3CORSRule{
+		{AllowedMethods: []string{"GET"}},
+		{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"PATCH"}},
+		{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}, MaxAgeSeconds: -1},
+	} {
+		if err := ValidateCORSRules([]S3CORSRule{bad}); err == nil {
+			t.Errorf("ValidateCORSRules(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestBucketUsage(t *testing.T) {
+	f, client := newFakeS3(t, "bucket")
+	for i := 0; i < 1500; i++ {
+		f.put(fmt.Sprintf("obj/%04d", i), 2, false)
+	}
+	f.put("gone", 100, false)
+	f.put("gone", 0, true)
+
+	var calls int
+	usage, err := client.BucketUsage("bucket", func(S3BucketUsage) { calls++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 删除标记对应的 object 不统计
+	if usage.Objects != 1500 || usage.Size != 3000 || calls != 1 {
+		t.Errorf("usage = %+v, progress calls = %d", usage, calls)
+	}
+}
@@ -0,0 +1,290 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-24 09:41:18
+ */
+
+package db
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// 迭代器每页查询的 object 数, 也是 s3 单次返回的上限
+const s3ListPageSize = 1000
+
+// 查询 object 列表, 查询前缀下的所有 object, 数量较多时使用 Objects 迭代
+func (c *S3Ceph) ListObjectFromBucket(bucketName string, objectPrefix string) (result []S3Object, err error) {
+	it := c.Objects(bucketName, objectPrefix, "")
+	for it.Next() {
+		result = append(result, it.Object())
+	}
+	return result, it.Err()
+}
+
+// ListObjectPage 按前缀和分隔符分页查询 object, token 为上一页返回的 NextToken
+// delimiter 不为空时, 前缀下一级的"目录"在 CommonPrefixes 中返回, 不展开其中的 object
+func (c *S3Ceph) ListObjectPage(bucketName, prefix, delimiter, token string, maxKeys int64) (S3ObjectPage, error) {
+	s3Input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucketName),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(maxKeys),
+	}
+	if delimiter != "" {
+		s3Input.Delimiter = aws.String(delimiter)
+	}
+	if token != "" {
+		s3Input.ContinuationToken = aws.String(token)
+	}
+
+	svc := s3.New(c.Session)
+	resp, err := svc.ListObjectsV2(s3Input)
+	if err != nil {
+		return S3ObjectPage{}, err
+	}
+
+	page := S3ObjectPage{
+		Prefix:         prefix,
+		Delimiter:      delimiter,
+		CommonPrefixes: []string{},
+		Objects:        []S3Object{},
+		NextToken:      aws.StringValue(resp.NextContinuationToken),
+		Truncated:      aws.BoolValue(resp.IsTruncated),
+	}
+	for _, p := range resp.CommonPrefixes {
+		page.CommonPrefixes = append(page.CommonPrefixes, aws.StringValue(p.Prefix))
+	}
+	for _, item := range resp.Contents {
+		page.Objects = append(page.Objects, S3Object{
+			Key:          aws.StringValue(item.Key),
+			LastModified: aws.TimeValue(item.LastModified),
+			Size:         aws.Int64Value(item.Size),
+			StorageClass: aws.StringValue(item.StorageClass),
+		})
+	}
+	return page, nil
+}
+
+// S3ObjectIterator 逐页查询 object, 只在当前页遍历完后才查询下一页, 不会一次把整个 bucket 读入内存
+//
+//	it := client.Objects(bucket, prefix, "/")
+//	for it.Next() {
+//		obj := it.Object()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type S3ObjectIterator struct {
+	client    *S3Ceph
+	bucket    string
+	prefix    string
+	delimiter string
+
+	page     S3ObjectPage
+	idx      int
+	fetched  bool
+	prefixes []string
+	err      error
+}
+
+// Objects 返回前缀下 object 的迭代器, delimiter 不为空时下一级"目录"通过 CommonPrefixes 获取
+func (c *S3Ceph) Objects(bucketName, prefix, delimiter string) *S3ObjectIterator {
+	return &S3ObjectIterator{client: c, bucket: bucketName, prefix: prefix, delimiter: delimiter, idx: -1}
+}
+
+// Next 移动到下一个 object, 没有更多 object 或者出错时返回 false
+func (it *S3ObjectIterator) Next() bool {
+	for it.err == nil {
+		if it.idx+1 < len(it.page.Objects) {
+			it.idx++
+			return true
+		}
+		if it.fetched && !it.page.Truncated {
+			return false
+		}
+		// 只有目录没有 object 的页也要继续翻页
+		page, err := it.client.ListObjectPage(it.bucket, it.prefix, it.delimiter, it.page.NextToken, s3ListPageSize)
+		if err != nil {
+			it.err = fmt.Errorf("查询 bucket %s 失败: %v", it.bucket, err)
+			return false
+		}
+		if page.Truncated && page.NextToken == "" {
+			it.err = fmt.Errorf("查询 bucket %s 失败: 返回结果没有 NextContinuationToken", it.bucket)
+			return false
+		}
+		it.page, it.idx, it.fetched = page, -1, true
+		it.prefixes = append(it.prefixes, page.CommonPrefixes...)
+	}
+	return false
+}
+
+// Object 当前的 object, 只能在 Next 返回 true 后调用
+func (it *S3ObjectIterator) Object() S3Object {
+	return it.page.Objects[it.idx]
+}
+
+// CommonPrefixes 已经查询过的页中的"目录", 遍历结束后为所有的"目录"
+func (it *S3ObjectIterator) CommonPrefixes() []string {
+	return it.prefixes
+}
+
+// Err 遍历过程中的错误
+func (it *S3ObjectIterator) Err() error {
+	return it.err
+}
+
+// ListObjectVersionPage 分页查询 object 的所有版本和删除标记, keyMarker 和 versionMarker 为上一页返回的 NextKeyMarker 和 NextVersionIDMarker
+// 同一个 object 的版本按从新到旧的顺序返回
+func (c *S3Ceph) ListObjectVersionPage(bucketName, prefix, delimiter, keyMarker, versionMarker string, maxKeys int64) (S3VersionPage, error) {
+	s3Input := &s3.ListObjectVersionsInput{
+		Bucket:  aws.String(bucketName),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(maxKeys),
+	}
+	if delimiter != "" {
+		s3Input.Delimiter = aws.String(delimiter)
+	}
+	if keyMarker != "" {
+		s3Input.KeyMarker = aws.String(keyMarker)
+		if versionMarker != "" {
+			s3Input.VersionIdMarker = aws.String(versionMarker)
+		}
+	}
+
+	svc := s3.New(c.Session)
+	resp, err := svc.ListObjectVersions(s3Input)
+	if err != nil {
+		return S3VersionPage{}, err
+	}
+
+	page := S3VersionPage{
+		Prefix:              prefix,
+		Delimiter:           delimiter,
+		CommonPrefixes:      []string{},
+		Versions:            []S3ObjectVersion{},
+		NextKeyMarker:       aws.StringValue(resp.NextKeyMarker),
+		NextVersionIDMarker: aws.StringValue(resp.NextVersionIdMarker),
+		Truncated:           aws.BoolValue(resp.IsTruncated),
+	}
+	for _, p := range resp.CommonPrefixes {
+		page.CommonPrefixes = append(page.CommonPrefixes, aws.StringValue(p.Prefix))
+	}
+	for _, v := range resp.Versions {
+		page.Versions = append(page.Versions, S3ObjectVersion{
+			Key:          aws.StringValue(v.Key),
+			VersionID:    aws.StringValue(v.VersionId),
+			IsLatest:     aws.BoolValue(v.IsLatest),
+			LastModified: aws.TimeValue(v.LastModified),
+			Size:         aws.Int64Value(v.Size),
+			ETag:         aws.StringValue(v.ETag),
+			StorageClass: aws.StringValue(v.StorageClass),
+		})
+	}
+	for _, m := range resp.DeleteMarkers {
+		page.Versions = append(page.Versions, S3ObjectVersion{
+			Key:          aws.StringValue(m.Key),
+			VersionID:    aws.StringValue(m.VersionId),
+			IsLatest:     aws.BoolValue(m.IsLatest),
+			LastModified: aws.TimeValue(m.LastModified),
+			DeleteMarker: true,
+		})
+	}
+	// 版本和删除标记在响应中是两个列表, 合并后恢复 s3 的顺序: key 升序, 同一个 key 从新到旧
+	sort.SliceStable(page.Versions, func(i, j int) bool {
+		a, b := page.Versions[i], page.Versions[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		if a.IsLatest != b.IsLatest {
+			return a.IsLatest
+		}
+		return a.LastModified.After(b.LastModified)
+	})
+	return page, nil
+}
+
+// S3VersionIterator 逐页查询 object 版本, 用法与 S3ObjectIterator 相同
+type S3VersionIterator struct {
+	client    *S3Ceph
+	bucket    string
+	prefix    string
+	delimiter string
+
+	page     S3VersionPage
+	idx      int
+	fetched  bool
+	prefixes []string
+	err      error
+}
+
+// Versions 返回前缀下 object 版本的迭代器
+func (c *S3Ceph) Versions(bucketName, prefix, delimiter string) *S3VersionIterator {
+	return &S3VersionIterator{client: c, bucket: bucketName, prefix: prefix, delimiter: delimiter, idx: -1}
+}
+
+func (it *S3VersionIterator) Next() bool {
+	for it.err == nil {
+		if it.idx+1 < len(it.page.Versions) {
+			it.idx++
+			return true
+		}
+		if it.fetched && !it.page.Truncated {
+			return false
+		}
+		page, err := it.client.ListObjectVersionPage(it.bucket, it.prefix, it.delimiter, it.page.NextKeyMarker, it.page.NextVersionIDMarker, s3ListPageSize)
+		if err != nil {
+			it.err = fmt.Errorf("查询 bucket %s 的版本失败: %v", it.bucket, err)
+			return false
+		}
+		if page.Truncated && page.NextKeyMarker == "" {
+			it.err = fmt.Errorf("查询 bucket %s 的版本失败: 返回结果没有 NextKeyMarker", it.bucket)
+			return false
+		}
+		it.page, it.idx, it.fetched = page, -1, true
+		it.prefixes = append(it.prefixes, page.CommonPrefixes...)
+	}
+	return false
+}
+
+func (it *S3VersionIterator) Version() S3ObjectVersion {
+	return it.page.Versions[it.idx]
+}
+
+func (it *S3VersionIterator) CommonPrefixes() []string {
+	return it.prefixes
+}
+
+func (it *S3VersionIterator) Err() error {
+	return it.err
+}
+
+// RestoreObjectVersion 将历史版本复制为最新版本, 原有的版本都保留. 返回新版本的 version id
+// CopyObject 单次最多复制 5GB, 超过时需要分片复制
+func (c *S3Ceph) RestoreObjectVersion(bucketName, objectName, versionID string) (string, error) {
+	source := (&url.URL{Path: bucketName + "/" + objectName}).EscapedPath() + "?versionId=" + url.QueryEscape(versionID)
+
+	svc := s3.New(c.Session)
+	resp, err := svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String(objectName),
+		CopySource: aws.String(source),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(resp.VersionId), nil
+}
+
+// DeleteObjectVersion 永久删除 object 的一个版本; 删除的是删除标记时, 上一个版本重新成为最新版本
+func (c *S3Ceph) DeleteObjectVersion(bucketName, objectName, versionID string) error {
+	svc := s3.New(c.Session)
+	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(objectName),
+		VersionId: aws.String(versionID),
+	})
+	return err
+}
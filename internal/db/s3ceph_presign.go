@@ -0,0 +1,231 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-26 10:15:22
+ */
+
+package db
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	s3Region = "us-east-1"
+
+	// 分片上传的限制: 除最后一片外每片最小 5MB, 最多 10000 片
+	S3MinPartSize  = 5 << 20
+	S3MaxPartCount = 10000
+)
+
+// PresignPut 生成上传 object 的预签名 URL, contentType 不为空时签名中包含 Content-Type, 上传时必须使用相同的值
+// 签名中包含 Content-Length, 上传的文件必须正好是 size 字节
+func (c *S3Ceph) PresignPut(bucketName, objectName, contentType string, size int64, expire time.Duration) (S3PresignedRequest, error) {
+	if size <= 0 {
+		return S3PresignedRequest{}, errors.New("文件大小必须大于 0")
+	}
+	s3Input := &s3.PutObjectInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(objectName),
+		ContentLength: aws.Int64(size),
+	}
+	if contentType != "" {
+		s3Input.ContentType = aws.String(contentType)
+	}
+
+	svc := s3.New(c.Session)
+	req, _ := svc.PutObjectRequest(s3Input)
+	url, headers, err := req.PresignRequest(expire)
+	if err != nil {
+		return S3PresignedRequest{}, err
+	}
+
+	result := S3PresignedRequest{Method: "PUT", URL: url, Headers: map[string]string{}, Expiration: time.Now().Add(expire)}
+	for name, values := range headers {
+		// host 由 http 客户端自动设置
+		if !strings.EqualFold(name, "host") {
+			result.Headers[http.CanonicalHeaderKey(name)] = strings.Join(values, ",")
+		}
+	}
+	return result, nil
+}
+
+// PresignPost 生成浏览器表单上传的签名(POST policy), s3 在上传时校验 object 名称, Content-Type 和文件大小
+func (c *S3Ceph) PresignPost(bucketName, objectName, contentType string, maxSize int64, expire time.Duration) (S3PresignedPost, error) {
+	return c.presignPost(bucketName, objectName, contentType, maxSize, time.Now().UTC(), expire)
+}
+
+func (c *S3Ceph) presignPost(bucketName, objectName, contentType string, maxSize int64, now time.Time, expire time.Duration) (S3PresignedPost, error) {
+	if maxSize <= 0 {
+		return S3PresignedPost{}, errors.New("文件大小限制必须大于 0")
+	}
+
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+	credential := fmt.Sprintf("%s/%s/%s/s3/aws4_request", c.config.AccessKey, date, s3Region)
+	expiration := now.Add(expire)
+
+	fields := map[string]string{
+		"key":              objectName,
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": credential,
+		"x-amz-date":       amzDate,
+	}
+	if contentType != "" {
+		fields["Content-Type"] = contentType
+	}
+
+	conditions := []any{
+		map[string]string{"bucket": bucketName},
+		[]any{"content-length-range", 0, maxSize},
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		conditions = append(conditions, []any{"eq", "$" + name, fields[name]})
+	}
+
+	policy, err := json.Marshal(map[string]any{
+		"expiration": expiration.Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return S3PresignedPost{}, err
+	}
+	fields["policy"] = base64.StdEncoding.EncodeToString(policy)
+
+	key := s3SigningKey(c.config.SecretKey, date, s3Region, "s3")
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(key, fields["policy"]))
+
+	return S3PresignedPost{
+		URL:        strings.TrimSuffix(c.config.EndPoint, "/") + "/" + bucketName,
+		Fields:     fields,
+		Expiration: expiration,
+	}, nil
+}
+
+// s3SigningKey v4 签名的密钥
+func s3SigningKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// MultipartPartSize 根据文件大小计算分片大小和分片数. partSize 为 0 时使用最小分片, 分片数超过上限时自动增大分片
+func MultipartPartSize(size, partSize int64) (int64, int64, error) {
+	if size <= 0 {
+		return 0, 0, errors.New("文件大小必须大于 0")
+	}
+	if partSize == 0 {
+		partSize = S3MinPartSize
+	}
+	if partSize < S3MinPartSize {
+		return 0, 0, fmt.Errorf("分片大小不能小于 %d 字节", S3MinPartSize)
+	}
+	if minSize := (size + S3MaxPartCount - 1) / S3MaxPartCount; partSize < minSize {
+		partSize = (minSize + S3MinPartSize - 1) / S3MinPartSize * S3MinPartSize
+	}
+	return partSize, (size + partSize - 1) / partSize, nil
+}
+
+// CreateMultipartUpload 创建分片上传, 返回 upload id
+func (c *S3Ceph) CreateMultipartUpload(bucketName, objectName, contentType string) (string, error) {
+	s3Input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	}
+	if contentType != "" {
+		s3Input.ContentType = aws.String(contentType)
+	}
+
+	svc := s3.New(c.Session)
+	resp, err := svc.CreateMultipartUpload(s3Input)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(resp.UploadId), nil
+}
+
+// PresignUploadPart 生成上传一个分片的预签名 URL, 上传后从响应的 ETag 头获取分片的 etag
+func (c *S3Ceph) PresignUploadPart(bucketName, objectName, uploadID string, partNumber int64, expire time.Duration) (string, error) {
+	svc := s3.New(c.Session)
+	req, _ := svc.UploadPartRequest(&s3.UploadPartInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String(objectName),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(partNumber),
+	})
+	return req.Presign(expire)
+}
+
+// ListUploadedParts 获取分片上传中已经上传的分片
+func (c *S3Ceph) ListUploadedParts(bucketName, objectName, uploadID string) ([]S3UploadedPart, error) {
+	svc := s3.New(c.Session)
+	parts := []S3UploadedPart{}
+	err := svc.ListPartsPages(&s3.ListPartsInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(objectName),
+		UploadId: aws.String(uploadID),
+	}, func(page *s3.ListPartsOutput, lastPage bool) bool {
+		for _, p := range page.Parts {
+			parts = append(parts, S3UploadedPart{
+				PartNumber: aws.Int64Value(p.PartNumber),
+				ETag:       aws.StringValue(p.ETag),
+				Size:       aws.Int64Value(p.Size),
+			})
+		}
+		return true
+	})
+	return parts, err
+}
+
+// CompleteMultipartUpload 按分片号合并分片
+func (c *S3Ceph) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []S3UploadedPart) error {
+	completed := make([]*s3.CompletedPart, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, &s3.CompletedPart{PartNumber: aws.Int64(p.PartNumber), ETag: aws.String(p.ETag)})
+	}
+	sort.Slice(completed, func(i, j int) bool { return *completed[i].PartNumber < *completed[j].PartNumber })
+
+	svc := s3.New(c.Session)
+	_, err := svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(objectName),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	return err
+}
+
+// AbortMultipartUpload 取消分片上传, 删除已经上传的分片
+func (c *S3Ceph) AbortMultipartUpload(bucketName, objectName, uploadID string) error {
+	svc := s3.New(c.Session)
+	_, err := svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(objectName),
+		UploadId: aws.String(uploadID),
+	})
+	return err
+}
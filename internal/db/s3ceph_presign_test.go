@@ -0,0 +1,124 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-26 17:30:52
+ */
+
+package db
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"myadmin/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestS3SigningKey(t *testing.T) {
+	// AWS 文档中 v4 签名密钥的示例
+	key := s3SigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	if got := hex.EncodeToString(key); got != "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d" {
+		t.Errorf("signing key = %s", got)
+	}
+}
+
+func TestPresignPost(t *testing.T) {
+	client, err := NewS3Ceph(&config.S3Config{EndPoint: "http://rgw.local:8080/", AccessKey: "ak", SecretKey: "sk", DisableSSL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 4, 26, 8, 0, 0, 0, time.UTC)
+	post, err := client.presignPost("bucket", "upload/a.png", "image/png", 1<<20, now, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if post.URL != "http://rgw.local:8080/bucket" || !post.Expiration.Equal(now.Add(time.Hour)) {
+		t.Errorf("url = %s, expiration = %s", post.URL, post.Expiration)
+	}
+	if post.Fields["x-amz-credential"] != "ak/20240426/us-east-1/s3/aws4_request" || post.Fields["x-amz-date"] != "20240426T080000Z" {
+		t.Errorf("fields = %v", post.Fields)
+	}
+	wantSig := hex.EncodeToString(hmacSHA256(s3SigningKey("sk", "20240426", "us-east-1", "s3"), post.Fields["policy"]))
+	if post.Fields["x-amz-signature"] != wantSig {
+		t.Errorf("signature = %s, want %s", post.Fields["x-amz-signature"], wantSig)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(post.Fields["policy"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var policy struct {
+		Expiration string `json:"expiration"`
+		Conditions []any  `json:"conditions"`
+	}
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		t.Fatal(err)
+	}
+	if policy.Expiration != "2024-04-26T09:00:00.000Z" {
+		t.Errorf("policy expiration = %s", policy.Expiration)
+	}
+	// 表单中的每个字段都要在 policy 中, 并限制文件大小
+	conditions := string(raw)
+	for _, want := range []string{`{"bucket":"bucket"}`, `["content-length-range",0,1048576]`, `["eq","$key","upload/a.png"]`, `["eq","$Content-Type","image/png"]`, `["eq","$x-amz-date","20240426T080000Z"]`} {
+		if !strings.Contains(conditions, want) {
+			t.Errorf("policy %s does not contain %s", conditions, want)
+		}
+	}
+
+	if _, err := client.presignPost("bucket", "a", "", 0, now, time.Hour); err == nil {
+		t.Error("presignPost without size limit should fail")
+	}
+}
+
+func TestPresignPut(t *testing.T) {
+	client, err := NewS3Ceph(&config.S3Config{EndPoint: "http://rgw.local:8080", AccessKey: "ak", SecretKey: "sk", DisableSSL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := client.PresignPut("bucket", "a b.png", "image/png", 1024, 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "PUT" || !strings.HasPrefix(req.URL, "http://rgw.local:8080/bucket/a%20b.png?") || !strings.Contains(req.URL, "X-Amz-Expires=600") {
+		t.Errorf("url = %s", req.URL)
+	}
+	if req.Headers["Content-Type"] != "image/png" {
+		t.Errorf("headers = %v, want signed Content-Type", req.Headers)
+	}
+	if req.Headers["Content-Length"] != "1024" || !strings.Contains(req.URL, "content-length") {
+		t.Errorf("headers = %v, url = %s, want signed Content-Length", req.Headers, req.URL)
+	}
+	if _, err := client.PresignPut("bucket", "a", "", 0, time.Minute); err == nil {
+		t.Error("PresignPut without size should fail")
+	}
+}
+
+func TestMultipartPartSize(t *testing.T) {
+	tests := []struct {
+		size, partSize, wantSize, wantCount int64
+		wantErr                             bool
+	}{
+		{size: 1, wantSize: S3MinPartSize, wantCount: 1},
+		{size: 12 << 20, wantSize: S3MinPartSize, wantCount: 3},
+		{size: 100 << 20, partSize: 10 << 20, wantSize: 10 << 20, wantCount: 10},
+		// 分片数超过 10000 时按 5MB 的整数倍增大分片
+		{size: 100 << 30, wantSize: 15 << 20, wantCount: 6827},
+		{size: 0, wantErr: true},
+		{size: 10 << 20, partSize: 1 << 20, wantErr: true},
+	}
+	for _, tt := range tests {
+		size, count, err := MultipartPartSize(tt.size, tt.partSize)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("MultipartPartSize(%d, %d) err = %v", tt.size, tt.partSize, err)
+			continue
+		}
+		if size != tt.wantSize || count != tt.wantCount {
+			t.Errorf("MultipartPartSize(%d, %d) = %d, %d, want %d, %d", tt.size, tt.partSize, size, count, tt.wantSize, tt.wantCount)
+		}
+		if !tt.wantErr && count > S3MaxPartCount {
+			t.Errorf("MultipartPartSize(%d, %d) count %d exceeds limit", tt.size, tt.partSize, count)
+		}
+	}
+}
@@ -0,0 +1,417 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-24 14:26:03
+ */
+
+package db
+
+import (
+	"encoding/xml"
+	"fmt"
+	"myadmin/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 本地的 s3 替身, 只实现测试用到的接口: ListObjectsV2, ListObjectVersions, CopyObject 和按版本删除
+type fakeS3 struct {
+	mu       sync.Mutex
+	bucket   string
+	versions []fakeVersion
+	seq      int
+	requests int
+}
+
+type fakeVersion struct {
+	Key          string
+	VersionID    string
+	DeleteMarker bool
+	Size         int64
+	Modified     time.Time
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *S3Ceph) {
+	f := &fakeS3{bucket: bucket}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	client, err := NewS3Ceph(&config.S3Config{EndPoint: srv.URL, AccessKey: "ak", SecretKey: "sk", DisableSSL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, client
+}
+
+// put 写入一个新版本, 后写入的版本更新
+func (f *fakeS3) put(key string, size int64, deleteMarker bool) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	id := fmt.Sprintf("v%04d", f.seq)
+	f.versions = append(f.versions, fakeVersion{
+		Key: key, VersionID: id, DeleteMarker: deleteMarker, Size: size,
+		Modified: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(f.seq) * time.Second),
+	})
+	return id
+}
+
+// sorted 按 key 升序, 同一个 key 从新到旧排序
+func (f *fakeS3) sorted() []fakeVersion {
+	vs := append([]fakeVersion(nil), f.versions...)
+	sort.SliceStable(vs, func(i, j int) bool {
+		if vs[i].Key != vs[j].Key {
+			return vs[i].Key < vs[j].Key
+		}
+		return vs[i].Modified.After(vs[j].Modified)
+	})
+	return vs
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<Error><Code>NoSuchBucket</Code><Message>no such bucket</Message></Error>`)
+		return
+	}
+	q := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodGet && key == "" && q.Get("list-type") == "2":
+		f.listObjectsV2(w, q)
+	case r.Method == http.MethodGet && key == "" && q.Has("versions"):
+		f.listVersions(w, q)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		f.copyObject(w, key, r.Header.Get("X-Amz-Copy-Source"))
+	case r.Method == http.MethodDelete && q.Get("versionId") != "":
+		f.deleteVersion(w, key, q.Get("versionId"))
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) listObjectsV2(w http.ResponseWriter, q url.Values) {
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	maxKeys, _ := strconv.Atoi(q.Get("max-keys"))
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	// 最新版本不是删除标记的 object, 按分隔符折叠为目录, object 和目录都计入 max-keys
+	type entry struct {
+		key    string
+		prefix bool
+		size   int64
+		mod    time.Time
+	}
+	var entries []entry
+	seen := map[string]bool{}
+	for _, v := range f.sorted() {
+		if seen[v.Key] {
+			continue
+		}
+		seen[v.Key] = true
+		if v.DeleteMarker || !strings.HasPrefix(v.Key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(v.Key[len(prefix):], delimiter); i >= 0 {
+				p := v.Key[:len(prefix)+i+len(delimiter)]
+				if len(entries) == 0 || entries[len(entries)-1].key != p {
+					entries = append(entries, entry{key: p, prefix: true})
+				}
+				continue
+			}
+		}
+		entries = append(entries, entry{key: v.Key, size: v.Size, mod: v.Modified})
+	}
+
+	start, _ := strconv.Atoi(q.Get("continuation-token"))
+	end := min(start+maxKeys, len(entries))
+
+	type content struct {
+		Key          string
+		LastModified string
+		Size         int64
+		StorageClass string
+	}
+	type commonPrefix struct{ Prefix string }
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		KeyCount              int
+		MaxKeys               int
+		IsTruncated           bool
+		NextContinuationToken string         `xml:",omitempty"`
+		Contents              []content      `xml:"Contents"`
+		CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+	}{Name: f.bucket, Prefix: prefix, KeyCount: end - start, MaxKeys: maxKeys, IsTruncated: end < len(entries)}
+	if result.IsTruncated {
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+	for _, e := range entries[start:end] {
+		if e.prefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{e.key})
+		} else {
+			result.Contents = append(result.Contents, content{e.key, e.mod.Format(time.RFC3339), e.size, "STANDARD"})
+		}
+	}
+	writeXML(w, result)
+}
+
+func (f *fakeS3) listVersions(w http.ResponseWriter, q url.Values) {
+	prefix := q.Get("prefix")
+	maxKeys, _ := strconv.Atoi(q.Get("max-keys"))
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	var all []fakeVersion
+	for _, v := range f.sorted() {
+		if strings.HasPrefix(v.Key, prefix) {
+			all = append(all, v)
+		}
+	}
+	start := 0
+	if marker := q.Get("key-marker"); marker != "" {
+		for i, v := range all {
+			if v.Key == marker && v.VersionID == q.Get("version-id-marker") {
+				start = i + 1
+				break
+			}
+		}
+	}
+	end := min(start+maxKeys, len(all))
+
+	type version struct {
+		Key          string
+		VersionId    string
+		IsLatest     bool
+		LastModified string
+		Size         int64  `xml:",omitempty"`
+		ETag         string `xml:",omitempty"`
+	}
+	result := struct {
+		XMLName             xml.Name `xml:"ListVersionsResult"`
+		Name                string
+		Prefix              string
+		MaxKeys             int
+		IsTruncated         bool
+		NextKeyMarker       string    `xml:",omitempty"`
+		NextVersionIdMarker string    `xml:",omitempty"`
+		Versions            []version `xml:"Version"`
+		DeleteMarkers       []version `xml:"DeleteMarker"`
+	}{Name: f.bucket, Prefix: prefix, MaxKeys: maxKeys, IsTruncated: end < len(all)}
+	if result.IsTruncated {
+		result.NextKeyMarker, result.NextVersionIdMarker = all[end-1].Key, all[end-1].VersionID
+	}
+	for i, v := range all[start:end] {
+		latest := start+i == 0 || all[start+i-1].Key != v.Key
+		item := version{Key: v.Key, VersionId: v.VersionID, IsLatest: latest, LastModified: v.Modified.Format(time.RFC3339)}
+		if v.DeleteMarker {
+			result.DeleteMarkers = append(result.DeleteMarkers, item)
+			continue
+		}
+		item.Size, item.ETag = v.Size, `"`+v.VersionID+`"`
+		result.Versions = append(result.Versions, item)
+	}
+	writeXML(w, result)
+}
+
+func (f *fakeS3) copyObject(w http.ResponseWriter, key, source string) {
+	u, err := url.Parse(source)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	srcPath, _ := url.PathUnescape(strings.TrimPrefix(u.EscapedPath(), "/"))
+	if srcPath != f.bucket+"/"+key {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, v := range f.versions {
+		if v.Key == key && v.VersionID == u.Query().Get("versionId") && !v.DeleteMarker {
+			f.seq++
+			id := fmt.Sprintf("v%04d", f.seq)
+			f.versions = append(f.versions, fakeVersion{Key: key, VersionID: id, Size: v.Size, Modified: v.Modified.Add(time.Hour * time.Duration(f.seq))})
+			w.Header().Set("x-amz-version-id", id)
+			writeXML(w, struct {
+				XMLName      xml.Name `xml:"CopyObjectResult"`
+				ETag         string
+				LastModified string
+			}{ETag: `"` + id + `"`, LastModified: time.Now().UTC().Format(time.RFC3339)})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprint(w, `<Error><Code>NoSuchVersion</Code><Message>no such version</Message></Error>`)
+}
+
+func (f *fakeS3) deleteVersion(w http.ResponseWriter, key, versionID string) {
+	for i, v := range f.versions {
+		if v.Key == key && v.VersionID == versionID {
+			f.versions = append(f.versions[:i], f.versions[i+1:]...)
+			break
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
+
+func TestS3ObjectIterator(t *testing.T) {
+	f, client := newFakeS3(t, "bucket")
+	// 超过一页的 object, 确认不会在 1000 个时截断
+	for i := 0; i < 2500; i++ {
+		f.put(fmt.Sprintf("logs/%05d.log", i), int64(i), false)
+	}
+	f.put("docs/a.txt", 1, false)
+	f.put("docs/sub/b.txt", 1, false)
+	f.put("docs/deleted.txt", 1, false)
+	f.put("docs/deleted.txt", 0, true)
+	f.put("readme.md", 1, false)
+
+	objects, err := client.ListObjectFromBucket("bucket", "logs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2500 {
+		t.Fatalf("ListObjectFromBucket returned %d objects, want 2500", len(objects))
+	}
+	for i, obj := range objects {
+		if want := fmt.Sprintf("logs/%05d.log", i); obj.Key != want || obj.Size != int64(i) {
+			t.Fatalf("objects[%d] = %s/%d, want %s/%d", i, obj.Key, obj.Size, want, i)
+		}
+	}
+
+	// 按目录浏览: 下一级目录在 CommonPrefixes 中, 删除标记对应的 object 不返回
+	it := client.Objects("bucket", "", "/")
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Object().Key)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(keys) != "[readme.md]" {
+		t.Errorf("root objects = %v, want [readme.md]", keys)
+	}
+	if fmt.Sprint(it.CommonPrefixes()) != "[docs/ logs/]" {
+		t.Errorf("root prefixes = %v, want [docs/ logs/]", it.CommonPrefixes())
+	}
+
+	it = client.Objects("bucket", "docs/", "/")
+	keys = nil
+	for it.Next() {
+		keys = append(keys, it.Object().Key)
+	}
+	if fmt.Sprint(keys) != "[docs/a.txt]" || fmt.Sprint(it.CommonPrefixes()) != "[docs/sub/]" {
+		t.Errorf("docs/ = %v %v, want [docs/a.txt] [docs/sub/]", keys, it.CommonPrefixes())
+	}
+
+	// 迭代器按需翻页: 只读取第一个 object 时只发送一次请求
+	f.requests = 0
+	it = client.Objects("bucket", "logs/", "")
+	if !it.Next() || f.requests != 1 {
+		t.Errorf("first Next sent %d requests, want 1", f.requests)
+	}
+
+	if _, err := client.ListObjectFromBucket("missing", ""); err == nil {
+		t.Error("ListObjectFromBucket on missing bucket should fail")
+	}
+}
+
+func TestS3ObjectPage(t *testing.T) {
+	f, client := newFakeS3(t, "bucket")
+	for i := 0; i < 5; i++ {
+		f.put(fmt.Sprintf("%d.txt", i), 1, false)
+	}
+
+	page, err := client.ListObjectPage("bucket", "", "/", "", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Objects) != 3 || !page.Truncated || page.NextToken == "" {
+		t.Fatalf("first page = %+v, want 3 objects and truncated", page)
+	}
+	page, err = client.ListObjectPage("bucket", "", "/", page.NextToken, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Objects) != 2 || page.Truncated || page.Objects[0].Key != "3.txt" {
+		t.Fatalf("second page = %+v, want 3.txt, 4.txt", page)
+	}
+}
+
+func TestS3Versions(t *testing.T) {
+	f, client := newFakeS3(t, "bucket")
+	v1 := f.put("a.txt", 10, false)
+	f.put("a.txt", 20, false)
+	marker := f.put("a.txt", 0, true)
+	for i := 0; i < 1200; i++ {
+		f.put(fmt.Sprintf("b/%04d", i), 1, false)
+	}
+
+	it := client.Versions("bucket", "", "")
+	var versions []S3ObjectVersion
+	for it.Next() {
+		versions = append(versions, it.Version())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1203 {
+		t.Fatalf("got %d versions, want 1203", len(versions))
+	}
+	// 删除标记是最新版本, 排在同一个 key 的最前面
+	if a := versions[0]; a.VersionID != marker || !a.DeleteMarker || !a.IsLatest {
+		t.Errorf("versions[0] = %+v, want latest delete marker %s", a, marker)
+	}
+	if a := versions[2]; a.VersionID != v1 || a.IsLatest || a.Size != 10 {
+		t.Errorf("versions[2] = %+v, want old version %s", a, v1)
+	}
+
+	// 恢复旧版本生成新的最新版本, 删除标记保留在历史中
+	newID, err := client.RestoreObjectVersion("bucket", "a.txt", v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := client.ListObjectVersionPage("bucket", "a.txt", "", "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Versions) != 4 || page.Versions[0].VersionID != newID || page.Versions[0].Size != 10 || !page.Versions[0].IsLatest {
+		t.Fatalf("after restore = %+v, want new latest version %s", page.Versions, newID)
+	}
+
+	if _, err := client.RestoreObjectVersion("bucket", "a.txt", "nope"); err == nil {
+		t.Error("restore of missing version should fail")
+	}
+
+	// 删除版本
+	if err := client.DeleteObjectVersion("bucket", "a.txt", marker); err != nil {
+		t.Fatal(err)
+	}
+	page, _ = client.ListObjectVersionPage("bucket", "a.txt", "", "", "", 10)
+	for _, v := range page.Versions {
+		if v.VersionID == marker {
+			t.Errorf("version %s still exists after delete", marker)
+		}
+	}
+}
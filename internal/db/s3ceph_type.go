@@ -5,16 +5,165 @@
 
 package db
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type S3Bucket struct {
-	Name         string
-	CreationDate time.Time
+	Name         string    `json:"name"`
+	CreationDate time.Time `json:"creation_date"`
 }
 
 type S3Object struct {
-	Key          string
-	LastModified time.Time
-	Size         int64
-	StorageClass string
+	Key          string    `json:"key"`
+	LastModified time.Time `json:"last_modified"`
+	Size         int64     `json:"size"`
+	StorageClass string    `json:"storage_class"`
+}
+
+// S3ObjectPage 分页查询 object 的结果, Truncated 为 true 时用 NextToken 查询下一页
+type S3ObjectPage struct {
+	Prefix         string     `json:"prefix"`
+	Delimiter      string     `json:"delimiter"`
+	CommonPrefixes []string   `json:"common_prefixes"`
+	Objects        []S3Object `json:"objects"`
+	NextToken      string     `json:"next_token"`
+	Truncated      bool       `json:"truncated"`
+}
+
+type S3DeleteResult struct {
+	Deleted []string        `json:"deleted"`
+	Errors  []S3DeleteError `json:"errors"`
+}
+
+type S3DeleteError struct {
+	Key     string `json:"key"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// S3VersionPage 分页查询 object 版本的结果
+type S3VersionPage struct {
+	Prefix              string            `json:"prefix"`
+	Delimiter           string            `json:"delimiter"`
+	CommonPrefixes      []string          `json:"common_prefixes"`
+	Versions            []S3ObjectVersion `json:"versions"`
+	NextKeyMarker       string            `json:"next_key_marker"`
+	NextVersionIDMarker string            `json:"next_version_id_marker"`
+	Truncated           bool              `json:"truncated"`
+}
+
+type S3ObjectVersion struct {
+	Key          string    `json:"key"`
+	VersionID    string    `json:"version_id"`
+	IsLatest     bool      `json:"is_latest"`
+	DeleteMarker bool      `json:"delete_marker"`
+	LastModified time.Time `json:"last_modified"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	StorageClass string    `json:"storage_class"`
+}
+
+const S3PolicyVersion = "2012-10-17"
+
+// S3Policy bucket 策略
+type S3Policy struct {
+	Version   string              `json:"Version"`
+	Statement []S3PolicyStatement `json:"Statement"`
+}
+
+type S3PolicyStatement struct {
+	Sid       string                             `json:"Sid,omitempty"`
+	Effect    string                             `json:"Effect"`
+	Principal S3Principal                        `json:"Principal,omitempty"`
+	Action    S3StringList                       `json:"Action"`
+	Resource  S3StringList                       `json:"Resource"`
+	Condition map[string]map[string]S3StringList `json:"Condition,omitempty"`
+}
+
+// S3StringList 策略中的字段可以是单个字符串或字符串数组, 解析后统一为数组
+type S3StringList []string
+
+func (l *S3StringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = S3StringList{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// S3Principal 策略的授权对象, "*" 解析为 {"AWS": ["*"]}
+type S3Principal map[string]S3StringList
+
+func (p *S3Principal) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = S3Principal{"AWS": {s}}
+		return nil
+	}
+	var m map[string]S3StringList
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*p = m
+	return nil
+}
+
+// S3PolicyGrant 生成 bucket 策略的授权: 用户对 bucket 中前缀下的 object 有只读或读写权限, 前缀为空时为整个 bucket
+type S3PolicyGrant struct {
+	Users  []string `json:"users"`
+	Prefix string   `json:"prefix"`
+	Access string   `json:"access"` // read, readwrite
+}
+
+// S3LifecycleRule 生命周期规则, 天数为 0 表示不执行该动作
+type S3LifecycleRule struct {
+	ID                 string `json:"id"`
+	Prefix             string `json:"prefix"`
+	Enabled            bool   `json:"enabled"`
+	ExpirationDays     int64  `json:"expiration_days"`      // 最新版本创建多少天后过期
+	NoncurrentDays     int64  `json:"noncurrent_days"`      // 历史版本变为非最新版本多少天后删除
+	AbortMultipartDays int64  `json:"abort_multipart_days"` // 未完成的分片上传多少天后清理
+}
+
+type S3CORSRule struct {
+	AllowedOrigins []string `json:"allowed_origins"`
+	AllowedMethods []string `json:"allowed_methods"`
+	AllowedHeaders []string `json:"allowed_headers"`
+	ExposeHeaders  []string `json:"expose_headers"`
+	MaxAgeSeconds  int64    `json:"max_age_seconds"`
+}
+
+type S3BucketUsage struct {
+	Bucket  string `json:"bucket"`
+	Objects int64  `json:"objects"`
+	Size    int64  `json:"size"`
+}
+
+// S3PresignedRequest 预签名请求, 请求时必须带上 Headers 中的请求头
+type S3PresignedRequest struct {
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers"`
+	Expiration time.Time         `json:"expiration"`
+}
+
+// S3PresignedPost 表单上传的签名, 以 multipart/form-data POST 到 URL, Fields 作为表单字段, 文件字段 file 放在最后
+type S3PresignedPost struct {
+	URL        string            `json:"url"`
+	Fields     map[string]string `json:"fields"`
+	Expiration time.Time         `json:"expiration"`
+}
+
+type S3UploadedPart struct {
+	PartNumber int64  `json:"part_number"`
+	ETag       string `json:"etag"`
+	Size       int64  `json:"size"`
 }
@@ -0,0 +1,75 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-10 10:40:11
+ */
+
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemaInspector 读取表结构, 按 DBClient 的类型选择 mysql 或 postgres 的实现
+type SchemaInspector interface {
+	Dialect() string
+	// Databases 实例中的库
+	Databases() ([]string, error)
+	// Schemas mysql 为所有库, postgres 为当前连接的库中的 schema
+	Schemas() ([]string, error)
+	// Tables schema 中的表和视图
+	Tables(schema string) ([]SchemaTableSummary, error)
+	// Describe 读取表结构, tables 为空时读取 schema 中的所有表和视图, 不包括 DDL
+	Describe(schema string, tables ...string) ([]SchemaTable, error)
+	// DDL 建表或者建视图的语句
+	DDL(schema, table string) (string, error)
+}
+
+// NewSchemaInspector 根据连接的类型创建 SchemaInspector
+func NewSchemaInspector(client DBClient) (SchemaInspector, error) {
+	switch c := client.(type) {
+	case *MysqlClient:
+		return &mysqlSchema{client: c}, nil
+	case *PgSQLClient:
+		return &pgSchema{client: c}, nil
+	}
+	return nil, fmt.Errorf("不支持读取 %s 的表结构", client.Config().Dialect)
+}
+
+// schemaDDL 各数据库生成 DDL 的方法, schema 为执行语句的目标 schema
+type schemaDDL interface {
+	createTable(schema string, t SchemaTable) []string
+	dropTable(schema string, t SchemaTable) string
+	// addColumn after 为 source 中的上一列, 为空时是第一列
+	addColumn(schema string, t SchemaTable, c SchemaColumn, after string) []string
+	alterColumn(schema string, t SchemaTable, want, have SchemaColumn) []SchemaStatement
+	dropColumn(schema string, t SchemaTable, c SchemaColumn) string
+	// indexKey 用于比较索引是否相同, 不包括索引名
+	indexKey(idx SchemaIndex) string
+	addIndex(schema string, t SchemaTable, idx SchemaIndex) string
+	dropIndex(schema string, t SchemaTable, idx SchemaIndex) string
+	addConstraint(schema string, t SchemaTable, c SchemaConstraint) string
+	dropConstraint(schema string, t SchemaTable, c SchemaConstraint) string
+	// tableOptions 表选项的差异和修改语句, 如 engine 和 comment
+	tableOptions(schema string, want, have SchemaTable) (diffs []string, stmts []string)
+}
+
+func schemaDDLFor(dialect string) (schemaDDL, error) {
+	switch dialect {
+	case "mysql":
+		return mysqlDDL{}, nil
+	case "postgres":
+		return pgDDL{}, nil
+	}
+	return nil, fmt.Errorf("不支持比较 %s 的表结构", dialect)
+}
+
+// 列表类型的字段在查询中使用 \x1f 分隔
+const schemaListSep = "\x1f"
+
+func splitSchemaList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, schemaListSep)
+}
@@ -0,0 +1,200 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-11 10:16:48
+ */
+
+package db
+
+import "sort"
+
+// DiffSchema 比较 source 和 target 的表结构, 生成在 target 的 targetSchema 上执行后与 source 一致的语句
+// 只比较表, 不比较视图; 列, 索引和约束按名字匹配, 改名会被当作删除后添加; 不调整已有列的顺序
+// 语句的顺序: 删除约束, 创建表, 修改表, 添加约束, 删除表, 外键在所有表创建之后添加, 在删除表之前删除
+func DiffSchema(dialect, targetSchema string, source, target []SchemaTable) (SchemaDiff, error) {
+	ddl, err := schemaDDLFor(dialect)
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+
+	diff := SchemaDiff{
+		Dialect:       dialect,
+		MissingTables: []string{},
+		ExtraTables:   []string{},
+		ChangedTables: []SchemaTableDiff{},
+		Statements:    []SchemaStatement{},
+	}
+	sources, targets := schemaTablesByName(source), schemaTablesByName(target)
+
+	var dropConstraints, createTables, alterTables, addConstraints, dropTables []SchemaStatement
+	add := func(stmts *[]SchemaStatement, table string, destructive bool, sqls ...string) {
+		for _, sql := range sqls {
+			*stmts = append(*stmts, SchemaStatement{Table: table, SQL: sql, Destructive: destructive})
+		}
+	}
+
+	for _, name := range sortedTableNames(sources) {
+		want := sources[name]
+		have, ok := targets[name]
+		if !ok {
+			diff.MissingTables = append(diff.MissingTables, name)
+			add(&createTables, name, false, ddl.createTable(targetSchema, want)...)
+			for _, c := range want.Constraints {
+				add(&addConstraints, name, false, ddl.addConstraint(targetSchema, want, c))
+			}
+			continue
+		}
+
+		td := SchemaTableDiff{Table: name}
+
+		// 约束
+		haveConstraints := schemaConstraintsByName(have.Constraints)
+		wantConstraints := schemaConstraintsByName(want.Constraints)
+		for _, c := range have.Constraints {
+			if w, ok := wantConstraints[c.Name]; !ok {
+				td.ExtraConstraints = append(td.ExtraConstraints, c.Name)
+				add(&dropConstraints, name, false, ddl.dropConstraint(targetSchema, have, c))
+			} else if w.Type != c.Type || w.Definition != c.Definition {
+				td.ChangedConstraints = append(td.ChangedConstraints, c.Name)
+				add(&dropConstraints, name, false, ddl.dropConstraint(targetSchema, have, c))
+				add(&addConstraints, name, false, ddl.addConstraint(targetSchema, want, w))
+			}
+		}
+		for _, c := range want.Constraints {
+			if _, ok := haveConstraints[c.Name]; !ok {
+				td.MissingConstraints = append(td.MissingConstraints, c.Name)
+				add(&addConstraints, name, false, ddl.addConstraint(targetSchema, want, c))
+			}
+		}
+
+		// 索引, 先删除再添加, 避免主键或同名索引冲突
+		haveIndexes := schemaIndexesByName(have.Indexes)
+		wantIndexes := schemaIndexesByName(want.Indexes)
+		var addIndexes []SchemaIndex
+		for _, idx := range have.Indexes {
+			if w, ok := wantIndexes[idx.Name]; !ok {
+				td.ExtraIndexes = append(td.ExtraIndexes, idx.Name)
+				add(&alterTables, name, false, ddl.dropIndex(targetSchema, have, idx))
+			} else if ddl.indexKey(w) != ddl.indexKey(idx) {
+				td.ChangedIndexes = append(td.ChangedIndexes, idx.Name)
+				add(&alterTables, name, false, ddl.dropIndex(targetSchema, have, idx))
+				addIndexes = append(addIndexes, w)
+			}
+		}
+		for _, idx := range want.Indexes {
+			if _, ok := haveIndexes[idx.Name]; !ok {
+				td.MissingIndexes = append(td.MissingIndexes, idx.Name)
+				addIndexes = append(addIndexes, idx)
+			}
+		}
+
+		// 列
+		haveColumns := schemaColumnsByName(have.Columns)
+		wantColumns := schemaColumnsByName(want.Columns)
+		after := ""
+		for _, c := range want.Columns {
+			if h, ok := haveColumns[c.Name]; !ok {
+				td.MissingColumns = append(td.MissingColumns, c.Name)
+				add(&alterTables, name, false, ddl.addColumn(targetSchema, want, c, after)...)
+			} else if !equalColumn(c, h) {
+				td.ChangedColumns = append(td.ChangedColumns, c.Name)
+				alterTables = append(alterTables, ddl.alterColumn(targetSchema, want, c, h)...)
+			}
+			after = c.Name
+		}
+		for _, c := range have.Columns {
+			if _, ok := wantColumns[c.Name]; !ok {
+				td.ExtraColumns = append(td.ExtraColumns, c.Name)
+				add(&alterTables, name, true, ddl.dropColumn(targetSchema, have, c))
+			}
+		}
+
+		for _, idx := range addIndexes {
+			add(&alterTables, name, false, ddl.addIndex(targetSchema, want, idx))
+		}
+
+		options, stmts := ddl.tableOptions(targetSchema, want, have)
+		td.Options = options
+		add(&alterTables, name, false, stmts...)
+
+		changed := len(td.MissingColumns)+len(td.ExtraColumns)+len(td.ChangedColumns)+
+			len(td.MissingIndexes)+len(td.ExtraIndexes)+len(td.ChangedIndexes)+
+			len(td.MissingConstraints)+len(td.ExtraConstraints)+len(td.ChangedConstraints)+len(td.Options) > 0
+		if changed {
+			diff.ChangedTables = append(diff.ChangedTables, td)
+		}
+	}
+
+	for _, name := range sortedTableNames(targets) {
+		if _, ok := sources[name]; !ok {
+			diff.ExtraTables = append(diff.ExtraTables, name)
+			// 先删除外键, 多余的表之间有引用时按任意顺序删除都不会失败
+			for _, c := range targets[name].Constraints {
+				if c.Type == "FOREIGN KEY" {
+					add(&dropConstraints, name, false, ddl.dropConstraint(targetSchema, targets[name], c))
+				}
+			}
+			add(&dropTables, name, true, ddl.dropTable(targetSchema, targets[name]))
+		}
+	}
+
+	for _, stmts := range [][]SchemaStatement{dropConstraints, createTables, alterTables, addConstraints, dropTables} {
+		diff.Statements = append(diff.Statements, stmts...)
+	}
+	return diff, nil
+}
+
+func schemaTablesByName(tables []SchemaTable) map[string]SchemaTable {
+	m := map[string]SchemaTable{}
+	for _, t := range tables {
+		if t.Type == SchemaTypeTable {
+			m[t.Name] = t
+		}
+	}
+	return m
+}
+
+func sortedTableNames(tables map[string]SchemaTable) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func schemaColumnsByName(columns []SchemaColumn) map[string]SchemaColumn {
+	m := make(map[string]SchemaColumn, len(columns))
+	for _, c := range columns {
+		m[c.Name] = c
+	}
+	return m
+}
+
+func schemaIndexesByName(indexes []SchemaIndex) map[string]SchemaIndex {
+	m := make(map[string]SchemaIndex, len(indexes))
+	for _, idx := range indexes {
+		m[idx.Name] = idx
+	}
+	return m
+}
+
+func schemaConstraintsByName(constraints []SchemaConstraint) map[string]SchemaConstraint {
+	m := make(map[string]SchemaConstraint, len(constraints))
+	for _, c := range constraints {
+		m[c.Name] = c
+	}
+	return m
+}
+
+// equalColumn 比较列的定义, 不比较位置
+func equalColumn(a, b SchemaColumn) bool {
+	return a.Type == b.Type && a.Nullable == b.Nullable && equalDefault(a.Default, b.Default) && a.Extra == b.Extra &&
+		a.Generated == b.Generated && a.Identity == b.Identity && a.Collation == b.Collation && a.Comment == b.Comment
+}
+
+func equalDefault(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,362 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-11 15:32:20
+ */
+
+package db
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestMysqlColumnDefault(t *testing.T) {
+	for _, tc := range []struct {
+		value      *string
+		extra      string
+		generation string
+		def        string // "" 表示没有默认值
+		rest       string
+		generated  string
+		mariadb    bool
+	}{
+		{nil, "auto_increment", "", "", "auto_increment", "", false},
+		{strPtr("abc"), "", "", "'abc'", "", "", false},
+		{strPtr("it's"), "", "", "'it''s'", "", "", false},
+		{strPtr(""), "", "", "''", "", "", false},
+		{strPtr("CURRENT_TIMESTAMP"), "on update CURRENT_TIMESTAMP", "", "CURRENT_TIMESTAMP", "on update CURRENT_TIMESTAMP", "", false},
+		{strPtr("CURRENT_TIMESTAMP(3)"), "DEFAULT_GENERATED on update CURRENT_TIMESTAMP(3)", "", "CURRENT_TIMESTAMP(3)", "on update CURRENT_TIMESTAMP(3)", "", false},
+		{strPtr("uuid()"), "DEFAULT_GENERATED", "", "(uuid())", "", "", false},
+		{strPtr("b'0'"), "", "", "b'0'", "", "", false},
+		{nil, "STORED GENERATED", "`price` * `qty`", "", "", "GENERATED ALWAYS AS (`price` * `qty`) STORED", false},
+		// mariadb 的默认值已经是 SQL 表达式
+		{strPtr("'abc'"), "", "", "'abc'", "", "", true},
+		{strPtr("NULL"), "", "", "", "", "", true},
+		{strPtr("'NULL'"), "", "", "'NULL'", "", "", true},
+		{strPtr("0"), "", "", "0", "", "", true},
+		{strPtr("current_timestamp()"), "on update current_timestamp()", "", "current_timestamp()", "on update current_timestamp()", "", true},
+	} {
+		def, rest, generated := mysqlColumnDefault(tc.value, tc.extra, tc.generation, tc.mariadb)
+		got := ""
+		if def != nil {
+			got = *def
+		}
+		if got != tc.def || rest != tc.rest || generated != tc.generated {
+			t.Errorf("%v %q: got (%q, %q, %q)", tc.value, tc.extra, got, rest, generated)
+		}
+	}
+}
+
+func sqls(stmts []SchemaStatement) []string {
+	result := make([]string, 0, len(stmts))
+	for _, s := range stmts {
+		result = append(result, s.SQL)
+	}
+	return result
+}
+
+func TestDiffSchemaMysql(t *testing.T) {
+	users := SchemaTable{Name: "users", Type: SchemaTypeTable, Engine: "InnoDB", Collation: "utf8mb4_bin",
+		Columns: []SchemaColumn{
+			{Name: "id", Type: "bigint unsigned", Extra: "auto_increment"},
+			{Name: "name", Type: "varchar(64)", Default: strPtr("''")},
+		},
+		Indexes: []SchemaIndex{{Name: "PRIMARY", Columns: []string{"id"}, Unique: true, Primary: true, Method: "BTREE"}},
+	}
+	orders := SchemaTable{Name: "orders", Type: SchemaTypeTable, Engine: "InnoDB", Collation: "utf8mb4_bin", Comment: "订单",
+		Columns: []SchemaColumn{
+			{Name: "id", Type: "bigint unsigned", Extra: "auto_increment"},
+			{Name: "user_id", Type: "bigint unsigned"},
+			{Name: "note", Type: "varchar(255)", Nullable: true},
+		},
+		Indexes: []SchemaIndex{
+			{Name: "PRIMARY", Columns: []string{"id"}, Unique: true, Primary: true, Method: "BTREE"},
+			{Name: "idx_user", Columns: []string{"user_id"}, Method: "BTREE"},
+		},
+		Constraints: []SchemaConstraint{{Name: "fk_user", Type: "FOREIGN KEY", Definition: "FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)"}},
+	}
+	source := []SchemaTable{users, orders, {Name: "v_orders", Type: SchemaTypeView}}
+
+	// target: users 的 name 更长, 多一个 legacy 列和 idx_name 索引; 没有 orders; 多一个 tmp 表
+	targetUsers := users
+	targetUsers.Columns = []SchemaColumn{
+		{Name: "id", Type: "bigint unsigned", Extra: "auto_increment"},
+		{Name: "legacy", Type: "int", Nullable: true},
+		{Name: "name", Type: "varchar(32)", Default: strPtr("''")},
+	}
+	targetUsers.Indexes = append(slices.Clone(users.Indexes), SchemaIndex{Name: "idx_name", Columns: []string{"name(16)"}, Method: "BTREE"})
+	targetUsers.Engine = "MyISAM"
+	target := []SchemaTable{targetUsers, {Name: "tmp", Type: SchemaTypeTable}}
+
+	diff, err := DiffSchema("mysql", "shop", source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(diff.MissingTables, []string{"orders"}) || !slices.Equal(diff.ExtraTables, []string{"tmp"}) || len(diff.ChangedTables) != 1 {
+		t.Fatalf("got %+v", diff)
+	}
+	td := diff.ChangedTables[0]
+	if !slices.Equal(td.ChangedColumns, []string{"name"}) || !slices.Equal(td.ExtraColumns, []string{"legacy"}) ||
+		!slices.Equal(td.ExtraIndexes, []string{"idx_name"}) || len(td.Options) != 1 {
+		t.Errorf("table diff got %+v", td)
+	}
+
+	want := []string{
+		"CREATE TABLE `shop`.`orders` (\n" +
+			"  `id` bigint unsigned NOT NULL auto_increment,\n" +
+			"  `user_id` bigint unsigned NOT NULL,\n" +
+			"  `note` varchar(255) NULL,\n" +
+			"  PRIMARY KEY (`id`),\n" +
+			"  INDEX `idx_user` (`user_id`)\n" +
+			") ENGINE=InnoDB DEFAULT COLLATE=utf8mb4_bin COMMENT='订单'",
+		"ALTER TABLE `shop`.`users` DROP INDEX `idx_name`",
+		"ALTER TABLE `shop`.`users` MODIFY COLUMN `name` varchar(64) NOT NULL DEFAULT ''",
+		"ALTER TABLE `shop`.`users` DROP COLUMN `legacy`",
+		"ALTER TABLE `shop`.`users` ENGINE=InnoDB",
+		"ALTER TABLE `shop`.`orders` ADD CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)",
+		"DROP TABLE `shop`.`tmp`",
+	}
+	if got := sqls(diff.Statements); !slices.Equal(got, want) {
+		t.Errorf("statements got:\n%s", strings.Join(got, "\n"))
+	}
+	for _, s := range diff.Statements {
+		if s.Destructive != (strings.HasPrefix(s.SQL, "DROP TABLE") || strings.Contains(s.SQL, "DROP COLUMN") || strings.Contains(s.SQL, "MODIFY COLUMN `name`")) {
+			t.Errorf("%s destructive %v", s.SQL, s.Destructive)
+		}
+	}
+
+	same, err := DiffSchema("mysql", "shop", source, source)
+	if err != nil || len(same.Statements) != 0 || len(same.ChangedTables) != 0 {
+		t.Errorf("same schema got %+v, %v", same, err)
+	}
+}
+
+func TestDiffSchemaMysqlAddColumn(t *testing.T) {
+	source := []SchemaTable{{Name: "t", Type: SchemaTypeTable, Columns: []SchemaColumn{
+		{Name: "a", Type: "int"}, {Name: "b", Type: "int", Nullable: true, Comment: "b's"}, {Name: "c", Type: "int"},
+	}}}
+	target := []SchemaTable{{Name: "t", Type: SchemaTypeTable, Columns: []SchemaColumn{{Name: "c", Type: "int"}}}}
+	diff, _ := DiffSchema("mysql", "db", source, target)
+	want := []string{
+		"ALTER TABLE `db`.`t` ADD COLUMN `a` int NOT NULL FIRST",
+		"ALTER TABLE `db`.`t` ADD COLUMN `b` int NULL COMMENT 'b''s' AFTER `a`",
+	}
+	if got := sqls(diff.Statements); !slices.Equal(got, want) {
+		t.Errorf("statements got:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestDiffSchemaMysqlCheck(t *testing.T) {
+	d := mysqlDDL{}
+	source := []SchemaTable{{Name: "orders", Type: SchemaTypeTable, Constraints: []SchemaConstraint{
+		{Name: "chk_total", Type: "CHECK", Definition: d.check("`total` >= 0", true)},
+		{Name: "chk_note", Type: "CHECK", Definition: d.check("`note` <> ''", false)},
+	}}}
+	target := []SchemaTable{{Name: "orders", Type: SchemaTypeTable, Constraints: []SchemaConstraint{
+		{Name: "chk_total", Type: "CHECK", Definition: d.check("`total` > 0", true)},
+		{Name: "chk_old", Type: "CHECK", Definition: d.check("`total` < 100", true)},
+	}}}
+
+	diff, err := DiffSchema("mysql", "shop", source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.ChangedTables) != 1 {
+		t.Fatalf("got %+v", diff)
+	}
+	td := diff.ChangedTables[0]
+	if !slices.Equal(td.ChangedConstraints, []string{"chk_total"}) || !slices.Equal(td.ExtraConstraints, []string{"chk_old"}) ||
+		!slices.Equal(td.MissingConstraints, []string{"chk_note"}) {
+		t.Errorf("table diff got %+v", td)
+	}
+	want := []string{
+		"ALTER TABLE `shop`.`orders` DROP CHECK `chk_total`",
+		"ALTER TABLE `shop`.`orders` DROP CHECK `chk_old`",
+		"ALTER TABLE `shop`.`orders` ADD CONSTRAINT `chk_total` CHECK (`total` >= 0)",
+		"ALTER TABLE `shop`.`orders` ADD CONSTRAINT `chk_note` CHECK (`note` <> '') NOT ENFORCED",
+	}
+	if got := sqls(diff.Statements); !slices.Equal(got, want) {
+		t.Errorf("statements got:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestDiffSchemaPgSQL(t *testing.T) {
+	source := []SchemaTable{{Name: "orders", Type: SchemaTypeTable, Comment: "订单",
+		Columns: []SchemaColumn{
+			{Name: "id", Type: "bigint", Identity: "BY DEFAULT"},
+			{Name: "status", Type: "text", Default: strPtr("'new'::text")},
+			{Name: "total", Type: "numeric(12,2)", Nullable: true},
+		},
+		Indexes: []SchemaIndex{
+			{Name: "orders_pkey", Columns: []string{"id"}, Unique: true, Primary: true, Constraint: true, Method: "btree",
+				Definition: "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (id)"},
+			{Name: "orders_status_idx", Columns: []string{"status"}, Method: "btree", Where: "(status <> 'done'::text)",
+				Definition: "CREATE INDEX orders_status_idx ON public.orders USING btree (status) WHERE (status <> 'done'::text)"},
+		},
+		Constraints: []SchemaConstraint{{Name: "orders_total_check", Type: "CHECK", Definition: "CHECK ((total >= (0)::numeric))"}},
+	}}
+
+	target := []SchemaTable{{Name: "orders", Type: SchemaTypeTable,
+		Columns: []SchemaColumn{
+			{Name: "id", Type: "integer"},
+			{Name: "status", Type: "text", Nullable: true},
+		},
+		Indexes: []SchemaIndex{
+			{Name: "orders_pkey", Columns: []string{"id"}, Unique: true, Primary: true, Constraint: true, Method: "btree",
+				Definition: "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (id)"},
+			{Name: "orders_status_idx", Columns: []string{"status"}, Method: "btree",
+				Definition: "CREATE INDEX orders_status_idx ON public.orders USING btree (status)"},
+		},
+		Constraints: []SchemaConstraint{{Name: "orders_total_check", Type: "CHECK", Definition: "CHECK ((total > (0)::numeric))"}},
+	}}
+
+	diff, err := DiffSchema("postgres", "public", source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`ALTER TABLE "public"."orders" DROP CONSTRAINT "orders_total_check"`,
+		`DROP INDEX "public"."orders_status_idx"`,
+		`ALTER TABLE "public"."orders" ALTER COLUMN "id" TYPE bigint USING "id"::bigint`,
+		`ALTER TABLE "public"."orders" ALTER COLUMN "id" ADD GENERATED BY DEFAULT AS IDENTITY`,
+		`ALTER TABLE "public"."orders" ALTER COLUMN "status" SET NOT NULL`,
+		`ALTER TABLE "public"."orders" ALTER COLUMN "status" SET DEFAULT 'new'::text`,
+		`ALTER TABLE "public"."orders" ADD COLUMN "total" numeric(12,2)`,
+		`CREATE INDEX "orders_status_idx" ON "public"."orders" USING btree (status) WHERE (status <> 'done'::text)`,
+		`COMMENT ON TABLE "public"."orders" IS '订单'`,
+		`ALTER TABLE "public"."orders" ADD CONSTRAINT "orders_total_check" CHECK ((total >= (0)::numeric))`,
+	}
+	if got := sqls(diff.Statements); !slices.Equal(got, want) {
+		t.Errorf("statements got:\n%s", strings.Join(got, "\n"))
+	}
+	for _, s := range diff.Statements {
+		if s.Destructive != strings.Contains(s.SQL, " TYPE ") {
+			t.Errorf("%s destructive %v", s.SQL, s.Destructive)
+		}
+	}
+
+	// target 没有 orders 时建表
+	diff, _ = DiffSchema("postgres", "staging", source, nil)
+	want = []string{
+		"CREATE TABLE \"staging\".\"orders\" (\n" +
+			"    \"id\" bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,\n" +
+			"    \"status\" text NOT NULL DEFAULT 'new'::text,\n" +
+			"    \"total\" numeric(12,2),\n" +
+			"    CONSTRAINT \"orders_pkey\" PRIMARY KEY (\"id\")\n" +
+			")",
+		`CREATE INDEX "orders_status_idx" ON "staging"."orders" USING btree (status) WHERE (status <> 'done'::text)`,
+		`COMMENT ON TABLE "staging"."orders" IS '订单'`,
+		`ALTER TABLE "staging"."orders" ADD CONSTRAINT "orders_total_check" CHECK ((total >= (0)::numeric))`,
+	}
+	if got := sqls(diff.Statements); !slices.Equal(got, want) {
+		t.Errorf("create statements got:\n%s", strings.Join(got, "\n"))
+	}
+
+	if _, err := DiffSchema("sqlite", "main", nil, nil); err == nil {
+		t.Error("unsupported dialect should fail")
+	}
+}
+
+func TestDiffSchemaMysqlModifyDestructive(t *testing.T) {
+	source := []SchemaTable{{Name: "t", Type: SchemaTypeTable, Columns: []SchemaColumn{
+		{Name: "a", Type: "int", Comment: "new"}, {Name: "b", Type: "varchar(50)"},
+	}}}
+	target := []SchemaTable{{Name: "t", Type: SchemaTypeTable, Columns: []SchemaColumn{
+		{Name: "a", Type: "int"}, {Name: "b", Type: "varchar(255)"},
+	}}}
+	diff, _ := DiffSchema("mysql", "db", source, target)
+	if len(diff.Statements) != 2 || diff.Statements[0].Destructive || !diff.Statements[1].Destructive {
+		t.Errorf("got %+v", diff.Statements)
+	}
+}
+
+func TestDiffSchemaPgSQLSerial(t *testing.T) {
+	source := []SchemaTable{{Name: "users", Type: SchemaTypeTable,
+		Columns: []SchemaColumn{{Name: "id", Type: "bigserial"}, {Name: "no", Type: "serial"}},
+		Indexes: []SchemaIndex{{Name: "users_pkey", Columns: []string{"id"}, Unique: true, Primary: true, Constraint: true, Method: "btree"}},
+	}}
+
+	// target 没有 users 时由 serial 创建序列
+	diff, _ := DiffSchema("postgres", "public", source, nil)
+	want := []string{
+		"CREATE TABLE \"public\".\"users\" (\n" +
+			"    \"id\" bigserial NOT NULL,\n" +
+			"    \"no\" serial NOT NULL,\n" +
+			"    CONSTRAINT \"users_pkey\" PRIMARY KEY (\"id\")\n" +
+			")",
+	}
+	if got := sqls(diff.Statements); !slices.Equal(got, want) {
+		t.Errorf("create statements got:\n%s", strings.Join(got, "\n"))
+	}
+
+	// target 的 id 为 integer, no 没有序列
+	target := []SchemaTable{{Name: "users", Type: SchemaTypeTable,
+		Columns: []SchemaColumn{{Name: "id", Type: "serial"}, {Name: "no", Type: "integer", Default: strPtr("0")}},
+		Indexes: source[0].Indexes,
+	}}
+	diff, _ = DiffSchema("postgres", "public", source, target)
+	want = []string{
+		`ALTER TABLE "public"."users" ALTER COLUMN "id" TYPE bigint USING "id"::bigint`,
+		`CREATE SEQUENCE "public"."users_no_seq" OWNED BY "public"."users"."no"`,
+		`SELECT setval('"public"."users_no_seq"', COALESCE(max("no"), 0) + 1, false) FROM "public"."users"`,
+		`ALTER TABLE "public"."users" ALTER COLUMN "no" SET DEFAULT nextval('"public"."users_no_seq"'::regclass)`,
+	}
+	if got := sqls(diff.Statements); !slices.Equal(got, want) {
+		t.Errorf("alter statements got:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestDiffSchemaDropReferencedTables(t *testing.T) {
+	parent := SchemaTable{Name: "a_parent", Type: SchemaTypeTable, Columns: []SchemaColumn{{Name: "id", Type: "integer"}}}
+	child := SchemaTable{Name: "b_child", Type: SchemaTypeTable, Columns: []SchemaColumn{{Name: "parent_id", Type: "integer"}},
+		Constraints: []SchemaConstraint{{Name: "b_child_parent_fk", Type: "FOREIGN KEY", Definition: "FOREIGN KEY (parent_id) REFERENCES a_parent(id)"}},
+	}
+	diff, _ := DiffSchema("postgres", "public", nil, []SchemaTable{parent, child})
+	want := []string{
+		`ALTER TABLE "public"."b_child" DROP CONSTRAINT "b_child_parent_fk"`,
+		`DROP TABLE "public"."a_parent"`,
+		`DROP TABLE "public"."b_child"`,
+	}
+	if got := sqls(diff.Statements); !slices.Equal(got, want) {
+		t.Errorf("postgres statements got:\n%s", strings.Join(got, "\n"))
+	}
+
+	diff, _ = DiffSchema("mysql", "shop", nil, []SchemaTable{parent, child})
+	want = []string{
+		"ALTER TABLE `shop`.`b_child` DROP FOREIGN KEY `b_child_parent_fk`",
+		"DROP TABLE `shop`.`a_parent`",
+		"DROP TABLE `shop`.`b_child`",
+	}
+	if got := sqls(diff.Statements); !slices.Equal(got, want) {
+		t.Errorf("mysql statements got:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestPgForeignKey(t *testing.T) {
+	d := pgDDL{}
+	fk := SchemaConstraint{Name: "orders_user_fk", Type: "FOREIGN KEY", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"},
+		OnUpdate: "NO ACTION", OnDelete: "CASCADE"}
+	if got, want := d.foreignKey(fk, false, false, false), `FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// 引用其他 schema 中的表时带上 schema
+	fk.RefTable, fk.OnDelete = "auth.users", "NO ACTION"
+	if got, want := d.foreignKey(fk, true, true, true), `FOREIGN KEY ("user_id") REFERENCES "auth"."users"("id") MATCH FULL DEFERRABLE INITIALLY DEFERRED`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// 两个 schema 中引用本 schema 的相同外键没有差异
+	fk.RefTable = "users"
+	fk.Definition = d.foreignKey(fk, false, false, false)
+	source := []SchemaTable{{Schema: "a", Name: "orders", Type: SchemaTypeTable, Constraints: []SchemaConstraint{fk}}}
+	target := []SchemaTable{{Schema: "b", Name: "orders", Type: SchemaTypeTable, Constraints: []SchemaConstraint{fk}}}
+	if diff, _ := DiffSchema("postgres", "b", source, target); len(diff.ChangedTables) != 0 || len(diff.Statements) != 0 {
+		t.Errorf("got %+v", diff)
+	}
+}
@@ -0,0 +1,588 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-10 11:02:45
+ */
+
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// mysql 的系统库
+var mysqlSystemSchemas = []string{"mysql", "information_schema", "performance_schema", "sys"}
+
+type mysqlSchema struct {
+	client DBClient
+}
+
+func (m *mysqlSchema) Dialect() string {
+	return "mysql"
+}
+
+func (m *mysqlSchema) Databases() ([]string, error) {
+	return m.Schemas()
+}
+
+func (m *mysqlSchema) Schemas() ([]string, error) {
+	schemas := []string{}
+	err := m.client.Raw("SELECT SCHEMA_NAME FROM information_schema.SCHEMATA WHERE SCHEMA_NAME NOT IN ? ORDER BY SCHEMA_NAME",
+		mysqlSystemSchemas).Scan(&schemas).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询库列表失败: %v", err)
+	}
+	return schemas, nil
+}
+
+type mysqlTableRow struct {
+	SchemaTableSummary
+	Collation string `gorm:"column:table_collation"`
+}
+
+func (m *mysqlSchema) tables(schema string, names []string) ([]mysqlTableRow, error) {
+	sql := `SELECT TABLE_SCHEMA AS table_schema, TABLE_NAME AS table_name,
+	CASE WHEN TABLE_TYPE IN ('VIEW', 'SYSTEM VIEW') THEN 'view' ELSE 'table' END AS table_type,
+	COALESCE(ENGINE, '') AS engine, COALESCE(TABLE_ROWS, 0) AS table_rows,
+	COALESCE(DATA_LENGTH, 0) AS data_bytes, COALESCE(INDEX_LENGTH, 0) AS index_bytes,
+	CASE WHEN TABLE_TYPE = 'VIEW' THEN '' ELSE COALESCE(TABLE_COMMENT, '') END AS table_comment,
+	COALESCE(TABLE_COLLATION, '') AS table_collation
+FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?`
+	values := []any{schema}
+	if len(names) > 0 {
+		sql += " AND TABLE_NAME IN ?"
+		values = append(values, names)
+	}
+
+	rows := []mysqlTableRow{}
+	if err := m.client.Raw(sql+" ORDER BY TABLE_NAME", values...).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询表列表失败: %v", err)
+	}
+	return rows, nil
+}
+
+func (m *mysqlSchema) Tables(schema string) ([]SchemaTableSummary, error) {
+	rows, err := m.tables(schema, nil)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]SchemaTableSummary, 0, len(rows))
+	for _, r := range rows {
+		tables = append(tables, r.SchemaTableSummary)
+	}
+	return tables, nil
+}
+
+func (m *mysqlSchema) Describe(schema string, names ...string) ([]SchemaTable, error) {
+	rows, err := m.tables(schema, names)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]SchemaTable, 0, len(rows))
+	index := map[string]int{}
+	for i, r := range rows {
+		tables = append(tables, SchemaTable{
+			Schema:      r.Schema,
+			Name:        r.Name,
+			Type:        r.Type,
+			Engine:      r.Engine,
+			Collation:   r.Collation,
+			Comment:     r.Comment,
+			Columns:     []SchemaColumn{},
+			Indexes:     []SchemaIndex{},
+			Constraints: []SchemaConstraint{},
+		})
+		index[r.Name] = i
+	}
+	if len(tables) == 0 {
+		return tables, nil
+	}
+
+	filter, values := " AND TABLE_NAME IN ?", []any{schema, names}
+	if len(names) == 0 {
+		filter, values = "", []any{schema}
+	}
+	if err := m.columns(tables, index, filter, values); err != nil {
+		return nil, err
+	}
+	if err := m.indexes(tables, index, filter, values); err != nil {
+		return nil, err
+	}
+	if err := m.foreignKeys(tables, index, strings.Replace(filter, "TABLE_NAME", "k.TABLE_NAME", 1), values); err != nil {
+		return nil, err
+	}
+	if err := m.checks(tables, index, strings.Replace(filter, "TABLE_NAME", "t.TABLE_NAME", 1), values); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+func (m *mysqlSchema) columns(tables []SchemaTable, index map[string]int, filter string, values []any) error {
+	var version string
+	if err := m.client.Raw("SELECT VERSION()").Scan(&version).Error; err != nil {
+		return fmt.Errorf("查询版本失败: %v", err)
+	}
+	mariadb := strings.Contains(strings.ToLower(version), "mariadb")
+
+	var rows []struct {
+		Table      string  `gorm:"column:table_name"`
+		Name       string  `gorm:"column:column_name"`
+		Position   int     `gorm:"column:position"`
+		Type       string  `gorm:"column:column_type"`
+		Nullable   bool    `gorm:"column:nullable"`
+		Default    *string `gorm:"column:column_default"`
+		Extra      string  `gorm:"column:extra"`
+		Generation string  `gorm:"column:generation_expression"`
+		Collation  string  `gorm:"column:collation_name"`
+		Comment    string  `gorm:"column:column_comment"`
+	}
+	err := m.client.Raw(`SELECT TABLE_NAME AS table_name, COLUMN_NAME AS column_name, ORDINAL_POSITION AS position,
+	COLUMN_TYPE AS column_type, IS_NULLABLE = 'YES' AS nullable, COLUMN_DEFAULT AS column_default, EXTRA AS extra,
+	COALESCE(GENERATION_EXPRESSION, '') AS generation_expression, COALESCE(COLLATION_NAME, '') AS collation_name,
+	COLUMN_COMMENT AS column_comment
+FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ?`+filter+` ORDER BY TABLE_NAME, ORDINAL_POSITION`, values...).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("查询列失败: %v", err)
+	}
+
+	for _, r := range rows {
+		i, ok := index[r.Table]
+		if !ok {
+			continue
+		}
+		c := SchemaColumn{Name: r.Name, Position: r.Position, Type: r.Type, Nullable: r.Nullable, Comment: r.Comment}
+		c.Default, c.Extra, c.Generated = mysqlColumnDefault(r.Default, r.Extra, r.Generation, mariadb)
+		if r.Collation != tables[i].Collation {
+			c.Collation = r.Collation
+		}
+		tables[i].Columns = append(tables[i].Columns, c)
+	}
+	return nil
+}
+
+var mysqlCurrentTimestamp = regexp.MustCompile(`(?i)^(current_timestamp|now|localtime|localtimestamp)(\(\d*\))?$`)
+
+// mysqlColumnDefault 把 information_schema 中的默认值转换为 SQL 表达式, 并从 EXTRA 中拆出生成列的定义
+// mysql 8.0 开始表达式默认值的 EXTRA 中有 DEFAULT_GENERATED, 字面量默认值没有引号;
+// mariadb 10.2.7 开始默认值已经是 SQL 表达式, 字面量带引号, 没有默认值的可空列为字符串 NULL
+func mysqlColumnDefault(value *string, extra, generation string, mariadb bool) (def *string, rest string, generated string) {
+	var parts []string
+	expression := false
+	for _, item := range []string{"DEFAULT_GENERATED", "VIRTUAL GENERATED", "STORED GENERATED"} {
+		if strings.Contains(extra, item) {
+			switch item {
+			case "DEFAULT_GENERATED":
+				expression = true
+			default:
+				generated = fmt.Sprintf("GENERATED ALWAYS AS (%s) %s", generation, strings.Fields(item)[0])
+			}
+			extra = strings.Replace(extra, item, "", 1)
+		}
+	}
+	parts = strings.Fields(extra)
+	rest = strings.Join(parts, " ")
+
+	if value == nil || generated != "" || (mariadb && *value == "NULL") {
+		return nil, rest, generated
+	}
+	v := *value
+	switch {
+	case mariadb:
+	case mysqlCurrentTimestamp.MatchString(v):
+	case expression:
+		v = "(" + v + ")"
+	case strings.HasPrefix(v, "b'") && strings.HasSuffix(v, "'"):
+	default:
+		v = mysqlDDL{}.literal(v)
+	}
+	return &v, rest, generated
+}
+
+func (m *mysqlSchema) indexes(tables []SchemaTable, index map[string]int, filter string, values []any) error {
+	var rows []struct {
+		Table   string `gorm:"column:table_name"`
+		Name    string `gorm:"column:index_name"`
+		Column  string `gorm:"column:column_name"`
+		SubPart *int64 `gorm:"column:sub_part"`
+		Unique  bool   `gorm:"column:is_unique"`
+		Method  string `gorm:"column:index_type"`
+	}
+	err := m.client.Raw(`SELECT TABLE_NAME AS table_name, INDEX_NAME AS index_name, COALESCE(COLUMN_NAME, '') AS column_name,
+	SUB_PART AS sub_part, NON_UNIQUE = 0 AS is_unique, INDEX_TYPE AS index_type
+FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = ?`+filter+` ORDER BY TABLE_NAME, INDEX_NAME = 'PRIMARY' DESC, INDEX_NAME, SEQ_IN_INDEX`, values...).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("查询索引失败: %v", err)
+	}
+
+	for _, r := range rows {
+		i, ok := index[r.Table]
+		if !ok {
+			continue
+		}
+		column := r.Column
+		if r.SubPart != nil {
+			column = fmt.Sprintf("%s(%d)", column, *r.SubPart)
+		}
+
+		indexes := tables[i].Indexes
+		if n := len(indexes); n > 0 && indexes[n-1].Name == r.Name {
+			indexes[n-1].Columns = append(indexes[n-1].Columns, column)
+			continue
+		}
+		tables[i].Indexes = append(indexes, SchemaIndex{
+			Name:    r.Name,
+			Columns: []string{column},
+			Unique:  r.Unique,
+			Primary: r.Name == "PRIMARY",
+			Method:  r.Method,
+		})
+	}
+	return nil
+}
+
+func (m *mysqlSchema) foreignKeys(tables []SchemaTable, index map[string]int, filter string, values []any) error {
+	var rows []struct {
+		Table     string `gorm:"column:table_name"`
+		Name      string `gorm:"column:constraint_name"`
+		Column    string `gorm:"column:column_name"`
+		RefSchema string `gorm:"column:ref_schema"`
+		RefTable  string `gorm:"column:ref_table"`
+		RefColumn string `gorm:"column:ref_column"`
+		OnUpdate  string `gorm:"column:update_rule"`
+		OnDelete  string `gorm:"column:delete_rule"`
+	}
+	err := m.client.Raw(`SELECT k.TABLE_NAME AS table_name, k.CONSTRAINT_NAME AS constraint_name, k.COLUMN_NAME AS column_name,
+	k.REFERENCED_TABLE_SCHEMA AS ref_schema, k.REFERENCED_TABLE_NAME AS ref_table, k.REFERENCED_COLUMN_NAME AS ref_column,
+	r.UPDATE_RULE AS update_rule, r.DELETE_RULE AS delete_rule
+FROM information_schema.KEY_COLUMN_USAGE AS k
+	JOIN information_schema.REFERENTIAL_CONSTRAINTS AS r
+		ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME AND r.TABLE_NAME = k.TABLE_NAME
+WHERE k.TABLE_SCHEMA = ? AND k.REFERENCED_TABLE_NAME IS NOT NULL`+filter+`
+ORDER BY k.TABLE_NAME, k.CONSTRAINT_NAME, k.ORDINAL_POSITION`, values...).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("查询外键失败: %v", err)
+	}
+
+	for _, r := range rows {
+		i, ok := index[r.Table]
+		if !ok {
+			continue
+		}
+		constraints := tables[i].Constraints
+		if n := len(constraints); n > 0 && constraints[n-1].Name == r.Name {
+			constraints[n-1].Columns = append(constraints[n-1].Columns, r.Column)
+			constraints[n-1].RefColumns = append(constraints[n-1].RefColumns, r.RefColumn)
+			continue
+		}
+
+		refTable := r.RefTable
+		if r.RefSchema != tables[i].Schema {
+			refTable = r.RefSchema + "." + r.RefTable
+		}
+		tables[i].Constraints = append(constraints, SchemaConstraint{
+			Name:       r.Name,
+			Type:       "FOREIGN KEY",
+			Columns:    []string{r.Column},
+			RefTable:   refTable,
+			RefColumns: []string{r.RefColumn},
+			OnUpdate:   r.OnUpdate,
+			OnDelete:   r.OnDelete,
+		})
+	}
+
+	for i := range tables {
+		for j := range tables[i].Constraints {
+			if c := &tables[i].Constraints[j]; c.Type == "FOREIGN KEY" {
+				c.Definition = mysqlDDL{}.foreignKey(*c)
+			}
+		}
+	}
+	return nil
+}
+
+// checks 查询 check 约束, information_schema.CHECK_CONSTRAINTS 在 mysql 8.0.16 和 mariadb 10.2 开始才有, 没有时跳过
+// 只有 mysql 的 TABLE_CONSTRAINTS 有 ENFORCED 列, mariadb 的 check 约束都是生效的
+// mariadb 的约束名只在表内唯一, CHECK_CONSTRAINTS 有 TABLE_NAME 列时关联条件要带上表名
+func (m *mysqlSchema) checks(tables []SchemaTable, index map[string]int, filter string, values []any) error {
+	var support struct {
+		Check    bool `gorm:"column:has_check"`
+		Enforced bool `gorm:"column:has_enforced"`
+		Table    bool `gorm:"column:has_table"`
+	}
+	err := m.client.Raw(`SELECT COALESCE(SUM(TABLE_NAME = 'CHECK_CONSTRAINTS'), 0) > 0 AS has_check,
+	COALESCE(SUM(TABLE_NAME = 'TABLE_CONSTRAINTS' AND COLUMN_NAME = 'ENFORCED'), 0) > 0 AS has_enforced,
+	COALESCE(SUM(TABLE_NAME = 'CHECK_CONSTRAINTS' AND COLUMN_NAME = 'TABLE_NAME'), 0) > 0 AS has_table
+FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA = 'information_schema' AND TABLE_NAME IN ('CHECK_CONSTRAINTS', 'TABLE_CONSTRAINTS')`).Scan(&support).Error
+	if err != nil {
+		return fmt.Errorf("查询 check 约束失败: %v", err)
+	}
+	if !support.Check {
+		return nil
+	}
+
+	enforced, on := "'YES'", ""
+	if support.Enforced {
+		enforced = "t.ENFORCED"
+	}
+	if support.Table {
+		on = " AND c.TABLE_NAME = t.TABLE_NAME"
+	}
+	var rows []struct {
+		Table    string `gorm:"column:table_name"`
+		Name     string `gorm:"column:constraint_name"`
+		Clause   string `gorm:"column:check_clause"`
+		Enforced bool   `gorm:"column:enforced"`
+	}
+	err = m.client.Raw(`SELECT t.TABLE_NAME AS table_name, t.CONSTRAINT_NAME AS constraint_name, c.CHECK_CLAUSE AS check_clause,
+	`+enforced+` = 'YES' AS enforced
+FROM information_schema.TABLE_CONSTRAINTS AS t
+	JOIN information_schema.CHECK_CONSTRAINTS AS c
+		ON c.CONSTRAINT_SCHEMA = t.CONSTRAINT_SCHEMA AND c.CONSTRAINT_NAME = t.CONSTRAINT_NAME`+on+`
+WHERE t.TABLE_SCHEMA = ? AND t.CONSTRAINT_TYPE = 'CHECK'`+filter+`
+ORDER BY t.TABLE_NAME, t.CONSTRAINT_NAME`, values...).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("查询 check 约束失败: %v", err)
+	}
+
+	for _, r := range rows {
+		i, ok := index[r.Table]
+		if !ok {
+			continue
+		}
+		tables[i].Constraints = append(tables[i].Constraints, SchemaConstraint{
+			Name:       r.Name,
+			Type:       "CHECK",
+			Definition: mysqlDDL{}.check(r.Clause, r.Enforced),
+		})
+	}
+	return nil
+}
+
+// DDL 执行 show create table, 视图为 show create view 的结果
+func (m *mysqlSchema) DDL(schema, table string) (string, error) {
+	d := mysqlDDL{}
+	rows, err := m.client.Raw("SHOW CREATE TABLE " + d.table(schema, table)).Rows()
+	if err != nil {
+		return "", fmt.Errorf("执行 show create table 失败: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	if !rows.Next() {
+		return "", fmt.Errorf("表 %s.%s 不存在", schema, table)
+	}
+	values := make([]any, len(columns))
+	var ddl string
+	for i := range values {
+		values[i] = new(any)
+	}
+	values[1] = &ddl
+	if err := rows.Scan(values...); err != nil {
+		return "", err
+	}
+	return ddl, rows.Err()
+}
+
+// mysqlDDL 生成 mysql 的 DDL
+type mysqlDDL struct{}
+
+func (mysqlDDL) quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (d mysqlDDL) table(schema, name string) string {
+	return d.quote(schema) + "." + d.quote(name)
+}
+
+func (mysqlDDL) literal(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(s) + "'"
+}
+
+func (d mysqlDDL) quoteColumns(columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, c := range columns {
+		quoted = append(quoted, d.quote(c))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+var mysqlPrefixColumn = regexp.MustCompile(`^(.*)\((\d+)\)$`)
+
+// indexColumns 前缀索引的列为 name(length)
+func (d mysqlDDL) indexColumns(columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if m := mysqlPrefixColumn.FindStringSubmatch(c); m != nil {
+			quoted = append(quoted, d.quote(m[1])+"("+m[2]+")")
+		} else {
+			quoted = append(quoted, d.quote(c))
+		}
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (d mysqlDDL) foreignKey(c SchemaConstraint) string {
+	refTable := d.quote(c.RefTable)
+	if schema, table, ok := strings.Cut(c.RefTable, "."); ok {
+		refTable = d.table(schema, table)
+	}
+	def := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", d.quoteColumns(c.Columns), refTable, d.quoteColumns(c.RefColumns))
+	if c.OnDelete != "" && c.OnDelete != "RESTRICT" && c.OnDelete != "NO ACTION" {
+		def += " ON DELETE " + c.OnDelete
+	}
+	if c.OnUpdate != "" && c.OnUpdate != "RESTRICT" && c.OnUpdate != "NO ACTION" {
+		def += " ON UPDATE " + c.OnUpdate
+	}
+	return def
+}
+
+// check mariadb 的 CHECK_CLAUSE 没有外层括号, 统一加上一层括号
+func (mysqlDDL) check(clause string, enforced bool) string {
+	def := "CHECK (" + clause + ")"
+	if !enforced {
+		def += " NOT ENFORCED"
+	}
+	return def
+}
+
+func (d mysqlDDL) columnDef(c SchemaColumn) string {
+	def := d.quote(c.Name) + " " + c.Type
+	if c.Collation != "" {
+		def += " COLLATE " + c.Collation
+	}
+	if c.Generated != "" {
+		def += " " + c.Generated
+	}
+	if c.Nullable {
+		def += " NULL"
+	} else {
+		def += " NOT NULL"
+	}
+	if c.Default != nil {
+		def += " DEFAULT " + *c.Default
+	}
+	if c.Extra != "" {
+		def += " " + c.Extra
+	}
+	if c.Comment != "" {
+		def += " COMMENT " + d.literal(c.Comment)
+	}
+	return def
+}
+
+func (d mysqlDDL) indexDef(idx SchemaIndex) string {
+	switch {
+	case idx.Primary:
+		return fmt.Sprintf("PRIMARY KEY (%s)", d.indexColumns(idx.Columns))
+	case idx.Method == "FULLTEXT" || idx.Method == "SPATIAL":
+		return fmt.Sprintf("%s INDEX %s (%s)", idx.Method, d.quote(idx.Name), d.indexColumns(idx.Columns))
+	case idx.Unique:
+		return fmt.Sprintf("UNIQUE INDEX %s (%s)", d.quote(idx.Name), d.indexColumns(idx.Columns))
+	}
+	def := fmt.Sprintf("INDEX %s (%s)", d.quote(idx.Name), d.indexColumns(idx.Columns))
+	if idx.Method == "HASH" {
+		def += " USING HASH"
+	}
+	return def
+}
+
+// createTable 建表语句不包括外键, 外键在所有表创建之后添加
+func (d mysqlDDL) createTable(schema string, t SchemaTable) []string {
+	var lines []string
+	for _, c := range t.Columns {
+		lines = append(lines, "  "+d.columnDef(c))
+	}
+	for _, idx := range t.Indexes {
+		lines = append(lines, "  "+d.indexDef(idx))
+	}
+
+	sql := fmt.Sprintf("CREATE TABLE %s (\n%s\n)", d.table(schema, t.Name), strings.Join(lines, ",\n"))
+	if t.Engine != "" {
+		sql += " ENGINE=" + t.Engine
+	}
+	if t.Collation != "" {
+		sql += " DEFAULT COLLATE=" + t.Collation
+	}
+	if t.Comment != "" {
+		sql += " COMMENT=" + d.literal(t.Comment)
+	}
+	return []string{sql}
+}
+
+func (d mysqlDDL) dropTable(schema string, t SchemaTable) string {
+	return "DROP TABLE " + d.table(schema, t.Name)
+}
+
+func (d mysqlDDL) addColumn(schema string, t SchemaTable, c SchemaColumn, after string) []string {
+	position := " FIRST"
+	if after != "" {
+		position = " AFTER " + d.quote(after)
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s%s", d.table(schema, t.Name), d.columnDef(c), position)}
+}
+
+// alterColumn 修改类型时可能截断数据, 不判断是否是扩大类型, 都标记为 destructive
+func (d mysqlDDL) alterColumn(schema string, t SchemaTable, want, have SchemaColumn) []SchemaStatement {
+	return []SchemaStatement{{
+		Table:       t.Name,
+		SQL:         fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", d.table(schema, t.Name), d.columnDef(want)),
+		Destructive: want.Type != have.Type,
+	}}
+}
+
+func (d mysqlDDL) dropColumn(schema string, t SchemaTable, c SchemaColumn) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", d.table(schema, t.Name), d.quote(c.Name))
+}
+
+func (mysqlDDL) indexKey(idx SchemaIndex) string {
+	return fmt.Sprintf("%v|%v|%v|%s", idx.Primary, idx.Unique, idx.Columns, idx.Method)
+}
+
+func (d mysqlDDL) addIndex(schema string, t SchemaTable, idx SchemaIndex) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", d.table(schema, t.Name), d.indexDef(idx))
+}
+
+func (d mysqlDDL) dropIndex(schema string, t SchemaTable, idx SchemaIndex) string {
+	if idx.Primary {
+		return fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY", d.table(schema, t.Name))
+	}
+	return fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", d.table(schema, t.Name), d.quote(idx.Name))
+}
+
+func (d mysqlDDL) addConstraint(schema string, t SchemaTable, c SchemaConstraint) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", d.table(schema, t.Name), d.quote(c.Name), c.Definition)
+}
+
+func (d mysqlDDL) dropConstraint(schema string, t SchemaTable, c SchemaConstraint) string {
+	kind := "CHECK"
+	if c.Type == "FOREIGN KEY" {
+		kind = "FOREIGN KEY"
+	}
+	return fmt.Sprintf("ALTER TABLE %s DROP %s %s", d.table(schema, t.Name), kind, d.quote(c.Name))
+}
+
+func (d mysqlDDL) tableOptions(schema string, want, have SchemaTable) (diffs []string, stmts []string) {
+	var options []string
+	if want.Engine != have.Engine {
+		diffs = append(diffs, fmt.Sprintf("engine: %s -> %s", have.Engine, want.Engine))
+		options = append(options, "ENGINE="+want.Engine)
+	}
+	if want.Collation != have.Collation {
+		diffs = append(diffs, fmt.Sprintf("collation: %s -> %s", have.Collation, want.Collation))
+		options = append(options, "DEFAULT COLLATE="+want.Collation)
+	}
+	if want.Comment != have.Comment {
+		diffs = append(diffs, "comment")
+		options = append(options, "COMMENT="+d.literal(want.Comment))
+	}
+	if len(options) > 0 {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s %s", d.table(schema, want.Name), strings.Join(options, " ")))
+	}
+	return diffs, stmts
+}
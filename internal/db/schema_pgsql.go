@@ -0,0 +1,591 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-10 14:21:06
+ */
+
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// postgres 的系统 schema, pg_toast 和 pg_temp 开头的也排除
+var pgSystemSchemas = []string{"pg_catalog", "information_schema"}
+
+type pgSchema struct {
+	client *PgSQLClient
+}
+
+func (p *pgSchema) Dialect() string {
+	return "postgres"
+}
+
+func (p *pgSchema) Databases() ([]string, error) {
+	databases := []string{}
+	err := p.client.Raw("SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate ORDER BY datname").Scan(&databases).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询库列表失败: %v", err)
+	}
+	return databases, nil
+}
+
+func (p *pgSchema) Schemas() ([]string, error) {
+	schemas := []string{}
+	err := p.client.Raw(`SELECT nspname FROM pg_namespace
+WHERE nspname NOT IN ? AND nspname NOT LIKE 'pg\_toast%' AND nspname NOT LIKE 'pg\_temp\_%' ORDER BY nspname`, pgSystemSchemas).Scan(&schemas).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询 schema 列表失败: %v", err)
+	}
+	return schemas, nil
+}
+
+// pgTableFilter 按 schema 和表名过滤 pg_class c 和 pg_namespace n 的条件
+func pgTableFilter(schema string, names []string) (string, []any) {
+	filter, values := " AND n.nspname = ?", []any{schema}
+	if len(names) > 0 {
+		filter += " AND c.relname IN ?"
+		values = append(values, names)
+	}
+	return filter, values
+}
+
+func (p *pgSchema) tables(schema string, names []string) ([]SchemaTableSummary, error) {
+	filter, values := pgTableFilter(schema, names)
+	tables := []SchemaTableSummary{}
+	err := p.client.Raw(`SELECT n.nspname AS table_schema, c.relname AS table_name,
+	CASE c.relkind WHEN 'v' THEN 'view' WHEN 'm' THEN 'materialized view' ELSE 'table' END AS table_type,
+	'' AS engine, GREATEST(c.reltuples, 0)::int8 AS table_rows,
+	pg_table_size(c.oid) AS data_bytes, pg_indexes_size(c.oid) AS index_bytes,
+	COALESCE(obj_description(c.oid, 'pg_class'), '') AS table_comment
+FROM pg_class AS c JOIN pg_namespace AS n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p', 'v', 'm')`+filter+` ORDER BY c.relname`, values...).Scan(&tables).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询表列表失败: %v", err)
+	}
+	return tables, nil
+}
+
+func (p *pgSchema) Tables(schema string) ([]SchemaTableSummary, error) {
+	return p.tables(schema, nil)
+}
+
+func (p *pgSchema) Describe(schema string, names ...string) ([]SchemaTable, error) {
+	summaries, err := p.tables(schema, names)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]SchemaTable, 0, len(summaries))
+	index := map[string]int{}
+	for i, s := range summaries {
+		tables = append(tables, SchemaTable{
+			Schema:      s.Schema,
+			Name:        s.Name,
+			Type:        s.Type,
+			Comment:     s.Comment,
+			Columns:     []SchemaColumn{},
+			Indexes:     []SchemaIndex{},
+			Constraints: []SchemaConstraint{},
+		})
+		index[s.Name] = i
+	}
+	if len(tables) == 0 {
+		return tables, nil
+	}
+
+	filter, values := pgTableFilter(schema, names)
+	if err := p.columns(tables, index, filter, values); err != nil {
+		return nil, err
+	}
+	if err := p.indexes(tables, index, filter, values); err != nil {
+		return nil, err
+	}
+	if err := p.constraints(tables, index, filter, values); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+func (p *pgSchema) columns(tables []SchemaTable, index map[string]int, filter string, values []any) error {
+	version, err := p.client.ServerVersion()
+	if err != nil {
+		return err
+	}
+	// 10 开始支持 identity 列, 12 开始支持生成列
+	identity, generated := "''", "''"
+	if version >= 100000 {
+		identity = "a.attidentity"
+	}
+	if version >= 120000 {
+		generated = "a.attgenerated"
+	}
+
+	var rows []struct {
+		Table     string  `gorm:"column:table_name"`
+		Name      string  `gorm:"column:column_name"`
+		Position  int     `gorm:"column:position"`
+		Type      string  `gorm:"column:column_type"`
+		Nullable  bool    `gorm:"column:nullable"`
+		Default   *string `gorm:"column:column_default"`
+		Generated string  `gorm:"column:generated"`
+		Identity  string  `gorm:"column:identity"`
+		Serial    bool    `gorm:"column:is_serial"`
+		Collation string  `gorm:"column:collation_name"`
+		Comment   string  `gorm:"column:column_comment"`
+	}
+	err = p.client.Raw(`SELECT c.relname AS table_name, a.attname AS column_name, a.attnum AS position,
+	format_type(a.atttypid, a.atttypmod) AS column_type, NOT a.attnotnull AS nullable,
+	pg_get_expr(d.adbin, d.adrelid) AS column_default, `+generated+`::text AS generated,
+	CASE `+identity+` WHEN 'a' THEN 'ALWAYS' WHEN 'd' THEN 'BY DEFAULT' ELSE '' END AS identity,
+	EXISTS (SELECT 1 FROM pg_depend AS dep JOIN pg_class AS seq ON seq.oid = dep.objid AND seq.relkind = 'S'
+		WHERE dep.classid = 'pg_class'::regclass AND dep.refobjid = a.attrelid AND dep.refobjsubid = a.attnum AND dep.deptype = 'a') AS is_serial,
+	CASE WHEN a.attcollation <> t.typcollation THEN COALESCE(co.collname, '') ELSE '' END AS collation_name,
+	COALESCE(col_description(c.oid, a.attnum), '') AS column_comment
+FROM pg_attribute AS a
+	JOIN pg_class AS c ON c.oid = a.attrelid
+	JOIN pg_namespace AS n ON n.oid = c.relnamespace
+	JOIN pg_type AS t ON t.oid = a.atttypid
+	LEFT JOIN pg_attrdef AS d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+	LEFT JOIN pg_collation AS co ON co.oid = a.attcollation
+WHERE c.relkind IN ('r', 'p', 'v', 'm') AND a.attnum > 0 AND NOT a.attisdropped`+filter+`
+ORDER BY c.relname, a.attnum`, values...).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("查询列失败: %v", err)
+	}
+
+	for _, r := range rows {
+		i, ok := index[r.Table]
+		if !ok {
+			continue
+		}
+		c := SchemaColumn{Name: r.Name, Position: r.Position, Type: r.Type, Nullable: r.Nullable, Default: r.Default,
+			Identity: r.Identity, Collation: r.Collation, Comment: r.Comment}
+		// 生成列的表达式保存在 pg_attrdef 中
+		if r.Generated == "s" && r.Default != nil {
+			c.Generated = fmt.Sprintf("GENERATED ALWAYS AS (%s) STORED", *r.Default)
+			c.Default = nil
+		}
+		// 默认值为自己拥有的序列的 nextval 时还原为 serial, 建表时由 serial 创建序列
+		if serial, ok := pgSerialTypes[c.Type]; ok && r.Serial && c.Default != nil && strings.HasPrefix(*c.Default, "nextval(") {
+			c.Type = serial
+			c.Default = nil
+		}
+		tables[i].Columns = append(tables[i].Columns, c)
+	}
+	return nil
+}
+
+// indexes exclude 约束的索引只在 Constraints 中
+func (p *pgSchema) indexes(tables []SchemaTable, index map[string]int, filter string, values []any) error {
+	var rows []struct {
+		Table      string `gorm:"column:table_name"`
+		Name       string `gorm:"column:index_name"`
+		Unique     bool   `gorm:"column:is_unique"`
+		Primary    bool   `gorm:"column:is_primary"`
+		Constraint bool   `gorm:"column:is_constraint"`
+		Method     string `gorm:"column:method"`
+		Where      string `gorm:"column:predicate"`
+		Definition string `gorm:"column:definition"`
+		Columns    string `gorm:"column:columns"`
+	}
+	err := p.client.Raw(`SELECT c.relname AS table_name, i.relname AS index_name, ix.indisunique AS is_unique, ix.indisprimary AS is_primary,
+	EXISTS (SELECT 1 FROM pg_constraint AS con WHERE con.conrelid = ix.indrelid AND con.conindid = ix.indexrelid AND con.contype IN ('p', 'u')) AS is_constraint,
+	am.amname AS method, COALESCE(pg_get_expr(ix.indpred, ix.indrelid), '') AS predicate,
+	pg_get_indexdef(ix.indexrelid) AS definition,
+	array_to_string(ARRAY(SELECT pg_get_indexdef(ix.indexrelid, k, true) FROM generate_series(1, ix.indnatts) AS k ORDER BY k), chr(31)) AS columns
+FROM pg_index AS ix
+	JOIN pg_class AS i ON i.oid = ix.indexrelid
+	JOIN pg_class AS c ON c.oid = ix.indrelid
+	JOIN pg_namespace AS n ON n.oid = c.relnamespace
+	JOIN pg_am AS am ON am.oid = i.relam
+WHERE NOT EXISTS (SELECT 1 FROM pg_constraint AS con WHERE con.conrelid = ix.indrelid AND con.conindid = ix.indexrelid AND con.contype = 'x')`+filter+`
+ORDER BY c.relname, ix.indisprimary DESC, i.relname`, values...).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("查询索引失败: %v", err)
+	}
+
+	for _, r := range rows {
+		i, ok := index[r.Table]
+		if !ok {
+			continue
+		}
+		tables[i].Indexes = append(tables[i].Indexes, SchemaIndex{
+			Name:       r.Name,
+			Columns:    splitSchemaList(r.Columns),
+			Unique:     r.Unique,
+			Primary:    r.Primary,
+			Constraint: r.Constraint,
+			Method:     r.Method,
+			Where:      r.Where,
+			Definition: r.Definition,
+		})
+	}
+	return nil
+}
+
+const pgConstraintAction = `CASE %s WHEN 'r' THEN 'RESTRICT' WHEN 'c' THEN 'CASCADE' WHEN 'n' THEN 'SET NULL' WHEN 'd' THEN 'SET DEFAULT' WHEN 'a' THEN 'NO ACTION' ELSE '' END`
+
+// constraints 外键, check 和 exclude 约束, 非空约束见列的 Nullable
+func (p *pgSchema) constraints(tables []SchemaTable, index map[string]int, filter string, values []any) error {
+	var rows []struct {
+		Table      string `gorm:"column:table_name"`
+		Name       string `gorm:"column:constraint_name"`
+		Type       string `gorm:"column:constraint_type"`
+		Definition string `gorm:"column:definition"`
+		Columns    string `gorm:"column:columns"`
+		RefSchema  string `gorm:"column:ref_schema"`
+		RefTable   string `gorm:"column:ref_table"`
+		RefColumns string `gorm:"column:ref_columns"`
+		OnUpdate   string `gorm:"column:update_rule"`
+		OnDelete   string `gorm:"column:delete_rule"`
+		MatchFull  bool   `gorm:"column:match_full"`
+		Deferrable bool   `gorm:"column:is_deferrable"`
+		Deferred   bool   `gorm:"column:is_deferred"`
+	}
+	err := p.client.Raw(`SELECT c.relname AS table_name, con.conname AS constraint_name,
+	CASE con.contype WHEN 'f' THEN 'FOREIGN KEY' WHEN 'c' THEN 'CHECK' ELSE 'EXCLUDE' END AS constraint_type,
+	pg_get_constraintdef(con.oid) AS definition,
+	array_to_string(ARRAY(SELECT a.attname FROM unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute AS a ON a.attrelid = con.conrelid AND a.attnum = k.attnum ORDER BY k.ord), chr(31)) AS columns,
+	COALESCE(rn.nspname, '') AS ref_schema, COALESCE(rc.relname, '') AS ref_table,
+	array_to_string(ARRAY(SELECT a.attname FROM unnest(con.confkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute AS a ON a.attrelid = con.confrelid AND a.attnum = k.attnum ORDER BY k.ord), chr(31)) AS ref_columns,
+	`+fmt.Sprintf(pgConstraintAction, "con.confupdtype")+` AS update_rule,
+	`+fmt.Sprintf(pgConstraintAction, "con.confdeltype")+` AS delete_rule,
+	con.confmatchtype = 'f' AS match_full, con.condeferrable AS is_deferrable, con.condeferred AS is_deferred
+FROM pg_constraint AS con
+	JOIN pg_class AS c ON c.oid = con.conrelid
+	JOIN pg_namespace AS n ON n.oid = c.relnamespace
+	LEFT JOIN pg_class AS rc ON rc.oid = con.confrelid
+	LEFT JOIN pg_namespace AS rn ON rn.oid = rc.relnamespace
+WHERE con.contype IN ('f', 'c', 'x')`+filter+`
+ORDER BY c.relname, con.conname`, values...).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("查询约束失败: %v", err)
+	}
+
+	for _, r := range rows {
+		i, ok := index[r.Table]
+		if !ok {
+			continue
+		}
+		c := SchemaConstraint{
+			Name:       r.Name,
+			Type:       r.Type,
+			Columns:    splitSchemaList(r.Columns),
+			RefTable:   r.RefTable,
+			RefColumns: splitSchemaList(r.RefColumns),
+			OnUpdate:   r.OnUpdate,
+			OnDelete:   r.OnDelete,
+			Definition: r.Definition,
+		}
+		// pg_get_constraintdef 中被引用的表是否带 schema 取决于 search_path, 外键的定义自己生成,
+		// 引用同一 schema 中的表时不带 schema, 不同 schema 中相同的外键定义相同
+		if c.Type == "FOREIGN KEY" {
+			if r.RefSchema != tables[i].Schema {
+				c.RefTable = r.RefSchema + "." + r.RefTable
+			}
+			c.Definition = pgDDL{}.foreignKey(c, r.MatchFull, r.Deferrable, r.Deferred)
+		}
+		tables[i].Constraints = append(tables[i].Constraints, c)
+	}
+	return nil
+}
+
+// DDL postgres 没有 show create table, 按表结构生成; 视图使用 pg_get_viewdef
+func (p *pgSchema) DDL(schema, table string) (string, error) {
+	tables, err := p.Describe(schema, table)
+	if err != nil {
+		return "", err
+	}
+	if len(tables) == 0 {
+		return "", fmt.Errorf("表 %s.%s 不存在", schema, table)
+	}
+
+	d := pgDDL{}
+	t := tables[0]
+	if t.Type != SchemaTypeTable {
+		var def string
+		err := p.client.Raw("SELECT pg_get_viewdef(c.oid, true) FROM pg_class AS c JOIN pg_namespace AS n ON n.oid = c.relnamespace WHERE n.nspname = ? AND c.relname = ?",
+			schema, table).Scan(&def).Error
+		if err != nil {
+			return "", fmt.Errorf("查询视图定义失败: %v", err)
+		}
+		return fmt.Sprintf("CREATE %s %s AS\n%s", strings.ToUpper(t.Type), d.table(schema, table), def), nil
+	}
+
+	stmts := d.createTable(schema, t)
+	for _, c := range t.Constraints {
+		stmts = append(stmts, d.addConstraint(schema, t, c))
+	}
+	return strings.Join(stmts, ";\n") + ";", nil
+}
+
+// pgSerialTypes 整数类型对应的 serial 类型
+var pgSerialTypes = map[string]string{"smallint": "smallserial", "integer": "serial", "bigint": "bigserial"}
+
+// pgSerialBase serial 类型对应的整数类型, 不是 serial 时返回原类型
+func pgSerialBase(typ string) (string, bool) {
+	for base, serial := range pgSerialTypes {
+		if typ == serial {
+			return base, true
+		}
+	}
+	return typ, false
+}
+
+// pgDDL 生成 postgres 的 DDL
+type pgDDL struct{}
+
+func (pgDDL) quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (d pgDDL) table(schema, name string) string {
+	return d.quote(schema) + "." + d.quote(name)
+}
+
+func (pgDDL) literal(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (d pgDDL) quoteColumns(columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, c := range columns {
+		quoted = append(quoted, d.quote(c))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// foreignKey 与 pg_get_constraintdef 的格式一致, 默认的 NO ACTION 不输出
+func (d pgDDL) foreignKey(c SchemaConstraint, matchFull, deferrable, deferred bool) string {
+	refTable := d.quote(c.RefTable)
+	if schema, table, ok := strings.Cut(c.RefTable, "."); ok {
+		refTable = d.table(schema, table)
+	}
+	def := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s)", d.quoteColumns(c.Columns), refTable, d.quoteColumns(c.RefColumns))
+	if matchFull {
+		def += " MATCH FULL"
+	}
+	if c.OnUpdate != "" && c.OnUpdate != "NO ACTION" {
+		def += " ON UPDATE " + c.OnUpdate
+	}
+	if c.OnDelete != "" && c.OnDelete != "NO ACTION" {
+		def += " ON DELETE " + c.OnDelete
+	}
+	if deferrable {
+		def += " DEFERRABLE"
+		if deferred {
+			def += " INITIALLY DEFERRED"
+		}
+	}
+	return def
+}
+
+func (d pgDDL) columnDef(c SchemaColumn) string {
+	def := d.quote(c.Name) + " " + c.Type
+	if c.Collation != "" {
+		def += " COLLATE " + d.quote(c.Collation)
+	}
+	if c.Generated != "" {
+		def += " " + c.Generated
+	}
+	if c.Identity != "" {
+		def += " GENERATED " + c.Identity + " AS IDENTITY"
+	}
+	if !c.Nullable {
+		def += " NOT NULL"
+	}
+	if c.Default != nil {
+		def += " DEFAULT " + *c.Default
+	}
+	return def
+}
+
+func (d pgDDL) commentColumn(schema string, t SchemaTable, c SchemaColumn) string {
+	comment := "NULL"
+	if c.Comment != "" {
+		comment = d.literal(c.Comment)
+	}
+	return fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", d.table(schema, t.Name), d.quote(c.Name), comment)
+}
+
+func (d pgDDL) commentTable(schema string, t SchemaTable) string {
+	comment := "NULL"
+	if t.Comment != "" {
+		comment = d.literal(t.Comment)
+	}
+	return fmt.Sprintf("COMMENT ON TABLE %s IS %s", d.table(schema, t.Name), comment)
+}
+
+// indexBody pg_get_indexdef 中 USING 之后的部分: 索引方法, 列, INCLUDE 和 WHERE
+func (pgDDL) indexBody(idx SchemaIndex) string {
+	if _, body, ok := strings.Cut(idx.Definition, " USING "); ok {
+		return body
+	}
+	return fmt.Sprintf("%s (%s)", idx.Method, strings.Join(idx.Columns, ", "))
+}
+
+// createTable 建表语句包括主键和唯一约束, 不包括外键等其他约束, 其他约束在所有表创建之后添加
+func (d pgDDL) createTable(schema string, t SchemaTable) []string {
+	var lines []string
+	for _, c := range t.Columns {
+		lines = append(lines, "    "+d.columnDef(c))
+	}
+	for _, idx := range t.Indexes {
+		if idx.Constraint {
+			lines = append(lines, "    "+d.constraintIndexDef(idx))
+		}
+	}
+	stmts := []string{fmt.Sprintf("CREATE TABLE %s (\n%s\n)", d.table(schema, t.Name), strings.Join(lines, ",\n"))}
+
+	for _, idx := range t.Indexes {
+		if !idx.Constraint {
+			stmts = append(stmts, d.addIndex(schema, t, idx))
+		}
+	}
+	if t.Comment != "" {
+		stmts = append(stmts, d.commentTable(schema, t))
+	}
+	for _, c := range t.Columns {
+		if c.Comment != "" {
+			stmts = append(stmts, d.commentColumn(schema, t, c))
+		}
+	}
+	return stmts
+}
+
+func (d pgDDL) constraintIndexDef(idx SchemaIndex) string {
+	kind := "UNIQUE"
+	if idx.Primary {
+		kind = "PRIMARY KEY"
+	}
+	return fmt.Sprintf("CONSTRAINT %s %s (%s)", d.quote(idx.Name), kind, d.quoteColumns(idx.Columns))
+}
+
+func (d pgDDL) dropTable(schema string, t SchemaTable) string {
+	return "DROP TABLE " + d.table(schema, t.Name)
+}
+
+// addColumn postgres 不能指定新列的位置, 新列在最后
+func (d pgDDL) addColumn(schema string, t SchemaTable, c SchemaColumn, after string) []string {
+	stmts := []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", d.table(schema, t.Name), d.columnDef(c))}
+	if c.Comment != "" {
+		stmts = append(stmts, d.commentColumn(schema, t, c))
+	}
+	return stmts
+}
+
+// alterColumn 生成列的表达式不能修改, 需要删除后重新添加; 修改类型时可能截断数据或者转换失败, 标记为 destructive
+func (d pgDDL) alterColumn(schema string, t SchemaTable, want, have SchemaColumn) []SchemaStatement {
+	table := d.table(schema, t.Name)
+	prefix := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", table, d.quote(want.Name))
+	var stmts []SchemaStatement
+	add := func(sql string, destructive bool) {
+		stmts = append(stmts, SchemaStatement{Table: t.Name, SQL: sql, Destructive: destructive})
+	}
+
+	if want.Generated != have.Generated {
+		add(d.dropColumn(schema, t, have), true)
+		for _, sql := range d.addColumn(schema, t, want, "") {
+			add(sql, false)
+		}
+		return stmts
+	}
+
+	// serial 不是真正的类型, 修改类型时使用对应的整数类型, 序列和默认值单独处理
+	wantType, wantSerial := pgSerialBase(want.Type)
+	haveType, haveSerial := pgSerialBase(have.Type)
+	if wantType != haveType || want.Collation != have.Collation {
+		sql := prefix + "TYPE " + wantType
+		if want.Collation != "" {
+			sql += " COLLATE " + d.quote(want.Collation)
+		}
+		if wantType != haveType {
+			sql += fmt.Sprintf(" USING %s::%s", d.quote(want.Name), wantType)
+		}
+		add(sql, wantType != haveType)
+	}
+	if wantSerial && !haveSerial {
+		seq := d.table(schema, t.Name+"_"+want.Name+"_seq")
+		add(fmt.Sprintf("CREATE SEQUENCE %s OWNED BY %s.%s", seq, table, d.quote(want.Name)), false)
+		add(fmt.Sprintf("SELECT setval(%s, COALESCE(max(%s), 0) + 1, false) FROM %s", d.literal(seq), d.quote(want.Name), table), false)
+		add(prefix+fmt.Sprintf("SET DEFAULT nextval(%s::regclass)", d.literal(seq)), false)
+	} else if haveSerial && !wantSerial && want.Default == nil {
+		add(prefix+"DROP DEFAULT", false)
+	}
+	if want.Identity != have.Identity {
+		switch {
+		case have.Identity == "":
+			add(prefix+"ADD GENERATED "+want.Identity+" AS IDENTITY", false)
+		case want.Identity == "":
+			add(prefix+"DROP IDENTITY", false)
+		default:
+			add(prefix+"SET GENERATED "+want.Identity, false)
+		}
+	}
+	if want.Nullable != have.Nullable {
+		if want.Nullable {
+			add(prefix+"DROP NOT NULL", false)
+		} else {
+			add(prefix+"SET NOT NULL", false)
+		}
+	}
+	if !wantSerial && !equalDefault(want.Default, have.Default) {
+		if want.Default == nil {
+			add(prefix+"DROP DEFAULT", false)
+		} else {
+			add(prefix+"SET DEFAULT "+*want.Default, false)
+		}
+	}
+	if want.Comment != have.Comment {
+		add(d.commentColumn(schema, t, want), false)
+	}
+	return stmts
+}
+
+func (d pgDDL) dropColumn(schema string, t SchemaTable, c SchemaColumn) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", d.table(schema, t.Name), d.quote(c.Name))
+}
+
+func (d pgDDL) indexKey(idx SchemaIndex) string {
+	if idx.Constraint {
+		return fmt.Sprintf("constraint|%v|%v", idx.Primary, idx.Columns)
+	}
+	return fmt.Sprintf("%v|%s", idx.Unique, d.indexBody(idx))
+}
+
+func (d pgDDL) addIndex(schema string, t SchemaTable, idx SchemaIndex) string {
+	if idx.Constraint {
+		return fmt.Sprintf("ALTER TABLE %s ADD %s", d.table(schema, t.Name), d.constraintIndexDef(idx))
+	}
+	unique := ""
+	if idx.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s USING %s", unique, d.quote(idx.Name), d.table(schema, t.Name), d.indexBody(idx))
+}
+
+func (d pgDDL) dropIndex(schema string, t SchemaTable, idx SchemaIndex) string {
+	if idx.Constraint {
+		return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", d.table(schema, t.Name), d.quote(idx.Name))
+	}
+	return "DROP INDEX " + d.table(schema, idx.Name)
+}
+
+func (d pgDDL) addConstraint(schema string, t SchemaTable, c SchemaConstraint) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", d.table(schema, t.Name), d.quote(c.Name), c.Definition)
+}
+
+func (d pgDDL) dropConstraint(schema string, t SchemaTable, c SchemaConstraint) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", d.table(schema, t.Name), d.quote(c.Name))
+}
+
+func (d pgDDL) tableOptions(schema string, want, have SchemaTable) (diffs []string, stmts []string) {
+	if want.Comment != have.Comment {
+		diffs = append(diffs, "comment")
+		stmts = append(stmts, d.commentTable(schema, want))
+	}
+	return diffs, stmts
+}
@@ -0,0 +1,108 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-10 10:05:32
+ */
+
+package db
+
+// 表的类型
+const (
+	SchemaTypeTable            = "table"
+	SchemaTypeView             = "view"
+	SchemaTypeMaterializedView = "materialized view"
+)
+
+// SchemaTableSummary 表列表中的一个表或视图, 行数和大小为统计信息中的估算值
+type SchemaTableSummary struct {
+	Schema     string `json:"schema" gorm:"column:table_schema"`
+	Name       string `json:"name" gorm:"column:table_name"`
+	Type       string `json:"type" gorm:"column:table_type"` // table, view, materialized view
+	Engine     string `json:"engine" gorm:"column:engine"`   // 只有 mysql 有
+	Rows       int64  `json:"rows" gorm:"column:table_rows"`
+	DataBytes  int64  `json:"data_bytes" gorm:"column:data_bytes"`
+	IndexBytes int64  `json:"index_bytes" gorm:"column:index_bytes"`
+	Comment    string `json:"comment" gorm:"column:table_comment"`
+}
+
+// SchemaTable 表结构. mysql 的 schema 即库名, postgres 为当前连接的库中的 schema
+type SchemaTable struct {
+	Schema      string             `json:"schema"`
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	Engine      string             `json:"engine"`    // 只有 mysql 有
+	Collation   string             `json:"collation"` // 只有 mysql 有
+	Comment     string             `json:"comment"`
+	Columns     []SchemaColumn     `json:"columns"`
+	Indexes     []SchemaIndex      `json:"indexes"`     // 包括主键和唯一约束对应的索引
+	Constraints []SchemaConstraint `json:"constraints"` // 外键, check 和 exclude 约束, 主键和唯一约束见 Indexes
+	DDL         string             `json:"ddl,omitempty"`
+}
+
+// SchemaColumn 表中的列
+type SchemaColumn struct {
+	Name      string  `json:"name"`
+	Position  int     `json:"position"`
+	Type      string  `json:"type"` // 完整的类型, 如 varchar(64), bigint unsigned, character varying(64); postgres 使用自己的序列自增的列为 serial, bigserial
+	Nullable  bool    `json:"nullable"`
+	Default   *string `json:"default"`   // 默认值的表达式, 没有默认值时为 null; mysql 为 information_schema 中的原始值
+	Extra     string  `json:"extra"`     // mysql: auto_increment, on update CURRENT_TIMESTAMP 等
+	Generated string  `json:"generated"` // 生成列的表达式, mysql 的 EXTRA 中有 VIRTUAL/STORED
+	Identity  string  `json:"identity"`  // postgres 的 identity 列: ALWAYS, BY DEFAULT
+	Collation string  `json:"collation"` // 和表(postgres 为类型)默认值不同时才有
+	Comment   string  `json:"comment"`
+}
+
+// SchemaIndex 表中的索引
+type SchemaIndex struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"` // 列名或表达式, mysql 前缀索引为 name(length)
+	Unique     bool     `json:"unique"`
+	Primary    bool     `json:"primary"`
+	Constraint bool     `json:"constraint"` // postgres 中是否为主键或唯一约束创建的索引, 需要通过约束修改
+	Method     string   `json:"method"`     // BTREE, HASH, FULLTEXT, btree, gin 等
+	Where      string   `json:"where"`      // postgres 部分索引的条件
+	Definition string   `json:"definition"` // postgres 的 pg_get_indexdef
+}
+
+// SchemaConstraint 外键, check 和 exclude 约束
+type SchemaConstraint struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"` // FOREIGN KEY, CHECK, EXCLUDE
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns"`
+	OnUpdate   string   `json:"on_update"`
+	OnDelete   string   `json:"on_delete"`
+	Definition string   `json:"definition"` // 约束的定义, 如 FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+}
+
+// SchemaDiff source 和 target 的结构差异, Statements 在 target 上执行后和 source 一致
+type SchemaDiff struct {
+	Dialect       string            `json:"dialect"`
+	MissingTables []string          `json:"missing_tables"` // source 有 target 没有的表
+	ExtraTables   []string          `json:"extra_tables"`   // target 有 source 没有的表
+	ChangedTables []SchemaTableDiff `json:"changed_tables"`
+	Statements    []SchemaStatement `json:"statements"`
+}
+
+// SchemaTableDiff 两边都有的表的差异, 每项为对象名, 没有差异的项不返回
+type SchemaTableDiff struct {
+	Table              string   `json:"table"`
+	MissingColumns     []string `json:"missing_columns,omitempty"`
+	ExtraColumns       []string `json:"extra_columns,omitempty"`
+	ChangedColumns     []string `json:"changed_columns,omitempty"`
+	MissingIndexes     []string `json:"missing_indexes,omitempty"`
+	ExtraIndexes       []string `json:"extra_indexes,omitempty"`
+	ChangedIndexes     []string `json:"changed_indexes,omitempty"`
+	MissingConstraints []string `json:"missing_constraints,omitempty"`
+	ExtraConstraints   []string `json:"extra_constraints,omitempty"`
+	ChangedConstraints []string `json:"changed_constraints,omitempty"`
+	Options            []string `json:"options,omitempty"` // 表选项的差异, 如 engine, comment
+}
+
+// SchemaStatement 同步结构的语句, 删除表或列, 修改列的类型可能丢失数据或者执行失败, 执行前需要确认
+type SchemaStatement struct {
+	Table       string `json:"table"`
+	SQL         string `json:"sql"`
+	Destructive bool   `json:"destructive"`
+}
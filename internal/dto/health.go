@@ -0,0 +1,14 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-09 10:12:27
+ */
+
+package dto
+
+// 健康检查和诊断结果的状态, 以及问题的严重程度
+const (
+	HealthOK         = "ok"
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
@@ -0,0 +1,219 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-30 14:08:22
+ */
+
+package dto
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/task"
+	"time"
+)
+
+type MongoInstanceReq struct {
+	Instance string `json:"instance" binding:"required"`
+}
+
+type MongoRSAddReq struct {
+	Instance           string   `json:"instance" binding:"required"`
+	Host               string   `json:"host" binding:"required"` // host:port
+	Arbiter            bool     `json:"arbiter"`                 // 为 true 时添加仲裁节点, 忽略其他属性
+	Priority           *float64 `json:"priority"`                // 为空时默认 1, 隐藏或延迟节点默认 0
+	Votes              *int     `json:"votes"`                   // 为空时默认 1
+	Hidden             bool     `json:"hidden"`
+	SecondaryDelaySecs int64    `json:"secondaryDelaySecs"`
+	AllowEvenVoters    bool     `json:"allowEvenVoters"` // 允许变更后投票成员数为偶数
+}
+
+type MongoRSRemoveReq struct {
+	Instance        string `json:"instance" binding:"required"`
+	Host            string `json:"host" binding:"required"`
+	AllowEvenVoters bool   `json:"allowEvenVoters"`
+}
+
+type MongoRSMemberUpdateReq struct {
+	Instance           string   `json:"instance" binding:"required"`
+	Host               string   `json:"host" binding:"required"`
+	Priority           *float64 `json:"priority"`
+	Votes              *int     `json:"votes" binding:"omitempty,oneof=0 1"`
+	Hidden             *bool    `json:"hidden"`
+	SecondaryDelaySecs *int64   `json:"secondaryDelaySecs"`
+	AllowEvenVoters    bool     `json:"allowEvenVoters"`
+}
+
+type MongoRSStepDownReq struct {
+	Instance     string `json:"instance" binding:"required"`
+	StepDownSecs int    `json:"stepDownSecs"` // 为空时默认 60 秒
+	CatchUpSecs  int    `json:"catchUpSecs"`  // 为空时默认 10 秒
+}
+
+type MongoReplSetHealthReq struct {
+	Instance      string  `json:"instance" binding:"required"`
+	MaxLagSeconds float64 `json:"maxLagSeconds"` // 从节点复制延迟超过该值时告警, 为空时默认 10 秒
+}
+
+// MongoReplSetHealth 副本集健康检查结果
+type MongoReplSetHealth struct {
+	Instance string                    `json:"instance"`
+	Set      string                    `json:"set"`
+	Status   string                    `json:"status"` // ok, warning, critical, 取所有问题中最严重的
+	Primary  string                    `json:"primary"`
+	Issues   []MongoReplSetHealthIssue `json:"issues"`
+	Error    string                    `json:"error"` // 获取副本集状态失败时的错误信息
+}
+
+type MongoReplSetHealthIssue struct {
+	Severity string  `json:"severity"`
+	Member   string  `json:"member"`
+	Message  string  `json:"message"`
+	Lag      float64 `json:"lag,omitempty"`
+}
+
+type MongoReplSetHealthAllReq struct {
+	MaxLagSeconds float64 `json:"maxLagSeconds"`
+}
+
+type MongoInconsistentIndexReq struct {
+	Instance    string `json:"instance" binding:"required"` // 必须是 mongos
+	Database    string `json:"database"`                    // 为空时检查所有库
+	Concurrency int    `json:"concurrency"`                 // 并发检查的集合数, 为空时默认 4, 最大 16
+	Fix         bool   `json:"fix"`                         // 是否生成修复命令
+}
+
+type MongoCurrentOpReq struct {
+	Instance string `json:"instance" binding:"required"`
+	db.CurrentOpFilter
+}
+
+type MongoKillOpReq struct {
+	Instance string `json:"instance" binding:"required"`
+	OpID     any    `json:"opid" binding:"required"` // mongod 为数字, mongos 为 "分片名:opid"
+}
+
+type MongoKillMatchingReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Confirm  bool   `json:"confirm"` // 为 true 时执行 kill, 默认只返回将要 kill 的操作
+	db.CurrentOpFilter
+}
+
+type MongoProfileReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Database string `json:"database" binding:"required"`
+	Slowms   int64  `json:"slowms"` // 慢查询阈值, 单位: 毫秒, 为空时不修改. 注意 slowms 对实例上所有库生效
+}
+
+type MongoProfileStatusReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Database string `json:"database"` // 为空时返回所有库
+}
+
+// MongoProfileStatus 库的 profiler 设置
+type MongoProfileStatus struct {
+	Database string `json:"database"`
+	db.ProfileStatus
+}
+
+type MongoSlowQueryReq struct {
+	Instance  string `json:"instance"`  // 为空时返回所有实例
+	Namespace string `json:"namespace"` // 库名或 库名.集合名, 为空时返回所有
+	SortBy    string `json:"sortBy"`    // count, avg, max, total, ratio, 默认 total
+	Limit     int    `json:"limit"`     // 默认 100
+}
+
+// MongoSlowQuery 按 实例, namespace, 查询模式 聚合的慢查询统计
+type MongoSlowQuery struct {
+	Instance          string    `json:"instance"`
+	Namespace         string    `json:"namespace"`
+	Op                string    `json:"op"`
+	Shape             string    `json:"shape"` // 归一化后的查询模式, 值替换为 "?"
+	Count             int64     `json:"count"`
+	TotalMillis       int64     `json:"totalMillis"`
+	AvgMillis         float64   `json:"avgMillis"`
+	MaxMillis         int64     `json:"maxMillis"`
+	DocsExamined      int64     `json:"docsExamined"`
+	NReturned         int64     `json:"nreturned"`
+	DocsExaminedRatio float64   `json:"docsExaminedRatio"` // 扫描文档数 / 返回文档数, 比值越大越可能缺少索引
+	PlanSummary       string    `json:"planSummary"`       // 最近一次的执行计划
+	FirstSeen         time.Time `json:"firstSeen"`
+	LastSeen          time.Time `json:"lastSeen"`
+}
+
+// MongoProfileHarvest 一次采集的结果
+type MongoProfileHarvest struct {
+	Instance string `json:"instance"`
+	Database string `json:"database"`
+	Entries  int    `json:"entries"`
+	Error    string `json:"error,omitempty"`
+}
+
+type MongoProvisionCollection struct {
+	Name     string   `json:"name" binding:"required"`
+	ShardKey []string `json:"shardKey"` // 片键字段, 为空时不分片, 只在 mongos 上有效
+	KeyType  string   `json:"keyType"`  // hashed 或 ranged, 默认 hashed
+	Unique   bool     `json:"unique"`
+}
+
+// MongoProvisionReq 为业务创建库, 每个集合一个最小权限的角色, 以及拥有这些角色的业务用户
+type MongoProvisionReq struct {
+	Instance    string                     `json:"instance" binding:"required"`
+	Database    string                     `json:"database" binding:"required"`
+	Username    string                     `json:"username" binding:"required"`
+	Collections []MongoProvisionCollection `json:"collections" binding:"required,min=1,dive"`
+}
+
+type MongoProvisionResp struct {
+	Task     task.TaskInfo `json:"task"`
+	Username string        `json:"username"`
+	Password string        `json:"password"` // 自动生成的密码, 只在创建新用户时返回一次; 用户已存在时为空, 不修改原密码
+}
+
+type MongoParamGetReq struct {
+	Instance string   `json:"instance" binding:"required"`
+	Names    []string `json:"names"` // 为空时获取参数目录中的所有参数
+}
+
+type MongoParamSetReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Value    any    `json:"value" binding:"required"`
+	DryRun   bool   `json:"dryRun"` // 只校验并返回当前值, 不实际修改
+}
+
+type MongoParamSetResp struct {
+	Name    string `json:"name"`
+	Old     any    `json:"old"`
+	New     any    `json:"new"`
+	Changed bool   `json:"changed"`
+	DryRun  bool   `json:"dryRun"`
+}
+
+type MongoParamDiffReport struct {
+	Instance string              `json:"instance"`
+	Env      string              `json:"env"`
+	Diffs    []db.MongoParamDiff `json:"diffs"`
+	Error    string              `json:"error"` // 获取实例参数失败时的错误信息
+}
+
+type MongoChunkReq struct {
+	Instance  string `json:"instance" binding:"required"` // 必须是 mongos
+	Namespace string `json:"namespace"`                   // 库名.集合名, 为空时返回所有分片集合的分布, 不返回 jumbo chunk
+}
+
+type MongoChunkResp struct {
+	Distributions []db.ChunkDistribution `json:"distributions"`
+	JumboChunks   []db.Chunk             `json:"jumboChunks"`
+}
+
+type MongoBalancerWindowReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Start    string `json:"start"` // HH:MM, 开始和结束都为空时删除时间窗口
+	Stop     string `json:"stop"`
+}
+
+type MongoCollectionStatsReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Database string `json:"database"` // 为空时统计所有非系统库
+	Page     int    `json:"page"`     // 从 1 开始, 默认 1
+	PageSize int    `json:"pageSize"` // 默认 20, 最大 100
+}
@@ -0,0 +1,88 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-29 18:20:05
+ */
+
+package dto
+
+import "myadmin/internal/db"
+
+type MysqlInstanceReq struct {
+	Instance string `json:"instance" binding:"required"`
+}
+
+type MysqlProcesslistReq struct {
+	Instance string `json:"instance" binding:"required"`
+	db.MysqlProcessFilter
+}
+
+type MysqlVariablesReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Like     string `json:"like"` // show ... like 的模式, 如 innodb%, 为空时返回全部
+}
+
+type MysqlStatusRatesReq struct {
+	Instance    string `json:"instance" binding:"required"`
+	IntervalSec int    `json:"interval_sec"` // 两次采集的间隔, 默认 1 秒, 最大 10 秒
+	Prefix      string `json:"prefix"`       // 只返回该前缀的状态, 如 com_
+}
+
+type MysqlKillReq struct {
+	Instance string `json:"instance" binding:"required"`
+	ID       int64  `json:"id" binding:"required"` // processlist 中的 id
+	Query    bool   `json:"query"`                 // true: kill query 只终止正在执行的语句; false: kill connection 断开连接
+}
+
+type MysqlKillMatchingReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Query    bool   `json:"query"`   // true: kill query; false: kill connection
+	Confirm  bool   `json:"confirm"` // 为 true 时执行 kill, 默认只返回将要 kill 的连接
+	db.MysqlProcessFilter
+}
+
+type MysqlReplTopologyReq struct {
+	Instance      string `json:"instance" binding:"required"` // 从该实例开始发现上游和下游
+	MaxLagSeconds int64  `json:"max_lag_seconds"`             // 复制延迟(扣除延迟复制的配置)超过该值时告警, 为空时默认 30 秒
+	MaxNodes      int    `json:"max_nodes"`                   // 最多发现的节点数, 为空时默认 32, 最大 128
+}
+
+// MysqlReplTopology 复制拓扑, 自动发现的节点是配置中的实例或在 repl_probe_hosts 中时才使用起始实例的连接参数连接
+type MysqlReplTopology struct {
+	Instance  string               `json:"instance"`
+	Nodes     []db.MysqlReplNode   `json:"nodes"`
+	Tree      []*MysqlReplTreeNode `json:"tree"`      // 没有上游的节点为根, 环形复制时选择可写的节点为根
+	Truncated bool                 `json:"truncated"` // 节点数超过 max_nodes, 没有发现全部节点
+	Health    MysqlReplHealth      `json:"health"`
+}
+
+// MysqlReplTreeNode 复制树中的节点, 复制状态为到树中上游的复制通道的状态
+type MysqlReplTreeNode struct {
+	Addr         string               `json:"addr"`
+	ServerID     int64                `json:"server_id"`
+	ServerUUID   string               `json:"server_uuid"`
+	Version      string               `json:"version"`
+	ReadOnly     bool                 `json:"read_only"`
+	Error        string               `json:"error"`
+	Channel      string               `json:"channel"`
+	IOThread     string               `json:"io_thread"`
+	SQLThread    string               `json:"sql_thread"`
+	LagSeconds   *int64               `json:"lag_seconds"`   // Seconds_Behind_Source
+	GTIDBehind   int64                `json:"gtid_behind"`   // 上游已经执行而本节点没有执行的事务数, 两个节点不是同时读取的, 只是估算
+	OtherSources []string             `json:"other_sources"` // 多源复制或环形复制时的其他上游
+	Cycle        bool                 `json:"cycle"`         // 环形复制, 本节点的上游也在树中
+	Children     []*MysqlReplTreeNode `json:"children"`
+}
+
+// MysqlReplHealth 复制健康检查结果
+type MysqlReplHealth struct {
+	Status string                 `json:"status"` // ok, warning, critical, 取所有问题中最严重的
+	Issues []MysqlReplHealthIssue `json:"issues"`
+}
+
+type MysqlReplHealthIssue struct {
+	Severity string `json:"severity"`
+	Node     string `json:"node"`
+	Channel  string `json:"channel"`
+	Message  string `json:"message"`
+	Lag      int64  `json:"lag,omitempty"`
+}
@@ -0,0 +1,67 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-06 14:12:09
+ */
+
+package dto
+
+import "myadmin/internal/db"
+
+type PgSQLInstanceReq struct {
+	Instance string `json:"instance" binding:"required"`
+}
+
+type PgSQLActivityReq struct {
+	Instance string `json:"instance" binding:"required"`
+	db.PgActivityFilter
+}
+
+type PgSQLLocksReq struct {
+	Instance    string `json:"instance" binding:"required"`
+	PID         int64  `json:"pid"`          // 只返回该进程的锁, 为空时返回全部
+	WaitingOnly bool   `json:"waiting_only"` // 只返回等待中(未获得)的锁
+}
+
+type PgSQLLongRunningReq struct {
+	Instance string  `json:"instance" binding:"required"`
+	QuerySec float64 `json:"query_sec"` // 语句执行超过该秒数, 默认 60
+	XactSec  float64 `json:"xact_sec"`  // 事务执行超过该秒数, 默认 300
+	IdleSec  float64 `json:"idle_sec"`  // idle in transaction 超过该秒数, 默认 60
+}
+
+type PgSQLCancelReq struct {
+	Instance  string `json:"instance" binding:"required"`
+	PID       int64  `json:"pid" binding:"required"`
+	Terminate bool   `json:"terminate"` // true: pg_terminate_backend 断开连接; false: pg_cancel_backend 只取消正在执行的语句
+}
+
+type PgSQLHealthReq struct {
+	Instance         string  `json:"instance" binding:"required"`
+	MaxLagSeconds    float64 `json:"max_lag_seconds"`     // 下游回放延迟超过该值时告警, 为空时默认 30 秒
+	MaxRetainedWALMB int64   `json:"max_retained_wal_mb"` // 复制槽保留的 wal 超过该值时告警, 为空时默认 1024MB
+	BloatRatio       float64 `json:"bloat_ratio"`         // 膨胀比例超过该值时告警, 为空时默认 0.5
+	MinBloatMB       int64   `json:"min_bloat_mb"`        // 小于该大小的表和索引不告警, 为空时默认 100MB
+	Limit            int     `json:"limit"`               // 返回膨胀最多和事务 id 年龄最大的表的数量, 为空时默认 20, 最大 100
+}
+
+// PgSQLHealth postgres 健康检查结果, 膨胀和表的事务 id 年龄只检查配置中连接的库
+type PgSQLHealth struct {
+	Instance    string                 `json:"instance"`
+	Status      string                 `json:"status"` // ok, warning, critical, 取所有问题中最严重的
+	Recovery    db.PgRecovery          `json:"recovery"`
+	Replication []db.PgReplication     `json:"replication"`
+	Slots       []db.PgReplicationSlot `json:"slots"`
+	TableBloat  []db.PgBloat           `json:"table_bloat"`
+	IndexBloat  []db.PgBloat           `json:"index_bloat"`
+	Vacuum      []db.PgVacuumProgress  `json:"vacuum"`
+	Wraparound  db.PgWraparound        `json:"wraparound"`
+	Issues      []PgSQLHealthIssue     `json:"issues"`
+	Errors      map[string]string      `json:"errors"` // 查询失败的检查项: 检查项 -> 错误信息, 不影响其他检查项
+}
+
+type PgSQLHealthIssue struct {
+	Severity string `json:"severity"`
+	Category string `json:"category"` // recovery, replication, slot, bloat, vacuum, wraparound
+	Object   string `json:"object"`   // 下游, 复制槽, 库, 表或索引
+	Message  string `json:"message"`
+}
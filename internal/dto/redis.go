@@ -0,0 +1,159 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 15:30:18
+ */
+
+package dto
+
+import (
+	"fmt"
+	"myadmin/internal/db"
+	"slices"
+	"time"
+)
+
+type RedisKeyScanReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Cursor   uint64 `json:"cursor"`
+	Pattern  string `json:"pattern"`
+	Type     string `json:"type"` // 为空时不过滤类型
+	Count    int64  `json:"count"`
+}
+
+func (r *RedisKeyScanReq) Validator() error {
+	if r.Type != "" && !slices.Contains(db.RedisKeyTypes, r.Type) {
+		return fmt.Errorf("不支持的 key 类型: %s", r.Type)
+	}
+	return nil
+}
+
+type RedisKeyReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Key      string `json:"key" binding:"required"`
+}
+
+type RedisKeyValueReq struct {
+	Instance    string `json:"instance" binding:"required"`
+	Key         string `json:"key" binding:"required"`
+	Cursor      uint64 `json:"cursor"`       // hash, set 翻页使用
+	Offset      int64  `json:"offset"`       // string, list, zset 翻页使用
+	Count       int64  `json:"count"`        // 每页条数, string 类型为字节数
+	Match       string `json:"match"`        // hash, set 的 field/member 过滤
+	StreamStart string `json:"stream_start"` // stream 翻页使用
+}
+
+type RedisKeyExpireReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Key      string `json:"key" binding:"required"`
+	TTL      int64  `json:"ttl"` // 单位: 秒, 小于等于 0 表示移除过期时间
+}
+
+type RedisKeyDeleteReq struct {
+	Instance string   `json:"instance" binding:"required"`
+	Keys     []string `json:"keys" binding:"required,min=1,max=1000"`
+}
+
+type RedisKeyDeleteResp struct {
+	Deleted int64 `json:"deleted"`
+}
+
+type RedisKeyRenameReq struct {
+	Instance  string `json:"instance" binding:"required"`
+	Key       string `json:"key" binding:"required"`
+	NewKey    string `json:"new_key" binding:"required"`
+	Overwrite bool   `json:"overwrite"` // 目标 key 已存在时是否覆盖
+}
+
+type RedisInstanceReq struct {
+	Instance string `json:"instance" binding:"required"`
+}
+
+type RedisClusterMeetReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Host     string `json:"host" binding:"required"`
+	Port     int    `json:"port" binding:"required,min=1,max=65535"`
+}
+
+type RedisClusterAddSlotsReq struct {
+	Instance string `json:"instance" binding:"required"`
+	NodeID   string `json:"node_id" binding:"required"`
+	Slots    string `json:"slots" binding:"required"` // 格式: 0-100,200,300-400
+}
+
+type RedisClusterRebalanceReq struct {
+	Instance  string  `json:"instance" binding:"required"`
+	Threshold float64 `json:"threshold"` // 允许的不均衡百分比, 默认 2
+	Batch     int     `json:"batch"`     // 每次 migrate 的 key 数量
+}
+
+type RedisClusterMigrateReq struct {
+	Instance string `json:"instance" binding:"required"`
+	SourceID string `json:"source_id" binding:"required"`
+	TargetID string `json:"target_id" binding:"required"`
+	Slots    string `json:"slots" binding:"required"` // 格式: 0-100,200,300-400
+	Batch    int    `json:"batch"`                    // 每次 migrate 的 key 数量
+}
+
+type RedisClusterFailoverReq struct {
+	Instance string `json:"instance" binding:"required"`
+	NodeID   string `json:"node_id" binding:"required"`                    // 要提升为主节点的从节点ID
+	Mode     string `json:"mode" binding:"omitempty,oneof=force takeover"` // 为空表示正常切换
+}
+
+type RedisClusterForgetReq struct {
+	Instance string `json:"instance" binding:"required"`
+	NodeID   string `json:"node_id" binding:"required"`
+}
+
+type RedisConfigDiffReport struct {
+	Instance string               `json:"instance"`
+	Env      string               `json:"env"`
+	Diffs    []db.RedisConfigDiff `json:"diffs"`
+	Error    string               `json:"error"` // 获取实例参数失败时的错误信息, cluster 模式下部分节点失败时其他节点的结果仍然返回
+}
+
+type RedisConfigSetReq struct {
+	Instance string            `json:"instance" binding:"required"`
+	Params   map[string]string `json:"params" binding:"required,min=1"`
+	Rewrite  bool              `json:"rewrite"` // 修改后执行 config rewrite 写回配置文件
+	Apply    bool              `json:"apply"`   // 为 true 时执行修改, 默认只返回将要修改的内容
+}
+
+type RedisConfigChange struct {
+	Param   string `json:"param"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+	Changed bool   `json:"changed"` // 新值与当前值不同
+}
+
+type RedisConfigSetResp struct {
+	DryRun  bool                `json:"dry_run"`
+	Changes []RedisConfigChange `json:"changes"`
+}
+
+// RedisDiagnoseFinding 诊断发现的一个问题
+type RedisDiagnoseFinding struct {
+	Rule      string  `json:"rule"`
+	Severity  string  `json:"severity"`
+	Node      string  `json:"node"` // cluster 模式下为节点地址, 其他模式为空
+	Message   string  `json:"message"`
+	Value     float64 `json:"value"`     // 触发规则的实际值
+	Threshold float64 `json:"threshold"` // 规则阈值
+}
+
+type RedisDiagnoseReport struct {
+	Instance  string                 `json:"instance"`
+	Mode      string                 `json:"mode"`
+	Findings  []RedisDiagnoseFinding `json:"findings"` // 按严重程度从高到低排序
+	Error     string                 `json:"error"`    // 获取实例信息失败时的错误信息
+	CheckedAt time.Time              `json:"checked_at"`
+}
+
+// RedisDiagnoseRule 生效的诊断规则, 已合并配置文件中的参数
+type RedisDiagnoseRule struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Disabled    bool    `json:"disabled"`
+	Severity    string  `json:"severity"`
+	Threshold   float64 `json:"threshold"`
+}
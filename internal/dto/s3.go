@@ -0,0 +1,204 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-23 10:12:36
+ */
+
+package dto
+
+import (
+	"myadmin/internal/db"
+	"time"
+)
+
+type S3InstanceReq struct {
+	Instance string `json:"instance" binding:"required"`
+}
+
+type S3ObjectListReq struct {
+	Instance  string `json:"instance" binding:"required"`
+	Bucket    string `json:"bucket" binding:"required"`
+	Prefix    string `json:"prefix"`    // "目录"前缀, 以 / 结尾
+	Recursive bool   `json:"recursive"` // 为 true 时列出前缀下的所有 object, 不按目录分组
+	Token     string `json:"token"`     // 上一页返回的 next_token, 为空时从第一页开始
+	Limit     int64  `json:"limit"`
+	ProjectID uint   `json:"project_id"` // 非管理员需要指定项目, 并且项目对前缀有读授权
+}
+
+// S3UploadReq 上传参数通过 query 传递, 请求体为 multipart/form-data, 每个文件上传为 prefix + 文件名
+type S3UploadReq struct {
+	Instance string `form:"instance" binding:"required"`
+	Bucket   string `form:"bucket" binding:"required"`
+	Prefix   string `form:"prefix"`
+}
+
+type S3UploadResp struct {
+	Keys []string `json:"keys"`
+}
+
+type S3DownloadReq struct {
+	Instance  string `form:"instance" binding:"required"`
+	Bucket    string `form:"bucket" binding:"required"`
+	Key       string `form:"key" binding:"required"`
+	ProjectID uint   `form:"project_id"` // 非管理员需要指定项目, 并且项目对 key 有读授权
+}
+
+type S3DeleteReq struct {
+	Instance string   `json:"instance" binding:"required"`
+	Bucket   string   `json:"bucket" binding:"required"`
+	Keys     []string `json:"keys" binding:"required,min=1"`
+}
+
+type S3VersionListReq struct {
+	Instance        string `json:"instance" binding:"required"`
+	Bucket          string `json:"bucket" binding:"required"`
+	Prefix          string `json:"prefix"`
+	Recursive       bool   `json:"recursive"`
+	KeyMarker       string `json:"key_marker"`        // 上一页返回的 next_key_marker
+	VersionIDMarker string `json:"version_id_marker"` // 上一页返回的 next_version_id_marker
+	Limit           int64  `json:"limit"`
+	ProjectID       uint   `json:"project_id"` // 非管理员需要指定项目, 并且项目对前缀有读授权
+}
+
+type S3VersionReq struct {
+	Instance  string `json:"instance" binding:"required"`
+	Bucket    string `json:"bucket" binding:"required"`
+	Key       string `json:"key" binding:"required"`
+	VersionID string `json:"version_id" binding:"required"`
+}
+
+type S3VersionRestoreResp struct {
+	Key       string `json:"key"`
+	VersionID string `json:"version_id"` // 恢复后生成的新版本
+}
+
+type S3BucketReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Bucket   string `json:"bucket" binding:"required"`
+}
+
+// S3PolicyPutReq policy 和 grants 二选一: policy 为完整的策略, grants 按授权生成策略
+type S3PolicyPutReq struct {
+	Instance string             `json:"instance" binding:"required"`
+	Bucket   string             `json:"bucket" binding:"required"`
+	Policy   *db.S3Policy       `json:"policy"`
+	Grants   []db.S3PolicyGrant `json:"grants"`
+	DryRun   bool               `json:"dry_run"` // 为 true 时只返回生成的策略, 不修改
+}
+
+type S3LifecyclePutReq struct {
+	Instance string               `json:"instance" binding:"required"`
+	Bucket   string               `json:"bucket" binding:"required"`
+	Rules    []db.S3LifecycleRule `json:"rules" binding:"required,min=1"`
+}
+
+type S3CORSPutReq struct {
+	Instance string          `json:"instance" binding:"required"`
+	Bucket   string          `json:"bucket" binding:"required"`
+	Rules    []db.S3CORSRule `json:"rules" binding:"required,min=1"`
+}
+
+type S3VersioningReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Bucket   string `json:"bucket" binding:"required"`
+	Enabled  *bool  `json:"enabled" binding:"required"` // false 时暂停版本控制
+}
+
+type S3VersioningResp struct {
+	Bucket string `json:"bucket"`
+	Status string `json:"status"` // 从未开启时为空, 否则为 Enabled 或 Suspended
+}
+
+// S3PresignReq 预签名上传, 非管理员需要指定项目, 并且项目对 key 有读写授权
+type S3PresignReq struct {
+	Instance      string `json:"instance" binding:"required"`
+	Bucket        string `json:"bucket" binding:"required"`
+	Key           string `json:"key" binding:"required"`
+	ProjectID     uint   `json:"project_id"`
+	ContentType   string `json:"content_type"`
+	ExpireSeconds int64  `json:"expire_seconds"` // 为 0 时使用配置的最长有效期
+}
+
+// S3PresignPutReq 预签名 PUT 上传, size 为文件的字节数, 签名中包含 Content-Length, 不能超过配置的上限
+type S3PresignPutReq struct {
+	S3PresignReq
+	Size int64 `json:"size" binding:"required,min=1"`
+}
+
+// S3PresignPostReq 表单上传, max_size 为允许上传的最大字节数, 为 0 时使用配置的上限
+type S3PresignPostReq struct {
+	S3PresignReq
+	MaxSize int64 `json:"max_size"`
+}
+
+type S3MultipartCreateReq struct {
+	S3PresignReq
+	Size     int64 `json:"size" binding:"required,min=1"` // 文件大小, 合并时已上传的分片总大小不能超过该值
+	PartSize int64 `json:"part_size"`                     // 分片大小, 为 0 时使用最小分片 5MB
+}
+
+type S3MultipartPart struct {
+	PartNumber int64  `json:"part_number"`
+	URL        string `json:"url"`
+}
+
+type S3MultipartCreateResp struct {
+	UploadID   string            `json:"upload_id"`
+	PartSize   int64             `json:"part_size"`
+	Parts      []S3MultipartPart `json:"parts"`
+	Expiration time.Time         `json:"expiration"`
+}
+
+type S3MultipartCompleteReq struct {
+	Instance  string              `json:"instance" binding:"required"`
+	Bucket    string              `json:"bucket" binding:"required"`
+	Key       string              `json:"key" binding:"required"`
+	ProjectID uint                `json:"project_id"`
+	UploadID  string              `json:"upload_id" binding:"required"`
+	Size      int64               `json:"size" binding:"required,min=1"`
+	Parts     []db.S3UploadedPart `json:"parts" binding:"required,min=1"` // 只需要 part_number 和 etag
+}
+
+type S3MultipartAbortReq struct {
+	Instance  string `json:"instance" binding:"required"`
+	Bucket    string `json:"bucket" binding:"required"`
+	Key       string `json:"key" binding:"required"`
+	ProjectID uint   `json:"project_id"`
+	UploadID  string `json:"upload_id" binding:"required"`
+}
+
+// S3StsReq 申请临时凭证, 凭证只能访问 bucket 中 prefix 下的 object
+type S3StsReq struct {
+	Instance        string `json:"instance" binding:"required"`
+	Bucket          string `json:"bucket" binding:"required"`
+	Prefix          string `json:"prefix"`
+	ProjectID       uint   `json:"project_id"`
+	Access          string `json:"access" binding:"required,oneof=read readwrite"`
+	DurationSeconds int64  `json:"duration_seconds"` // 为 0 时使用配置的最长有效期
+}
+
+type S3StsResp struct {
+	AccessKeyID     string    `json:"access_key_id"`
+	SecretAccessKey string    `json:"secret_access_key"`
+	SessionToken    string    `json:"session_token"`
+	Expiration      time.Time `json:"expiration"`
+	EndPoint        string    `json:"endpoint"`
+	Bucket          string    `json:"bucket"`
+	Prefix          string    `json:"prefix"`
+	Access          string    `json:"access"`
+}
+
+type S3GrantListReq struct {
+	ProjectID uint `json:"project_id"` // 为 0 时返回所有项目的授权
+}
+
+type S3GrantCreateReq struct {
+	ProjectID uint   `json:"project_id" binding:"required"`
+	Instance  string `json:"instance" binding:"required"`
+	Bucket    string `json:"bucket" binding:"required"`
+	Prefix    string `json:"prefix"`
+	Access    string `json:"access" binding:"required,oneof=read readwrite"`
+}
+
+type S3GrantDeleteReq struct {
+	ID uint `json:"id" binding:"required"`
+}
@@ -0,0 +1,30 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-11 10:20:45
+ */
+
+package dto
+
+type SchemaInstanceReq struct {
+	Instance string `json:"instance" binding:"required"`
+}
+
+type SchemaTablesReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Schema   string `json:"schema" binding:"required"` // mysql 为库名, postgres 为当前连接的库中的 schema
+}
+
+type SchemaTableReq struct {
+	Instance string `json:"instance" binding:"required"`
+	Schema   string `json:"schema" binding:"required"`
+	Table    string `json:"table" binding:"required"`
+}
+
+// SchemaDiffReq 比较两个实例或者同一个实例的两个库, 生成在 target 上执行后与 source 一致的语句
+type SchemaDiffReq struct {
+	SourceInstance string   `json:"source_instance" binding:"required"`
+	SourceSchema   string   `json:"source_schema" binding:"required"`
+	TargetInstance string   `json:"target_instance" binding:"required"`
+	TargetSchema   string   `json:"target_schema" binding:"required"`
+	Tables         []string `json:"tables"` // 只比较这些表, 为空时比较所有表
+}
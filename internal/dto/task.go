@@ -0,0 +1,23 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-09 17:40:26
+ */
+
+package dto
+
+import "myadmin/internal/task"
+
+type TaskReq struct {
+	ID string `json:"id" binding:"required"`
+}
+
+type TaskListReq struct {
+	Module string `json:"module"` // 为空时返回所有模块的任务
+	Page   int    `json:"page"`
+	Limit  int    `json:"limit"`
+}
+
+type TaskListResp struct {
+	Total int64           `json:"total"`
+	Tasks []task.TaskInfo `json:"tasks"`
+}
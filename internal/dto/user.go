@@ -129,3 +129,19 @@ type UserUpdatePasswordReq struct {
 type UserDeleteReq struct {
 	ID uint `json:"id"`
 }
+
+// 上传头像请求, 图片在 multipart 表单的 file 字段中, id 为空时更新当前用户的头像
+type UserAvatarUploadReq struct {
+	ID uint `form:"id"`
+}
+
+type UserAvatarUploadResp struct {
+	AvatarURL string `json:"avatar_url"`
+}
+
+// 读取头像请求, size 为空时返回默认尺寸
+type UserAvatarReq struct {
+	UID  uint   `uri:"uid" binding:"required"`
+	Name string `uri:"name" binding:"required"`
+	Size int    `form:"size"`
+}
@@ -0,0 +1,37 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 15:06:12
+ */
+
+package model
+
+import (
+	"time"
+)
+
+// AuditLog 操作审计表, 记录对被管理实例的所有写操作
+type AuditLog struct {
+	ID        uint      `gorm:"column:id;autoIncrement;primary_key;not null;" json:"id"`
+	UserID    uint      `gorm:"column:user_id;not null;default:0;index;comment:操作人ID" json:"user_id"`
+	Username  string    `gorm:"column:username;type:string;size:32;not null;default:'';comment:操作人用户名" json:"username"`
+	Module    string    `gorm:"column:module;type:string;size:32;not null;default:'';index;comment:模块: redis, mongodb, mysql 等" json:"module"`
+	Instance  string    `gorm:"column:instance;type:string;size:64;not null;default:'';index;comment:实例名, 即配置文件中的名称" json:"instance"`
+	Action    string    `gorm:"column:action;type:string;size:64;not null;default:'';comment:操作" json:"action"`
+	Target    string    `gorm:"column:target;type:string;size:512;not null;default:'';comment:操作对象" json:"target"`
+	Detail    string    `gorm:"column:detail;type:text;comment:操作参数" json:"detail"`
+	Status    int       `gorm:"column:status;type:uint;size:8;not null;default:0;comment:成功(0),失败(1)" json:"status"`
+	Message   string    `gorm:"column:message;type:string;size:1024;not null;default:'';comment:失败原因" json:"message"`
+	CreatedAt time.Time `gorm:"column:created_at;index" json:"created_at"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+const (
+	// AuditStatusSuccess 操作成功
+	AuditStatusSuccess = 0
+
+	// AuditStatusFailed 操作失败
+	AuditStatusFailed = 1
+)
@@ -0,0 +1,28 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-26 14:02:18
+ */
+
+package model
+
+import "time"
+
+// ProjectBucketGrant 项目对 s3 bucket 的授权, 项目成员可以在授权范围内获取预签名 URL 和临时凭证
+type ProjectBucketGrant struct {
+	ID        uint      `gorm:"column:id;autoIncrement;primary_key;not null;" json:"id"`
+	ProjectID uint      `gorm:"column:project_id;not null;index;comment:项目ID" json:"project_id"`
+	Instance  string    `gorm:"column:instance;type:string;size:64;not null;default:'';comment:s3 实例名, 即配置文件中的名称" json:"instance"`
+	Bucket    string    `gorm:"column:bucket;type:string;size:64;not null;default:'';comment:bucket" json:"bucket"`
+	Prefix    string    `gorm:"column:prefix;type:string;size:512;not null;default:'';comment:前缀, 为空时为整个 bucket" json:"prefix"`
+	Access    string    `gorm:"column:access;type:string;size:16;not null;default:'read';comment:只读(read),读写(readwrite)" json:"access"`
+	CreateBy  uint      `gorm:"column:create_by;" json:"create_by"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (ProjectBucketGrant) TableName() string {
+	return "project_bucket_grants"
+}
+
+// ProjectStatusActive 项目, 项目成员已激活, 只有激活的项目成员可以使用项目的授权
+const ProjectStatusActive = 2
@@ -18,4 +18,6 @@ var Tables = map[string]any{
 	"web_menu_withs":            WebMenuWith{},
 	"sys_authorities":           SysAuthority{},
 	"web_menu_btns":             WebMenuBtn{},
+	"audit_logs":                AuditLog{},
+	"project_bucket_grants":     ProjectBucketGrant{},
 }
@@ -0,0 +1,66 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-30 15:10:04
+ */
+
+package router
+
+import (
+	"myadmin/internal/controller"
+	"myadmin/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Mongo(root *gin.RouterGroup) {
+	mongo := controller.Mongo{}
+	mongoRouter := root.Group("/mongodb")
+	{
+		mongoRouter.POST("/hello", mongo.Hello)
+
+		// 副本集状态和健康检查
+		mongoRouter.POST("/replset/status", mongo.ReplSetStatus)
+		mongoRouter.POST("/replset/health", mongo.ReplSetHealth)
+		mongoRouter.POST("/replset/health/all", mongo.ReplSetHealthAll)
+
+		// 副本集成员管理, 变更后等待多数投票成员应用新配置
+		mongoRouter.POST("/replset/config", mongo.ReplSetConfig)
+		mongoRouter.POST("/replset/add", middleware.JWTAuth.AdminRequired, mongo.ReplSetAdd)
+		mongoRouter.POST("/replset/remove", middleware.JWTAuth.AdminRequired, mongo.ReplSetRemove)
+		mongoRouter.POST("/replset/member/update", middleware.JWTAuth.AdminRequired, mongo.ReplSetUpdateMember)
+		mongoRouter.POST("/replset/stepdown", middleware.JWTAuth.AdminRequired, mongo.ReplSetStepDown)
+
+		// 正在执行的操作, 命令中可能有密码和业务数据, 只允许管理员查看; kill 时不允许 kill 内部和复制相关的操作
+		mongoRouter.POST("/currentop", middleware.JWTAuth.AdminRequired, mongo.CurrentOp)
+		mongoRouter.POST("/currentop/kill", middleware.JWTAuth.AdminRequired, mongo.KillOp)
+		mongoRouter.POST("/currentop/kill/matching", middleware.JWTAuth.AdminRequired, mongo.KillMatching) // 默认只预览, confirm 为 true 时执行
+
+		// 运行时参数, 只允许修改参数目录中的参数, 基线按环境配置
+		mongoRouter.POST("/param/catalogue", mongo.ParamCatalogue)
+		mongoRouter.POST("/param/get", mongo.ParamGet)
+		mongoRouter.POST("/param/set", middleware.JWTAuth.AdminRequired, mongo.ParamSet)
+		mongoRouter.POST("/param/diff", mongo.ParamDiff)
+		mongoRouter.POST("/param/diff/all", mongo.ParamDiffAll)
+
+		// 为业务创建库, 集合, 角色和用户, 后台任务执行, 可以重复执行
+		mongoRouter.POST("/provision", middleware.JWTAuth.AdminRequired, mongo.Provision)
+
+		// 集合大小和索引使用情况, 标记没有使用和冗余的索引
+		mongoRouter.POST("/stats/collections", mongo.CollectionStats)
+
+		// 慢查询, profiler 开启后定时采集 system.profile, 按查询模式聚合
+		mongoRouter.POST("/profile/status", mongo.ProfileStatus)
+		mongoRouter.POST("/profile/enable", middleware.JWTAuth.AdminRequired, mongo.ProfileEnable)
+		mongoRouter.POST("/profile/disable", middleware.JWTAuth.AdminRequired, mongo.ProfileDisable)
+		mongoRouter.POST("/profile/harvest", middleware.JWTAuth.AdminRequired, mongo.ProfileHarvest)
+		mongoRouter.POST("/profile/slow", mongo.SlowQueries)
+
+		// 分片集群
+		mongoRouter.POST("/sharding/overview", mongo.ShardOverview) // 分片, 均衡器, chunk 分布, 正在进行的迁移
+		mongoRouter.POST("/sharding/chunks", mongo.Chunks)          // 集合的 chunk 分布和 jumbo chunk
+		mongoRouter.POST("/sharding/balancer/start", middleware.JWTAuth.AdminRequired, mongo.BalancerStart)
+		mongoRouter.POST("/sharding/balancer/stop", middleware.JWTAuth.AdminRequired, mongo.BalancerStop)
+		mongoRouter.POST("/sharding/balancer/window", middleware.JWTAuth.AdminRequired, mongo.BalancerWindow)
+		mongoRouter.POST("/sharding/index/inconsistent", mongo.InconsistentIndex) // 各分片索引不一致的检查
+	}
+}
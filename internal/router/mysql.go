@@ -0,0 +1,36 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-29 18:40:13
+ */
+
+package router
+
+import (
+	"myadmin/internal/controller"
+	"myadmin/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Mysql(root *gin.RouterGroup) {
+	mysql := controller.Mysql{}
+	mysqlRouter := root.Group("/mysql")
+	{
+		// 连接列表, 语句中可能有密码和业务数据, 只允许管理员查看; kill 时不允许 kill 系统线程, 复制线程和受保护用户的连接
+		mysqlRouter.POST("/processlist", middleware.JWTAuth.AdminRequired, mysql.Processlist)
+		mysqlRouter.POST("/kill", middleware.JWTAuth.AdminRequired, mysql.Kill)
+		mysqlRouter.POST("/kill/matching", middleware.JWTAuth.AdminRequired, mysql.KillMatching) // 默认只预览, confirm 为 true 时执行
+
+		// 全局变量和状态
+		mysqlRouter.POST("/variables", mysql.Variables)
+		mysqlRouter.POST("/status", mysql.Status)
+		mysqlRouter.POST("/status/rates", mysql.StatusRates) // 间隔采集两次, 计算 qps, tps 和各计数器的速率
+
+		// 解析后的 show engine innodb status: 最近的死锁, 事务和 buffer pool, 包括语句, 只允许管理员查看
+		mysqlRouter.POST("/innodb/status", middleware.JWTAuth.AdminRequired, mysql.InnodbStatus)
+
+		// 复制状态, 以及从实例开始自动发现的复制拓扑和健康检查
+		mysqlRouter.POST("/replication/status", mysql.ReplNode)
+		mysqlRouter.POST("/replication/topology", mysql.ReplTopology)
+	}
+}
@@ -0,0 +1,33 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-06 15:10:36
+ */
+
+package router
+
+import (
+	"myadmin/internal/controller"
+	"myadmin/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PgSQL(root *gin.RouterGroup) {
+	pgsql := controller.PgSQL{}
+	pgsqlRouter := root.Group("/pgsql")
+	{
+		// pg_stat_activity 和 pg_locks, 语句中可能有密码和业务数据, 包括语句的接口只允许管理员查看
+		pgsqlRouter.POST("/activity", middleware.JWTAuth.AdminRequired, pgsql.Activity)
+		pgsqlRouter.POST("/locks", pgsql.Locks)
+
+		// 阻塞链, 长时间运行的语句, 长事务和 idle in transaction
+		pgsqlRouter.POST("/blocking", middleware.JWTAuth.AdminRequired, pgsql.BlockingChains)
+		pgsqlRouter.POST("/long-running", middleware.JWTAuth.AdminRequired, pgsql.LongRunning)
+
+		// 健康检查: 复制延迟, 复制槽保留的 wal, 表和索引膨胀, vacuum 进度和事务 id 回卷
+		pgsqlRouter.POST("/health", pgsql.Health)
+
+		// pg_cancel_backend/pg_terminate_backend, 不允许操作后台进程, 当前管理连接和受保护用户的连接
+		pgsqlRouter.POST("/cancel", middleware.JWTAuth.AdminRequired, pgsql.Cancel)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 16:25:14
+ */
+
+package router
+
+import (
+	"myadmin/internal/controller"
+	"myadmin/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Redis(root *gin.RouterGroup) {
+	redis := controller.Redis{}
+	redisRouter := root.Group("/redis")
+	{
+		// default 实例同时保存登录用户的会话和 refresh token, 读取 key 也需要管理员权限
+		redisRouter.POST("/key/scan", middleware.JWTAuth.AdminRequired, redis.ScanKeys)    // scan 遍历 key
+		redisRouter.POST("/key/meta", middleware.JWTAuth.AdminRequired, redis.KeyMeta)     // key 元数据
+		redisRouter.POST("/key/value", middleware.JWTAuth.AdminRequired, redis.KeyValue)   // 分页读取 key 的值
+		redisRouter.POST("/key/expire", middleware.JWTAuth.AdminRequired, redis.KeyExpire) // 设置过期时间
+		redisRouter.POST("/key/delete", middleware.JWTAuth.AdminRequired, redis.KeyDelete) // 删除 key
+		redisRouter.POST("/key/rename", middleware.JWTAuth.AdminRequired, redis.KeyRename) // 重命名 key
+
+		// 集群拓扑管理, 变更操作以后台任务执行, 通过 /task/info 查看进度和步骤日志
+		redisRouter.POST("/cluster/topology", redis.ClusterTopology)
+		redisRouter.POST("/cluster/rebalance/plan", redis.ClusterRebalancePlan)
+		redisRouter.POST("/cluster/meet", middleware.JWTAuth.AdminRequired, redis.ClusterMeet)
+		redisRouter.POST("/cluster/addslots", middleware.JWTAuth.AdminRequired, redis.ClusterAddSlots)
+		redisRouter.POST("/cluster/rebalance", middleware.JWTAuth.AdminRequired, redis.ClusterRebalance)
+		redisRouter.POST("/cluster/migrate", middleware.JWTAuth.AdminRequired, redis.ClusterMigrate)
+		redisRouter.POST("/cluster/failover", middleware.JWTAuth.AdminRequired, redis.ClusterFailover)
+		redisRouter.POST("/cluster/forget", middleware.JWTAuth.AdminRequired, redis.ClusterForget)
+
+		// 运行时参数
+		redisRouter.POST("/config/get", middleware.JWTAuth.AdminRequired, redis.ConfigGet) // 密码类参数只返回占位符
+		redisRouter.POST("/config/diff", redis.ConfigDiff)                                 // 单个实例与基线对比
+		redisRouter.POST("/config/diff/all", redis.ConfigDiffAll)                          // 所有实例与基线对比
+		redisRouter.POST("/config/set", middleware.JWTAuth.AdminRequired, redis.ConfigSet) // 默认只预览, apply 为 true 时执行
+
+		// 复制和内存诊断, 规则可在配置文件 redis_admin.diagnose 中调整
+		redisRouter.POST("/diagnose", redis.Diagnose)
+		redisRouter.POST("/diagnose/all", redis.DiagnoseAll)
+		redisRouter.POST("/diagnose/rules", redis.DiagnoseRules)
+	}
+}
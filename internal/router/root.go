@@ -69,6 +69,7 @@ func (r *RouterEngine) MountRoutes() error {
 		root.POST("/auth/user/login", middleware.JWTAuth.Login)                //登录
 		root.POST("/auth/user/refresh-token", middleware.JWTAuth.RefreshToken) //刷新 Token
 		root.POST("/route/getAsyncRoutes", controller.Route{}.Info)            //刷新 Token
+		root.GET("/files/avatar/:uid/:name", user.Avatar)                      //读取头像, img 标签无法携带 token, 不需要登录
 	}
 
 	root.Use(middleware.JWTAuth.LoginRequired)
@@ -76,6 +77,13 @@ func (r *RouterEngine) MountRoutes() error {
 
 	// 加载分组路由
 	User(root)
+	Redis(root)
+	Mysql(root)
+	PgSQL(root)
+	Schema(root)
+	Mongo(root)
+	S3(root)
+	Task(root)
 
 	// 自定义没有路由的处理
 	r.NoRoute()
@@ -90,8 +98,5 @@ func (r *RouterEngine) StaticRoutes() {
 	r.Static("/assets", "./dist/assets")   // dist里面的静态资源
 	r.StaticFile("/", "./dist/index.html") // 前端网页入口页面
 
-	// 为用户头像和文件提供静态地址。
-	// TODO: 如果访问文件需要验证, 则需要放到其他分组下
-	// TODO: 如果使用 S3 存储文件, 则不需要
-	// r.StaticFS("/files/image/avatar/", http.Dir("/data1/file/image/avatar"))
+	// 用户头像由 /files/avatar/ 接口读取, 支持本地目录和 S3 存储, 见 [image] 配置
 }
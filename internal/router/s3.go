@@ -0,0 +1,60 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-23 11:18:09
+ */
+
+package router
+
+import (
+	"myadmin/internal/controller"
+	"myadmin/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func S3(root *gin.RouterGroup) {
+	s3 := controller.S3{}
+	s3Router := root.Group("/s3")
+	{
+		// 文件浏览, 按 / 分组显示"目录", 通过 token 翻页; 非管理员只能浏览和下载所在项目有读授权的前缀
+		s3Router.POST("/bucket/list", middleware.JWTAuth.AdminRequired, s3.Buckets)
+		s3Router.POST("/object/list", s3.Objects)
+
+		// 上传和下载直接在请求和 s3 之间转发, 不写本地文件
+		s3Router.POST("/object/upload", middleware.JWTAuth.AdminRequired, s3.Upload) // 参数通过 query 传递, 文件通过 multipart/form-data 上传
+		s3Router.GET("/object/download", s3.Download)
+		s3Router.POST("/object/delete", middleware.JWTAuth.AdminRequired, s3.Delete) // 批量删除
+
+		// 历史版本, 恢复时将历史版本复制为最新版本, 删除版本后无法恢复
+		s3Router.POST("/object/versions", s3.Versions)
+		s3Router.POST("/object/version/restore", middleware.JWTAuth.AdminRequired, s3.RestoreVersion)
+		s3Router.POST("/object/version/delete", middleware.JWTAuth.AdminRequired, s3.DeleteVersion)
+
+		// bucket 管理, 只允许管理员操作; 策略可以按授权生成, 规则类的设置都是整体替换
+		s3Router.POST("/bucket/policy", middleware.JWTAuth.AdminRequired, s3.Policy)
+		s3Router.POST("/bucket/policy/put", middleware.JWTAuth.AdminRequired, s3.PolicyPut)
+		s3Router.POST("/bucket/policy/delete", middleware.JWTAuth.AdminRequired, s3.PolicyDelete)
+		s3Router.POST("/bucket/lifecycle", middleware.JWTAuth.AdminRequired, s3.Lifecycle)
+		s3Router.POST("/bucket/lifecycle/put", middleware.JWTAuth.AdminRequired, s3.LifecyclePut)
+		s3Router.POST("/bucket/lifecycle/delete", middleware.JWTAuth.AdminRequired, s3.LifecycleDelete)
+		s3Router.POST("/bucket/cors", middleware.JWTAuth.AdminRequired, s3.CORS)
+		s3Router.POST("/bucket/cors/put", middleware.JWTAuth.AdminRequired, s3.CORSPut)
+		s3Router.POST("/bucket/cors/delete", middleware.JWTAuth.AdminRequired, s3.CORSDelete)
+		s3Router.POST("/bucket/versioning", middleware.JWTAuth.AdminRequired, s3.Versioning)
+		s3Router.POST("/bucket/versioning/put", middleware.JWTAuth.AdminRequired, s3.VersioningPut)
+		s3Router.POST("/bucket/usage", middleware.JWTAuth.AdminRequired, s3.Usage) // 遍历 bucket 统计, 以后台任务执行
+
+		// 预签名上传和临时凭证, 非管理员只能在所在项目的授权范围内申请, 有效期和文件大小由配置限制
+		s3Router.POST("/presign/put", s3.PresignPut)
+		s3Router.POST("/presign/post", s3.PresignPost) // 表单上传, 上传时校验文件大小
+		s3Router.POST("/presign/multipart/create", s3.MultipartCreate)
+		s3Router.POST("/presign/multipart/complete", s3.MultipartComplete)
+		s3Router.POST("/presign/multipart/abort", s3.MultipartAbort)
+		s3Router.POST("/sts", s3.Sts)
+
+		// 项目对 bucket 的授权
+		s3Router.POST("/grant/list", middleware.JWTAuth.AdminRequired, s3.GrantList)
+		s3Router.POST("/grant/create", middleware.JWTAuth.AdminRequired, s3.GrantCreate)
+		s3Router.POST("/grant/delete", middleware.JWTAuth.AdminRequired, s3.GrantDelete)
+	}
+}
@@ -0,0 +1,27 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-11 11:03:40
+ */
+
+package router
+
+import (
+	"myadmin/internal/controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Schema(root *gin.RouterGroup) {
+	schema := controller.Schema{}
+	schemaRouter := root.Group("/schema")
+	{
+		// 表结构浏览, 支持 mysql 和 postgres
+		schemaRouter.POST("/databases", schema.Databases)
+		schemaRouter.POST("/schemas", schema.Schemas)
+		schemaRouter.POST("/tables", schema.Tables)
+		schemaRouter.POST("/table", schema.Table) // 列, 索引, 约束和建表语句
+
+		// 比较两个实例或者两个库的表结构, 只返回语句不执行
+		schemaRouter.POST("/diff", schema.Diff)
+	}
+}
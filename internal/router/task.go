@@ -0,0 +1,23 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-09 17:52:19
+ */
+
+package router
+
+import (
+	"myadmin/internal/controller"
+	"myadmin/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Task(root *gin.RouterGroup) {
+	task := controller.Task{}
+	taskRouter := root.Group("/task")
+	{
+		// 任务都由管理员提交, 步骤日志中包含实例和操作的详细信息, 只允许管理员查看
+		taskRouter.POST("/info", middleware.JWTAuth.AdminRequired, task.Info) // 任务详情及步骤日志
+		taskRouter.POST("/list", middleware.JWTAuth.AdminRequired, task.List) // 任务列表
+	}
+}
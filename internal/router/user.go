@@ -23,6 +23,6 @@ func User(root *gin.RouterGroup) {
 		userRouter.POST("/user/delete", middleware.JWTAuth.RootRequired, user.Delete)
 		userRouter.POST("/user/password/update", user.UpdatePassword)
 		userRouter.POST("/user/password/reset", middleware.JWTAuth.RootRequired, user.ResetPassword)
-		// 添加更新头像的接口
+		userRouter.POST("/user/avatar/upload", user.UploadAvatar)
 	}
 }
@@ -11,6 +11,7 @@ import (
 	"myadmin/internal/db"
 	"myadmin/internal/middleware"
 	"myadmin/internal/router"
+	"myadmin/internal/service/mongoservice"
 	"myadmin/internal/zlog"
 	"syscall"
 	"time"
@@ -34,6 +35,10 @@ func Server() error {
 		return err
 	}
 
+	if err := mongoservice.SetupProfilerHarvest(); err != nil { // 定时采集 mongodb 慢查询
+		return err
+	}
+
 	engine := router.NewRouterEngine()           // 创建路由引擎
 	if err := engine.MountRoutes(); err != nil { //挂载路由
 		return err
@@ -0,0 +1,69 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 15:16:25
+ */
+
+package auditservice
+
+import (
+	"encoding/json"
+	"myadmin/internal/dao"
+	"myadmin/internal/model"
+
+	"go.uber.org/zap"
+)
+
+type AuditService struct{}
+
+func NewAuditService() *AuditService {
+	return &AuditService{}
+}
+
+// Record 记录一条审计日志, opErr 为操作本身的执行结果
+// 审计日志写入失败只记录到日志文件, 不影响操作结果的返回
+func (a *AuditService) Record(operator model.User, module, instance, action, target string, detail any, opErr error) {
+	log := model.AuditLog{
+		UserID:   operator.ID,
+		Username: operator.Username,
+		Module:   module,
+		Instance: instance,
+		Action:   action,
+		Target:   truncate(target, 512),
+		Status:   model.AuditStatusSuccess,
+	}
+
+	if detail != nil {
+		if b, err := json.Marshal(detail); err == nil {
+			log.Detail = string(b)
+		}
+	}
+
+	if opErr != nil {
+		log.Status = model.AuditStatusFailed
+		log.Message = truncate(opErr.Error(), 1024)
+	}
+
+	zap.L().Info("audit",
+		zap.String("username", log.Username),
+		zap.String("module", log.Module),
+		zap.String("instance", log.Instance),
+		zap.String("action", log.Action),
+		zap.String("target", log.Target),
+		zap.String("detail", log.Detail),
+		zap.Int("status", log.Status),
+		zap.String("message", log.Message),
+	)
+
+	if err := dao.NewAuditDao().Create(&log); err != nil {
+		zap.L().Error("写入审计日志失败", zap.String("error", err.Error()))
+	}
+}
+
+// truncate 按字符截断, 防止超出表字段长度
+func truncate(s string, size int) string {
+	r := []rune(s)
+	if len(r) <= size {
+		return s
+	}
+	return string(r[:size])
+}
@@ -0,0 +1,56 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-08 15:30:19
+ */
+
+package mongoservice
+
+import (
+	"fmt"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+)
+
+func (m *MongoService) CurrentOp(req dto.MongoCurrentOpReq) ([]db.CurrentOp, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	return client.CurrentOp(req.CurrentOpFilter)
+}
+
+// KillOp kill 单个操作, 内部或复制相关的操作不允许 kill
+func (m *MongoService) KillOp(operator model.User, req dto.MongoKillOpReq) (db.CurrentOp, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.CurrentOp{}, err
+	}
+
+	op, err := client.KillOp(req.OpID)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "currentop.kill", fmt.Sprint(req.OpID), op, err)
+	return op, err
+}
+
+// KillMatching kill 所有匹配条件的活跃操作, 没有确认时只返回将要 kill 的操作
+func (m *MongoService) KillMatching(operator model.User, req dto.MongoKillMatchingReq) (db.CurrentOpKillResult, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.CurrentOpKillResult{}, err
+	}
+
+	result, err := client.KillMatching(req.CurrentOpFilter, !req.Confirm)
+	killed := make([]any, 0, len(result.Killed))
+	for _, op := range result.Killed {
+		killed = append(killed, op.OpID)
+	}
+	// 预览时没有 kill 任何操作, 使用单独的 action 记录将要 kill 的操作
+	action, key := "currentop.kill_matching", "killed"
+	if !req.Confirm {
+		action, key = "currentop.kill_matching_preview", "would_kill"
+	}
+	detail := map[string]any{"request": req, "dry_run": !req.Confirm, key: killed, "skipped": len(result.Skipped), "failed": result.Failed}
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, action, fmt.Sprintf("%+v", req.CurrentOpFilter), detail, err)
+	return result, err
+}
@@ -0,0 +1,144 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-02 10:33:18
+ */
+
+package mongoservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"sort"
+	"strings"
+)
+
+// 从节点复制延迟的默认告警阈值, 单位: 秒
+const DefaultMaxLagSeconds = 10
+
+func (m *MongoService) ReplSetStatus(req dto.MongoInstanceReq) (db.ReplSetStatus, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.ReplSetStatus{}, err
+	}
+	return client.GetReplStatus()
+}
+
+func (m *MongoService) Hello(req dto.MongoInstanceReq) (db.HelloResult, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.HelloResult{}, err
+	}
+	return client.Hello()
+}
+
+// ReplSetHealth 检查单个副本集实例的健康状态
+func (m *MongoService) ReplSetHealth(req dto.MongoReplSetHealthReq) (dto.MongoReplSetHealth, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return dto.MongoReplSetHealth{}, err
+	}
+
+	status, err := client.GetReplStatus()
+	if err != nil {
+		return dto.MongoReplSetHealth{}, err
+	}
+
+	// 延迟从库的配置只在副本集配置中
+	conf, err := client.GetReplSetConfig()
+	if err != nil {
+		return dto.MongoReplSetHealth{}, err
+	}
+	delays := make(map[string]int64, len(conf.Members))
+	for _, member := range conf.Members {
+		if delay := member.Delay(); delay > 0 {
+			delays[strings.ToLower(member.Host)] = delay
+		}
+	}
+
+	if req.MaxLagSeconds <= 0 {
+		req.MaxLagSeconds = DefaultMaxLagSeconds
+	}
+	health := evaluateReplSetHealth(status, delays, req.MaxLagSeconds)
+	health.Instance = req.Instance
+	return health, nil
+}
+
+// ReplSetHealthAll 检查所有实例, 单个实例失败不影响其他实例; mongos 等非副本集实例跳过
+func (m *MongoService) ReplSetHealthAll(maxLagSeconds float64) []dto.MongoReplSetHealth {
+	instances := make([]string, 0, len(config.GlobalConfig.Mongo))
+	for name := range config.GlobalConfig.Mongo {
+		instances = append(instances, name)
+	}
+	sort.Strings(instances)
+
+	reports := []dto.MongoReplSetHealth{}
+	for _, name := range instances {
+		client, err := m.client(name)
+		if err == nil {
+			var hello db.HelloResult
+			if hello, err = client.Hello(); err == nil && hello.SetName == "" {
+				continue
+			}
+		}
+		if err != nil {
+			reports = append(reports, dto.MongoReplSetHealth{Instance: name, Issues: []dto.MongoReplSetHealthIssue{}, Error: err.Error()})
+			continue
+		}
+
+		report, err := m.ReplSetHealth(dto.MongoReplSetHealthReq{Instance: name, MaxLagSeconds: maxLagSeconds})
+		if err != nil {
+			report = dto.MongoReplSetHealth{Instance: name, Issues: []dto.MongoReplSetHealthIssue{}, Error: err.Error()}
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// evaluateReplSetHealth 检查没有主节点, 成员不可达, 成员状态异常, 从节点复制延迟和没有同步源
+// delays 为延迟从库配置的延迟秒数, key 为小写的成员地址, 延迟从库的复制延迟扣除配置的延迟后再和阈值比较
+func evaluateReplSetHealth(status db.ReplSetStatus, delays map[string]int64, maxLagSeconds float64) dto.MongoReplSetHealth {
+	health := dto.MongoReplSetHealth{Set: status.Set, Status: dto.HealthOK, Issues: []dto.MongoReplSetHealthIssue{}}
+	issue := func(severity, member, format string, args ...any) {
+		health.Issues = append(health.Issues, dto.MongoReplSetHealthIssue{Severity: severity, Member: member, Message: fmt.Sprintf(format, args...)})
+		if severity == dto.SeverityCritical || health.Status == dto.HealthOK {
+			health.Status = severity
+		}
+	}
+
+	if primary, ok := status.Primary(); ok {
+		health.Primary = primary.Name
+	} else {
+		issue(dto.SeverityCritical, "", "副本集没有主节点")
+	}
+
+	for _, member := range status.Members {
+		if member.Health != 1 || member.State == db.ReplStateDown || member.State == db.ReplStateUnknown {
+			issue(dto.SeverityCritical, member.Name, "成员不可达, 状态: %s, %s", member.StateStr, member.LastHeartbeatMessage)
+			continue
+		}
+
+		switch member.State {
+		case db.ReplStatePrimary, db.ReplStateArbiter:
+		case db.ReplStateSecondary:
+			delay := delays[strings.ToLower(member.Name)]
+			if lag := member.LagSeconds - float64(delay); lag > maxLagSeconds {
+				if delay > 0 {
+					issue(dto.SeverityWarning, member.Name, "复制延迟 %.0f 秒, 扣除延迟从库配置的 %d 秒后超过 %.0f 秒", member.LagSeconds, delay, maxLagSeconds)
+				} else {
+					issue(dto.SeverityWarning, member.Name, "复制延迟 %.0f 秒, 超过 %.0f 秒", member.LagSeconds, maxLagSeconds)
+				}
+				health.Issues[len(health.Issues)-1].Lag = member.LagSeconds
+			}
+			if member.SyncSource() == "" {
+				issue(dto.SeverityWarning, member.Name, "从节点没有同步源 %s", member.InfoMessage)
+			}
+		case db.ReplStateRollback, db.ReplStateRemoved:
+			issue(dto.SeverityCritical, member.Name, "成员状态异常: %s", member.StateStr)
+		default:
+			issue(dto.SeverityWarning, member.Name, "成员状态异常: %s", member.StateStr)
+		}
+	}
+	return health
+}
@@ -0,0 +1,61 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-02 11:46:52
+ */
+
+package mongoservice
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"testing"
+)
+
+func TestEvaluateReplSetHealth(t *testing.T) {
+	healthy := db.ReplSetStatus{Set: "rs0", Members: []db.ReplSetMemberStatus{
+		{Name: "a:27017", Health: 1, State: db.ReplStatePrimary, StateStr: "PRIMARY"},
+		{Name: "b:27017", Health: 1, State: db.ReplStateSecondary, StateStr: "SECONDARY", SyncSourceHost: "a:27017", LagSeconds: 1},
+		{Name: "c:27017", Health: 1, State: db.ReplStateArbiter, StateStr: "ARBITER"},
+	}}
+	health := evaluateReplSetHealth(healthy, nil, 10)
+	if health.Status != dto.HealthOK || len(health.Issues) != 0 || health.Primary != "a:27017" {
+		t.Errorf("healthy replset got %+v", health)
+	}
+
+	lagging := db.ReplSetStatus{Set: "rs0", Members: []db.ReplSetMemberStatus{
+		{Name: "a:27017", Health: 1, State: db.ReplStatePrimary, StateStr: "PRIMARY"},
+		{Name: "b:27017", Health: 1, State: db.ReplStateSecondary, StateStr: "SECONDARY", SyncSourceHost: "a:27017", LagSeconds: 60},
+	}}
+	health = evaluateReplSetHealth(lagging, nil, 10)
+	if health.Status != dto.SeverityWarning || len(health.Issues) != 1 || health.Issues[0].Lag != 60 {
+		t.Errorf("lagging replset got %+v", health)
+	}
+
+	broken := db.ReplSetStatus{Set: "rs0", Members: []db.ReplSetMemberStatus{
+		{Name: "a:27017", Health: 0, State: db.ReplStateDown, StateStr: "(not reachable/healthy)"},
+		{Name: "b:27017", Health: 1, State: db.ReplStateSecondary, StateStr: "SECONDARY"},
+	}}
+	health = evaluateReplSetHealth(broken, nil, 10)
+	if health.Status != dto.SeverityCritical || health.Primary != "" {
+		t.Errorf("broken replset got %+v", health)
+	}
+	// 没有主节点, a 不可达, b 没有同步源
+	if len(health.Issues) != 3 {
+		t.Errorf("broken replset issues = %+v", health.Issues)
+	}
+
+	// 4.4 之前的版本同步源在 syncingTo 中; 延迟从库扣除配置的延迟后没有超过阈值
+	legacy := db.ReplSetStatus{Set: "rs0", Members: []db.ReplSetMemberStatus{
+		{Name: "a:27017", Health: 1, State: db.ReplStatePrimary, StateStr: "PRIMARY"},
+		{Name: "b:27017", Health: 1, State: db.ReplStateSecondary, StateStr: "SECONDARY", SyncingTo: "a:27017", LagSeconds: 1},
+		{Name: "D:27017", Health: 1, State: db.ReplStateSecondary, StateStr: "SECONDARY", SyncSourceHost: "a:27017", LagSeconds: 3605},
+	}}
+	health = evaluateReplSetHealth(legacy, map[string]int64{"d:27017": 3600}, 10)
+	if health.Status != dto.HealthOK || len(health.Issues) != 0 {
+		t.Errorf("legacy replset got %+v", health)
+	}
+	health = evaluateReplSetHealth(legacy, map[string]int64{"d:27017": 60}, 10)
+	if len(health.Issues) != 1 || health.Issues[0].Member != "D:27017" || health.Issues[0].Lag != 3605 {
+		t.Errorf("delayed member over threshold got %+v", health.Issues)
+	}
+}
@@ -0,0 +1,21 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-06 11:25:40
+ */
+
+package mongoservice
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+)
+
+// InconsistentIndex 检查分片集合在各分片上的索引是否一致
+func (m *MongoService) InconsistentIndex(req dto.MongoInconsistentIndexReq) (db.IndexInconsistencyReport, error) {
+	client, err := m.mongosClient(req.Instance)
+	if err != nil {
+		return db.IndexInconsistencyReport{}, err
+	}
+
+	return client.InconsistentIndexReport(req.Database, req.Concurrency, req.Fix)
+}
@@ -0,0 +1,33 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-30 14:15:09
+ */
+
+package mongoservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+)
+
+const AuditModule = "mongodb"
+
+type MongoService struct{}
+
+func NewMongoService() *MongoService {
+	return &MongoService{}
+}
+
+// client 根据配置文件中的实例名获取 mongodb 连接
+func (m *MongoService) client(instance string) (*db.MongoDBClient, error) {
+	if _, ok := config.GlobalConfig.Mongo[instance]; !ok {
+		return nil, fmt.Errorf("mongodb 实例: %s 不存在", instance)
+	}
+
+	client := db.MongoDB(instance)
+	if client == nil || client.Conn == nil {
+		return nil, fmt.Errorf("mongodb 实例: %s 连接不可用", instance)
+	}
+	return client, nil
+}
@@ -0,0 +1,125 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-15 15:36:08
+ */
+
+package mongoservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"sort"
+)
+
+func (m *MongoService) ParamCatalogue() []db.MongoParamSpec {
+	return db.MongoParamCatalogue()
+}
+
+func (m *MongoService) ParamGet(req dto.MongoParamGetReq) ([]db.MongoParam, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetParameters(req.Names)
+}
+
+// ParamSet 修改参数, 只允许修改参数目录中的参数, 值按参数类型和范围校验
+func (m *MongoService) ParamSet(operator model.User, req dto.MongoParamSetReq) (dto.MongoParamSetResp, error) {
+	resp := dto.MongoParamSetResp{Name: req.Name, DryRun: req.DryRun}
+
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return resp, err
+	}
+
+	spec, err := db.LookupMongoParam(req.Name)
+	if err != nil {
+		return resp, err
+	}
+	if resp.New, err = spec.Parse(req.Value); err != nil {
+		return resp, err
+	}
+
+	params, err := client.GetParameters([]string{req.Name})
+	if err != nil {
+		return resp, err
+	}
+	if !params[0].Supported {
+		return resp, fmt.Errorf("获取参数: %s 失败: %s", req.Name, params[0].Error)
+	}
+	resp.Old = params[0].Value
+	resp.Changed = resp.Old != resp.New
+
+	if req.DryRun || !resp.Changed {
+		return resp, nil
+	}
+
+	_, _, err = client.SetParameter(req.Name, req.Value)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "param.set", req.Name, resp, err)
+	return resp, err
+}
+
+// ParamDiff 对比单个实例的参数与所属环境的基线
+func (m *MongoService) ParamDiff(req dto.MongoInstanceReq) (dto.MongoParamDiffReport, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return dto.MongoParamDiffReport{}, err
+	}
+
+	env := instanceEnv(req.Instance)
+	baseline, ok := config.GlobalConfig.MongoAdmin.Baseline[env]
+	if !ok {
+		return dto.MongoParamDiffReport{}, fmt.Errorf("环境: %s 没有配置参数基线", env)
+	}
+
+	names := make([]string, 0, len(baseline))
+	for name := range baseline {
+		names = append(names, name)
+	}
+	params, err := client.GetParameters(names)
+	if err != nil {
+		return dto.MongoParamDiffReport{}, err
+	}
+
+	diffs, err := db.DiffMongoParams(params, baseline)
+	if err != nil {
+		return dto.MongoParamDiffReport{}, err
+	}
+	return dto.MongoParamDiffReport{Instance: req.Instance, Env: env, Diffs: diffs}, nil
+}
+
+// ParamDiffAll 对比所有配置了基线的实例, 单个实例失败不影响其他实例
+func (m *MongoService) ParamDiffAll() []dto.MongoParamDiffReport {
+	instances := make([]string, 0, len(config.GlobalConfig.Mongo))
+	for name := range config.GlobalConfig.Mongo {
+		instances = append(instances, name)
+	}
+	sort.Strings(instances)
+
+	reports := []dto.MongoParamDiffReport{}
+	for _, name := range instances {
+		env := instanceEnv(name)
+		if _, ok := config.GlobalConfig.MongoAdmin.Baseline[env]; !ok {
+			continue
+		}
+
+		report, err := m.ParamDiff(dto.MongoInstanceReq{Instance: name})
+		if err != nil {
+			report = dto.MongoParamDiffReport{Instance: name, Env: env, Diffs: []db.MongoParamDiff{}, Error: err.Error()}
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// instanceEnv 实例所属环境, 实例没有配置时使用服务所在环境
+func instanceEnv(instance string) string {
+	if conf, ok := config.GlobalConfig.Mongo[instance]; ok && conf.Env != "" {
+		return conf.Env
+	}
+	return config.GlobalConfig.Server.Env
+}
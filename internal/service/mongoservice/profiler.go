@@ -0,0 +1,327 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-11 14:22:36
+ */
+
+package mongoservice
+
+import (
+	"errors"
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/cron"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultProfileHarvestLimit = 1000
+	defaultSlowQueryLimit      = 100
+	maxSlowQueryShapes         = 10000 // 内存中最多保存的查询模式数, 超过时淘汰最久没有出现的
+)
+
+// 不采集的系统库
+var profileSkipDatabases = map[string]bool{"admin": true, "local": true, "config": true}
+
+// profileStore 慢查询统计, 保存在内存中, 重启后丢失
+type profileStore struct {
+	mu         sync.Mutex
+	harvesting sync.Mutex
+	shapes     map[string]*dto.MongoSlowQuery
+	watermarks map[string]profileMark // 实例名.库名 => 采集水位
+}
+
+// profileMark 已经采集的记录中最大的 ts, 以及最后采集的记录在 system.profile 中的 $recordId
+type profileMark struct {
+	ts       time.Time
+	recordID int64
+}
+
+var profiles = &profileStore{
+	shapes:     make(map[string]*dto.MongoSlowQuery),
+	watermarks: make(map[string]profileMark),
+}
+
+func (s *profileStore) watermark(instance, dbname string) profileMark {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.watermarks[instance+"."+dbname]
+}
+
+// add 把采集到的记录合并到统计中, 并推进水位. entries 需要按插入顺序排列, 并且都在上次的水位之后
+func (s *profileStore) add(instance, dbname string, entries []db.ProfileEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		shape := db.QueryShape(entry)
+		key := instance + "\x00" + entry.Ns + "\x00" + shape
+		q, ok := s.shapes[key]
+		if !ok {
+			q = &dto.MongoSlowQuery{Instance: instance, Namespace: entry.Ns, Op: entry.Op, Shape: shape, FirstSeen: entry.Ts}
+			s.shapes[key] = q
+		}
+
+		q.Count++
+		q.TotalMillis += entry.Millis
+		q.AvgMillis = float64(q.TotalMillis) / float64(q.Count)
+		if entry.Millis > q.MaxMillis {
+			q.MaxMillis = entry.Millis
+		}
+		q.DocsExamined += entry.DocsExamined
+		q.NReturned += entry.NReturned
+		q.DocsExaminedRatio = float64(q.DocsExamined) / float64(max(q.NReturned, 1))
+		if entry.PlanSummary != "" {
+			q.PlanSummary = entry.PlanSummary
+		}
+		if entry.Ts.After(q.LastSeen) {
+			q.LastSeen = entry.Ts
+		}
+
+		mark := instance + "." + dbname
+		wm := s.watermarks[mark]
+		if entry.Ts.After(wm.ts) {
+			wm.ts = entry.Ts
+		}
+		wm.recordID = entry.RecordID
+		s.watermarks[mark] = wm
+	}
+	s.evict()
+}
+
+// evict 查询模式过多时淘汰最久没有出现的
+func (s *profileStore) evict() {
+	if len(s.shapes) <= maxSlowQueryShapes {
+		return
+	}
+
+	keys := make([]string, 0, len(s.shapes))
+	for key := range s.shapes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return s.shapes[keys[i]].LastSeen.Before(s.shapes[keys[j]].LastSeen) })
+	for _, key := range keys[:len(keys)-maxSlowQueryShapes] {
+		delete(s.shapes, key)
+	}
+}
+
+// list 按实例和 namespace 过滤并排序
+func (s *profileStore) list(req dto.MongoSlowQueryReq) []dto.MongoSlowQuery {
+	s.mu.Lock()
+	result := []dto.MongoSlowQuery{}
+	for _, q := range s.shapes {
+		if req.Instance != "" && q.Instance != req.Instance {
+			continue
+		}
+		if req.Namespace != "" && q.Namespace != req.Namespace && !strings.HasPrefix(q.Namespace, req.Namespace+".") {
+			continue
+		}
+		result = append(result, *q)
+	}
+	s.mu.Unlock()
+
+	value := func(q dto.MongoSlowQuery) float64 {
+		switch req.SortBy {
+		case "count":
+			return float64(q.Count)
+		case "avg":
+			return q.AvgMillis
+		case "max":
+			return float64(q.MaxMillis)
+		case "ratio":
+			return q.DocsExaminedRatio
+		}
+		return float64(q.TotalMillis)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		vi, vj := value(result[i]), value(result[j])
+		if vi != vj {
+			return vi > vj
+		}
+		return result[i].Shape < result[j].Shape
+	})
+
+	if req.Limit <= 0 {
+		req.Limit = defaultSlowQueryLimit
+	}
+	if len(result) > req.Limit {
+		result = result[:req.Limit]
+	}
+	return result
+}
+
+// ProfileEnable 开启库的 profiler, 只记录超过 slowms 的慢查询
+func (m *MongoService) ProfileEnable(operator model.User, req dto.MongoProfileReq) (db.ProfileStatus, error) {
+	return m.setProfilingLevel(operator, req, 1, "profile.enable")
+}
+
+// ProfileDisable 关闭库的 profiler, 已经采集的统计保留
+func (m *MongoService) ProfileDisable(operator model.User, req dto.MongoProfileReq) (db.ProfileStatus, error) {
+	return m.setProfilingLevel(operator, req, 0, "profile.disable")
+}
+
+func (m *MongoService) setProfilingLevel(operator model.User, req dto.MongoProfileReq, level int, action string) (db.ProfileStatus, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.ProfileStatus{}, err
+	}
+
+	// mongos 上没有 system.profile, 需要在各分片的 mongod 上开启
+	hello, err := client.Hello()
+	if err != nil {
+		return db.ProfileStatus{}, err
+	}
+	if hello.IsMongos() {
+		return db.ProfileStatus{}, errors.New("mongos 不支持采集慢查询, 请在各分片的 mongod 上开启 profiler")
+	}
+
+	prev, err := client.SetProfilingLevel(req.Database, level, req.Slowms)
+	detail := map[string]any{"level": level, "slowms": req.Slowms, "previous": prev}
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, action, req.Database, detail, err)
+	if err != nil {
+		return prev, err
+	}
+	return client.ProfilingLevel(req.Database)
+}
+
+// ProfileStatus 查看库的 profiler 设置, 不指定库时返回所有非系统库
+func (m *MongoService) ProfileStatus(req dto.MongoProfileStatusReq) ([]dto.MongoProfileStatus, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	dbs := []string{req.Database}
+	if req.Database == "" {
+		if dbs, err = profileDatabases(client); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]dto.MongoProfileStatus, 0, len(dbs))
+	for _, dbname := range dbs {
+		status, err := client.ProfilingLevel(dbname)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, dto.MongoProfileStatus{Database: dbname, ProfileStatus: status})
+	}
+	return result, nil
+}
+
+// SlowQueries 已经采集的慢查询统计
+func (m *MongoService) SlowQueries(req dto.MongoSlowQueryReq) []dto.MongoSlowQuery {
+	return profiles.list(req)
+}
+
+// ProfileHarvest 采集所有实例上开启了 profiler 的库, 单个库失败不影响其他库
+// 上一次采集没有结束时直接返回
+func (m *MongoService) ProfileHarvest() []dto.MongoProfileHarvest {
+	if !profiles.harvesting.TryLock() {
+		return []dto.MongoProfileHarvest{}
+	}
+	defer profiles.harvesting.Unlock()
+
+	instances := make([]string, 0, len(config.GlobalConfig.Mongo))
+	for name := range config.GlobalConfig.Mongo {
+		instances = append(instances, name)
+	}
+	sort.Strings(instances)
+
+	limit := config.GlobalConfig.MongoAdmin.ProfileHarvestLimit
+	if limit <= 0 {
+		limit = defaultProfileHarvestLimit
+	}
+
+	results := []dto.MongoProfileHarvest{}
+	for _, name := range instances {
+		results = append(results, m.harvestInstance(name, limit)...)
+	}
+	return results
+}
+
+func (m *MongoService) harvestInstance(instance string, limit int64) []dto.MongoProfileHarvest {
+	client, err := m.client(instance)
+	if err == nil {
+		var hello db.HelloResult
+		if hello, err = client.Hello(); err == nil && hello.IsMongos() {
+			return nil
+		}
+	}
+
+	var dbs []string
+	if err == nil {
+		dbs, err = profileDatabases(client)
+	}
+	if err != nil {
+		return []dto.MongoProfileHarvest{{Instance: instance, Error: err.Error()}}
+	}
+
+	results := []dto.MongoProfileHarvest{}
+	for _, dbname := range dbs {
+		result := dto.MongoProfileHarvest{Instance: instance, Database: dbname}
+
+		status, err := client.ProfilingLevel(dbname)
+		if err == nil && status.Was == 0 {
+			continue
+		}
+
+		var entries []db.ProfileEntry
+		if err == nil {
+			mark := profiles.watermark(instance, dbname)
+			entries, err = client.ProfileEntries(dbname, mark.ts, mark.recordID, limit)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			profiles.add(instance, dbname, entries)
+			result.Entries = len(entries)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func profileDatabases(client *db.MongoDBClient) ([]string, error) {
+	names, err := client.GetDBList()
+	if err != nil {
+		return nil, err
+	}
+
+	dbs := make([]string, 0, len(names))
+	for _, name := range names {
+		if !profileSkipDatabases[name] {
+			dbs = append(dbs, name)
+		}
+	}
+	return dbs, nil
+}
+
+// SetupProfilerHarvest 按配置的 cron 表达式定时采集慢查询
+func SetupProfilerHarvest() error {
+	spec := config.GlobalConfig.MongoAdmin.ProfileHarvest
+	if spec == "" {
+		return nil
+	}
+
+	_, err := cron.Cron.AddFunc(spec, func() {
+		for _, result := range NewMongoService().ProfileHarvest() {
+			if result.Error != "" {
+				zap.L().Warn("采集 mongodb 慢查询失败", zap.String("instance", result.Instance), zap.String("database", result.Database), zap.String("error", result.Error))
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("mongodb_admin.profile_harvest 配置错误: %v", err)
+	}
+	cron.Cron.Start()
+	return nil
+}
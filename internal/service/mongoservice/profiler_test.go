@@ -0,0 +1,85 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-12 10:18:33
+ */
+
+package mongoservice
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestProfileWatermark(t *testing.T) {
+	t1 := time.Date(2024, 4, 12, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Millisecond)
+	var profile []db.ProfileEntry
+	for i := 0; i < 5; i++ {
+		profile = append(profile, db.ProfileEntry{Op: "query", Ns: "app.c", Millis: int64(i + 1), Ts: t1})
+	}
+	profile = append(profile, db.ProfileEntry{Op: "query", Ns: "app.c", Millis: 10, Ts: t2}, db.ProfileEntry{Op: "query", Ns: "app.c", Millis: 20, Ts: t2})
+	for i := range profile {
+		profile[i].Command = bson.D{{Key: "find", Value: "c"}}
+		profile[i].RecordID = int64(i + 1)
+	}
+
+	// 与 ProfileEntries 的查询相同: ts >= since, 按插入顺序, 跳过水位之前的记录, 最多 limit 条
+	query := func(since time.Time, after int64, limit int) []db.ProfileEntry {
+		var result []db.ProfileEntry
+		for _, e := range profile {
+			if len(result) < limit && !e.Ts.Before(since) && db.ProfileEntryAfter(e, since, after) {
+				result = append(result, e)
+			}
+		}
+		return result
+	}
+
+	store := &profileStore{shapes: map[string]*dto.MongoSlowQuery{}, watermarks: map[string]profileMark{}}
+	harvest := func() int {
+		mark := store.watermark("m", "app")
+		entries := query(mark.ts, mark.recordID, 3)
+		store.add("m", "app", entries)
+		return len(entries)
+	}
+
+	// 每次最多 3 条, 第一次在 t1 的 5 条记录中间截断
+	for i, want := range []int{3, 3, 1, 0, 0} {
+		if got := harvest(); got != want {
+			t.Fatalf("harvest %d got %d entries, want %d", i, got, want)
+		}
+	}
+	if len(store.shapes) != 1 {
+		t.Fatalf("got %d shapes", len(store.shapes))
+	}
+	for _, q := range store.shapes {
+		if q.Count != 7 || q.TotalMillis != 45 || q.MaxMillis != 20 {
+			t.Errorf("got %+v", q)
+		}
+	}
+	if mark := store.watermark("m", "app"); !mark.ts.Equal(t2) || mark.recordID != 7 {
+		t.Errorf("watermark got %+v", mark)
+	}
+
+	// 水位时刻之后又产生了同一毫秒的记录, 以及 ts 更早但插入更晚的记录(ts 早于水位, 不再采集)
+	profile = append(profile,
+		db.ProfileEntry{Op: "query", Ns: "app.c", Millis: 5, Ts: t2, RecordID: 8, Command: bson.D{{Key: "find", Value: "c"}}},
+		db.ProfileEntry{Op: "query", Ns: "app.c", Millis: 5, Ts: t1, RecordID: 9, Command: bson.D{{Key: "find", Value: "c"}}},
+	)
+	if got := harvest(); got != 1 {
+		t.Errorf("new entry at boundary got %d", got)
+	}
+
+	// system.profile 重建后 $recordId 从头开始, ts 更新的记录仍然采集
+	t3 := t2.Add(time.Second)
+	profile = []db.ProfileEntry{{Op: "query", Ns: "app.c", Millis: 1, Ts: t3, RecordID: 1, Command: bson.D{{Key: "find", Value: "c"}}}}
+	if got := harvest(); got != 1 {
+		t.Errorf("recreated profile got %d", got)
+	}
+	if mark := store.watermark("m", "app"); !mark.ts.Equal(t3) || mark.recordID != 1 {
+		t.Errorf("watermark after recreate got %+v", mark)
+	}
+}
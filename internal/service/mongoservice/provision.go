@@ -0,0 +1,357 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-13 09:48:51
+ */
+
+package mongoservice
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"myadmin/internal/task"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	provisionPasswordLength = 24
+	passwordChars           = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+)
+
+// 不允许为业务创建的系统库
+var systemDatabases = map[string]bool{"admin": true, "local": true, "config": true}
+
+// undoStack 记录已经完成的步骤的回滚操作, 失败时倒序执行
+type undoStack []struct {
+	name string
+	fn   func() error
+}
+
+func (u *undoStack) push(name string, fn func() error) {
+	*u = append(*u, struct {
+		name string
+		fn   func() error
+	}{name, fn})
+}
+
+// rollback 倒序执行回滚, 单个回滚失败时记录日志并继续
+func (u undoStack) rollback(t *task.Task) error {
+	return t.Step("rollback", func() error {
+		var failed int
+		for i := len(u) - 1; i >= 0; i-- {
+			if err := u[i].fn(); err != nil {
+				failed++
+				t.Logf("回滚 %s 失败: %v", u[i].name, err)
+				continue
+			}
+			t.Logf("回滚 %s 完成", u[i].name)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d 个操作回滚失败, 需要手动处理", failed)
+		}
+		return nil
+	})
+}
+
+// Provision 为业务创建库: 创建集合, mongos 上启用分片并对集合分片, 为每个集合创建读写角色, 创建拥有这些角色的业务用户
+// 已经存在的库, 集合, 角色, 用户直接使用, 可以重复执行; 失败时回滚本次新建的对象
+func (m *MongoService) Provision(operator model.User, req dto.MongoProvisionReq) (dto.MongoProvisionResp, error) {
+	if err := validateProvisionReq(req); err != nil {
+		return dto.MongoProvisionResp{}, err
+	}
+
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return dto.MongoProvisionResp{}, err
+	}
+
+	hello, err := client.Hello()
+	if err != nil {
+		return dto.MongoProvisionResp{}, err
+	}
+	mongos := hello.IsMongos()
+	if !mongos {
+		for _, col := range req.Collections {
+			if len(col.ShardKey) > 0 {
+				return dto.MongoProvisionResp{}, fmt.Errorf("实例 %s 不是 mongos, 集合 %s 不能分片", req.Instance, col.Name)
+			}
+		}
+	}
+
+	// 密码在提交任务前生成, 只通过本次接口返回, 不写入任务结果和审计日志
+	userExist, err := client.UserExist(req.Username, req.Database)
+	if err != nil {
+		return dto.MongoProvisionResp{}, err
+	}
+	resp := dto.MongoProvisionResp{Username: req.Username}
+	if !userExist {
+		if resp.Password, err = generatePassword(provisionPasswordLength); err != nil {
+			return dto.MongoProvisionResp{}, err
+		}
+	}
+
+	t := task.Tasks.Submit("provision", AuditModule, req.Instance, operator.Username, func(t *task.Task) error {
+		err := provision(t, client, req, mongos, resp.Password)
+		auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "provision", req.Database+"."+req.Username, map[string]any{"task_id": t.ID, "request": req}, err)
+		return err
+	})
+	resp.Task = t.Snapshot()
+	return resp, nil
+}
+
+func provision(t *task.Task, client *db.MongoDBClient, req dto.MongoProvisionReq, mongos bool, password string) error {
+	var undo undoStack
+	steps := []func() error{
+		func() error { return provisionDatabase(t, client, req, mongos, &undo) },
+		func() error { return provisionCollections(t, client, req, mongos, &undo) },
+		func() error { return provisionRoles(t, client, req, &undo) },
+		func() error { return provisionUser(t, client, req, password, &undo) },
+	}
+
+	for i, step := range steps {
+		if err := step(); err != nil {
+			if rbErr := undo.rollback(t); rbErr != nil {
+				return fmt.Errorf("%v, %v", err, rbErr)
+			}
+			return err
+		}
+		t.SetProgress(float64(i+1) * 100 / float64(len(steps)))
+	}
+
+	roles := make([]string, 0, len(req.Collections))
+	for _, col := range req.Collections {
+		roles = append(roles, provisionRoleName(col.Name))
+	}
+	t.SetResult(map[string]any{"database": req.Database, "username": req.Username, "roles": roles})
+	return nil
+}
+
+// provisionDatabase 检查库是否存在, mongos 上启用分片. 本次新建的库回滚时整个删除
+func provisionDatabase(t *task.Task, client *db.MongoDBClient, req dto.MongoProvisionReq, mongos bool, undo *undoStack) error {
+	return t.Step("database "+req.Database, func() error {
+		exist, err := client.DBExist(req.Database)
+		if err != nil {
+			return err
+		}
+		if exist {
+			t.Logf("库 %s 已经存在", req.Database)
+		} else {
+			undo.push("drop database "+req.Database, func() error { return client.DropDatabase(req.Database) })
+		}
+
+		if mongos {
+			if err := client.EnableSharding(req.Database); err != nil {
+				return fmt.Errorf("库 %s 启用分片失败: %v", req.Database, err)
+			}
+			t.Logf("库 %s 已启用分片", req.Database)
+		}
+		return nil
+	})
+}
+
+// provisionCollections 创建集合, 有片键的集合执行分片. 已经分片的集合片键必须一致
+// 已经存在的集合分片后无法回滚, 只记录日志
+func provisionCollections(t *task.Task, client *db.MongoDBClient, req dto.MongoProvisionReq, mongos bool, undo *undoStack) error {
+	for _, col := range req.Collections {
+		err := t.Step("collection "+col.Name, func() error {
+			exist, err := client.ColExist(req.Database, col.Name)
+			if err != nil {
+				return err
+			}
+			if exist {
+				t.Logf("集合 %s.%s 已经存在", req.Database, col.Name)
+			} else {
+				if err := client.CreateCollection(req.Database, col.Name); err != nil {
+					return err
+				}
+				t.Logf("集合 %s.%s 创建完成", req.Database, col.Name)
+				undo.push("drop collection "+col.Name, func() error { return client.DropCollection(req.Database, col.Name) })
+			}
+
+			if !mongos || len(col.ShardKey) == 0 {
+				return nil
+			}
+
+			keyType := col.KeyType
+			if keyType == "" {
+				keyType = "hashed"
+			}
+			sharded, err := client.ShardedCollection(req.Database, col.Name)
+			if err != nil {
+				return err
+			}
+			if sharded != nil {
+				if !sameShardKey(sharded.Key, col.ShardKey, keyType) {
+					return fmt.Errorf("集合 %s.%s 已经分片, 片键 %v 与请求的片键不一致", req.Database, col.Name, sharded.Key)
+				}
+				t.Logf("集合 %s.%s 已经分片", req.Database, col.Name)
+				return nil
+			}
+
+			if err := client.ShardCollection(req.Database, col.Name, keyType, col.ShardKey, col.Unique); err != nil {
+				return err
+			}
+			if exist {
+				t.Logf("集合 %s.%s 分片完成, 已有集合的分片在后续步骤失败时无法回滚", req.Database, col.Name)
+			} else {
+				t.Logf("集合 %s.%s 分片完成", req.Database, col.Name)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// provisionRoles 为每个集合创建读写角色, 角色已经存在时直接使用
+func provisionRoles(t *task.Task, client *db.MongoDBClient, req dto.MongoProvisionReq, undo *undoStack) error {
+	return t.Step("roles", func() error {
+		for _, col := range req.Collections {
+			role := provisionRoleName(col.Name)
+			exist, err := client.RoleExist(role, req.Database)
+			if err != nil {
+				return err
+			}
+			if exist {
+				t.Logf("角色 %s.%s 已经存在", req.Database, role)
+				continue
+			}
+
+			if err := client.CreateRole(role, req.Database, col.Name); err != nil {
+				return err
+			}
+			t.Logf("角色 %s.%s 创建完成", req.Database, role)
+			undo.push("drop role "+role, func() error { return client.DropRole(role, req.Database) })
+		}
+		return nil
+	})
+}
+
+// provisionUser 创建业务用户; 用户已经存在时不修改密码, 只授予缺少的角色
+func provisionUser(t *task.Task, client *db.MongoDBClient, req dto.MongoProvisionReq, password string, undo *undoStack) error {
+	roles := make([]string, 0, len(req.Collections))
+	for _, col := range req.Collections {
+		roles = append(roles, provisionRoleName(col.Name))
+	}
+
+	return t.Step("user "+req.Username, func() error {
+		users, err := client.GetUser(req.Username, req.Database)
+		if err != nil {
+			return err
+		}
+
+		if len(users) == 0 {
+			if password == "" {
+				return errors.New("用户在提交任务后被删除, 请重新执行")
+			}
+			if err := client.CreateUser(req.Username, password, req.Database, roles); err != nil {
+				return err
+			}
+			t.Logf("用户 %s.%s 创建完成, 角色: %v", req.Database, req.Username, roles)
+			undo.push("drop user "+req.Username, func() error { return client.DropUser(req.Username, req.Database) })
+			return nil
+		}
+
+		if password != "" {
+			t.Logf("用户 %s.%s 在提交任务后被创建, 返回的密码无效", req.Database, req.Username)
+		}
+		granted := userRoles(users[0])
+		for _, role := range roles {
+			if granted[role] {
+				continue
+			}
+			if err := client.GrantRolesToUser(req.Username, role, req.Database); err != nil {
+				return err
+			}
+			t.Logf("用户 %s.%s 授予角色 %s", req.Database, req.Username, role)
+			undo.push("revoke role "+role, func() error { return client.RevokeRolesFromUser(req.Username, role, req.Database) })
+		}
+		return nil
+	})
+}
+
+// userRoles usersInfo 返回的用户在本库中拥有的角色
+func userRoles(user any) map[string]bool {
+	roles := make(map[string]bool)
+	doc, ok := user.(bson.M)
+	if !ok {
+		return roles
+	}
+	list, _ := doc["roles"].(bson.A)
+	for _, item := range list {
+		if role, ok := item.(bson.M); ok && role["db"] == doc["db"] {
+			if name, ok := role["role"].(string); ok {
+				roles[name] = true
+			}
+		}
+	}
+	return roles
+}
+
+func provisionRoleName(colName string) string {
+	return "rw_" + colName
+}
+
+func validateProvisionReq(req dto.MongoProvisionReq) error {
+	if systemDatabases[req.Database] {
+		return fmt.Errorf("不能在系统库 %s 中创建业务对象", req.Database)
+	}
+	if len(req.Collections) == 0 {
+		return errors.New("至少需要一个集合")
+	}
+
+	names := make(map[string]bool)
+	for _, col := range req.Collections {
+		if names[col.Name] {
+			return fmt.Errorf("集合 %s 重复", col.Name)
+		}
+		names[col.Name] = true
+
+		switch col.KeyType {
+		case "", "hashed", "ranged":
+		default:
+			return fmt.Errorf("集合 %s 的片键类型 %s 不正确, 只支持 hashed 和 ranged", col.Name, col.KeyType)
+		}
+		if (col.KeyType == "" || col.KeyType == "hashed") && len(col.ShardKey) > 1 {
+			return fmt.Errorf("集合 %s 的 hashed 片键只能有一个字段", col.Name)
+		}
+	}
+	return nil
+}
+
+// sameShardKey 判断已有的片键与请求的片键是否一致
+func sameShardKey(key bson.D, fields []string, keyType string) bool {
+	if len(key) != len(fields) {
+		return false
+	}
+	for i, e := range key {
+		if e.Key != fields[i] {
+			return false
+		}
+		if hashed := e.Value == "hashed"; hashed != (keyType == "hashed") {
+			return false
+		}
+	}
+	return true
+}
+
+// generatePassword 生成由大小写字母和数字组成的随机密码, 去掉了容易混淆的字符
+func generatePassword(n int) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(passwordChars))))
+		if err != nil {
+			return "", fmt.Errorf("生成密码失败: %v", err)
+		}
+		b[i] = passwordChars[idx.Int64()]
+	}
+	return string(b), nil
+}
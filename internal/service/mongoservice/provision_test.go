@@ -0,0 +1,73 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-13 15:20:37
+ */
+
+package mongoservice
+
+import (
+	"myadmin/internal/dto"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidateProvisionReq(t *testing.T) {
+	col := func(name, keyType string, keys ...string) dto.MongoProvisionCollection {
+		return dto.MongoProvisionCollection{Name: name, KeyType: keyType, ShardKey: keys}
+	}
+
+	tests := []struct {
+		name    string
+		req     dto.MongoProvisionReq
+		wantErr bool
+	}{
+		{name: "ok", req: dto.MongoProvisionReq{Database: "app", Collections: []dto.MongoProvisionCollection{col("a", ""), col("b", "ranged", "x", "y"), col("c", "hashed", "x")}}},
+		{name: "system database", req: dto.MongoProvisionReq{Database: "admin", Collections: []dto.MongoProvisionCollection{col("a", "")}}, wantErr: true},
+		{name: "no collection", req: dto.MongoProvisionReq{Database: "app"}, wantErr: true},
+		{name: "duplicate collection", req: dto.MongoProvisionReq{Database: "app", Collections: []dto.MongoProvisionCollection{col("a", ""), col("a", "")}}, wantErr: true},
+		{name: "bad key type", req: dto.MongoProvisionReq{Database: "app", Collections: []dto.MongoProvisionCollection{col("a", "range", "x")}}, wantErr: true},
+		{name: "compound hashed", req: dto.MongoProvisionReq{Database: "app", Collections: []dto.MongoProvisionCollection{col("a", "", "x", "y")}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := validateProvisionReq(tt.req); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSameShardKey(t *testing.T) {
+	hashed := bson.D{{Key: "uid", Value: "hashed"}}
+	ranged := bson.D{{Key: "uid", Value: int32(1)}, {Key: "ts", Value: int32(1)}}
+
+	if !sameShardKey(hashed, []string{"uid"}, "hashed") {
+		t.Error("hashed key should match")
+	}
+	if sameShardKey(hashed, []string{"uid"}, "ranged") {
+		t.Error("hashed key should not match ranged")
+	}
+	if !sameShardKey(ranged, []string{"uid", "ts"}, "ranged") {
+		t.Error("ranged key should match")
+	}
+	if sameShardKey(ranged, []string{"ts", "uid"}, "ranged") {
+		t.Error("field order should matter")
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	a, err := generatePassword(24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := generatePassword(24)
+	if len(a) != 24 || a == b {
+		t.Errorf("unexpected passwords %q %q", a, b)
+	}
+	for _, c := range a {
+		if !strings.ContainsRune(passwordChars, c) {
+			t.Errorf("unexpected char %q", c)
+		}
+	}
+}
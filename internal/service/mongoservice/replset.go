@@ -0,0 +1,97 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-30 14:26:51
+ */
+
+package mongoservice
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+)
+
+func (m *MongoService) ReplSetConfig(req dto.MongoInstanceReq) (db.ReplSetConfig, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.ReplSetConfig{}, err
+	}
+	return client.GetReplSetConfig()
+}
+
+// ReplSetAdd 增加数据节点或仲裁节点
+func (m *MongoService) ReplSetAdd(operator model.User, req dto.MongoRSAddReq) (db.ReplSetConfig, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.ReplSetConfig{}, err
+	}
+
+	var conf db.ReplSetConfig
+	action := "replset.add"
+	if req.Arbiter {
+		action = "replset.add_arbiter"
+		conf, err = client.ReplSetAddArbiter(req.Host, req.AllowEvenVoters)
+	} else {
+		member := db.ReplSetMember{Host: req.Host, Hidden: req.Hidden, Priority: 1, Votes: 1}
+		if req.Hidden || req.SecondaryDelaySecs > 0 {
+			member.Priority = 0
+		}
+		if req.SecondaryDelaySecs > 0 {
+			member.SetDelay(req.SecondaryDelaySecs)
+		}
+		if req.Votes != nil {
+			member.Votes = *req.Votes
+			if member.Votes == 0 {
+				member.Priority = 0
+			}
+		}
+		if req.Priority != nil {
+			member.Priority = *req.Priority
+		}
+		conf, err = client.ReplSetAddMember(member, req.AllowEvenVoters)
+	}
+
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, action, req.Host, req, err)
+	return conf, err
+}
+
+func (m *MongoService) ReplSetRemove(operator model.User, req dto.MongoRSRemoveReq) (db.ReplSetConfig, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.ReplSetConfig{}, err
+	}
+
+	conf, err := client.ReplSetRemoveMember(req.Host, req.AllowEvenVoters)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "replset.remove", req.Host, req, err)
+	return conf, err
+}
+
+// ReplSetUpdateMember 修改成员的 priority, votes, hidden, 延迟秒数
+func (m *MongoService) ReplSetUpdateMember(operator model.User, req dto.MongoRSMemberUpdateReq) (db.ReplSetConfig, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.ReplSetConfig{}, err
+	}
+
+	change := db.ReplSetMemberChange{
+		Priority:           req.Priority,
+		Votes:              req.Votes,
+		Hidden:             req.Hidden,
+		SecondaryDelaySecs: req.SecondaryDelaySecs,
+	}
+	conf, err := client.ReplSetUpdateMember(req.Host, change, req.AllowEvenVoters)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "replset.update_member", req.Host, req, err)
+	return conf, err
+}
+
+func (m *MongoService) ReplSetStepDown(operator model.User, req dto.MongoRSStepDownReq) error {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	err = client.ReplSetStepDown(req.StepDownSecs, req.CatchUpSecs)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "replset.stepdown", req.Instance, req, err)
+	return err
+}
@@ -0,0 +1,110 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-17 15:08:52
+ */
+
+package mongoservice
+
+import (
+	"fmt"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+)
+
+// mongosClient 获取连接, 实例必须是 mongos
+func (m *MongoService) mongosClient(instance string) (*db.MongoDBClient, error) {
+	client, err := m.client(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	hello, err := client.Hello()
+	if err != nil {
+		return nil, err
+	}
+	if !hello.IsMongos() {
+		return nil, fmt.Errorf("实例: %s 不是 mongos, 只支持分片集群", instance)
+	}
+	return client, nil
+}
+
+func (m *MongoService) ShardOverview(req dto.MongoInstanceReq) (db.ShardOverview, error) {
+	client, err := m.mongosClient(req.Instance)
+	if err != nil {
+		return db.ShardOverview{}, err
+	}
+	return client.ShardOverview()
+}
+
+// Chunks 集合的 chunk 分布, 指定集合时同时返回 jumbo chunk
+func (m *MongoService) Chunks(req dto.MongoChunkReq) (dto.MongoChunkResp, error) {
+	resp := dto.MongoChunkResp{JumboChunks: []db.Chunk{}}
+
+	client, err := m.mongosClient(req.Instance)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.Distributions, err = client.ChunkDistributions(req.Namespace); err != nil {
+		return resp, err
+	}
+	if req.Namespace != "" {
+		if resp.JumboChunks, err = client.JumboChunks(req.Namespace); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+func (m *MongoService) BalancerStart(operator model.User, req dto.MongoInstanceReq) (db.BalancerStatus, error) {
+	client, err := m.mongosClient(req.Instance)
+	if err != nil {
+		return db.BalancerStatus{}, err
+	}
+
+	err = client.BalancerStart()
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "balancer.start", "", nil, err)
+	if err != nil {
+		return db.BalancerStatus{}, err
+	}
+	return client.BalancerStatus()
+}
+
+func (m *MongoService) BalancerStop(operator model.User, req dto.MongoInstanceReq) (db.BalancerStatus, error) {
+	client, err := m.mongosClient(req.Instance)
+	if err != nil {
+		return db.BalancerStatus{}, err
+	}
+
+	err = client.BalancerStop()
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "balancer.stop", "", nil, err)
+	if err != nil {
+		return db.BalancerStatus{}, err
+	}
+	return client.BalancerStatus()
+}
+
+// BalancerWindow 设置均衡器的运行时间窗口, 开始和结束时间都为空时删除时间窗口
+func (m *MongoService) BalancerWindow(operator model.User, req dto.MongoBalancerWindowReq) (db.BalancerStatus, error) {
+	client, err := m.mongosClient(req.Instance)
+	if err != nil {
+		return db.BalancerStatus{}, err
+	}
+
+	var window *db.BalancerWindow
+	if req.Start != "" || req.Stop != "" {
+		window = &db.BalancerWindow{Start: req.Start, Stop: req.Stop}
+		if err := db.ValidateBalancerWindow(*window); err != nil {
+			return db.BalancerStatus{}, err
+		}
+	}
+
+	err = client.SetBalancerWindow(window)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "balancer.window", "", window, err)
+	if err != nil {
+		return db.BalancerStatus{}, err
+	}
+	return client.BalancerStatus()
+}
@@ -0,0 +1,21 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-19 15:52:30
+ */
+
+package mongoservice
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+)
+
+// CollectionStats 分页返回集合的大小和索引使用情况, 标记没有使用和冗余的索引
+// 副本集的索引访问次数只统计当前连接的节点, 判断索引是否可以删除时需要结合从库的查询情况
+func (m *MongoService) CollectionStats(req dto.MongoCollectionStatsReq) (db.CollectionStatsPage, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.CollectionStatsPage{}, err
+	}
+	return client.CollectionStatsPage(req.Database, req.Page, req.PageSize)
+}
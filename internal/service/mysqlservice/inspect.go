@@ -0,0 +1,121 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-29 18:10:47
+ */
+
+package mysqlservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"time"
+)
+
+const (
+	defaultStatusIntervalSec = 1
+	maxStatusIntervalSec     = 10
+)
+
+func (m *MysqlService) Processlist(req dto.MysqlProcesslistReq) ([]db.MysqlProcess, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	return client.Processlist(req.MysqlProcessFilter, config.GlobalConfig.MysqlAdmin.KillProtectedUsers)
+}
+
+func (m *MysqlService) Variables(req dto.MysqlVariablesReq) ([]db.MysqlVariable, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	return client.GlobalVariables(req.Like)
+}
+
+func (m *MysqlService) Status(req dto.MysqlVariablesReq) ([]db.MysqlVariable, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	return client.GlobalStatus(req.Like)
+}
+
+// StatusRates 间隔 interval_sec 秒采集两次 global status, 计算累计值的每秒速率
+func (m *MysqlService) StatusRates(req dto.MysqlStatusRatesReq) (db.MysqlStatusRates, error) {
+	interval := req.IntervalSec
+	if interval <= 0 {
+		interval = defaultStatusIntervalSec
+	}
+	if interval > maxStatusIntervalSec {
+		return db.MysqlStatusRates{}, fmt.Errorf("采集间隔不能超过 %d 秒", maxStatusIntervalSec)
+	}
+
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.MysqlStatusRates{}, err
+	}
+
+	prev, err := client.StatusSample()
+	if err != nil {
+		return db.MysqlStatusRates{}, err
+	}
+	time.Sleep(time.Duration(interval) * time.Second)
+	cur, err := client.StatusSample()
+	if err != nil {
+		return db.MysqlStatusRates{}, err
+	}
+	return db.StatusRates(prev, cur, req.Prefix), nil
+}
+
+func (m *MysqlService) InnodbStatus(req dto.MysqlInstanceReq) (db.MysqlInnodbStatus, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.MysqlInnodbStatus{}, err
+	}
+	return client.InnodbStatus()
+}
+
+// Kill kill 单个连接或者连接正在执行的语句, 系统线程, 复制线程和受保护用户的连接不允许 kill
+func (m *MysqlService) Kill(operator model.User, req dto.MysqlKillReq) (db.MysqlProcess, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.MysqlProcess{}, err
+	}
+
+	process, err := client.Kill(req.ID, req.Query, config.GlobalConfig.MysqlAdmin.KillProtectedUsers)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, killAction(req.Query), fmt.Sprint(req.ID), process, err)
+	return process, err
+}
+
+// KillMatching kill 所有匹配条件的连接, 没有确认时只返回将要 kill 的连接
+func (m *MysqlService) KillMatching(operator model.User, req dto.MysqlKillMatchingReq) (db.MysqlKillResult, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.MysqlKillResult{}, err
+	}
+
+	result, err := client.KillMatching(req.MysqlProcessFilter, req.Query, !req.Confirm, config.GlobalConfig.MysqlAdmin.KillProtectedUsers)
+	killed := make([]int64, 0, len(result.Killed))
+	for _, p := range result.Killed {
+		killed = append(killed, p.ID)
+	}
+	// 预览时没有 kill 任何连接, 使用单独的 action 记录将要 kill 的连接
+	action, key := killAction(req.Query)+"_matching", "killed"
+	if !req.Confirm {
+		action, key = action+"_preview", "would_kill"
+	}
+	detail := map[string]any{"request": req, "dry_run": !req.Confirm, key: killed, "skipped": len(result.Skipped), "failed": result.Failed}
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, action, fmt.Sprintf("%+v", req.MysqlProcessFilter), detail, err)
+	return result, err
+}
+
+func killAction(query bool) string {
+	if query {
+		return "process.kill_query"
+	}
+	return "process.kill"
+}
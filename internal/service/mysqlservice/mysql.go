@@ -0,0 +1,37 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-29 18:02:26
+ */
+
+package mysqlservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+)
+
+const AuditModule = "mysql"
+
+type MysqlService struct{}
+
+func NewMysqlService() *MysqlService {
+	return &MysqlService{}
+}
+
+// client 根据配置文件中的实例名获取 mysql 连接
+func (m *MysqlService) client(instance string) (*db.MysqlClient, error) {
+	cfg, ok := config.GlobalConfig.DB[instance]
+	if !ok {
+		return nil, fmt.Errorf("mysql 实例: %s 不存在", instance)
+	}
+	if cfg.Dialect != "mysql" {
+		return nil, fmt.Errorf("实例: %s 不是 mysql, 而是 %s", instance, cfg.Dialect)
+	}
+
+	client, ok := db.DB(instance).(*db.MysqlClient)
+	if !ok || client == nil || client.Conn() == nil {
+		return nil, fmt.Errorf("mysql 实例: %s 连接不可用", instance)
+	}
+	return client, nil
+}
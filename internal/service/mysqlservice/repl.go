@@ -0,0 +1,359 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-30 15:12:09
+ */
+
+package mysqlservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReplMaxLagSeconds = 30
+	defaultReplMaxNodes      = 32
+	maxReplMaxNodes          = 128
+	// 连接自动发现的节点的超时时间
+	replProbeTimeout = 3 * time.Second
+)
+
+// ReplNode 读取实例的复制状态: 上游复制通道, 下游从库和 gtid
+func (m *MysqlService) ReplNode(req dto.MysqlInstanceReq) (db.MysqlReplNode, error) {
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return db.MysqlReplNode{}, err
+	}
+
+	node, err := client.ReplNode()
+	node.Addr = db.MysqlAddr(config.GlobalConfig.DB[req.Instance])
+	return node, err
+}
+
+// ReplTopology 从实例开始发现复制拓扑, 生成复制树并检查健康状态
+func (m *MysqlService) ReplTopology(req dto.MysqlReplTopologyReq) (dto.MysqlReplTopology, error) {
+	maxNodes := req.MaxNodes
+	if maxNodes <= 0 {
+		maxNodes = defaultReplMaxNodes
+	}
+	if maxNodes > maxReplMaxNodes {
+		return dto.MysqlReplTopology{}, fmt.Errorf("最多发现 %d 个节点", maxReplMaxNodes)
+	}
+	maxLag := req.MaxLagSeconds
+	if maxLag <= 0 {
+		maxLag = defaultReplMaxLagSeconds
+	}
+
+	client, err := m.client(req.Instance)
+	if err != nil {
+		return dto.MysqlReplTopology{}, err
+	}
+
+	cfg := config.GlobalConfig.DB[req.Instance]
+	start := db.MysqlAddr(cfg)
+	nodes, truncated := db.DiscoverReplTopology(start, maxNodes, func(addr string) (db.MysqlReplNode, error) {
+		if addr == start {
+			return client.ReplNode()
+		}
+		if !replProbeAllowed(addr, configuredMysqlAddrs(), config.GlobalConfig.MysqlAdmin.ReplProbeHosts) {
+			return db.MysqlReplNode{}, fmt.Errorf("%s 不是配置中的实例, 也不在 repl_probe_hosts 中, 不连接", addr)
+		}
+		probe, err := db.NewMysqlProbeClient(cfg, addr, replProbeTimeout)
+		if err != nil {
+			return db.MysqlReplNode{}, err
+		}
+		defer probe.Close()
+		return probe.ReplNode()
+	})
+	if len(nodes) > 0 && nodes[0].Error != "" {
+		return dto.MysqlReplTopology{}, fmt.Errorf("读取实例 %s 的复制状态失败: %s", req.Instance, nodes[0].Error)
+	}
+
+	return dto.MysqlReplTopology{
+		Instance:  req.Instance,
+		Nodes:     nodes,
+		Tree:      buildReplTree(nodes),
+		Truncated: truncated,
+		Health:    evaluateReplHealth(nodes, maxLag),
+	}, nil
+}
+
+// configuredMysqlAddrs 配置中所有 mysql 实例的地址
+func configuredMysqlAddrs() []string {
+	var addrs []string
+	for _, cfg := range config.GlobalConfig.DB {
+		if cfg != nil && cfg.Dialect == "mysql" {
+			addrs = append(addrs, db.MysqlAddr(cfg))
+		}
+	}
+	return addrs
+}
+
+// replProbeAllowed 自动发现的地址是配置中的实例, 或者主机在允许的 ip, 主机名或网段中
+func replProbeAllowed(addr string, configured, allowed []string) bool {
+	for _, c := range configured {
+		if strings.EqualFold(c, addr) {
+			return true
+		}
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	for _, a := range allowed {
+		if strings.EqualFold(a, host) {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(a); err == nil && ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// replGraph 节点之间的复制关系
+type replGraph struct {
+	nodes   []db.MysqlReplNode
+	parents [][]replEdge // 每个节点在已发现节点中的上游
+}
+
+type replEdge struct {
+	parent  int
+	channel db.MysqlReplicaStatus
+}
+
+// newReplGraph 复制通道的上游优先按 Source_UUID 匹配节点, 连接失败的节点没有 uuid, 按地址匹配
+func newReplGraph(nodes []db.MysqlReplNode) replGraph {
+	byUUID := map[string]int{}
+	byAddr := map[string]int{}
+	for i, n := range nodes {
+		if n.ServerUUID != "" {
+			byUUID[strings.ToLower(n.ServerUUID)] = i
+		}
+		byAddr[strings.ToLower(n.Addr)] = i
+	}
+	for i, n := range nodes {
+		if n.Hostname == "" || n.Port == 0 {
+			continue
+		}
+		if addr := strings.ToLower(net.JoinHostPort(n.Hostname, strconv.Itoa(n.Port))); addr != "" {
+			if _, ok := byAddr[addr]; !ok {
+				byAddr[addr] = i
+			}
+		}
+	}
+
+	g := replGraph{nodes: nodes, parents: make([][]replEdge, len(nodes))}
+	for i, n := range nodes {
+		for _, ch := range n.Channels {
+			parent, ok := byUUID[strings.ToLower(ch.SourceUUID)]
+			if !ok {
+				parent, ok = byAddr[strings.ToLower(net.JoinHostPort(ch.SourceHost, strconv.Itoa(ch.SourcePort)))]
+			}
+			if ok && parent != i {
+				g.parents[i] = append(g.parents[i], replEdge{parent: parent, channel: ch})
+			}
+		}
+	}
+	return g
+}
+
+// ancestors 所有上游节点(包括间接上游)的 server uuid
+func (g replGraph) ancestors(i int) []string {
+	var uuids []string
+	visited := map[int]bool{i: true}
+	queue := []int{i}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, e := range g.parents[cur] {
+			if visited[e.parent] {
+				continue
+			}
+			visited[e.parent] = true
+			if uuid := g.nodes[e.parent].ServerUUID; uuid != "" {
+				uuids = append(uuids, strings.ToLower(uuid))
+			}
+			queue = append(queue, e.parent)
+		}
+	}
+	return uuids
+}
+
+// buildReplTree 生成复制树, 多源复制的节点挂在第一个上游下面
+func buildReplTree(nodes []db.MysqlReplNode) []*dto.MysqlReplTreeNode {
+	g := newReplGraph(nodes)
+	children := make([][]int, len(nodes))
+	for i, edges := range g.parents {
+		if len(edges) > 0 {
+			children[edges[0].parent] = append(children[edges[0].parent], i)
+		}
+	}
+
+	visited := make([]bool, len(nodes))
+	var build func(i int, edge *replEdge) *dto.MysqlReplTreeNode
+	build = func(i int, edge *replEdge) *dto.MysqlReplTreeNode {
+		visited[i] = true
+		t := g.treeNode(i, edge)
+		for _, c := range children[i] {
+			if !visited[c] {
+				t.Children = append(t.Children, build(c, &g.parents[c][0]))
+			}
+		}
+		return t
+	}
+
+	roots := []*dto.MysqlReplTreeNode{}
+	for i := range nodes {
+		if len(g.parents[i]) == 0 {
+			roots = append(roots, build(i, nil))
+		}
+	}
+
+	// 环形复制(如双主)中的节点都有上游, 选择可写的节点作为根
+	for {
+		pick := -1
+		for i := range nodes {
+			if !visited[i] && (pick < 0 || (nodes[pick].ReadOnly && !nodes[i].ReadOnly)) {
+				pick = i
+			}
+		}
+		if pick < 0 {
+			break
+		}
+		root := build(pick, &g.parents[pick][0])
+		root.Cycle = true
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+func (g replGraph) treeNode(i int, edge *replEdge) *dto.MysqlReplTreeNode {
+	n := g.nodes[i]
+	t := &dto.MysqlReplTreeNode{
+		Addr:         n.Addr,
+		ServerID:     n.ServerID,
+		ServerUUID:   n.ServerUUID,
+		Version:      n.Version,
+		ReadOnly:     n.ReadOnly,
+		Error:        n.Error,
+		OtherSources: []string{},
+		Children:     []*dto.MysqlReplTreeNode{},
+	}
+	for _, e := range g.parents[i] {
+		if edge == nil || e.parent != edge.parent {
+			t.OtherSources = append(t.OtherSources, g.nodes[e.parent].Addr)
+		}
+	}
+	if edge == nil {
+		return t
+	}
+
+	t.Channel = edge.channel.Channel
+	t.IOThread = edge.channel.IOThread
+	t.SQLThread = edge.channel.SQLThread
+	t.LagSeconds = edge.channel.SecondsBehind
+	if parent, err := db.ParseGTIDSet(g.nodes[edge.parent].GTIDExecuted); err == nil {
+		if executed, err := db.ParseGTIDSet(n.GTIDExecuted); err == nil {
+			t.GTIDBehind = parent.Subtract(executed).Count()
+		}
+	}
+	return t
+}
+
+// evaluateReplHealth 检查节点不可达, IO/SQL 线程异常, 复制延迟, 从库可写和 errant gtid
+func evaluateReplHealth(nodes []db.MysqlReplNode, maxLagSeconds int64) dto.MysqlReplHealth {
+	health := dto.MysqlReplHealth{Status: dto.HealthOK, Issues: []dto.MysqlReplHealthIssue{}}
+	issue := func(severity, node, channel, format string, args ...any) {
+		health.Issues = append(health.Issues, dto.MysqlReplHealthIssue{Severity: severity, Node: node, Channel: channel, Message: fmt.Sprintf(format, args...)})
+		if severity == dto.SeverityCritical || health.Status == dto.HealthOK {
+			health.Status = severity
+		}
+	}
+
+	g := newReplGraph(nodes)
+	for i, n := range nodes {
+		if n.Error != "" {
+			issue(dto.SeverityCritical, n.Addr, "", "无法读取节点状态: %s", n.Error)
+			continue
+		}
+
+		for _, ch := range n.Channels {
+			if ch.IOThread != "Yes" {
+				issue(dto.SeverityCritical, n.Addr, ch.Channel, "IO 线程没有运行(%s)%s", ch.IOThread, replError(ch.LastIOErrno, ch.LastIOError))
+			}
+			if ch.SQLThread != "Yes" {
+				issue(dto.SeverityCritical, n.Addr, ch.Channel, "SQL 线程没有运行(%s)%s", ch.SQLThread, replError(ch.LastSQLErrno, ch.LastSQLError))
+			}
+			if ch.IOThread != "Yes" || ch.SQLThread != "Yes" {
+				continue
+			}
+
+			if ch.SecondsBehind == nil {
+				issue(dto.SeverityWarning, n.Addr, ch.Channel, "复制延迟未知")
+			} else if lag := *ch.SecondsBehind - ch.SQLDelay; lag > maxLagSeconds {
+				issue(dto.SeverityWarning, n.Addr, ch.Channel, "复制延迟 %d 秒, 超过 %d 秒", lag, maxLagSeconds)
+				health.Issues[len(health.Issues)-1].Lag = lag
+			}
+		}
+
+		if len(n.Channels) > 0 && !n.ReadOnly {
+			issue(dto.SeverityWarning, n.Addr, "", "从库没有开启 read_only")
+		}
+		if errant := g.errantGTIDs(i); errant.Count() > 0 {
+			issue(dto.SeverityWarning, n.Addr, "", "存在 %d 个上游没有的事务(errant gtid): %s", errant.Count(), errant)
+		}
+	}
+	return health
+}
+
+func replError(errno int64, message string) string {
+	if errno == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", 错误 %d: %s", errno, message)
+}
+
+// errantGTIDs 从库执行过但所有上游都没有的事务, 通常是直接在从库上写入的. 上游不可达或者没有全部发现时不检查
+// 上游链路上的实例产生的事务可能是读取上游状态之后才复制过来的, 不算 errant
+func (g replGraph) errantGTIDs(i int) db.GTIDSet {
+	n := g.nodes[i]
+	if len(g.parents[i]) == 0 || len(g.parents[i]) < len(n.Channels) {
+		return nil
+	}
+	executed, err := db.ParseGTIDSet(n.GTIDExecuted)
+	if err != nil {
+		return nil
+	}
+
+	upstream := db.GTIDSet{}
+	for _, e := range g.parents[i] {
+		parent := g.nodes[e.parent]
+		if parent.Error != "" {
+			return nil
+		}
+		set, err := db.ParseGTIDSet(parent.GTIDExecuted)
+		if err != nil {
+			return nil
+		}
+		upstream = upstream.Union(set)
+	}
+
+	errant := executed.Subtract(upstream)
+	for _, uuid := range g.ancestors(i) {
+		for source := range errant {
+			if source == uuid || strings.HasPrefix(source, uuid+":") {
+				delete(errant, source)
+			}
+		}
+	}
+	return errant
+}
@@ -0,0 +1,129 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-30 16:45:18
+ */
+
+package mysqlservice
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"testing"
+)
+
+const (
+	uuidA = "aaaaaaaa-0000-0000-0000-000000000001"
+	uuidB = "bbbbbbbb-0000-0000-0000-000000000002"
+	uuidC = "cccccccc-0000-0000-0000-000000000003"
+)
+
+func replNode(addr, uuid string, readOnly bool, gtid string, channels ...db.MysqlReplicaStatus) db.MysqlReplNode {
+	return db.MysqlReplNode{
+		Addr:            addr,
+		MysqlServerInfo: db.MysqlServerInfo{ServerUUID: uuid, ReadOnly: readOnly, GTIDExecuted: gtid},
+		Channels:        channels,
+	}
+}
+
+func replChannel(uuid string, lag int64) db.MysqlReplicaStatus {
+	return db.MysqlReplicaStatus{SourceUUID: uuid, IOThread: "Yes", SQLThread: "Yes", SecondsBehind: &lag}
+}
+
+func TestBuildReplTree(t *testing.T) {
+	nodes := []db.MysqlReplNode{
+		replNode("b:3306", uuidB, true, uuidA+":1-8", replChannel(uuidA, 2)),
+		replNode("a:3306", uuidA, false, uuidA+":1-10"),
+		// c 通过地址匹配上游 b
+		replNode("c:3306", uuidC, true, uuidA+":1-8", db.MysqlReplicaStatus{SourceHost: "b", SourcePort: 3306, IOThread: "Yes", SQLThread: "Yes"}),
+	}
+	tree := buildReplTree(nodes)
+	if len(tree) != 1 || tree[0].Addr != "a:3306" || tree[0].Cycle {
+		t.Fatalf("got %+v", tree)
+	}
+	b := tree[0].Children[0]
+	if b.Addr != "b:3306" || b.GTIDBehind != 2 || *b.LagSeconds != 2 || len(b.Children) != 1 || b.Children[0].Addr != "c:3306" {
+		t.Errorf("got %+v", b)
+	}
+
+	// 双主: a 和 b 互为主从, 可写的 a 作为根
+	cycle := []db.MysqlReplNode{
+		replNode("b:3306", uuidB, true, uuidA+":1-10", replChannel(uuidA, 0)),
+		replNode("a:3306", uuidA, false, uuidA+":1-10", replChannel(uuidB, 0)),
+	}
+	tree = buildReplTree(cycle)
+	if len(tree) != 1 || tree[0].Addr != "a:3306" || !tree[0].Cycle || len(tree[0].Children) != 1 {
+		t.Errorf("cycle got %+v", tree)
+	}
+}
+
+func TestEvaluateReplHealth(t *testing.T) {
+	healthy := []db.MysqlReplNode{
+		replNode("a:3306", uuidA, false, uuidA+":1-10"),
+		replNode("b:3306", uuidB, true, uuidA+":1-10", replChannel(uuidA, 1)),
+	}
+	if health := evaluateReplHealth(healthy, 30); health.Status != dto.HealthOK || len(health.Issues) != 0 {
+		t.Errorf("healthy got %+v", health)
+	}
+
+	delayed := replChannel(uuidA, 3620)
+	delayed.SQLDelay = 3600
+	lagging := []db.MysqlReplNode{
+		replNode("a:3306", uuidA, false, uuidA+":1-10"),
+		replNode("b:3306", uuidB, true, uuidA+":1-10", replChannel(uuidA, 60)),
+		replNode("c:3306", uuidC, true, uuidA+":1-10", delayed),
+	}
+	health := evaluateReplHealth(lagging, 30)
+	if health.Status != dto.SeverityWarning || len(health.Issues) != 1 || health.Issues[0].Node != "b:3306" || health.Issues[0].Lag != 60 {
+		t.Errorf("lagging got %+v", health)
+	}
+
+	stopped := replChannel(uuidA, 0)
+	stopped.SQLThread, stopped.LastSQLErrno, stopped.SecondsBehind = "No", 1062, nil
+	broken := []db.MysqlReplNode{
+		replNode("a:3306", uuidA, false, uuidA+":1-10"),
+		replNode("b:3306", uuidB, false, uuidA+":1-10,"+uuidB+":1-2", stopped),
+		{Addr: "c:3306", Error: "connection refused"},
+	}
+	health = evaluateReplHealth(broken, 30)
+	if health.Status != dto.SeverityCritical || len(health.Issues) != 4 {
+		t.Errorf("broken got %+v", health)
+	}
+}
+
+func TestErrantGTIDs(t *testing.T) {
+	// b 的上游是 a, a 的上游是 c; b 上 c 产生的事务不算 errant
+	nodes := []db.MysqlReplNode{
+		replNode("c:3306", uuidC, false, uuidC+":1-5"),
+		replNode("a:3306", uuidA, true, uuidA+":1-10,"+uuidC+":1-4", replChannel(uuidC, 0)),
+		replNode("b:3306", uuidB, true, uuidA+":1-12,"+uuidB+":1-3,"+uuidC+":1-5", replChannel(uuidA, 0)),
+	}
+	g := newReplGraph(nodes)
+	if got := g.errantGTIDs(2).String(); got != uuidB+":1-3" {
+		t.Errorf("errant got %s", got)
+	}
+
+	// 上游不可达时不检查
+	nodes[1].Error = "timeout"
+	if got := newReplGraph(nodes).errantGTIDs(2); got.Count() != 0 {
+		t.Errorf("unreachable upstream got %s", got)
+	}
+}
+
+func TestReplProbeAllowed(t *testing.T) {
+	configured := []string{"10.0.0.1:3306", "db1.local:3306"}
+	allowed := []string{"10.0.1.0/24", "db2.local"}
+	for addr, want := range map[string]bool{
+		"10.0.0.1:3306":   true,
+		"DB1.local:3306":  true,
+		"10.0.0.1:3307":   false,
+		"10.0.1.20:3306":  true,
+		"10.0.2.20:3306":  false,
+		"db2.local:3306":  true,
+		"db3.local:3306":  false,
+		"evil.example:22": false,
+	} {
+		if got := replProbeAllowed(addr, configured, allowed); got != want {
+			t.Errorf("replProbeAllowed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-06 14:20:47
+ */
+
+package pgsqlservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+)
+
+// 长时间运行的默认阈值, 单位秒
+const (
+	defaultLongQuerySec  = 60
+	defaultLongXactSec   = 300
+	defaultIdleInXactSec = 60
+)
+
+func (p *PgSQLService) Activity(req dto.PgSQLActivityReq) ([]db.PgActivity, error) {
+	client, err := p.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	return client.Activity(req.PgActivityFilter, config.GlobalConfig.PgSQLAdmin.TerminateProtectedUsers)
+}
+
+func (p *PgSQLService) Locks(req dto.PgSQLLocksReq) ([]db.PgLock, error) {
+	client, err := p.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	return client.Locks(req.PID, req.WaitingOnly)
+}
+
+// BlockingChains 阻塞链: 谁阻塞了谁, 阻塞了多久, 以及双方正在执行的语句
+func (p *PgSQLService) BlockingChains(req dto.PgSQLInstanceReq) ([]*db.PgBlockingNode, error) {
+	client, err := p.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	activities, err := client.Activity(db.PgActivityFilter{}, config.GlobalConfig.PgSQLAdmin.TerminateProtectedUsers)
+	if err != nil {
+		return nil, err
+	}
+	return db.BlockingChains(activities), nil
+}
+
+// LongRunning 长时间运行的语句, 长事务和长时间 idle in transaction 的连接, 阈值为空时使用默认值
+func (p *PgSQLService) LongRunning(req dto.PgSQLLongRunningReq) (db.PgLongRunning, error) {
+	client, err := p.client(req.Instance)
+	if err != nil {
+		return db.PgLongRunning{}, err
+	}
+
+	activities, err := client.Activity(db.PgActivityFilter{}, config.GlobalConfig.PgSQLAdmin.TerminateProtectedUsers)
+	if err != nil {
+		return db.PgLongRunning{}, err
+	}
+	return db.LongRunning(activities,
+		orDefault(req.QuerySec, defaultLongQuerySec),
+		orDefault(req.XactSec, defaultLongXactSec),
+		orDefault(req.IdleSec, defaultIdleInXactSec),
+	), nil
+}
+
+func orDefault(v, def float64) float64 {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+// Cancel 取消进程正在执行的语句(pg_cancel_backend), terminate 时断开连接(pg_terminate_backend)
+// 后台进程, 当前管理连接和受保护用户的连接不允许操作
+func (p *PgSQLService) Cancel(operator model.User, req dto.PgSQLCancelReq) (db.PgActivity, error) {
+	client, err := p.client(req.Instance)
+	if err != nil {
+		return db.PgActivity{}, err
+	}
+
+	activity, err := client.SignalBackend(req.PID, req.Terminate, config.GlobalConfig.PgSQLAdmin.TerminateProtectedUsers)
+	action := "backend.cancel"
+	if req.Terminate {
+		action = "backend.terminate"
+	}
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, action, fmt.Sprint(req.PID), activity, err)
+	return activity, err
+}
@@ -0,0 +1,200 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-08 14:36:52
+ */
+
+package pgsqlservice
+
+import (
+	"fmt"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"sort"
+)
+
+// 健康检查的默认阈值
+const (
+	defaultHealthMaxLagSeconds = 30
+	defaultHealthMaxRetainedMB = 1024
+	defaultHealthBloatRatio    = 0.5
+	defaultHealthMinBloatMB    = 100
+	defaultHealthLimit         = 20
+	maxHealthLimit             = 100
+)
+
+// 事务 id 年龄达到 2^31 时实例拒绝写入, 按占比告警
+const (
+	pgXIDLimit              = 1 << 31
+	wraparoundWarningRatio  = 0.5
+	wraparoundCriticalRatio = 0.75
+)
+
+// 健康检查项
+const (
+	healthRecovery    = "recovery"
+	healthReplication = "replication"
+	healthSlot        = "slot"
+	healthBloat       = "bloat"
+	healthVacuum      = "vacuum"
+	healthWraparound  = "wraparound"
+)
+
+type healthThresholds struct {
+	maxLagSeconds    float64
+	maxRetainedBytes int64
+	bloatRatio       float64
+	minBloatBytes    int64
+}
+
+// Health 检查复制, 复制槽, 膨胀, vacuum 和事务 id 回卷, 单个检查项失败不影响其他检查项
+func (p *PgSQLService) Health(req dto.PgSQLHealthReq) (dto.PgSQLHealth, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultHealthLimit
+	}
+	if limit > maxHealthLimit {
+		return dto.PgSQLHealth{}, fmt.Errorf("limit 不能超过 %d", maxHealthLimit)
+	}
+	thresholds := healthThresholds{
+		maxLagSeconds:    orDefault(req.MaxLagSeconds, defaultHealthMaxLagSeconds),
+		maxRetainedBytes: int64(orDefault(float64(req.MaxRetainedWALMB), defaultHealthMaxRetainedMB)) << 20,
+		bloatRatio:       orDefault(req.BloatRatio, defaultHealthBloatRatio),
+		minBloatBytes:    int64(orDefault(float64(req.MinBloatMB), defaultHealthMinBloatMB)) << 20,
+	}
+
+	client, err := p.client(req.Instance)
+	if err != nil {
+		return dto.PgSQLHealth{}, err
+	}
+
+	report := dto.PgSQLHealth{Instance: req.Instance, Errors: map[string]string{}}
+	check := func(name string, err error) {
+		if err == nil {
+			return
+		}
+		if prev, ok := report.Errors[name]; ok {
+			report.Errors[name] = prev + "; " + err.Error()
+		} else {
+			report.Errors[name] = err.Error()
+		}
+	}
+
+	report.Recovery, err = client.Recovery()
+	check(healthRecovery, err)
+	report.Replication, err = client.Replication()
+	check(healthReplication, err)
+	report.Slots, err = client.ReplicationSlots()
+	check(healthSlot, err)
+	report.TableBloat, err = client.TableBloat(limit)
+	check(healthBloat, err)
+	report.IndexBloat, err = client.IndexBloat(limit)
+	check(healthBloat, err)
+	report.Vacuum, err = client.VacuumProgress()
+	check(healthVacuum, err)
+	report.Wraparound, err = client.Wraparound(limit)
+	check(healthWraparound, err)
+
+	evaluateHealth(&report, thresholds)
+	return report, nil
+}
+
+// evaluateHealth 根据采集的结果生成问题列表和状态
+func evaluateHealth(report *dto.PgSQLHealth, t healthThresholds) {
+	report.Status = dto.HealthOK
+	report.Issues = []dto.PgSQLHealthIssue{}
+	issue := func(severity, category, object, format string, args ...any) {
+		report.Issues = append(report.Issues, dto.PgSQLHealthIssue{Severity: severity, Category: category, Object: object, Message: fmt.Sprintf(format, args...)})
+		if severity == dto.SeverityCritical || report.Status == dto.HealthOK {
+			report.Status = severity
+		}
+	}
+
+	failed := make([]string, 0, len(report.Errors))
+	for name := range report.Errors {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	for _, name := range failed {
+		issue(dto.SeverityWarning, name, "", "检查失败: %s", report.Errors[name])
+	}
+
+	if delay := report.Recovery.ReplayDelaySec; delay != nil && *delay > t.maxLagSeconds {
+		issue(dto.SeverityWarning, healthRecovery, "", "从库回放延迟 %.0f 秒, 超过 %.0f 秒", *delay, t.maxLagSeconds)
+	}
+
+	for _, r := range report.Replication {
+		name := r.Application
+		if name == "" {
+			name = r.ClientAddr
+		}
+		if r.State != "streaming" {
+			issue(dto.SeverityWarning, healthReplication, name, "下游状态为 %s", r.State)
+			continue
+		}
+		if r.ReplayLagSec != nil && *r.ReplayLagSec > t.maxLagSeconds {
+			issue(dto.SeverityWarning, healthReplication, name, "回放延迟 %.0f 秒, 超过 %.0f 秒, 落后 %s", *r.ReplayLagSec, t.maxLagSeconds, formatBytes(r.ReplayLagBytes))
+		}
+	}
+
+	for _, s := range report.Slots {
+		if s.RetainedBytes <= t.maxRetainedBytes {
+			if !s.Active {
+				issue(dto.SeverityWarning, healthSlot, s.Name, "复制槽没有被使用")
+			}
+			continue
+		}
+		// 没有被使用的复制槽保留的 wal 会一直增长, 直到磁盘写满
+		severity, state := dto.SeverityWarning, "使用中"
+		if !s.Active {
+			severity, state = dto.SeverityCritical, "没有被使用"
+		}
+		issue(severity, healthSlot, s.Name, "复制槽%s, 保留了 %s wal, 超过 %s", state, formatBytes(s.RetainedBytes), formatBytes(t.maxRetainedBytes))
+	}
+
+	for _, list := range [][]db.PgBloat{report.TableBloat, report.IndexBloat} {
+		for _, b := range list {
+			if b.RealBytes < t.minBloatBytes || b.BloatRatio < t.bloatRatio {
+				continue
+			}
+			object, kind := b.Schema+"."+b.Table, "表"
+			if b.Index != "" {
+				object, kind = b.Schema+"."+b.Index, "索引"
+			}
+			issue(dto.SeverityWarning, healthBloat, object, "%s膨胀约 %.0f%%, 估算可回收 %s / %s", kind, b.BloatRatio*100, formatBytes(b.BloatBytes), formatBytes(b.RealBytes))
+		}
+	}
+
+	for _, v := range report.Vacuum {
+		if v.Wraparound {
+			issue(dto.SeverityWarning, healthVacuum, v.Relation, "正在执行防止事务 id 回卷的 autovacuum, 已运行 %.0f 秒, 阶段: %s", v.RunningSec, v.Phase)
+		}
+	}
+
+	checkAge := func(object string, age, mxidAge int64) {
+		ratio := float64(max(age, mxidAge)) / pgXIDLimit
+		switch {
+		case ratio >= wraparoundCriticalRatio:
+			issue(dto.SeverityCritical, healthWraparound, object, "事务 id 年龄 %d, multixact 年龄 %d, 已达到回卷上限的 %.0f%%", age, mxidAge, ratio*100)
+		case ratio >= wraparoundWarningRatio:
+			issue(dto.SeverityWarning, healthWraparound, object, "事务 id 年龄 %d, multixact 年龄 %d, 已达到回卷上限的 %.0f%%", age, mxidAge, ratio*100)
+		}
+	}
+	for _, d := range report.Wraparound.Databases {
+		checkAge(d.DB, d.XIDAge, d.MXIDAge)
+	}
+	for _, r := range report.Wraparound.Tables {
+		checkAge(r.Relation, r.XIDAge, r.MXIDAge)
+	}
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,85 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-08 16:40:27
+ */
+
+package pgsqlservice
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"testing"
+)
+
+var testThresholds = healthThresholds{maxLagSeconds: 30, maxRetainedBytes: 1 << 30, bloatRatio: 0.5, minBloatBytes: 100 << 20}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestEvaluateHealthOK(t *testing.T) {
+	report := dto.PgSQLHealth{
+		Recovery:    db.PgRecovery{InRecovery: true, ReplayDelaySec: float(0)},
+		Replication: []db.PgReplication{{Application: "standby1", State: "streaming", ReplayLagSec: float(1)}},
+		Slots:       []db.PgReplicationSlot{{Name: "standby1", Active: true, RetainedBytes: 16 << 20}},
+		TableBloat:  []db.PgBloat{{Schema: "public", Table: "small", RealBytes: 10 << 20, BloatBytes: 9 << 20, BloatRatio: 0.9}},
+		Wraparound:  db.PgWraparound{Databases: []db.PgXIDAge{{DB: "shop", XIDAge: 200000000}}},
+	}
+	evaluateHealth(&report, testThresholds)
+	if report.Status != dto.HealthOK || len(report.Issues) != 0 {
+		t.Errorf("got %+v", report.Issues)
+	}
+}
+
+func TestEvaluateHealthIssues(t *testing.T) {
+	report := dto.PgSQLHealth{
+		Recovery: db.PgRecovery{InRecovery: true, ReplayDelaySec: float(120)},
+		Replication: []db.PgReplication{
+			{Application: "standby1", State: "streaming", ReplayLagSec: float(60), ReplayLagBytes: 64 << 20},
+			{ClientAddr: "10.0.0.9", State: "catchup"},
+		},
+		Slots: []db.PgReplicationSlot{
+			{Name: "active_big", Active: true, RetainedBytes: 2 << 30},
+			{Name: "abandoned", RetainedBytes: 5 << 30},
+			{Name: "idle", RetainedBytes: 1 << 20},
+		},
+		TableBloat: []db.PgBloat{{Schema: "public", Table: "orders", RealBytes: 1 << 30, BloatBytes: 600 << 20, BloatRatio: 600.0 / 1024}},
+		IndexBloat: []db.PgBloat{{Schema: "public", Table: "orders", Index: "orders_pkey", RealBytes: 1 << 30, BloatBytes: 100 << 20, BloatRatio: 0.1}},
+		Vacuum:     []db.PgVacuumProgress{{Relation: "orders", Phase: "scanning heap", Autovacuum: true, Wraparound: true}},
+		Wraparound: db.PgWraparound{
+			Databases: []db.PgXIDAge{{DB: "shop", XIDAge: 1200000000}},
+			Tables:    []db.PgXIDAge{{Relation: "orders", XIDAge: 1700000000}, {Relation: "users", MXIDAge: 1100000000}},
+		},
+		Errors: map[string]string{healthBloat: "permission denied"},
+	}
+	evaluateHealth(&report, testThresholds)
+	if report.Status != dto.SeverityCritical {
+		t.Errorf("status %s", report.Status)
+	}
+
+	got := map[string]map[string]string{}
+	for _, i := range report.Issues {
+		if got[i.Category] == nil {
+			got[i.Category] = map[string]string{}
+		}
+		got[i.Category][i.Object] = i.Severity
+	}
+	want := map[string]map[string]string{
+		healthBloat:       {"": dto.SeverityWarning, "public.orders": dto.SeverityWarning},
+		healthRecovery:    {"": dto.SeverityWarning},
+		healthReplication: {"standby1": dto.SeverityWarning, "10.0.0.9": dto.SeverityWarning},
+		healthSlot:        {"active_big": dto.SeverityWarning, "abandoned": dto.SeverityCritical, "idle": dto.SeverityWarning},
+		healthVacuum:      {"orders": dto.SeverityWarning},
+		healthWraparound:  {"shop": dto.SeverityWarning, "orders": dto.SeverityCritical, "users": dto.SeverityWarning},
+	}
+	if len(report.Issues) != 12 {
+		t.Errorf("got %d issues: %+v", len(report.Issues), report.Issues)
+	}
+	for category, objects := range want {
+		for object, severity := range objects {
+			if got[category][object] != severity {
+				t.Errorf("%s %q: got %q, want %q", category, object, got[category][object], severity)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-06 14:05:31
+ */
+
+package pgsqlservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+)
+
+const AuditModule = "pgsql"
+
+type PgSQLService struct{}
+
+func NewPgSQLService() *PgSQLService {
+	return &PgSQLService{}
+}
+
+// client 根据配置文件中的实例名获取 postgres 连接
+func (p *PgSQLService) client(instance string) (*db.PgSQLClient, error) {
+	cfg, ok := config.GlobalConfig.DB[instance]
+	if !ok {
+		return nil, fmt.Errorf("postgres 实例: %s 不存在", instance)
+	}
+	if cfg.Dialect != "postgres" {
+		return nil, fmt.Errorf("实例: %s 不是 postgres, 而是 %s", instance, cfg.Dialect)
+	}
+
+	client, ok := db.DB(instance).(*db.PgSQLClient)
+	if !ok || client == nil || client.Conn() == nil {
+		return nil, fmt.Errorf("postgres 实例: %s 连接不可用", instance)
+	}
+	return client, nil
+}
@@ -0,0 +1,210 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-09 16:48:31
+ */
+
+package redisservice
+
+import (
+	"fmt"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"myadmin/internal/task"
+)
+
+// 默认允许的 slot 不均衡百分比, 与 redis-cli --cluster rebalance 一致
+const DefaultRebalanceThreshold = 2
+
+func (r *RedisService) ClusterTopology(req dto.RedisInstanceReq) (db.RedisClusterTopology, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return db.RedisClusterTopology{}, err
+	}
+	return client.ClusterTopology()
+}
+
+func (r *RedisService) ClusterMeet(operator model.User, req dto.RedisClusterMeetReq) (task.TaskInfo, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return task.TaskInfo{}, err
+	}
+
+	target := fmt.Sprintf("%s:%d", req.Host, req.Port)
+	return r.submit(operator, req.Instance, "cluster.meet", target, req, func(t *task.Task) error {
+		return t.Step("meet "+target, func() error {
+			return client.ClusterMeet(req.Host, req.Port, t.Logf)
+		})
+	}), nil
+}
+
+func (r *RedisService) ClusterAddSlots(operator model.User, req dto.RedisClusterAddSlotsReq) (task.TaskInfo, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return task.TaskInfo{}, err
+	}
+
+	slots, err := db.ParseSlots(req.Slots)
+	if err != nil {
+		return task.TaskInfo{}, err
+	}
+
+	return r.submit(operator, req.Instance, "cluster.addslots", req.NodeID, req, func(t *task.Task) error {
+		return t.Step("addslots "+req.Slots, func() error {
+			return client.ClusterAddSlots(req.NodeID, slots, t.Logf)
+		})
+	}), nil
+}
+
+// ClusterRebalancePlan 只计算迁移计划, 不执行
+func (r *RedisService) ClusterRebalancePlan(req dto.RedisClusterRebalanceReq) ([]db.ClusterSlotMove, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := client.ClusterNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Threshold <= 0 {
+		req.Threshold = DefaultRebalanceThreshold
+	}
+	moves := db.PlanClusterRebalance(nodes, req.Threshold)
+	if moves == nil {
+		moves = []db.ClusterSlotMove{}
+	}
+	return moves, nil
+}
+
+func (r *RedisService) ClusterRebalance(operator model.User, req dto.RedisClusterRebalanceReq) (task.TaskInfo, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return task.TaskInfo{}, err
+	}
+
+	if req.Threshold <= 0 {
+		req.Threshold = DefaultRebalanceThreshold
+	}
+	moves, err := r.ClusterRebalancePlan(req)
+	if err != nil {
+		return task.TaskInfo{}, err
+	}
+	if len(moves) == 0 {
+		return task.TaskInfo{}, fmt.Errorf("集群各主节点 slot 不均衡程度未超过 %.2f%%, 不需要 rebalance", req.Threshold)
+	}
+
+	return r.submit(operator, req.Instance, "cluster.rebalance", req.Instance, req, func(t *task.Task) error {
+		t.SetResult(moves)
+		return migrateSlots(t, client, moves, req.Batch)
+	}), nil
+}
+
+func (r *RedisService) ClusterMigrate(operator model.User, req dto.RedisClusterMigrateReq) (task.TaskInfo, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return task.TaskInfo{}, err
+	}
+
+	if req.SourceID == req.TargetID {
+		return task.TaskInfo{}, fmt.Errorf("源节点和目标节点不能相同")
+	}
+
+	slots, err := db.ParseSlots(req.Slots)
+	if err != nil {
+		return task.TaskInfo{}, err
+	}
+
+	moves := []db.ClusterSlotMove{{SourceID: req.SourceID, TargetID: req.TargetID, Slots: slots}}
+	return r.submit(operator, req.Instance, "cluster.migrate", req.SourceID+"->"+req.TargetID, req, func(t *task.Task) error {
+		return migrateSlots(t, client, moves, req.Batch)
+	}), nil
+}
+
+func (r *RedisService) ClusterFailover(operator model.User, req dto.RedisClusterFailoverReq) (task.TaskInfo, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return task.TaskInfo{}, err
+	}
+
+	return r.submit(operator, req.Instance, "cluster.failover", req.NodeID, req, func(t *task.Task) error {
+		return t.Step("failover "+req.NodeID, func() error {
+			return client.ClusterFailover(req.NodeID, req.Mode, t.Logf)
+		})
+	}), nil
+}
+
+func (r *RedisService) ClusterForget(operator model.User, req dto.RedisClusterForgetReq) (task.TaskInfo, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return task.TaskInfo{}, err
+	}
+
+	return r.submit(operator, req.Instance, "cluster.forget", req.NodeID, req, func(t *task.Task) error {
+		return t.Step("forget "+req.NodeID, func() error {
+			return client.ClusterForget(req.NodeID, t.Logf)
+		})
+	}), nil
+}
+
+// submit 提交后台任务, 任务结束后记录审计日志
+func (r *RedisService) submit(operator model.User, instance, action, target string, detail any, fn func(t *task.Task) error) task.TaskInfo {
+	t := task.Tasks.Submit(action, AuditModule, instance, operator.Username, func(t *task.Task) error {
+		err := fn(t)
+		auditservice.NewAuditService().Record(operator, AuditModule, instance, action, target, map[string]any{"task_id": t.ID, "request": detail}, err)
+		return err
+	})
+	return t.Snapshot()
+}
+
+// migrateSlots 按计划逐个 slot 迁移, 每个迁移计划为一个步骤
+func migrateSlots(t *task.Task, client *db.RedisClient, moves []db.ClusterSlotMove, batch int) error {
+	total := 0
+	for _, move := range moves {
+		total += len(move.Slots)
+	}
+
+	done := 0
+	for _, move := range moves {
+		name := fmt.Sprintf("migrate %d slots %s -> %s", len(move.Slots), move.SourceID, move.TargetID)
+		err := t.Step(name, func() error {
+			// 每个计划开始前重新获取节点信息, 前一个计划可能已经改变了拓扑
+			source, err := client.ClusterNodeByID(move.SourceID)
+			if err != nil {
+				return err
+			}
+			target, err := client.ClusterNodeByID(move.TargetID)
+			if err != nil {
+				return err
+			}
+			if source.Role != "master" || target.Role != "master" {
+				return fmt.Errorf("源节点和目标节点都必须是主节点")
+			}
+
+			owned := make(map[int]bool, source.SlotCount)
+			for _, slot := range source.SlotList() {
+				owned[slot] = true
+			}
+
+			for _, slot := range move.Slots {
+				if !owned[slot] {
+					return fmt.Errorf("slot %d 不属于源节点 %s", slot, move.SourceID)
+				}
+				keys, err := client.ClusterMigrateSlot(source, target, slot, batch, t.Logf)
+				if err != nil {
+					return err
+				}
+				done++
+				t.Logf("slot %d 迁移完成, 共 %d 个 key, 总进度 %d/%d", slot, keys, done, total)
+				t.SetProgress(float64(done) * 100 / float64(total))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
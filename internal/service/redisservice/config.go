@@ -0,0 +1,160 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-16 15:20:44
+ */
+
+package redisservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ConfigGet 实例的运行时参数, 密码类参数只返回占位符
+func (r *RedisService) ConfigGet(req dto.RedisInstanceReq) (db.RedisRuntimeConfig, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return db.RedisRuntimeConfig{}, err
+	}
+
+	conf, err := client.ConfigGetAll()
+	conf.Params = db.MaskRedisConfig(conf.Params)
+	return conf, err
+}
+
+// ConfigDiff 对比单个实例的参数与所属环境的基线, cluster 模式下对比所有主从节点
+func (r *RedisService) ConfigDiff(req dto.RedisInstanceReq) (dto.RedisConfigDiffReport, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return dto.RedisConfigDiffReport{}, err
+	}
+
+	env := instanceEnv(req.Instance)
+	baseline, ok := config.GlobalConfig.RedisAdmin.Baseline[env]
+	if !ok {
+		return dto.RedisConfigDiffReport{}, fmt.Errorf("环境: %s 没有配置参数基线", env)
+	}
+
+	confs, err := client.NodeConfigs()
+	if len(confs) == 0 {
+		return dto.RedisConfigDiffReport{}, err
+	}
+
+	report := dto.RedisConfigDiffReport{Instance: req.Instance, Env: env, Diffs: []db.RedisConfigDiff{}}
+	if err != nil {
+		// 部分节点失败时返回其他节点的对比结果
+		report.Error = err.Error()
+	}
+	nodes := make([]string, 0, len(confs))
+	for node := range confs {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		for _, diff := range db.DiffRedisConfig(confs[node].Params, baseline) {
+			diff.Node = node
+			report.Diffs = append(report.Diffs, diff)
+		}
+	}
+	return report, nil
+}
+
+// ConfigDiffAll 对比所有配置了基线的实例, 单个实例失败不影响其他实例
+func (r *RedisService) ConfigDiffAll() []dto.RedisConfigDiffReport {
+	instances := make([]string, 0, len(config.GlobalConfig.Redis))
+	for name := range config.GlobalConfig.Redis {
+		instances = append(instances, name)
+	}
+	sort.Strings(instances)
+
+	reports := []dto.RedisConfigDiffReport{}
+	for _, name := range instances {
+		env := instanceEnv(name)
+		if _, ok := config.GlobalConfig.RedisAdmin.Baseline[env]; !ok {
+			continue
+		}
+
+		report, err := r.ConfigDiff(dto.RedisInstanceReq{Instance: name})
+		if err != nil {
+			report = dto.RedisConfigDiffReport{Instance: name, Env: env, Diffs: []db.RedisConfigDiff{}, Error: err.Error()}
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// ConfigSet 修改实例参数, 只允许修改配置文件中 redis_admin.config_set_allow 列出的参数
+// 默认只返回将要修改的内容, apply 为 true 时才执行; cluster 模式下在所有节点上执行
+func (r *RedisService) ConfigSet(operator model.User, req dto.RedisConfigSetReq) (dto.RedisConfigSetResp, error) {
+	resp := dto.RedisConfigSetResp{DryRun: !req.Apply, Changes: []dto.RedisConfigChange{}}
+
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return resp, err
+	}
+
+	params := make(map[string]string, len(req.Params))
+	for name, value := range req.Params {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(config.GlobalConfig.RedisAdmin.ConfigSetAllow, name) {
+			return resp, fmt.Errorf("参数: %s 不在允许修改的列表中", name)
+		}
+		params[name] = value
+	}
+
+	conf, err := client.ConfigGetAll()
+	if err != nil {
+		return resp, err
+	}
+
+	changed := make(map[string]string)
+	for name, value := range params {
+		old, ok := conf.Params[name]
+		if !ok {
+			return resp, fmt.Errorf("实例上不存在参数: %s", name)
+		}
+		change := dto.RedisConfigChange{
+			Param:   name,
+			Old:     db.MaskRedisConfigValue(name, old),
+			New:     db.MaskRedisConfigValue(name, value),
+			Changed: db.NormalizeRedisConfigValue(old) != db.NormalizeRedisConfigValue(value),
+		}
+		if change.Changed {
+			changed[name] = value
+		}
+		resp.Changes = append(resp.Changes, change)
+	}
+	sort.Slice(resp.Changes, func(i, j int) bool { return resp.Changes[i].Param < resp.Changes[j].Param })
+
+	if !req.Apply || len(changed) == 0 {
+		return resp, nil
+	}
+
+	err = client.ConfigSet(changed, req.Rewrite)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "config.set", strings.Join(sortedKeys(changed), ","), resp.Changes, err)
+	return resp, err
+}
+
+// instanceEnv 实例所属环境, 实例没有配置时使用服务所在环境
+func instanceEnv(instance string) string {
+	if conf, ok := config.GlobalConfig.Redis[instance]; ok && conf.Env != "" {
+		return conf.Env
+	}
+	return config.GlobalConfig.Server.Env
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
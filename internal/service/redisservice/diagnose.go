@@ -0,0 +1,310 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-24 09:52:36
+ */
+
+package redisservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"sort"
+	"time"
+)
+
+var severityLevel = map[string]int{dto.SeverityInfo: 1, dto.SeverityWarning: 2, dto.SeverityCritical: 3}
+
+const (
+	fragmentationMinUsedMemory = 64 << 20 // 使用内存小于该值时碎片率没有参考意义, 不检查
+	hitRateMinRequests         = 10000    // 访问次数小于该值时命中率没有参考意义, 不检查
+)
+
+// diagnoseRule 诊断规则, checkInfo 检查单个节点的 info, checkCluster 检查 cluster info, 二者只需实现一个
+type diagnoseRule struct {
+	name         string
+	description  string
+	disabled     bool
+	severity     string
+	threshold    float64
+	checkInfo    func(rule diagnoseRule, node string, info db.RedisInfo) []dto.RedisDiagnoseFinding
+	checkCluster func(rule diagnoseRule, info db.RedisClusterInfo) []dto.RedisDiagnoseFinding
+}
+
+func (rule diagnoseRule) finding(node string, value float64, format string, args ...any) dto.RedisDiagnoseFinding {
+	return dto.RedisDiagnoseFinding{
+		Rule:      rule.name,
+		Severity:  rule.severity,
+		Node:      node,
+		Message:   fmt.Sprintf(format, args...),
+		Value:     value,
+		Threshold: rule.threshold,
+	}
+}
+
+// defaultDiagnoseRules 默认规则, 可以通过配置文件 redis_admin.diagnose 覆盖
+var defaultDiagnoseRules = []diagnoseRule{
+	{
+		name:        "replica_lag",
+		description: "主库上看到的从库复制延迟(秒)超过阈值",
+		severity:    dto.SeverityWarning,
+		threshold:   10,
+		checkInfo: func(rule diagnoseRule, node string, info db.RedisInfo) (findings []dto.RedisDiagnoseFinding) {
+			for _, slave := range info.Slaves {
+				if float64(slave.Lag) > rule.threshold {
+					findings = append(findings, rule.finding(node, float64(slave.Lag), "从库 %s:%d 复制延迟 %d 秒", slave.IP, slave.Port, slave.Lag))
+				}
+			}
+			return findings
+		},
+	},
+	{
+		name:        "replica_state",
+		description: "主库上看到的从库状态不是 online",
+		severity:    dto.SeverityCritical,
+		checkInfo: func(rule diagnoseRule, node string, info db.RedisInfo) (findings []dto.RedisDiagnoseFinding) {
+			for _, slave := range info.Slaves {
+				if slave.State != "online" {
+					findings = append(findings, rule.finding(node, 0, "从库 %s:%d 状态为 %s", slave.IP, slave.Port, slave.State))
+				}
+			}
+			return findings
+		},
+	},
+	{
+		name:        "master_link_down",
+		description: "从库与主库的复制连接断开",
+		severity:    dto.SeverityCritical,
+		checkInfo: func(rule diagnoseRule, node string, info db.RedisInfo) []dto.RedisDiagnoseFinding {
+			if info.Role != "slave" || info.MasterLinkStatus == "up" {
+				return nil
+			}
+			return []dto.RedisDiagnoseFinding{rule.finding(node, float64(info.MasterLastIoSecondsAgo), "与主库的复制连接状态为 %s, 最近一次交互在 %d 秒前", info.MasterLinkStatus, info.MasterLastIoSecondsAgo)}
+		},
+	},
+	{
+		name:        "fragmentation_ratio",
+		description: "内存碎片率超过阈值, 使用内存小于 64MB 时不检查",
+		severity:    dto.SeverityWarning,
+		threshold:   1.5,
+		checkInfo: func(rule diagnoseRule, node string, info db.RedisInfo) []dto.RedisDiagnoseFinding {
+			if info.UsedMemory < fragmentationMinUsedMemory || info.MemFragmentationRatio <= rule.threshold {
+				return nil
+			}
+			return []dto.RedisDiagnoseFinding{rule.finding(node, info.MemFragmentationRatio, "内存碎片率 %.2f 超过 %.2f", info.MemFragmentationRatio, rule.threshold)}
+		},
+	},
+	{
+		name:        "maxmemory_unset",
+		description: "没有设置 maxmemory, 内存可能无限增长",
+		severity:    dto.SeverityWarning,
+		checkInfo: func(rule diagnoseRule, node string, info db.RedisInfo) []dto.RedisDiagnoseFinding {
+			if info.Maxmemory > 0 {
+				return nil
+			}
+			return []dto.RedisDiagnoseFinding{rule.finding(node, 0, "没有设置 maxmemory, 当前使用内存 %d 字节", info.UsedMemory)}
+		},
+	},
+	{
+		name:        "memory_usage",
+		description: "使用内存占 maxmemory 的比例超过阈值",
+		severity:    dto.SeverityWarning,
+		threshold:   0.9,
+		checkInfo: func(rule diagnoseRule, node string, info db.RedisInfo) []dto.RedisDiagnoseFinding {
+			if info.Maxmemory == 0 {
+				return nil
+			}
+			usage := float64(info.UsedMemory) / float64(info.Maxmemory)
+			if usage <= rule.threshold {
+				return nil
+			}
+			return []dto.RedisDiagnoseFinding{rule.finding(node, usage, "内存使用率 %.2f%%, 淘汰策略: %s", usage*100, info.MaxmemoryPolicy)}
+		},
+	},
+	{
+		name:        "hit_rate",
+		description: "key 命中率低于阈值, 访问次数少于 10000 时不检查",
+		severity:    dto.SeverityInfo,
+		threshold:   0.8,
+		checkInfo: func(rule diagnoseRule, node string, info db.RedisInfo) []dto.RedisDiagnoseFinding {
+			total := info.KeyspaceHits + info.KeyspaceMisses
+			if total < hitRateMinRequests {
+				return nil
+			}
+			rate := float64(info.KeyspaceHits) / float64(total)
+			if rate >= rule.threshold {
+				return nil
+			}
+			return []dto.RedisDiagnoseFinding{rule.finding(node, rate, "key 命中率 %.2f%%, 命中 %d 次, 未命中 %d 次", rate*100, info.KeyspaceHits, info.KeyspaceMisses)}
+		},
+	},
+	{
+		name:        "aof_disabled",
+		description: "主库没有开启 AOF 持久化",
+		severity:    dto.SeverityWarning,
+		checkInfo: func(rule diagnoseRule, node string, info db.RedisInfo) []dto.RedisDiagnoseFinding {
+			if info.Role != "master" || info.AofEnabled != 0 {
+				return nil
+			}
+			return []dto.RedisDiagnoseFinding{rule.finding(node, 0, "主库没有开启 AOF 持久化")}
+		},
+	},
+	{
+		name:        "evicted_keys",
+		description: "因内存不足被淘汰的 key 数量超过阈值",
+		severity:    dto.SeverityInfo,
+		checkInfo: func(rule diagnoseRule, node string, info db.RedisInfo) []dto.RedisDiagnoseFinding {
+			if float64(info.EvictedKeys) <= rule.threshold {
+				return nil
+			}
+			return []dto.RedisDiagnoseFinding{rule.finding(node, float64(info.EvictedKeys), "已淘汰 %d 个 key, 淘汰策略: %s", info.EvictedKeys, info.MaxmemoryPolicy)}
+		},
+	},
+	{
+		name:        "rejected_connections",
+		description: "因超过 maxclients 被拒绝的连接数超过阈值",
+		severity:    dto.SeverityWarning,
+		checkInfo: func(rule diagnoseRule, node string, info db.RedisInfo) []dto.RedisDiagnoseFinding {
+			if float64(info.RejectedConnections) <= rule.threshold {
+				return nil
+			}
+			return []dto.RedisDiagnoseFinding{rule.finding(node, float64(info.RejectedConnections), "已拒绝 %d 个连接, 当前连接数 %d", info.RejectedConnections, info.ConnectedClients)}
+		},
+	},
+	{
+		name:        "cluster_state",
+		description: "集群状态不是 ok",
+		severity:    dto.SeverityCritical,
+		checkCluster: func(rule diagnoseRule, info db.RedisClusterInfo) []dto.RedisDiagnoseFinding {
+			if info.ClusterState == "ok" {
+				return nil
+			}
+			return []dto.RedisDiagnoseFinding{rule.finding("", 0, "集群状态为 %s", info.ClusterState)}
+		},
+	},
+	{
+		name:        "cluster_slots",
+		description: "集群有未分配或处于 fail/pfail 状态的 slot",
+		severity:    dto.SeverityCritical,
+		checkCluster: func(rule diagnoseRule, info db.RedisClusterInfo) []dto.RedisDiagnoseFinding {
+			unassigned := db.RedisClusterSlots - int(info.ClusterSlotsAssigned)
+			if unassigned <= 0 && info.ClusterSlotsFail == 0 && info.ClusterSlotsPfail == 0 {
+				return nil
+			}
+			return []dto.RedisDiagnoseFinding{rule.finding("", float64(unassigned), "未分配 slot %d 个, fail %d 个, pfail %d 个", unassigned, info.ClusterSlotsFail, info.ClusterSlotsPfail)}
+		},
+	},
+}
+
+// diagnoseRules 返回合并了配置文件参数的规则
+func diagnoseRules() []diagnoseRule {
+	rules := make([]diagnoseRule, 0, len(defaultDiagnoseRules))
+	for _, rule := range defaultDiagnoseRules {
+		if conf, ok := config.GlobalConfig.RedisAdmin.Diagnose[rule.name]; ok && conf != nil {
+			rule.disabled = conf.Disabled
+			if conf.Severity != "" {
+				rule.severity = conf.Severity
+			}
+			if conf.Threshold != nil {
+				rule.threshold = *conf.Threshold
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// diagnose 使用规则检查各节点的 info 和 cluster info, cluster 为 nil 时不检查集群规则
+func diagnose(rules []diagnoseRule, infos map[string]db.RedisInfo, cluster *db.RedisClusterInfo) []dto.RedisDiagnoseFinding {
+	findings := []dto.RedisDiagnoseFinding{}
+	for _, rule := range rules {
+		if rule.disabled {
+			continue
+		}
+		if rule.checkInfo != nil {
+			for node, info := range infos {
+				findings = append(findings, rule.checkInfo(rule, node, info)...)
+			}
+		}
+		if rule.checkCluster != nil && cluster != nil {
+			findings = append(findings, rule.checkCluster(rule, *cluster)...)
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if severityLevel[findings[i].Severity] != severityLevel[findings[j].Severity] {
+			return severityLevel[findings[i].Severity] > severityLevel[findings[j].Severity]
+		}
+		if findings[i].Rule != findings[j].Rule {
+			return findings[i].Rule < findings[j].Rule
+		}
+		return findings[i].Node < findings[j].Node
+	})
+	return findings
+}
+
+// Diagnose 诊断单个实例, cluster 模式下检查所有节点
+func (r *RedisService) Diagnose(req dto.RedisInstanceReq) (dto.RedisDiagnoseReport, error) {
+	report := dto.RedisDiagnoseReport{Instance: req.Instance, Findings: []dto.RedisDiagnoseFinding{}, CheckedAt: time.Now()}
+
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return report, err
+	}
+	report.Mode = client.Mode()
+
+	infos, err := client.NodeInfos()
+	if err != nil {
+		return report, err
+	}
+
+	var cluster *db.RedisClusterInfo
+	for _, info := range infos {
+		if info.ClusterEnabled == 1 {
+			clusterInfo, err := client.ClusterInfo()
+			if err != nil {
+				return report, err
+			}
+			cluster = &clusterInfo
+			break
+		}
+	}
+
+	report.Findings = diagnose(diagnoseRules(), infos, cluster)
+	return report, nil
+}
+
+// DiagnoseAll 诊断所有实例, 单个实例失败不影响其他实例
+func (r *RedisService) DiagnoseAll() []dto.RedisDiagnoseReport {
+	instances := make([]string, 0, len(config.GlobalConfig.Redis))
+	for name := range config.GlobalConfig.Redis {
+		instances = append(instances, name)
+	}
+	sort.Strings(instances)
+
+	reports := make([]dto.RedisDiagnoseReport, 0, len(instances))
+	for _, name := range instances {
+		report, err := r.Diagnose(dto.RedisInstanceReq{Instance: name})
+		if err != nil {
+			report.Error = err.Error()
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// DiagnoseRules 返回当前生效的诊断规则
+func (r *RedisService) DiagnoseRules() []dto.RedisDiagnoseRule {
+	rules := []dto.RedisDiagnoseRule{}
+	for _, rule := range diagnoseRules() {
+		rules = append(rules, dto.RedisDiagnoseRule{
+			Name:        rule.name,
+			Description: rule.description,
+			Disabled:    rule.disabled,
+			Severity:    rule.severity,
+			Threshold:   rule.threshold,
+		})
+	}
+	return rules
+}
@@ -0,0 +1,117 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-24 11:07:12
+ */
+
+package redisservice
+
+import (
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	infos := map[string]db.RedisInfo{
+		"10.0.0.1:6379": {
+			Role:                  "master",
+			UsedMemory:            1 << 30,
+			Maxmemory:             0,
+			MemFragmentationRatio: 2.1,
+			AofEnabled:            0,
+			KeyspaceHits:          1000,
+			KeyspaceMisses:        19000,
+			Slaves: []db.SlaveInfo{
+				{IP: "10.0.0.2", Port: 6379, State: "online", Lag: 30},
+				{IP: "10.0.0.3", Port: 6379, State: "online", Lag: 0},
+			},
+		},
+		"10.0.0.2:6379": {
+			Role:                   "slave",
+			UsedMemory:             1 << 20,
+			Maxmemory:              2 << 20,
+			MemFragmentationRatio:  5,
+			MasterLinkStatus:       "down",
+			MasterLastIoSecondsAgo: 120,
+		},
+	}
+	cluster := &db.RedisClusterInfo{ClusterState: "fail", ClusterSlotsAssigned: 16000}
+
+	rules := make([]diagnoseRule, len(defaultDiagnoseRules))
+	copy(rules, defaultDiagnoseRules)
+	for i := range rules {
+		if rules[i].name == "evicted_keys" {
+			rules[i].disabled = true
+		}
+	}
+
+	findings := diagnose(rules, infos, cluster)
+
+	got := make(map[string][]string)
+	for _, f := range findings {
+		got[f.Rule] = append(got[f.Rule], f.Node)
+	}
+
+	want := map[string][]string{
+		"replica_lag":         {"10.0.0.1:6379"},
+		"master_link_down":    {"10.0.0.2:6379"},
+		"fragmentation_ratio": {"10.0.0.1:6379"}, // 从库使用内存太小, 不检查碎片率
+		"maxmemory_unset":     {"10.0.0.1:6379"},
+		"hit_rate":            {"10.0.0.1:6379"},
+		"aof_disabled":        {"10.0.0.1:6379"},
+		"cluster_state":       {""},
+		"cluster_slots":       {""},
+	}
+
+	for rule, nodes := range want {
+		if len(got[rule]) != len(nodes) || got[rule][0] != nodes[0] {
+			t.Errorf("rule %s: got nodes %v, want %v", rule, got[rule], nodes)
+		}
+	}
+	for rule := range got {
+		if _, ok := want[rule]; !ok {
+			t.Errorf("unexpected finding for rule %s", rule)
+		}
+	}
+
+	for i := 1; i < len(findings); i++ {
+		if severityLevel[findings[i-1].Severity] < severityLevel[findings[i].Severity] {
+			t.Errorf("findings not sorted by severity: %+v", findings)
+			break
+		}
+	}
+}
+
+func TestDiagnoseRulesOverride(t *testing.T) {
+	saved := config.GlobalConfig
+	defer func() { config.GlobalConfig = saved }()
+
+	zero := 0.0
+	config.GlobalConfig = &config.GlobalConfigs{RedisAdmin: &config.RedisAdminConfig{Diagnose: map[string]*config.RedisDiagnoseRule{
+		"replica_lag":  {Threshold: &zero},               // 阈值可以配置为 0
+		"hit_rate":     {Severity: dto.SeverityCritical}, // 只修改严重程度, 阈值使用默认值
+		"evicted_keys": {Disabled: true},
+	}}}
+
+	defaults := make(map[string]diagnoseRule)
+	for _, rule := range defaultDiagnoseRules {
+		defaults[rule.name] = rule
+	}
+	for _, rule := range diagnoseRules() {
+		switch rule.name {
+		case "replica_lag":
+			if rule.threshold != 0 {
+				t.Errorf("replica_lag threshold = %v, want 0", rule.threshold)
+			}
+		case "hit_rate":
+			if rule.severity != dto.SeverityCritical || rule.threshold != defaults[rule.name].threshold {
+				t.Errorf("hit_rate got %+v", rule)
+			}
+		case "evicted_keys":
+			if !rule.disabled {
+				t.Error("evicted_keys should be disabled")
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 15:48:52
+ */
+
+package redisservice
+
+import (
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"strings"
+	"time"
+)
+
+func (r *RedisService) ScanKeys(req dto.RedisKeyScanReq) (db.RedisScanResult, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return db.RedisScanResult{}, err
+	}
+	return client.ScanKeys(req.Cursor, req.Pattern, req.Type, req.Count)
+}
+
+func (r *RedisService) KeyMeta(req dto.RedisKeyReq) (db.RedisKeyMeta, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return db.RedisKeyMeta{}, err
+	}
+	return client.KeyMeta(req.Key)
+}
+
+func (r *RedisService) KeyValue(req dto.RedisKeyValueReq) (db.RedisKeyValue, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return db.RedisKeyValue{}, err
+	}
+	return client.KeyValue(req.Key, db.RedisValueQuery{
+		Cursor:      req.Cursor,
+		Offset:      req.Offset,
+		Count:       req.Count,
+		Match:       req.Match,
+		StreamStart: req.StreamStart,
+	})
+}
+
+func (r *RedisService) KeyExpire(operator model.User, req dto.RedisKeyExpireReq) error {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	err = client.KeyExpire(req.Key, time.Duration(req.TTL)*time.Second)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "key.expire", req.Key, req, err)
+	return err
+}
+
+func (r *RedisService) KeyDelete(operator model.User, req dto.RedisKeyDeleteReq) (dto.RedisKeyDeleteResp, error) {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return dto.RedisKeyDeleteResp{}, err
+	}
+
+	deleted, err := client.KeyDelete(req.Keys...)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "key.delete", strings.Join(req.Keys, ","), req, err)
+	return dto.RedisKeyDeleteResp{Deleted: deleted}, err
+}
+
+func (r *RedisService) KeyRename(operator model.User, req dto.RedisKeyRenameReq) error {
+	client, err := r.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	err = client.KeyRename(req.Key, req.NewKey, req.Overwrite)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "key.rename", req.Key, req, err)
+	return err
+}
@@ -0,0 +1,33 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-02 15:41:07
+ */
+
+package redisservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+)
+
+const AuditModule = "redis"
+
+type RedisService struct{}
+
+func NewRedisService() *RedisService {
+	return &RedisService{}
+}
+
+// client 根据配置文件中的实例名获取 redis 连接
+func (r *RedisService) client(instance string) (*db.RedisClient, error) {
+	if _, ok := config.GlobalConfig.Redis[instance]; !ok {
+		return nil, fmt.Errorf("redis 实例: %s 不存在", instance)
+	}
+
+	client := db.Redis(instance)
+	if client == nil || client.Conn == nil {
+		return nil, fmt.Errorf("redis 实例: %s 连接不可用", instance)
+	}
+	return client, nil
+}
@@ -0,0 +1,226 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-25 14:08:31
+ */
+
+package s3service
+
+import (
+	"errors"
+	"fmt"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"myadmin/internal/task"
+)
+
+// Policy 获取 bucket 策略, 没有策略时返回 null
+func (s *S3Service) Policy(req dto.S3BucketReq) (*db.S3Policy, error) {
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := client.GetBucketPolicy(req.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("获取 bucket %s 的策略失败: %v", req.Bucket, err)
+	}
+	return policy, nil
+}
+
+// PolicyPut 设置 bucket 策略, 按 grants 生成策略时可以先 dry_run 查看生成的策略
+func (s *S3Service) PolicyPut(operator model.User, req dto.S3PolicyPutReq) (db.S3Policy, error) {
+	var policy db.S3Policy
+	switch {
+	case req.Policy != nil && len(req.Grants) > 0:
+		return policy, errors.New("policy 和 grants 只能指定一个")
+	case req.Policy != nil:
+		policy = *req.Policy
+	case len(req.Grants) > 0:
+		var err error
+		if policy, err = db.BuildBucketPolicy(req.Bucket, req.Grants); err != nil {
+			return policy, err
+		}
+	default:
+		return policy, errors.New("需要指定 policy 或 grants")
+	}
+	if req.DryRun {
+		return policy, nil
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return policy, err
+	}
+
+	err = client.PutBucketPolicy(req.Bucket, policy)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "bucket.policy.put", req.Bucket, policy, err)
+	if err != nil {
+		return policy, fmt.Errorf("设置 bucket %s 的策略失败: %v", req.Bucket, err)
+	}
+	return policy, nil
+}
+
+// PolicyDelete 删除 bucket 策略
+func (s *S3Service) PolicyDelete(operator model.User, req dto.S3BucketReq) error {
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	err = client.DeleteBucketPolicy(req.Bucket)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "bucket.policy.delete", req.Bucket, nil, err)
+	if err != nil {
+		return fmt.Errorf("删除 bucket %s 的策略失败: %v", req.Bucket, err)
+	}
+	return nil
+}
+
+// Lifecycle 获取生命周期规则
+func (s *S3Service) Lifecycle(req dto.S3BucketReq) ([]db.S3LifecycleRule, error) {
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := client.GetBucketLifecycle(req.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("获取 bucket %s 的生命周期规则失败: %v", req.Bucket, err)
+	}
+	return rules, nil
+}
+
+// LifecyclePut 设置生命周期规则, 替换原有的所有规则
+func (s *S3Service) LifecyclePut(operator model.User, req dto.S3LifecyclePutReq) error {
+	if err := db.ValidateLifecycleRules(req.Rules); err != nil {
+		return err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	err = client.PutBucketLifecycle(req.Bucket, req.Rules)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "bucket.lifecycle.put", req.Bucket, req.Rules, err)
+	if err != nil {
+		return fmt.Errorf("设置 bucket %s 的生命周期规则失败: %v", req.Bucket, err)
+	}
+	return nil
+}
+
+// LifecycleDelete 删除所有生命周期规则
+func (s *S3Service) LifecycleDelete(operator model.User, req dto.S3BucketReq) error {
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	err = client.DeleteBucketLifecycle(req.Bucket)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "bucket.lifecycle.delete", req.Bucket, nil, err)
+	if err != nil {
+		return fmt.Errorf("删除 bucket %s 的生命周期规则失败: %v", req.Bucket, err)
+	}
+	return nil
+}
+
+// CORS 获取跨域规则
+func (s *S3Service) CORS(req dto.S3BucketReq) ([]db.S3CORSRule, error) {
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := client.GetBucketCORS(req.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("获取 bucket %s 的跨域规则失败: %v", req.Bucket, err)
+	}
+	return rules, nil
+}
+
+// CORSPut 设置跨域规则, 替换原有的所有规则
+func (s *S3Service) CORSPut(operator model.User, req dto.S3CORSPutReq) error {
+	if err := db.ValidateCORSRules(req.Rules); err != nil {
+		return err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	err = client.PutBucketCORS(req.Bucket, req.Rules)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "bucket.cors.put", req.Bucket, req.Rules, err)
+	if err != nil {
+		return fmt.Errorf("设置 bucket %s 的跨域规则失败: %v", req.Bucket, err)
+	}
+	return nil
+}
+
+// CORSDelete 删除所有跨域规则
+func (s *S3Service) CORSDelete(operator model.User, req dto.S3BucketReq) error {
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	err = client.DeleteBucketCORS(req.Bucket)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "bucket.cors.delete", req.Bucket, nil, err)
+	if err != nil {
+		return fmt.Errorf("删除 bucket %s 的跨域规则失败: %v", req.Bucket, err)
+	}
+	return nil
+}
+
+// Versioning 获取版本控制状态
+func (s *S3Service) Versioning(req dto.S3BucketReq) (dto.S3VersioningResp, error) {
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return dto.S3VersioningResp{}, err
+	}
+
+	status, err := client.GetBucketVersioning(req.Bucket)
+	if err != nil {
+		return dto.S3VersioningResp{}, fmt.Errorf("获取 bucket %s 的版本控制状态失败: %v", req.Bucket, err)
+	}
+	return dto.S3VersioningResp{Bucket: req.Bucket, Status: status}, nil
+}
+
+// VersioningPut 开启或暂停版本控制
+func (s *S3Service) VersioningPut(operator model.User, req dto.S3VersioningReq) (dto.S3VersioningResp, error) {
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return dto.S3VersioningResp{}, err
+	}
+
+	err = client.SetBucketVersioning(req.Bucket, *req.Enabled)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "bucket.versioning.put", req.Bucket, map[string]any{"enabled": *req.Enabled}, err)
+	if err != nil {
+		return dto.S3VersioningResp{}, fmt.Errorf("设置 bucket %s 的版本控制失败: %v", req.Bucket, err)
+	}
+	return s.Versioning(dto.S3BucketReq{Instance: req.Instance, Bucket: req.Bucket})
+}
+
+// Usage 统计 bucket 的 object 数量和大小, 需要遍历整个 bucket, 以后台任务执行
+func (s *S3Service) Usage(operator model.User, req dto.S3BucketReq) (task.TaskInfo, error) {
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return task.TaskInfo{}, err
+	}
+
+	t := task.Tasks.Submit("bucket.usage", AuditModule, req.Instance, operator.Username, func(t *task.Task) error {
+		return t.Step("list "+req.Bucket, func() error {
+			usage, err := client.BucketUsage(req.Bucket, func(usage db.S3BucketUsage) {
+				t.SetResult(usage)
+			})
+			if err != nil {
+				return err
+			}
+			t.SetResult(usage)
+			t.Logf("bucket %s 共 %d 个 object, %d 字节", req.Bucket, usage.Objects, usage.Size)
+			return nil
+		})
+	})
+	return t.Snapshot(), nil
+}
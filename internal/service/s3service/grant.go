@@ -0,0 +1,65 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-26 16:48:35
+ */
+
+package s3service
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/dao"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"strings"
+)
+
+// GrantList 项目的 bucket 授权
+func (s *S3Service) GrantList(req dto.S3GrantListReq) ([]model.ProjectBucketGrant, error) {
+	grants, err := dao.NewProjectBucketGrantDao().List(req.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if grants == nil {
+		grants = []model.ProjectBucketGrant{}
+	}
+	return grants, nil
+}
+
+// GrantCreate 为项目授权访问 bucket 中前缀下的 object
+func (s *S3Service) GrantCreate(operator model.User, req dto.S3GrantCreateReq) (model.ProjectBucketGrant, error) {
+	if _, ok := config.GlobalConfig.S3[req.Instance]; !ok {
+		return model.ProjectBucketGrant{}, fmt.Errorf("s3 实例: %s 不存在", req.Instance)
+	}
+	if req.Prefix != "" {
+		if err := validateObjectKey(req.Prefix); err != nil {
+			return model.ProjectBucketGrant{}, err
+		}
+		if !strings.HasSuffix(req.Prefix, objectDelimiter) {
+			return model.ProjectBucketGrant{}, fmt.Errorf("前缀 %s 必须以 / 结尾", req.Prefix)
+		}
+	}
+
+	grant := model.ProjectBucketGrant{
+		ProjectID: req.ProjectID,
+		Instance:  req.Instance,
+		Bucket:    req.Bucket,
+		Prefix:    req.Prefix,
+		Access:    req.Access,
+		CreateBy:  operator.ID,
+	}
+	err := dao.NewProjectBucketGrantDao().Create(&grant)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "grant.create", req.Bucket+"/"+req.Prefix, req, err)
+	return grant, err
+}
+
+// GrantDelete 删除授权, 已经签发的临时凭证在过期前仍然有效
+func (s *S3Service) GrantDelete(operator model.User, req dto.S3GrantDeleteReq) error {
+	affected, err := dao.NewProjectBucketGrantDao().Delete(req.ID)
+	if err == nil && affected == 0 {
+		err = fmt.Errorf("授权 %d 不存在", req.ID)
+	}
+	auditservice.NewAuditService().Record(operator, AuditModule, "", "grant.delete", fmt.Sprint(req.ID), nil, err)
+	return err
+}
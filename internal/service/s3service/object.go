@@ -0,0 +1,203 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-23 10:34:07
+ */
+
+package s3service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+	maxDeleteKeys    = 1000
+	maxObjectKeyLen  = 1024
+	objectDelimiter  = "/"
+)
+
+// Buckets 查询 bucket 列表
+func (s *S3Service) Buckets(req dto.S3InstanceReq) ([]db.S3Bucket, error) {
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := client.ListBuckets()
+	if err != nil {
+		return nil, err
+	}
+	if buckets == nil {
+		buckets = []db.S3Bucket{}
+	}
+	return buckets, nil
+}
+
+// Objects 分页浏览 bucket, 默认按 / 分组, 前缀下一级的"目录"在 common_prefixes 中返回
+// 非管理员只能浏览所在项目有读授权的前缀
+func (s *S3Service) Objects(operator model.User, req dto.S3ObjectListReq) (db.S3ObjectPage, error) {
+	if req.Prefix != "" && !strings.HasSuffix(req.Prefix, objectDelimiter) {
+		return db.S3ObjectPage{}, fmt.Errorf("前缀 %s 必须以 / 结尾", req.Prefix)
+	}
+	if err := authorize(operator, req.ProjectID, req.Instance, req.Bucket, req.Prefix, db.S3AccessRead); err != nil {
+		return db.S3ObjectPage{}, err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return db.S3ObjectPage{}, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	limit = min(limit, maxListLimit)
+
+	delimiter := objectDelimiter
+	if req.Recursive {
+		delimiter = ""
+	}
+
+	page, err := client.ListObjectPage(req.Bucket, req.Prefix, delimiter, req.Token, limit)
+	if err != nil {
+		return page, fmt.Errorf("查询 bucket %s 失败: %v", req.Bucket, err)
+	}
+	return page, nil
+}
+
+// Upload 将 multipart 请求中的文件依次上传到 prefix 下, 请求体直接转发给 s3, 不写入本地临时文件
+// 某个文件上传失败时返回错误, 之前已经上传的文件保留, 在 Keys 中返回
+func (s *S3Service) Upload(operator model.User, req dto.S3UploadReq, reader *multipart.Reader) (dto.S3UploadResp, error) {
+	resp := dto.S3UploadResp{Keys: []string{}}
+	if req.Prefix != "" {
+		if err := validateObjectKey(req.Prefix); err != nil {
+			return resp, err
+		}
+		if !strings.HasSuffix(req.Prefix, objectDelimiter) {
+			return resp, fmt.Errorf("前缀 %s 必须以 / 结尾", req.Prefix)
+		}
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return resp, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return resp, fmt.Errorf("读取上传文件失败: %v", err)
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		key, err := uploadObjectKey(req.Prefix, part.FileName())
+		if err == nil {
+			err = client.PutObjectStream(req.Bucket, key, part.Header.Get("Content-Type"), part)
+			auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "object.upload", req.Bucket+"/"+key, nil, err)
+		}
+		part.Close()
+		if err != nil {
+			return resp, fmt.Errorf("上传文件 %s 失败: %v", part.FileName(), err)
+		}
+		resp.Keys = append(resp.Keys, key)
+	}
+
+	if len(resp.Keys) == 0 {
+		return resp, errors.New("请求中没有文件")
+	}
+	return resp, nil
+}
+
+// Download 获取 object 的内容, 调用方负责关闭 Body. 非管理员只能下载所在项目有读授权的 object
+func (s *S3Service) Download(operator model.User, req dto.S3DownloadReq) (*s3.GetObjectOutput, error) {
+	if err := validateObjectKey(req.Key); err != nil {
+		return nil, err
+	}
+	if err := authorize(operator, req.ProjectID, req.Instance, req.Bucket, req.Key, db.S3AccessRead); err != nil {
+		return nil, err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := client.GetObjectStream(req.Bucket, req.Key)
+	if err != nil {
+		return nil, fmt.Errorf("下载 %s/%s 失败: %v", req.Bucket, req.Key, err)
+	}
+	return out, nil
+}
+
+// Delete 批量删除 object, 部分删除失败时在 errors 中返回
+func (s *S3Service) Delete(operator model.User, req dto.S3DeleteReq) (db.S3DeleteResult, error) {
+	if len(req.Keys) > maxDeleteKeys {
+		return db.S3DeleteResult{}, fmt.Errorf("单次最多删除 %d 个 object", maxDeleteKeys)
+	}
+	for _, key := range req.Keys {
+		if err := validateObjectKey(key); err != nil {
+			return db.S3DeleteResult{}, err
+		}
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return db.S3DeleteResult{}, err
+	}
+
+	result, err := client.DeleteObjects(req.Bucket, req.Keys)
+	if err == nil && len(result.Errors) > 0 {
+		auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "object.delete", req.Bucket, result, fmt.Errorf("%d 个 object 删除失败", len(result.Errors)))
+		return result, nil
+	}
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "object.delete", req.Bucket, req.Keys, err)
+	return result, err
+}
+
+// uploadObjectKey 上传文件的 object 名称, 浏览器可能上传带路径的文件名, 只保留最后一级
+func uploadObjectKey(prefix, filename string) (string, error) {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		return "", fmt.Errorf("文件名 %s 不正确", filename)
+	}
+	key := prefix + name
+	return key, validateObjectKey(key)
+}
+
+// validateObjectKey 校验 object 名称, 不允许以 / 开头和包含 . 或 .. 路径
+func validateObjectKey(key string) error {
+	if key == "" {
+		return errors.New("object 名称不能为空")
+	}
+	if len(key) > maxObjectKeyLen {
+		return fmt.Errorf("object 名称长度不能超过 %d", maxObjectKeyLen)
+	}
+	if strings.HasPrefix(key, objectDelimiter) {
+		return fmt.Errorf("object 名称 %s 不能以 / 开头", key)
+	}
+	for _, seg := range strings.Split(key, objectDelimiter) {
+		if seg == "." || seg == ".." {
+			return fmt.Errorf("object 名称 %s 不能包含 . 或 .. 路径", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-23 11:26:30
+ */
+
+package s3service
+
+import "testing"
+
+func TestUploadObjectKey(t *testing.T) {
+	cases := []struct {
+		prefix, filename, key string
+		ok                    bool
+	}{
+		{"", "a.txt", "a.txt", true},
+		{"docs/", "a.txt", "docs/a.txt", true},
+		{"docs/", `C:\Users\me\a.txt`, "docs/a.txt", true},
+		{"docs/", "../../a.txt", "docs/a.txt", true},
+		{"docs/", "..", "", false},
+		{"../", "a.txt", "", false},
+		{"", "/", "", false},
+	}
+	for _, c := range cases {
+		key, err := uploadObjectKey(c.prefix, c.filename)
+		if (err == nil) != c.ok {
+			t.Errorf("uploadObjectKey(%q, %q) err = %v, want ok %v", c.prefix, c.filename, err, c.ok)
+			continue
+		}
+		if c.ok && key != c.key {
+			t.Errorf("uploadObjectKey(%q, %q) = %q, want %q", c.prefix, c.filename, key, c.key)
+		}
+	}
+}
+
+func TestValidateObjectKey(t *testing.T) {
+	for key, ok := range map[string]bool{
+		"a/b/c.txt": true,
+		"a//b":      true,
+		"":          false,
+		"/a":        false,
+		"a/./b":     false,
+		"a/../b":    false,
+		"a/b/..":    false,
+		"a..b/c..":  true,
+	} {
+		if err := validateObjectKey(key); (err == nil) != ok {
+			t.Errorf("validateObjectKey(%q) err = %v, want ok %v", key, err, ok)
+		}
+	}
+}
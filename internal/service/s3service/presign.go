@@ -0,0 +1,338 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-26 15:11:09
+ */
+
+package s3service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/dao"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	defaultPresignMaxExpireSec = 3600
+	defaultPresignMaxSizeMB    = 5120
+	defaultStsMaxDurationSec   = 3600
+	minStsDurationSec          = 900 // AssumeRole 允许的最短有效期
+)
+
+func presignMaxExpire() time.Duration {
+	sec := config.GlobalConfig.S3Admin.PresignMaxExpireSec
+	if sec <= 0 {
+		sec = defaultPresignMaxExpireSec
+	}
+	return time.Duration(sec) * time.Second
+}
+
+func presignMaxSize() int64 {
+	mb := config.GlobalConfig.S3Admin.PresignMaxSizeMB
+	if mb <= 0 {
+		mb = defaultPresignMaxSizeMB
+	}
+	return mb << 20
+}
+
+// presignExpire 预签名 URL 的有效期, 为 0 时使用最长有效期
+func presignExpire(seconds int64) (time.Duration, error) {
+	maxExpire := presignMaxExpire()
+	if seconds < 0 {
+		return 0, errors.New("有效期不能小于 0")
+	}
+	if seconds == 0 {
+		return maxExpire, nil
+	}
+	if expire := time.Duration(seconds) * time.Second; expire <= maxExpire {
+		return expire, nil
+	}
+	return 0, fmt.Errorf("有效期不能超过 %d 秒", int64(maxExpire.Seconds()))
+}
+
+// authorize 检查用户是否可以访问 bucket 中的 key 或前缀. 管理员不受限制, 其他用户需要所在项目有覆盖 key 的授权
+func authorize(user model.User, projectID uint, instance, bucket, key, access string) error {
+	if user.Role == model.UserRoleAdmin || user.Role == model.UserRoleSeniorAdmin || user.Role == model.UserRoleRoot {
+		return nil
+	}
+	if projectID == 0 {
+		return errors.New("需要指定项目")
+	}
+
+	grants, err := dao.NewProjectBucketGrantDao().UserGrants(user.ID, projectID, instance, bucket)
+	if err != nil {
+		return fmt.Errorf("查询项目授权失败: %v", err)
+	}
+	if !grantCovers(grants, key, access) {
+		return fmt.Errorf("项目没有 %s/%s 的 %s 权限", bucket, key, access)
+	}
+	return nil
+}
+
+// grantCovers 授权的前缀是 key 的前缀, 并且权限包含请求的权限
+func grantCovers(grants []model.ProjectBucketGrant, key, access string) bool {
+	for _, g := range grants {
+		if strings.HasPrefix(key, g.Prefix) && db.S3AccessCovers(g.Access, access) {
+			return true
+		}
+	}
+	return false
+}
+
+// PresignPut 生成上传 object 的预签名 URL, 上传时必须带上返回的请求头, 文件大小签名在 Content-Length 中
+func (s *S3Service) PresignPut(operator model.User, req dto.S3PresignPutReq) (db.S3PresignedRequest, error) {
+	if err := validateObjectKey(req.Key); err != nil {
+		return db.S3PresignedRequest{}, err
+	}
+	expire, err := presignExpire(req.ExpireSeconds)
+	if err != nil {
+		return db.S3PresignedRequest{}, err
+	}
+	if req.Size <= 0 || req.Size > presignMaxSize() {
+		return db.S3PresignedRequest{}, fmt.Errorf("文件大小必须在 1 到 %d 字节之间", presignMaxSize())
+	}
+	if err := authorize(operator, req.ProjectID, req.Instance, req.Bucket, req.Key, db.S3AccessReadWrite); err != nil {
+		return db.S3PresignedRequest{}, err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return db.S3PresignedRequest{}, err
+	}
+
+	result, err := client.PresignPut(req.Bucket, req.Key, req.ContentType, req.Size, expire)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "presign.put", req.Bucket+"/"+req.Key, req, err)
+	return result, err
+}
+
+// PresignPost 生成表单上传的签名, 文件大小和 Content-Type 由 s3 在上传时校验
+func (s *S3Service) PresignPost(operator model.User, req dto.S3PresignPostReq) (db.S3PresignedPost, error) {
+	if err := validateObjectKey(req.Key); err != nil {
+		return db.S3PresignedPost{}, err
+	}
+	expire, err := presignExpire(req.ExpireSeconds)
+	if err != nil {
+		return db.S3PresignedPost{}, err
+	}
+	maxSize := req.MaxSize
+	if maxSize == 0 {
+		maxSize = presignMaxSize()
+	}
+	if maxSize < 0 || maxSize > presignMaxSize() {
+		return db.S3PresignedPost{}, fmt.Errorf("文件大小限制必须在 1 到 %d 字节之间", presignMaxSize())
+	}
+	if err := authorize(operator, req.ProjectID, req.Instance, req.Bucket, req.Key, db.S3AccessReadWrite); err != nil {
+		return db.S3PresignedPost{}, err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return db.S3PresignedPost{}, err
+	}
+
+	result, err := client.PresignPost(req.Bucket, req.Key, req.ContentType, maxSize, expire)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "presign.post", req.Bucket+"/"+req.Key, req, err)
+	return result, err
+}
+
+// MultipartCreate 创建分片上传并为每个分片生成预签名 URL. 分片上传无法在上传时限制大小, 合并时检查
+func (s *S3Service) MultipartCreate(operator model.User, req dto.S3MultipartCreateReq) (dto.S3MultipartCreateResp, error) {
+	var resp dto.S3MultipartCreateResp
+	if err := validateObjectKey(req.Key); err != nil {
+		return resp, err
+	}
+	expire, err := presignExpire(req.ExpireSeconds)
+	if err != nil {
+		return resp, err
+	}
+	if req.Size > presignMaxSize() {
+		return resp, fmt.Errorf("文件大小不能超过 %d 字节", presignMaxSize())
+	}
+	partSize, count, err := db.MultipartPartSize(req.Size, req.PartSize)
+	if err != nil {
+		return resp, err
+	}
+	if err := authorize(operator, req.ProjectID, req.Instance, req.Bucket, req.Key, db.S3AccessReadWrite); err != nil {
+		return resp, err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return resp, err
+	}
+
+	uploadID, err := client.CreateMultipartUpload(req.Bucket, req.Key, req.ContentType)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "multipart.create", req.Bucket+"/"+req.Key, map[string]any{"upload_id": uploadID, "request": req}, err)
+	if err != nil {
+		return resp, fmt.Errorf("创建分片上传失败: %v", err)
+	}
+
+	resp = dto.S3MultipartCreateResp{UploadID: uploadID, PartSize: partSize, Parts: make([]dto.S3MultipartPart, 0, count), Expiration: time.Now().Add(expire)}
+	for n := int64(1); n <= count; n++ {
+		url, err := client.PresignUploadPart(req.Bucket, req.Key, uploadID, n, expire)
+		if err != nil {
+			client.AbortMultipartUpload(req.Bucket, req.Key, uploadID)
+			return dto.S3MultipartCreateResp{}, fmt.Errorf("生成分片 %d 的预签名 URL 失败: %v", n, err)
+		}
+		resp.Parts = append(resp.Parts, dto.S3MultipartPart{PartNumber: n, URL: url})
+	}
+	return resp, nil
+}
+
+// MultipartComplete 合并分片. 已上传的分片总大小超过文件大小或配置的上限时取消上传, 其他校验失败时可以重新上传分片后再合并
+func (s *S3Service) MultipartComplete(operator model.User, req dto.S3MultipartCompleteReq) error {
+	if err := validateObjectKey(req.Key); err != nil {
+		return err
+	}
+	if err := authorize(operator, req.ProjectID, req.Instance, req.Bucket, req.Key, db.S3AccessReadWrite); err != nil {
+		return err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	uploaded, err := client.ListUploadedParts(req.Bucket, req.Key, req.UploadID)
+	if err != nil {
+		return fmt.Errorf("查询已上传的分片失败: %v", err)
+	}
+	if err := checkUploadedParts(uploaded, req.Parts, min(req.Size, presignMaxSize())); err != nil {
+		if !errors.Is(err, errUploadTooLarge) {
+			return err
+		}
+		if abortErr := client.AbortMultipartUpload(req.Bucket, req.Key, req.UploadID); abortErr != nil {
+			err = fmt.Errorf("%v, 取消上传失败: %v", err, abortErr)
+		}
+		auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "multipart.complete", req.Bucket+"/"+req.Key, map[string]any{"upload_id": req.UploadID}, err)
+		return err
+	}
+
+	err = client.CompleteMultipartUpload(req.Bucket, req.Key, req.UploadID, req.Parts)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "multipart.complete", req.Bucket+"/"+req.Key, map[string]any{"upload_id": req.UploadID, "parts": len(req.Parts)}, err)
+	if err != nil {
+		return fmt.Errorf("合并分片失败: %v", err)
+	}
+	return nil
+}
+
+var errUploadTooLarge = errors.New("上传的文件过大")
+
+// checkUploadedParts 请求合并的分片必须都已上传并且 etag 一致, 已上传的分片总大小不能超过 maxSize
+func checkUploadedParts(uploaded, parts []db.S3UploadedPart, maxSize int64) error {
+	var total int64
+	byNumber := make(map[int64]db.S3UploadedPart, len(uploaded))
+	for _, p := range uploaded {
+		total += p.Size
+		byNumber[p.PartNumber] = p
+	}
+	if total > maxSize {
+		return fmt.Errorf("%w: 已上传 %d 字节, 超过了 %d 字节, 已取消上传", errUploadTooLarge, total, maxSize)
+	}
+
+	for _, p := range parts {
+		u, ok := byNumber[p.PartNumber]
+		if !ok {
+			return fmt.Errorf("分片 %d 没有上传", p.PartNumber)
+		}
+		if strings.Trim(u.ETag, `"`) != strings.Trim(p.ETag, `"`) {
+			return fmt.Errorf("分片 %d 的 etag 不一致", p.PartNumber)
+		}
+	}
+	return nil
+}
+
+// MultipartAbort 取消分片上传
+func (s *S3Service) MultipartAbort(operator model.User, req dto.S3MultipartAbortReq) error {
+	if err := validateObjectKey(req.Key); err != nil {
+		return err
+	}
+	if err := authorize(operator, req.ProjectID, req.Instance, req.Bucket, req.Key, db.S3AccessReadWrite); err != nil {
+		return err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	err = client.AbortMultipartUpload(req.Bucket, req.Key, req.UploadID)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "multipart.abort", req.Bucket+"/"+req.Key, map[string]any{"upload_id": req.UploadID}, err)
+	if err != nil {
+		return fmt.Errorf("取消分片上传失败: %v", err)
+	}
+	return nil
+}
+
+// Sts 签发临时凭证, 会话策略按 bucket, 前缀和权限生成, 凭证的权限是角色权限和会话策略的交集
+// 凭证只通过本次接口返回, 不写入审计日志
+func (s *S3Service) Sts(operator model.User, req dto.S3StsReq) (dto.S3StsResp, error) {
+	if req.Prefix != "" {
+		if err := validateObjectKey(req.Prefix); err != nil {
+			return dto.S3StsResp{}, err
+		}
+	}
+
+	maxDuration := config.GlobalConfig.S3Admin.StsMaxDurationSec
+	if maxDuration <= 0 {
+		maxDuration = defaultStsMaxDurationSec
+	}
+	duration := req.DurationSeconds
+	if duration == 0 {
+		duration = maxDuration
+	}
+	if duration < minStsDurationSec || duration > maxDuration {
+		return dto.S3StsResp{}, fmt.Errorf("有效期必须在 %d 到 %d 秒之间", minStsDurationSec, maxDuration)
+	}
+
+	if err := authorize(operator, req.ProjectID, req.Instance, req.Bucket, req.Prefix, req.Access); err != nil {
+		return dto.S3StsResp{}, err
+	}
+
+	conf := config.GlobalConfig.S3[req.Instance]
+	if conf == nil || conf.StsRoleArn == "" {
+		return dto.S3StsResp{}, fmt.Errorf("s3 实例: %s 没有配置 StsRoleArn, 不能签发临时凭证", req.Instance)
+	}
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return dto.S3StsResp{}, err
+	}
+
+	policy, err := db.BuildSessionPolicy(req.Bucket, req.Prefix, req.Access)
+	if err != nil {
+		return dto.S3StsResp{}, err
+	}
+	body, err := json.Marshal(policy)
+	if err != nil {
+		return dto.S3StsResp{}, err
+	}
+
+	out, err := client.GenerateS3StsToken(fmt.Sprintf("myadmin-%s-%d", operator.Username, time.Now().Unix()), conf.StsRoleArn, string(body), duration)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "sts", req.Bucket+"/"+req.Prefix, req, err)
+	if err != nil {
+		return dto.S3StsResp{}, fmt.Errorf("签发临时凭证失败: %v", err)
+	}
+	if out.Credentials == nil {
+		return dto.S3StsResp{}, errors.New("签发临时凭证失败: 返回结果中没有凭证")
+	}
+
+	return dto.S3StsResp{
+		AccessKeyID:     aws.StringValue(out.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(out.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(out.Credentials.SessionToken),
+		Expiration:      aws.TimeValue(out.Credentials.Expiration),
+		EndPoint:        conf.EndPoint,
+		Bucket:          req.Bucket,
+		Prefix:          req.Prefix,
+		Access:          req.Access,
+	}, nil
+}
@@ -0,0 +1,56 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-26 17:52:06
+ */
+
+package s3service
+
+import (
+	"errors"
+	"myadmin/internal/db"
+	"myadmin/internal/model"
+	"testing"
+)
+
+func TestGrantCovers(t *testing.T) {
+	grants := []model.ProjectBucketGrant{
+		{Prefix: "team/", Access: db.S3AccessRead},
+		{Prefix: "team/upload/", Access: db.S3AccessReadWrite},
+	}
+	tests := []struct {
+		key, access string
+		want        bool
+	}{
+		{"team/a.txt", db.S3AccessRead, true},
+		{"team/a.txt", db.S3AccessReadWrite, false},
+		{"team/upload/a.txt", db.S3AccessReadWrite, true},
+		{"team/upload/", db.S3AccessRead, true},
+		{"other/a.txt", db.S3AccessRead, false},
+		{"team", db.S3AccessRead, false},
+	}
+	for _, tt := range tests {
+		if got := grantCovers(grants, tt.key, tt.access); got != tt.want {
+			t.Errorf("grantCovers(%q, %q) = %v, want %v", tt.key, tt.access, got, tt.want)
+		}
+	}
+	if grantCovers([]model.ProjectBucketGrant{{Prefix: "", Access: db.S3AccessReadWrite}}, "any/key", db.S3AccessReadWrite) != true {
+		t.Error("empty prefix should cover the whole bucket")
+	}
+}
+
+func TestCheckUploadedParts(t *testing.T) {
+	uploaded := []db.S3UploadedPart{{PartNumber: 1, ETag: `"a"`, Size: 5 << 20}, {PartNumber: 2, ETag: `"b"`, Size: 1 << 20}}
+
+	if err := checkUploadedParts(uploaded, []db.S3UploadedPart{{PartNumber: 1, ETag: "a"}, {PartNumber: 2, ETag: `"b"`}}, 6<<20); err != nil {
+		t.Error(err)
+	}
+	if err := checkUploadedParts(uploaded, []db.S3UploadedPart{{PartNumber: 1, ETag: "a"}}, 6<<20-1); !errors.Is(err, errUploadTooLarge) {
+		t.Errorf("err = %v, want errUploadTooLarge", err)
+	}
+	if err := checkUploadedParts(uploaded, []db.S3UploadedPart{{PartNumber: 3, ETag: "c"}}, 6<<20); err == nil || errors.Is(err, errUploadTooLarge) {
+		t.Errorf("missing part err = %v", err)
+	}
+	if err := checkUploadedParts(uploaded, []db.S3UploadedPart{{PartNumber: 2, ETag: "x"}}, 6<<20); err == nil {
+		t.Error("etag mismatch should fail")
+	}
+}
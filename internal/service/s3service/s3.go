@@ -0,0 +1,33 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-23 10:20:51
+ */
+
+package s3service
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+)
+
+const AuditModule = "s3"
+
+type S3Service struct{}
+
+func NewS3Service() *S3Service {
+	return &S3Service{}
+}
+
+// client 根据配置文件中的实例名获取 s3 连接
+func (s *S3Service) client(instance string) (*db.S3Ceph, error) {
+	if _, ok := config.GlobalConfig.S3[instance]; !ok {
+		return nil, fmt.Errorf("s3 实例: %s 不存在", instance)
+	}
+
+	client := db.S3(instance)
+	if client == nil || client.Session == nil {
+		return nil, fmt.Errorf("s3 实例: %s 连接不可用", instance)
+	}
+	return client, nil
+}
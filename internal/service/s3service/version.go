@@ -0,0 +1,85 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-24 11:02:55
+ */
+
+package s3service
+
+import (
+	"fmt"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/model"
+	"myadmin/internal/service/auditservice"
+	"strings"
+)
+
+// Versions 分页查询 object 的历史版本和删除标记, bucket 需要开启版本控制. 非管理员只能查询所在项目有读授权的前缀
+func (s *S3Service) Versions(operator model.User, req dto.S3VersionListReq) (db.S3VersionPage, error) {
+	if req.Prefix != "" && !strings.HasSuffix(req.Prefix, objectDelimiter) && !req.Recursive {
+		return db.S3VersionPage{}, fmt.Errorf("前缀 %s 必须以 / 结尾", req.Prefix)
+	}
+	if err := authorize(operator, req.ProjectID, req.Instance, req.Bucket, req.Prefix, db.S3AccessRead); err != nil {
+		return db.S3VersionPage{}, err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return db.S3VersionPage{}, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	limit = min(limit, maxListLimit)
+
+	delimiter := objectDelimiter
+	if req.Recursive {
+		delimiter = ""
+	}
+
+	page, err := client.ListObjectVersionPage(req.Bucket, req.Prefix, delimiter, req.KeyMarker, req.VersionIDMarker, limit)
+	if err != nil {
+		return page, fmt.Errorf("查询 bucket %s 的版本失败: %v", req.Bucket, err)
+	}
+	return page, nil
+}
+
+// RestoreVersion 将历史版本复制为最新版本
+func (s *S3Service) RestoreVersion(operator model.User, req dto.S3VersionReq) (dto.S3VersionRestoreResp, error) {
+	if err := validateObjectKey(req.Key); err != nil {
+		return dto.S3VersionRestoreResp{}, err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return dto.S3VersionRestoreResp{}, err
+	}
+
+	versionID, err := client.RestoreObjectVersion(req.Bucket, req.Key, req.VersionID)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "version.restore", req.Bucket+"/"+req.Key, map[string]any{"from": req.VersionID, "to": versionID}, err)
+	if err != nil {
+		return dto.S3VersionRestoreResp{}, fmt.Errorf("恢复 %s/%s 的版本 %s 失败: %v", req.Bucket, req.Key, req.VersionID, err)
+	}
+	return dto.S3VersionRestoreResp{Key: req.Key, VersionID: versionID}, nil
+}
+
+// DeleteVersion 永久删除 object 的一个版本, 无法恢复
+func (s *S3Service) DeleteVersion(operator model.User, req dto.S3VersionReq) error {
+	if err := validateObjectKey(req.Key); err != nil {
+		return err
+	}
+
+	client, err := s.client(req.Instance)
+	if err != nil {
+		return err
+	}
+
+	err = client.DeleteObjectVersion(req.Bucket, req.Key, req.VersionID)
+	auditservice.NewAuditService().Record(operator, AuditModule, req.Instance, "version.delete", req.Bucket+"/"+req.Key, map[string]any{"version_id": req.VersionID}, err)
+	if err != nil {
+		return fmt.Errorf("删除 %s/%s 的版本 %s 失败: %v", req.Bucket, req.Key, req.VersionID, err)
+	}
+	return nil
+}
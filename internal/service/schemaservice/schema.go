@@ -0,0 +1,112 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-05-11 10:32:17
+ */
+
+package schemaservice
+
+import (
+	"fmt"
+	"myadmin/internal/config"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+)
+
+type SchemaService struct{}
+
+func NewSchemaService() *SchemaService {
+	return &SchemaService{}
+}
+
+// inspector 根据配置文件中的实例名获取 mysql 或 postgres 的表结构读取器
+func (s *SchemaService) inspector(instance string) (db.SchemaInspector, error) {
+	if _, ok := config.GlobalConfig.DB[instance]; !ok {
+		return nil, fmt.Errorf("数据库实例: %s 不存在", instance)
+	}
+
+	client := db.DB(instance)
+	switch c := client.(type) {
+	case *db.MysqlClient:
+		if c == nil || c.Conn() == nil {
+			return nil, fmt.Errorf("数据库实例: %s 连接不可用", instance)
+		}
+	case *db.PgSQLClient:
+		if c == nil || c.Conn() == nil {
+			return nil, fmt.Errorf("数据库实例: %s 连接不可用", instance)
+		}
+	case nil:
+		return nil, fmt.Errorf("数据库实例: %s 连接不可用", instance)
+	}
+	return db.NewSchemaInspector(client)
+}
+
+func (s *SchemaService) Databases(req dto.SchemaInstanceReq) ([]string, error) {
+	inspector, err := s.inspector(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	return inspector.Databases()
+}
+
+func (s *SchemaService) Schemas(req dto.SchemaInstanceReq) ([]string, error) {
+	inspector, err := s.inspector(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	return inspector.Schemas()
+}
+
+func (s *SchemaService) Tables(req dto.SchemaTablesReq) ([]db.SchemaTableSummary, error) {
+	inspector, err := s.inspector(req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	return inspector.Tables(req.Schema)
+}
+
+// Table 表的列, 索引, 约束和建表语句
+func (s *SchemaService) Table(req dto.SchemaTableReq) (db.SchemaTable, error) {
+	inspector, err := s.inspector(req.Instance)
+	if err != nil {
+		return db.SchemaTable{}, err
+	}
+
+	tables, err := inspector.Describe(req.Schema, req.Table)
+	if err != nil {
+		return db.SchemaTable{}, err
+	}
+	if len(tables) == 0 {
+		return db.SchemaTable{}, fmt.Errorf("表 %s.%s 不存在", req.Schema, req.Table)
+	}
+
+	table := tables[0]
+	if table.DDL, err = inspector.DDL(req.Schema, req.Table); err != nil {
+		return db.SchemaTable{}, err
+	}
+	return table, nil
+}
+
+// Diff 比较两边的表结构, 两个实例必须是同一种数据库
+func (s *SchemaService) Diff(req dto.SchemaDiffReq) (db.SchemaDiff, error) {
+	source, err := s.inspector(req.SourceInstance)
+	if err != nil {
+		return db.SchemaDiff{}, err
+	}
+	target, err := s.inspector(req.TargetInstance)
+	if err != nil {
+		return db.SchemaDiff{}, err
+	}
+	if source.Dialect() != target.Dialect() {
+		return db.SchemaDiff{}, fmt.Errorf("不能比较 %s 和 %s 的表结构", source.Dialect(), target.Dialect())
+	}
+
+	sourceTables, err := source.Describe(req.SourceSchema, req.Tables...)
+	if err != nil {
+		return db.SchemaDiff{}, fmt.Errorf("读取 %s.%s 的表结构失败: %w", req.SourceInstance, req.SourceSchema, err)
+	}
+	targetTables, err := target.Describe(req.TargetSchema, req.Tables...)
+	if err != nil {
+		return db.SchemaDiff{}, fmt.Errorf("读取 %s.%s 的表结构失败: %w", req.TargetInstance, req.TargetSchema, err)
+	}
+	return db.DiffSchema(source.Dialect(), req.TargetSchema, sourceTables, targetTables)
+}
@@ -0,0 +1,308 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-28 11:05:37
+ */
+
+package userservice
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"myadmin/internal/config"
+	"myadmin/internal/dao"
+	"myadmin/internal/db"
+	"myadmin/internal/dto"
+	"myadmin/internal/utils/imageutils"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"go.uber.org/zap"
+)
+
+const (
+	avatarDir = "avatar"
+	// 解码前限制图片的像素数, 避免解压炸弹占满内存
+	avatarMaxPixels     = 40_000_000
+	avatarPresignExpire = 10 * time.Minute
+	// 没有配置 image.max_size_mb 时头像的最大大小
+	defaultAvatarMaxSizeMB = 10
+)
+
+// avatarSizes 头像的标准尺寸(正方形边长), 第一个为默认尺寸
+var avatarSizes = []int{256, 64}
+
+// 头像文件名为原图 sha256 的前 16 位, 内容变化时地址也会变化, 浏览器可以长期缓存
+var avatarNameRegexp = regexp.MustCompile(`^[0-9a-f]{16}\.(jpg|png)$`)
+
+// AvatarFile 读取头像的结果, RedirectURL 不为空时重定向到该地址, 否则返回 Body 的内容
+type AvatarFile struct {
+	RedirectURL string
+	Body        io.ReadCloser
+	Size        int64
+	ContentType string
+}
+
+// AvatarMaxSize 上传头像的最大字节数, 没有配置时使用默认值
+func AvatarMaxSize() int64 {
+	mb := int64(config.GlobalConfig.Image.MaxSizeMB)
+	if mb <= 0 {
+		mb = defaultAvatarMaxSizeMB
+	}
+	return mb << 20
+}
+
+// UploadAvatar 校验并裁剪缩放上传的图片, 保存后更新用户的头像地址, 返回新的头像地址
+func (u *UserService) UploadAvatar(uid uint, r io.Reader) (string, error) {
+	maxSize := AvatarMaxSize()
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("读取图片失败: %v", err)
+	}
+	if int64(len(data)) > maxSize {
+		return "", fmt.Errorf("图片大小超过 %dMB 的限制", maxSize>>20)
+	}
+
+	images, contentType, err := resizeAvatar(data)
+	if err != nil {
+		return "", err
+	}
+
+	store, err := newAvatarStore()
+	if err != nil {
+		return "", err
+	}
+
+	dao := dao.NewUserDao()
+	user, err := dao.QueryFirstByID(uid)
+	if err != nil {
+		return "", fmt.Errorf("UserID(%d) 获取用户失败: %v", uid, err)
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:8]) + imageutils.Ext(contentType)
+	for i, size := range avatarSizes {
+		if err := store.put(avatarKey(uid, name, size), contentType, images[i]); err != nil {
+			return "", fmt.Errorf("保存头像失败: %v", err)
+		}
+	}
+
+	url := avatarURL(uid, name)
+	if err := dao.UpdateByID(uid, "avatar_url", url); err != nil {
+		return "", err
+	}
+
+	// 删除旧头像, 删除失败不影响本次上传
+	if oldName, ok := parseAvatarURL(uid, user.AvatarURL); ok && oldName != name {
+		keys := make([]string, 0, len(avatarSizes))
+		for _, size := range avatarSizes {
+			keys = append(keys, avatarKey(uid, oldName, size))
+		}
+		if err := store.delete(keys); err != nil {
+			zap.L().Warn("删除旧头像失败", zap.Uint("uid", uid), zap.String("name", oldName), zap.Error(err))
+		}
+	}
+
+	user.AvatarURL = url
+	return url, u.SetSession(&user)
+}
+
+// Avatar 读取头像, size 为 0 时返回默认尺寸
+func (u *UserService) Avatar(req dto.UserAvatarReq) (AvatarFile, error) {
+	if !avatarNameRegexp.MatchString(req.Name) {
+		return AvatarFile{}, errors.New("头像不存在")
+	}
+
+	size := req.Size
+	if size == 0 {
+		size = avatarSizes[0]
+	}
+	if !slices.Contains(avatarSizes, size) {
+		return AvatarFile{}, fmt.Errorf("头像尺寸只支持 %v", avatarSizes)
+	}
+
+	store, err := newAvatarStore()
+	if err != nil {
+		return AvatarFile{}, err
+	}
+
+	contentType := imageutils.TypePNG
+	if path.Ext(req.Name) == imageutils.Ext(imageutils.TypeJPEG) {
+		contentType = imageutils.TypeJPEG
+	}
+	file, err := store.open(avatarKey(req.UID, req.Name, size), contentType)
+	if err != nil {
+		return AvatarFile{}, fmt.Errorf("读取头像失败: %v", err)
+	}
+	return file, nil
+}
+
+// resizeAvatar 从图片中心裁剪出正方形, 并缩放成每个标准尺寸, 返回的图片和 avatarSizes 一一对应
+func resizeAvatar(data []byte) ([][]byte, string, error) {
+	contentType, err := imageutils.Sniff(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	img, err := imageutils.Decode(data, avatarMaxPixels)
+	if err != nil {
+		return nil, "", err
+	}
+
+	square := imageutils.CropSquare(img)
+	outputType := imageutils.OutputType(contentType)
+	images := make([][]byte, 0, len(avatarSizes))
+	for _, size := range avatarSizes {
+		var buf bytes.Buffer
+		if err := imageutils.Encode(&buf, imageutils.Resize(square, size, size), outputType); err != nil {
+			return nil, "", fmt.Errorf("生成 %dx%d 头像失败: %v", size, size, err)
+		}
+		images = append(images, buf.Bytes())
+	}
+	return images, outputType, nil
+}
+
+// avatarKey 头像在存储中的路径, 如 avatar/12/0123456789abcdef_256.png
+func avatarKey(uid uint, name string, size int) string {
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s/%d/%s_%d%s", avatarDir, uid, strings.TrimSuffix(name, ext), size, ext)
+}
+
+// avatarURL 头像的访问地址, 由 Avatar 接口读取
+func avatarURL(uid uint, name string) string {
+	url := fmt.Sprintf("%s/files/%s/%d/%s", config.GlobalConfig.Server.ApiUrlPrefix, avatarDir, uid, name)
+	if host := config.GlobalConfig.Image.Host; host != "" {
+		url = "//" + host + url
+	}
+	return url
+}
+
+// parseAvatarURL 从头像地址解析出文件名, 不是上传的头像时返回 false
+func parseAvatarURL(uid uint, url string) (string, bool) {
+	name, ok := strings.CutPrefix(url, avatarURL(uid, ""))
+	return name, ok && avatarNameRegexp.MatchString(name)
+}
+
+type avatarStore interface {
+	put(key, contentType string, data []byte) error
+	open(key, contentType string) (AvatarFile, error)
+	delete(keys []string) error
+}
+
+func newAvatarStore() (avatarStore, error) {
+	cfg := config.GlobalConfig.Image
+	switch cfg.Storage {
+	case config.ImageStorageLocal:
+		if cfg.Path == "" {
+			return nil, errors.New("没有配置图片的存储目录")
+		}
+		return localAvatarStore{dir: cfg.Path}, nil
+	case config.ImageStorageS3:
+		if _, ok := config.GlobalConfig.S3[cfg.S3Instance]; !ok {
+			return nil, fmt.Errorf("图片存储使用的 S3 实例 %s 不存在", cfg.S3Instance)
+		}
+		if cfg.S3Bucket == "" {
+			return nil, errors.New("没有配置图片存储使用的 bucket")
+		}
+		return s3AvatarStore{client: db.S3(cfg.S3Instance), bucket: cfg.S3Bucket, presign: cfg.S3Presign}, nil
+	}
+	return nil, fmt.Errorf("不支持的图片存储方式 %s", cfg.Storage)
+}
+
+// localAvatarStore 头像保存在本地目录
+type localAvatarStore struct {
+	dir string
+}
+
+func (s localAvatarStore) put(key, contentType string, data []byte) error {
+	name := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名, 读取时不会读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s localAvatarStore) open(key, contentType string) (AvatarFile, error) {
+	f, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil {
+		return AvatarFile{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return AvatarFile{}, err
+	}
+	return AvatarFile{Body: f, Size: info.Size(), ContentType: contentType}, nil
+}
+
+func (s localAvatarStore) delete(keys []string) error {
+	var errs []error
+	for _, key := range keys {
+		if err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key))); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// s3AvatarStore 头像保存在 s3 bucket, presign 为 true 时访问头像重定向到预签名 URL
+type s3AvatarStore struct {
+	client  *db.S3Ceph
+	bucket  string
+	presign bool
+}
+
+func (s s3AvatarStore) put(key, contentType string, data []byte) error {
+	return s.client.PutObjectStream(s.bucket, key, contentType, bytes.NewReader(data))
+}
+
+func (s s3AvatarStore) open(key, contentType string) (AvatarFile, error) {
+	if s.presign {
+		url, err := s.client.GeneratePresign(s.bucket, key, contentType, avatarPresignExpire)
+		return AvatarFile{RedirectURL: url}, err
+	}
+
+	out, err := s.client.GetObjectStream(s.bucket, key)
+	if err != nil {
+		return AvatarFile{}, err
+	}
+	return AvatarFile{Body: out.Body, Size: aws.Int64Value(out.ContentLength), ContentType: contentType}, nil
+}
+
+func (s s3AvatarStore) delete(keys []string) error {
+	result, err := s.client.DeleteObjects(s.bucket, keys)
+	if err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%s: %s", result.Errors[0].Key, result.Errors[0].Message)
+	}
+	return nil
+}
@@ -65,7 +65,7 @@ func (u *UserService) Register(req dto.UserRegisterReq) error {
 		Email:     req.Email,
 		Gender:    req.Gender,
 		Role:      req.Role,
-		AvatarURL: "https://wpimg.wallstcn.com/f778738c-e4f8-4870-b634-56703b4acafe.gif",
+		AvatarURL: config.GlobalConfig.Image.DefaultAvatar,
 	}
 
 	if err := u.RegisterPreCheck(&user); err != nil {
@@ -0,0 +1,249 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-03-09 10:12:45
+ */
+
+package task
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 任务状态
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// 内存中最多保留的已结束任务数量, 超过后清理最早结束的任务
+const MaxFinishedTasks = 500
+
+// Task 后台执行的长任务, 执行过程按步骤记录日志
+type Task struct {
+	mu sync.RWMutex
+	TaskInfo
+}
+
+// TaskInfo 任务的状态信息
+type TaskInfo struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Module     string      `json:"module"`
+	Instance   string      `json:"instance"`
+	Operator   string      `json:"operator"`
+	Status     string      `json:"status"`
+	Progress   float64     `json:"progress"` // 0 - 100
+	Error      string      `json:"error"`
+	Steps      []*TaskStep `json:"steps"`
+	Result     any         `json:"result"`
+	CreatedAt  time.Time   `json:"created_at"`
+	FinishedAt *time.Time  `json:"finished_at"`
+}
+
+type TaskStep struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error"`
+	Logs       []string   `json:"logs"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// Step 执行一个步骤, fn 返回错误时步骤标记为失败
+func (t *Task) Step(name string, fn func() error) error {
+	step := &TaskStep{Name: name, Status: StatusRunning, StartedAt: time.Now(), Logs: []string{}}
+
+	t.mu.Lock()
+	t.Steps = append(t.Steps, step)
+	t.mu.Unlock()
+
+	err := fn()
+
+	now := time.Now()
+	t.mu.Lock()
+	step.FinishedAt = &now
+	step.Status = StatusSuccess
+	if err != nil {
+		step.Status = StatusFailed
+		step.Error = err.Error()
+	}
+	t.mu.Unlock()
+	return err
+}
+
+// Logf 向当前步骤追加一条日志, 没有步骤时创建一个同名步骤
+func (t *Task) Logf(format string, args ...any) {
+	msg := fmt.Sprintf("%s %s", time.Now().Format(time.DateTime), fmt.Sprintf(format, args...))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.Steps) == 0 {
+		t.Steps = append(t.Steps, &TaskStep{Name: t.Name, Status: StatusRunning, StartedAt: time.Now()})
+	}
+	step := t.Steps[len(t.Steps)-1]
+	step.Logs = append(step.Logs, msg)
+}
+
+// SetProgress 设置任务进度, 单位: 百分比
+func (t *Task) SetProgress(progress float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Progress = progress
+}
+
+// SetResult 设置任务的返回结果
+func (t *Task) SetResult(result any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Result = result
+}
+
+// Snapshot 返回任务当前状态的拷贝, 用于接口返回, 避免序列化时与执行中的任务产生数据竞争
+func (t *Task) Snapshot() TaskInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	snap := t.TaskInfo
+	snap.Steps = make([]*TaskStep, 0, len(t.Steps))
+	for _, step := range t.Steps {
+		s := *step
+		s.Logs = append([]string{}, step.Logs...)
+		snap.Steps = append(snap.Steps, &s)
+	}
+	return snap
+}
+
+func (t *Task) finished() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.FinishedAt != nil
+}
+
+// Manager 任务管理器, 任务只保存在内存中, 服务重启后丢失
+type Manager struct {
+	mu    sync.RWMutex
+	tasks map[string]*Task
+}
+
+var Tasks = NewManager()
+
+func NewManager() *Manager {
+	return &Manager{tasks: make(map[string]*Task)}
+}
+
+// Submit 创建任务并在后台执行
+func (m *Manager) Submit(name, module, instance, operator string, fn func(t *Task) error) *Task {
+	t := &Task{TaskInfo: TaskInfo{
+		ID:        newTaskID(),
+		Name:      name,
+		Module:    module,
+		Instance:  instance,
+		Operator:  operator,
+		Status:    StatusPending,
+		Steps:     []*TaskStep{},
+		CreatedAt: time.Now(),
+	}}
+
+	m.mu.Lock()
+	m.tasks[t.ID] = t
+	m.mu.Unlock()
+	m.cleanup()
+
+	go m.run(t, fn)
+	return t
+}
+
+func (m *Manager) run(t *Task, fn func(t *Task) error) {
+	t.mu.Lock()
+	t.Status = StatusRunning
+	t.mu.Unlock()
+
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务异常退出: %v", r)
+		}
+
+		now := time.Now()
+		t.mu.Lock()
+		t.FinishedAt = &now
+		t.Status = StatusSuccess
+		if err != nil {
+			t.Status = StatusFailed
+			t.Error = err.Error()
+		} else {
+			t.Progress = 100
+		}
+		t.mu.Unlock()
+
+		if err != nil {
+			zap.L().Warn("任务执行失败", zap.String("id", t.ID), zap.String("name", t.Name), zap.String("error", err.Error()))
+		}
+	}()
+
+	err = fn(t)
+}
+
+func (m *Manager) Get(id string) (*Task, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.tasks[id]
+	return t, ok
+}
+
+// List 按创建时间倒序返回任务列表, module 为空时返回全部任务
+func (m *Manager) List(module string) []*Task {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tasks := make([]*Task, 0, len(m.tasks))
+	for _, t := range m.tasks {
+		if module == "" || t.Module == module {
+			tasks = append(tasks, t)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.After(tasks[j].CreatedAt)
+	})
+	return tasks
+}
+
+// cleanup 清理超出数量的已结束任务
+func (m *Manager) cleanup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var finished []*Task
+	for _, t := range m.tasks {
+		if t.finished() {
+			finished = append(finished, t)
+		}
+	}
+	if len(finished) <= MaxFinishedTasks {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(*finished[j].FinishedAt)
+	})
+	for _, t := range finished[:len(finished)-MaxFinishedTasks] {
+		delete(m.tasks, t.ID)
+	}
+}
+
+func newTaskID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,158 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-28 10:12:45
+ */
+
+package imageutils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // 注册 gif 解码器, gif 只取第一帧
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+)
+
+const (
+	TypeJPEG = "image/jpeg"
+	TypePNG  = "image/png"
+	TypeGIF  = "image/gif"
+
+	jpegQuality = 90
+)
+
+// Sniff 根据文件内容(而不是文件名或者请求头)判断图片类型, 只允许 jpeg, png 和 gif
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case TypeJPEG, TypePNG, TypeGIF:
+		return contentType, nil
+	}
+	return "", fmt.Errorf("不支持的文件类型 %s, 只支持 jpeg, png 和 gif 图片", contentType)
+}
+
+// Decode 解码图片, 先读取图片头校验像素数, 防止很小的文件解压出超大的图片
+func Decode(data []byte, maxPixels int) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解析图片失败: %v", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, fmt.Errorf("图片尺寸 %dx%d 不正确", cfg.Width, cfg.Height)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("图片尺寸 %dx%d 超过 %d 像素的限制", cfg.Width, cfg.Height, maxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %v", err)
+	}
+	return img, nil
+}
+
+// CropSquare 从图片中心裁剪出最大的正方形
+func CropSquare(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, image.Pt(x0, y0), draw.Src)
+	return dst
+}
+
+// Resize 缩放图片, 每个目标像素取其覆盖的源像素的加权平均值, 缩小时不会出现锯齿
+func Resize(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if sw == 0 || sh == 0 || width <= 0 || height <= 0 {
+		return dst
+	}
+
+	// 先横向缩放到临时缓冲区, 再纵向缩放
+	tmp := make([]float64, sh*width*4)
+	xWeights := boxWeights(sw, width)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y):]
+		for x, weights := range xWeights {
+			out := tmp[(y*width+x)*4:]
+			for _, w := range weights {
+				for c := 0; c < 4; c++ {
+					out[c] += float64(row[w.index*4+c]) * w.weight
+				}
+			}
+		}
+	}
+
+	for y, weights := range boxWeights(sh, height) {
+		for x := 0; x < width; x++ {
+			var sum [4]float64
+			for _, w := range weights {
+				in := tmp[(w.index*width+x)*4:]
+				for c := 0; c < 4; c++ {
+					sum[c] += in[c] * w.weight
+				}
+			}
+			out := dst.Pix[y*dst.Stride+x*4:]
+			for c := 0; c < 4; c++ {
+				out[c] = uint8(math.Min(255, math.Max(0, math.Round(sum[c]))))
+			}
+		}
+	}
+	return dst
+}
+
+type boxWeight struct {
+	index  int
+	weight float64
+}
+
+// boxWeights 计算每个目标像素覆盖的源像素和权重, 同一个目标像素的权重之和为 1
+func boxWeights(srcLen, dstLen int) [][]boxWeight {
+	scale := float64(srcLen) / float64(dstLen)
+	result := make([][]boxWeight, dstLen)
+	for i := range result {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for j := int(start); j < srcLen && float64(j) < end; j++ {
+			overlap := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if overlap > 0 {
+				result[i] = append(result[i], boxWeight{index: j, weight: overlap / scale})
+			}
+		}
+	}
+	return result
+}
+
+// OutputType 缩放后图片的保存格式, jpeg 保持 jpeg, 其他格式保存为 png 以保留透明通道
+func OutputType(contentType string) string {
+	if contentType == TypeJPEG {
+		return TypeJPEG
+	}
+	return TypePNG
+}
+
+// Ext 图片格式对应的文件扩展名
+func Ext(contentType string) string {
+	switch contentType {
+	case TypeJPEG:
+		return ".jpg"
+	case TypeGIF:
+		return ".gif"
+	}
+	return ".png"
+}
+
+// Encode 按 OutputType 返回的格式编码图片
+func Encode(w io.Writer, img image.Image, contentType string) error {
+	if contentType == TypeJPEG {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return png.Encode(w, img)
+}
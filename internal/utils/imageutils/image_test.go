@@ -0,0 +1,113 @@
+/*
+ * @Author: Liu Sainan
+ * @Date: 2024-04-28 15:20:08
+ */
+
+package imageutils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Sniff(buf.Bytes()); err != nil || got != TypePNG {
+		t.Errorf("Sniff(png) = %q, %v", got, err)
+	}
+	if _, err := Sniff([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>")); err == nil {
+		t.Error("Sniff(svg) should fail")
+	}
+}
+
+func TestDecodeMaxPixels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 100, 100))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(buf.Bytes(), 10000); err != nil {
+		t.Error(err)
+	}
+	if _, err := Decode(buf.Bytes(), 9999); err == nil {
+		t.Error("Decode should reject images over the pixel limit")
+	}
+}
+
+func TestCropSquare(t *testing.T) {
+	// 宽 6 高 2, 中间两列为红色
+	src := image.NewRGBA(image.Rect(10, 10, 16, 12))
+	for y := 10; y < 12; y++ {
+		for x := 10; x < 16; x++ {
+			c := color.RGBA{0, 0, 255, 255}
+			if x == 12 || x == 13 {
+				c = color.RGBA{255, 0, 0, 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+
+	dst := CropSquare(src)
+	if dst.Bounds() != image.Rect(0, 0, 2, 2) {
+		t.Fatalf("bounds = %v", dst.Bounds())
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			if got := dst.RGBAAt(x, y); got != (color.RGBA{255, 0, 0, 255}) {
+				t.Errorf("pixel (%d, %d) = %v", x, y, got)
+			}
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	// 4x4 左半黑右半白, 缩小到 2x2 后左列黑右列白
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			v := uint8(0)
+			if x >= 2 {
+				v = 255
+			}
+			src.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+
+	dst := Resize(src, 2, 2)
+	if got := dst.RGBAAt(0, 1); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("left pixel = %v", got)
+	}
+	if got := dst.RGBAAt(1, 0); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("right pixel = %v", got)
+	}
+
+	// 缩小到 1x1 时取平均值
+	if got := Resize(src, 1, 1).RGBAAt(0, 0); got != (color.RGBA{128, 128, 128, 255}) {
+		t.Errorf("average pixel = %v", got)
+	}
+
+	// 放大时每个像素都有值
+	up := Resize(src, 6, 6)
+	if got := up.RGBAAt(5, 5); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("upscaled pixel = %v", got)
+	}
+}
+
+func TestBoxWeights(t *testing.T) {
+	for _, tc := range [][2]int{{1000, 256}, {3, 7}, {256, 256}, {5, 1}} {
+		for i, weights := range boxWeights(tc[0], tc[1]) {
+			var sum float64
+			for _, w := range weights {
+				sum += w.weight
+			}
+			if sum < 0.999999 || sum > 1.000001 {
+				t.Errorf("boxWeights(%d, %d)[%d] sum = %f", tc[0], tc[1], i, sum)
+			}
+		}
+	}
+}
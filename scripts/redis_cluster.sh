@@ -0,0 +1,56 @@
+#!/usr/bin/env bash
+# @Author: Liu Sainan
+# @Date: 2024-03-10 10:36:52
+
+# 在本机启动一个 3 主 3 从的 redis 集群, 用于测试集群拓扑管理功能
+# 用法: ./scripts/redis_cluster.sh start|stop
+# 启动后执行: MYADMIN_TEST_REDIS_CLUSTER=127.0.0.1:30001 go test ./internal/db/ -run TestClusterMigrateSlot
+
+set -e
+
+BASE_PORT=${BASE_PORT:-30000}
+NODES=${NODES:-6}
+REPLICAS=${REPLICAS:-1}
+DATA_DIR=${DATA_DIR:-/tmp/myadmin-redis-cluster}
+
+start() {
+    mkdir -p "${DATA_DIR}"
+    local addrs=""
+    for i in $(seq 1 "${NODES}"); do
+        local port=$((BASE_PORT + i))
+        redis-server --port "${port}" \
+            --cluster-enabled yes \
+            --cluster-config-file "nodes-${port}.conf" \
+            --cluster-node-timeout 5000 \
+            --appendonly no \
+            --save "" \
+            --dir "${DATA_DIR}" \
+            --logfile "${port}.log" \
+            --daemonize yes
+        addrs="${addrs} 127.0.0.1:${port}"
+    done
+
+    sleep 1
+    # shellcheck disable=SC2086
+    redis-cli --cluster create ${addrs} --cluster-replicas "${REPLICAS}" --cluster-yes
+}
+
+stop() {
+    for i in $(seq 1 "${NODES}"); do
+        redis-cli -p $((BASE_PORT + i)) shutdown nosave >/dev/null 2>&1 || true
+    done
+    rm -rf "${DATA_DIR}"
+}
+
+case "$1" in
+start)
+    start
+    ;;
+stop)
+    stop
+    ;;
+*)
+    echo "用法: $0 start|stop"
+    exit 1
+    ;;
+esac